@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	apierror "you2api/apierror"
+	audit "you2api/audit"
+	config "you2api/config"
+	logger "you2api/logger"
+)
+
+// handleAdmin 处理 /admin/ 下的管理接口，需要通过 ADMIN_KEY 或 ADMIN_READONLY_KEY 认证，见 admin_roles.go。
+func handleAdmin(w http.ResponseWriter, r *http.Request) {
+	w, done, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+
+	path := strings.TrimPrefix(r.URL.Path, "/admin")
+	switch {
+	case path == "/audit" && r.Method == http.MethodGet:
+		handleAuditList(w)
+	case strings.HasPrefix(path, "/audit/") && strings.HasSuffix(path, "/replay") && r.Method == http.MethodPost:
+		handleAuditReplay(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/audit/"), "/replay"))
+	case strings.HasPrefix(path, "/audit/") && r.Method == http.MethodGet:
+		handleAuditGet(w, strings.TrimPrefix(path, "/audit/"))
+	case path == "/output-stats" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, outputStats.snapshot())
+	case path == "/summary" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, dailySummary.snapshot(time.Now()))
+	case path == "/streams" || strings.HasPrefix(path, "/streams/"):
+		handleStreams(w, r, strings.TrimPrefix(path, "/streams"))
+	case path == "/key-aliases" || strings.HasPrefix(path, "/key-aliases/"):
+		handleKeyAliases(w, r, strings.TrimPrefix(path, "/key-aliases"))
+	case path == "/pool" && r.Method == http.MethodGet:
+		handlePoolStatus(w, r)
+	case path == "/tokens/import" && r.Method == http.MethodPost:
+		handleTokenImport(w, r)
+	case path == "/model-discovery" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, modelDiscovery.status())
+	case path == "/schema-drift" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, schemaDrift.snapshot())
+	case path == "/models/aliases" || strings.HasPrefix(path, "/models/aliases/"):
+		handleModelAliases(w, r, strings.TrimPrefix(path, "/models/aliases"))
+	case path == "/hidden-models" || strings.HasPrefix(path, "/hidden-models/"):
+		handleHiddenModels(w, r, strings.TrimPrefix(path, "/hidden-models"))
+	case path == "/features" || strings.HasPrefix(path, "/features/"):
+		handleFeatures(w, r, strings.TrimPrefix(path, "/features"))
+	case path == "/config" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, redactedConfig(currentConfig())) // 生效的配置，密钥类字段不会输出
+	case path == "/actions" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, adminActions.snapshot())
+	case path == "/jobs" || strings.HasPrefix(path, "/jobs/"):
+		handleJobs(w, r, strings.TrimPrefix(path, "/jobs"))
+	case path == "/state/export" && r.Method == http.MethodGet:
+		handleStateExport(w, r)
+	case path == "/state/import" && r.Method == http.MethodPost:
+		handleStateImport(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// redactedConfig 返回 /admin/config 输出的配置。webhook 与代理地址常在路径或 userinfo 中带有凭据
+// （Slack/Discord 的 token、user:pass@），只读管理密钥也能查看该接口，因此只保留协议与主机。
+func redactedConfig(c *config.Config) config.Config {
+	view := *c
+	view.Proxy.ProxyURL = redactURL(c.Proxy.ProxyURL)
+	view.AnomalyWebhookURL = redactURL(c.AnomalyWebhookURL)
+	view.DailySummaryWebhookURL = redactURL(c.DailySummaryWebhookURL)
+	view.SchemaDriftWebhookURL = redactURL(c.SchemaDriftWebhookURL)
+	return view
+}
+
+// redactURL 只保留地址的协议与主机，无法解析的地址整体隐藏。
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "[redacted]"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// lookupAuditEntry 从审计存储中查找记录，失败时直接写入错误响应。
+func lookupAuditEntry(w http.ResponseWriter, id string) (*audit.Entry, bool) {
+	store := getAuditStore()
+	if store == nil {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeAuditDisabled, "Audit log is disabled")
+		return nil, false
+	}
+	entry, ok := store.Get(id)
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, "Audit entry not found")
+		return nil, false
+	}
+	return entry, true
+}
+
+func handleAuditList(w http.ResponseWriter) {
+	store := getAuditStore()
+	if store == nil {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeAuditDisabled, "Audit log is disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, store.Recent())
+}
+
+func handleAuditGet(w http.ResponseWriter, id string) {
+	if entry, ok := lookupAuditEntry(w, id); ok {
+		writeJSON(w, http.StatusOK, entry)
+	}
+}
+
+// handleAuditReplay 重新执行审计记录，并返回与原始回复的差异。
+// 审计记录不保存 DS token，需在请求体中提供 {"ds_token": "..."}。
+func handleAuditReplay(w http.ResponseWriter, r *http.Request, id string) {
+	entry, ok := lookupAuditEntry(w, id)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		DSToken string `json:"ds_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.DSToken == "" {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Request body must contain ds_token")
+		return
+	}
+
+	replayed, err := Replay(r.Context(), entry, body.DSToken)
+	if err != nil {
+		apierror.Write(w, http.StatusBadGateway, apierror.CodeUpstreamError, logger.ScrubError(err, body.DSToken))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":        entry.ID,
+		"original":  entry.Response,
+		"replayed":  replayed,
+		"identical": replayed == entry.Response,
+		"diff":      audit.Diff(entry.Response, replayed),
+	})
+}
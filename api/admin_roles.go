@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	apierror "you2api/apierror"
+	logger "you2api/logger"
+
+	"go.uber.org/zap"
+)
+
+// adminRole 是管理密钥的权限等级。
+//   - 只读（ADMIN_READONLY_KEY）：查看指标、运行时信息、进行中的流等
+//   - 操作员（ADMIN_KEY）：此外还可以修改配置、管理账号与别名、取消请求、查看审计内容与 pprof
+type adminRole int
+
+const (
+	roleNone adminRole = iota
+	roleReadOnly
+	roleOperator
+)
+
+func (r adminRole) String() string {
+	switch r {
+	case roleReadOnly:
+		return "read_only"
+	case roleOperator:
+		return "operator"
+	default:
+		return "none"
+	}
+}
+
+// operatorOnlyPrefixes 是只读请求也需要操作员权限的路径：包含对话内容或可能影响性能的接口。
+var operatorOnlyPrefixes = []string{"/admin/audit", "/admin/jobs", "/admin/state", "/debug/pprof", "/debug/goroutines"}
+
+// requiredRole 返回访问该请求所需的最低权限：修改类请求总是需要操作员权限。
+func requiredRole(r *http.Request) adminRole {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return roleOperator
+	}
+	for _, prefix := range operatorOnlyPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return roleOperator
+		}
+	}
+	return roleReadOnly
+}
+
+// adminRoleOf 根据 Authorization 头中的密钥确定权限，ADMIN_KEY 与 ADMIN_READONLY_KEY 都可以是逗号分隔的多个密钥。
+func adminRoleOf(provided string) adminRole {
+	conf := currentConfig()
+	if matchesAnyKey(provided, conf.AdminKey) {
+		return roleOperator
+	}
+	if matchesAnyKey(provided, conf.AdminReadOnlyKey) {
+		return roleReadOnly
+	}
+	return roleNone
+}
+
+func matchesAnyKey(provided, keys string) bool {
+	matched := false
+	for _, key := range strings.Split(keys, ",") {
+		key = strings.TrimSpace(key)
+		if key != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// requireAdmin 校验管理密钥的权限，失败时写入错误响应并返回 false。
+// 修改类请求（包括被拒绝的请求）会记录一条管理操作审计，返回的 done 必须在请求处理结束后调用。
+func requireAdmin(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func(), bool) {
+	conf := currentConfig()
+	if conf.AdminKey == "" && conf.AdminReadOnlyKey == "" {
+		http.NotFound(w, r) // 未配置管理密钥时不暴露管理接口
+		return w, func() {}, false
+	}
+
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	role := adminRoleOf(provided)
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	done := func() {}
+	if requiredRole(r) == roleOperator {
+		done = func() { adminActions.record(r, role, keyID(provided), rec.status) }
+	}
+
+	switch {
+	case role == roleNone:
+		apierror.Write(rec, http.StatusUnauthorized, apierror.CodeInvalidAdminKey, "Invalid admin key")
+		done()
+		return w, func() {}, false
+	case role < requiredRole(r):
+		apierror.Write(rec, http.StatusForbidden, apierror.CodeInsufficientRole, "This admin key is read-only")
+		done()
+		return w, func() {}, false
+	}
+	return rec, done, true
+}
+
+// statusRecorder 记录写出的状态码。
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// adminAction 是一条管理操作审计记录。KeyID 是管理密钥的哈希前缀，不记录密钥本身。
+type adminAction struct {
+	Time     time.Time `json:"time"`
+	Role     string    `json:"role"`
+	KeyID    string    `json:"key_id"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	ClientIP string    `json:"client_ip"`
+}
+
+// maxAdminActions 是内存中保留的管理操作记录数。
+const maxAdminActions = 500
+
+// adminActionLog 保存最近的管理操作，同时写入结构化日志以便长期保存。
+type adminActionLog struct {
+	mu      sync.Mutex
+	actions []adminAction
+}
+
+var adminActions = &adminActionLog{}
+
+func (l *adminActionLog) record(r *http.Request, role adminRole, keyID string, status int) {
+	action := adminAction{
+		Time:     time.Now(),
+		Role:     role.String(),
+		KeyID:    keyID,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Status:   status,
+		ClientIP: clientIP(r),
+	}
+	logger.L().Info("管理操作",
+		zap.String("role", action.Role),
+		zap.String("key_id", action.KeyID),
+		zap.String("method", action.Method),
+		zap.String("path", action.Path),
+		zap.Int("status", action.Status),
+		zap.String("client_ip", action.ClientIP))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.actions = append(l.actions, action)
+	if len(l.actions) > maxAdminActions {
+		l.actions = l.actions[len(l.actions)-maxAdminActions:]
+	}
+}
+
+// snapshot 按时间倒序返回管理操作记录。
+func (l *adminActionLog) snapshot() []adminAction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make([]adminAction, len(l.actions))
+	for i, action := range l.actions {
+		result[len(l.actions)-1-i] = action
+	}
+	return result
+}
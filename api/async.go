@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	apierror "you2api/apierror"
+	jobqueue "you2api/jobqueue"
+	logger "you2api/logger"
+	sealed "you2api/sealed"
+	signing "you2api/signing"
+)
+
+// 异步补全模式：请求体中 async 为 true 时立即返回 202 与补全 ID，
+// 在后台完成生成后把结果签名并 POST 到 callback_url，适合无法保持长连接的 serverless 客户端。
+// 回调使用与响应签名相同的 X-U2API-Signature 头，接收方可以用 signing 包校验。
+// 任务保存在持久化队列中（见 jobs.go），回调投递失败时由队列负责重试。
+// callback_url 不能指向回环、私有、链路本地或未指定地址（CALLBACK_ALLOWED_HOSTS 中的主机除外），
+// 投递时在建立连接前再次检查实际连接的地址，避免 DNS 重绑定绕过校验。
+
+// asyncCompletionJob 是异步补全在任务队列中的类型。
+const asyncCompletionJob = "async_completion"
+
+// asyncAccepted 是异步请求被接受时立即返回的响应。
+type asyncAccepted struct {
+	ID          string `json:"id"`
+	Object      string `json:"object"`
+	Status      string `json:"status"`
+	Created     int64  `json:"created"`
+	CallbackURL string `json:"callback_url"`
+}
+
+// asyncCallback 是 POST 到 callback_url 的回调内容。
+type asyncCallback struct {
+	ID         string          `json:"id"`
+	Object     string          `json:"object"`
+	Status     string          `json:"status"` // completed / failed
+	StatusCode int             `json:"status_code"`
+	Response   json.RawMessage `json:"response,omitempty"` // 与同步请求相同的响应体
+	Error      string          `json:"error,omitempty"`
+}
+
+// asyncJob 是异步补全任务的 payload。请求头中包含客户端凭据，因此加密后保存，见 sealJobHeader。
+type asyncJob struct {
+	ID          string `json:"id"`
+	CallbackURL string `json:"callback_url"`
+	Path        string `json:"path"`
+	KeyID       string `json:"key_id"`
+	// SealedHeader 是加密后的请求头
+	SealedHeader []byte          `json:"sealed_header"`
+	RemoteAddr   string          `json:"remote_addr"`
+	Body         json.RawMessage `json:"body"` // 去掉 async 字段后的同步请求体
+	// Callback 是生成完成后的回调内容，投递失败重试时不再重新生成
+	Callback json.RawMessage `json:"callback,omitempty"`
+}
+
+// errCallbackAddress 表示回调地址指向不允许的内网或本机地址。
+var errCallbackAddress = errors.New("callback_url must not point to a loopback, private, link-local or unspecified address")
+
+// validateCallbackURL 检查回调地址是否为绝对的 http(s) URL，且主机不解析到内网或本机地址。
+func validateCallbackURL(ctx context.Context, raw string) error {
+	if raw == "" {
+		return errors.New("callback_url is required when async is true")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("callback_url must be an absolute http(s) URL")
+	}
+	if callbackHostAllowed(u.Hostname()) {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errors.New("callback_url host cannot be resolved")
+	}
+	for _, addr := range addrs {
+		if blockedCallbackAddr(addr) {
+			return errCallbackAddress
+		}
+	}
+	return nil
+}
+
+// callbackHostAllowed 判断主机是否在 CALLBACK_ALLOWED_HOSTS 中。
+func callbackHostAllowed(host string) bool {
+	for _, allowed := range strings.Split(currentConfig().CallbackAllowedHosts, ",") {
+		if allowed = strings.TrimSpace(allowed); allowed != "" && strings.EqualFold(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedCallbackPrefixes 是 netip.Addr 的方法没有覆盖、但在云厂商 VPC 中常常路由到内部服务的地址段。
+var blockedCallbackPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // “本网络”，部分系统把 0.x.x.x 当作本机
+	netip.MustParsePrefix("100.64.0.0/10"),  // 运营商级 NAT（CGNAT），也用于 VPC 内部地址
+	netip.MustParsePrefix("198.18.0.0/15"),  // 网络设备测试地址
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64，可以借此访问任意 IPv4 地址
+	netip.MustParsePrefix("64:ff9b:1::/48"), // 本地使用的 NAT64 前缀
+}
+
+// blockedCallbackAddr 判断回调是否不能连接该地址：回环、私有、链路本地（包括云厂商的元数据地址）、未指定、组播地址
+// 以及 blockedCallbackPrefixes 中的地址段。
+func blockedCallbackAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedCallbackPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// callbackDialControl 在建立连接前检查实际连接的地址，DNS 在校验之后改为解析到内网地址时拒绝连接。
+func callbackDialControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if blockedCallbackAddr(addrPort.Addr()) {
+		return errCallbackAddress
+	}
+	return nil
+}
+
+// sealJobHeader 加密请求头。密钥由 RESPONSE_SIGNING_KEY 派生（异步补全要求配置签名密钥），
+// 队列数据库泄露时不会暴露客户端的 API key 或 DS token；更换签名密钥后，排队中的任务无法再执行。
+func sealJobHeader(header http.Header) ([]byte, error) {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	return sealed.Seal(jobHeaderKey(), data)
+}
+
+// openJobHeader 解密 sealJobHeader 加密的请求头。
+func openJobHeader(data []byte) (http.Header, error) {
+	plain, err := sealed.Open(jobHeaderKey(), data)
+	if err != nil {
+		return nil, err
+	}
+	var header http.Header
+	if err := json.Unmarshal(plain, &header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// jobHeaderKey 返回加密任务请求头的 AES-256 密钥。
+func jobHeaderKey() []byte {
+	sum := sha256.Sum256([]byte("u2api async job header\x00" + currentConfig().SigningKey))
+	return sum[:]
+}
+
+// handleAsyncCompletion 校验异步请求并放入任务队列，立即返回补全 ID。
+// 后台任务以同步请求的形式重新进入 Handler，因此模型解析、审计、签名等行为与同步请求完全一致。
+func handleAsyncCompletion(w http.ResponseWriter, r *http.Request, body []byte, openAIReq OpenAIRequest, apiKey string) {
+	if openAIReq.Stream {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: async cannot be combined with stream")
+		return
+	}
+	if err := validateCallbackURL(r.Context(), openAIReq.CallbackURL); err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: %s", err)
+		return
+	}
+	if getSigner() == nil {
+		clientError(w, r, http.StatusNotImplemented, apierror.CodeAsyncNotConfigured, "Async completions require RESPONSE_SIGNING_KEY to sign callbacks")
+		return
+	}
+
+	syncBody, err := stripAsyncFields(body)
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	id := requestedResponseID(r, apiKey)
+	if id == "" {
+		id = newCompletionID()
+	}
+
+	header := r.Header.Clone()
+	header.Set(requestIDHeader, id)
+	header.Del(idempotencyKeyHeader)
+	sealedHeader, err := sealJobHeader(header)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternalError, err.Error())
+		return
+	}
+	payload, _ := json.Marshal(asyncJob{
+		ID:           id,
+		CallbackURL:  openAIReq.CallbackURL,
+		Path:         r.URL.Path,
+		KeyID:        keyID(apiKey),
+		SealedHeader: sealedHeader,
+		RemoteAddr:   r.RemoteAddr,
+		Body:         syncBody,
+	})
+
+	queue, err := getJobQueue()
+	if err != nil {
+		clientError(w, r, http.StatusServiceUnavailable, apierror.CodeJobQueueUnavailable, "Job queue unavailable: %s", err)
+		return
+	}
+	if _, err := queue.Enqueue(id, asyncCompletionJob, payload, time.Now()); err != nil {
+		apierror.Write(w, http.StatusConflict, apierror.CodeDuplicateRequestID, err.Error())
+		return
+	}
+	jobWorkers.notify()
+
+	w.Header().Set(requestIDHeader, id)
+	writeJSON(w, http.StatusAccepted, asyncAccepted{
+		ID:          id,
+		Object:      "chat.completion.async",
+		Status:      "queued",
+		Created:     time.Now().Unix(),
+		CallbackURL: openAIReq.CallbackURL,
+	})
+}
+
+// stripAsyncFields 去掉请求体中的 async 与 callback_url，得到等价的同步请求体。
+func stripAsyncFields(body []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "async")
+	delete(fields, "callback_url")
+	return json.Marshal(fields)
+}
+
+// runAsyncCompletionJob 执行异步补全并投递回调。生成结果先写回队列，投递失败重试时只重新投递。
+// 投递成功后回调内容作为任务结果保存，保留期内可以通过 /admin/jobs/{id} 查询。
+func runAsyncCompletionJob(ctx context.Context, job *jobqueue.Job) (json.RawMessage, error) {
+	var aj asyncJob
+	if err := json.Unmarshal(job.Payload, &aj); err != nil {
+		return nil, fmt.Errorf("decode async job: %w", err)
+	}
+	header, err := openJobHeader(aj.SealedHeader)
+	if err != nil {
+		return nil, fmt.Errorf("open async job header: %w", err)
+	}
+
+	if aj.Callback == nil {
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, aj.Path, bytes.NewReader(aj.Body))
+		if err != nil {
+			return nil, err
+		}
+		r.Header = header
+		r.RemoteAddr = aj.RemoteAddr
+
+		rec := newBufferedResponseWriter()
+		Handler(rec, r)
+		if ctx.Err() != nil {
+			return nil, ctx.Err() // 进程退出时中断，重启后重新生成
+		}
+
+		callback := asyncCallback{
+			ID:         aj.ID,
+			Object:     "chat.completion.callback",
+			Status:     "completed",
+			StatusCode: rec.status,
+		}
+		if rec.status >= http.StatusBadRequest {
+			callback.Status = "failed"
+		}
+		if json.Valid(rec.buf.Bytes()) {
+			callback.Response = rec.buf.Bytes()
+		} else {
+			callback.Error = strings.TrimSpace(rec.buf.String())
+		}
+		var failure apierror.Response
+		if callback.Status == "failed" && json.Unmarshal(rec.buf.Bytes(), &failure) == nil {
+			callback.Error = failure.Error.Message
+		}
+		aj.Callback, _ = json.Marshal(callback)
+		if payload, err := json.Marshal(aj); err == nil {
+			if queue, err := getJobQueue(); err == nil {
+				queue.Checkpoint(job.ID, payload, time.Now())
+			}
+		}
+	}
+
+	if err := deliverCallback(ctx, aj.CallbackURL, aj.Callback); err != nil {
+		apiKey := strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+		return nil, errors.New(logger.ScrubError(err, apiKey))
+	}
+	return aj.Callback, nil
+}
+
+// deliverCallback 对回调内容签名并 POST 到回调地址。回调不跟随重定向，3xx 视为投递失败。
+func deliverCallback(ctx context.Context, callbackURL string, payload []byte) error {
+	signer := getSigner()
+	if signer == nil {
+		return errors.New("RESPONSE_SIGNING_KEY is not configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signing.Header, signer.Sign(time.Now().Unix(), digest[:]))
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !callbackHostAllowed(req.URL.Hostname()) {
+		dialer.Control = callbackDialControl
+	}
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, DisableKeepAlives: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// bufferedResponseWriter 在内存中缓存响应，供后台任务读取状态码与响应体。
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+	wrote  bool
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+func (bw *bufferedResponseWriter) Header() http.Header { return bw.header }
+
+func (bw *bufferedResponseWriter) WriteHeader(status int) {
+	if bw.wrote {
+		return
+	}
+	bw.status = status
+	bw.wrote = true
+}
+
+func (bw *bufferedResponseWriter) Write(p []byte) (int, error) {
+	bw.wrote = true
+	return bw.buf.Write(p)
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jobqueue "you2api/jobqueue"
+	signing "you2api/signing"
+)
+
+// withTestSigner 在测试期间启用 HMAC 签名，返回签名密钥。
+func withTestSigner(t *testing.T) []byte {
+	t.Helper()
+	const key = "async-test-signing-key"
+	s, err := signing.NewSigner("hmac-sha256", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signerOnce.Do(func() {})
+	prev := signer
+	signer = s
+	t.Cleanup(func() { signer = prev })
+	return []byte(key)
+}
+
+// withCallbackAllowedHosts 在测试期间设置 CALLBACK_ALLOWED_HOSTS。
+func withCallbackAllowedHosts(t *testing.T, hosts string) {
+	t.Helper()
+	prev := currentConfig()
+	conf := *prev
+	conf.CallbackAllowedHosts = hosts
+	setConfig(&conf)
+	t.Cleanup(func() { setConfig(prev) })
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	ctx := context.Background()
+	for _, ok := range []string{"https://203.0.113.10/hook", "http://[2001:db8::1]:8080/cb", "http://100.128.0.1/cb", "http://198.20.0.1/cb"} {
+		if err := validateCallbackURL(ctx, ok); err != nil {
+			t.Errorf("validateCallbackURL(%q) = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "/relative", "ftp://example.com", "https://"} {
+		if err := validateCallbackURL(ctx, bad); err == nil {
+			t.Errorf("validateCallbackURL(%q) accepted", bad)
+		}
+	}
+	for _, internal := range []string{
+		"http://localhost:8080/cb",
+		"http://127.0.0.1/admin/config",
+		"http://10.0.0.5/cb",
+		"http://192.168.1.1/cb",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://0.0.0.0:8080/cb",
+		"http://0.1.2.3/cb",
+		"http://100.64.0.1/cb",
+		"http://100.127.255.254/cb",
+		"http://198.18.0.1/cb",
+		"http://198.19.255.1/cb",
+		"http://[::1]/cb",
+		"http://[::ffff:127.0.0.1]/cb",
+		"http://[::ffff:100.64.0.1]/cb",
+		"http://[64:ff9b::a9fe:a9fe]/cb",
+		"http://[64:ff9b:1::a00:5]/cb",
+	} {
+		if err := validateCallbackURL(ctx, internal); !errors.Is(err, errCallbackAddress) {
+			t.Errorf("validateCallbackURL(%q) = %v, want errCallbackAddress", internal, err)
+		}
+	}
+
+	// CALLBACK_ALLOWED_HOSTS 中的主机可以使用内网地址
+	withCallbackAllowedHosts(t, "localhost, 10.0.0.5")
+	for _, ok := range []string{"http://localhost:8080/cb", "http://10.0.0.5/cb"} {
+		if err := validateCallbackURL(ctx, ok); err != nil {
+			t.Errorf("allowed host: validateCallbackURL(%q) = %v", ok, err)
+		}
+	}
+}
+
+func TestDeliverCallbackRejectsInternalAddress(t *testing.T) {
+	withTestSigner(t)
+	called := false
+	hook := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer hook.Close()
+
+	// 即使校验之后 DNS 改为解析到本机地址，投递时也不会连接
+	if err := deliverCallback(context.Background(), hook.URL, []byte(`{}`)); !errors.Is(err, errCallbackAddress) {
+		t.Errorf("deliverCallback = %v, want errCallbackAddress", err)
+	}
+	if called {
+		t.Error("callback reached a loopback address")
+	}
+}
+
+func TestAsyncRequestValidation(t *testing.T) {
+	withMockUpstream(t, "echo")
+	tests := []struct {
+		name   string
+		body   string
+		signer bool
+		want   int
+	}{
+		{"stream", `{"model":"gpt-4o","async":true,"stream":true,"callback_url":"https://203.0.113.10/cb","messages":[{"role":"user","content":"hi"}]}`, true, http.StatusBadRequest},
+		{"missing callback", `{"model":"gpt-4o","async":true,"messages":[{"role":"user","content":"hi"}]}`, true, http.StatusBadRequest},
+		{"no signing key", `{"model":"gpt-4o","async":true,"callback_url":"https://203.0.113.10/cb","messages":[{"role":"user","content":"hi"}]}`, false, http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.signer {
+				withTestSigner(t)
+			} else {
+				signerOnce.Do(func() {})
+				prev := signer
+				signer = nil
+				t.Cleanup(func() { signer = prev })
+			}
+			if rec := postChat(t, tt.body); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAsyncCompletionCallback(t *testing.T) {
+	withMockUpstream(t, "echo")
+	secret := withTestSigner(t)
+
+	received := make(chan []byte, 1)
+	var signature string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(signing.Header)
+		received <- body
+	}))
+	defer hook.Close()
+	withCallbackAllowedHosts(t, "127.0.0.1")
+
+	rec := postChat(t, `{"model":"gpt-4o","async":true,"callback_url":"`+hook.URL+`","messages":[{"role":"user","content":"later"}]}`, requestIDHeader, "async-test-1")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var accepted asyncAccepted
+	json.Unmarshal(rec.Body.Bytes(), &accepted)
+	if accepted.ID != "async-test-1" || accepted.Status != "queued" {
+		t.Errorf("accepted = %+v", accepted)
+	}
+
+	// 测试中不启动后台 worker，直接执行队列中的任务
+	queue, err := getJobQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, ok := queue.Get("async-test-1")
+	if !ok {
+		t.Fatal("job not enqueued")
+	}
+	if bytes.Contains(job.Payload, []byte(testDSToken)) {
+		t.Error("job payload stores the client credential in plain text")
+	}
+	result, err := runAsyncCompletionJob(context.Background(), job)
+	if err != nil {
+		t.Fatalf("run job: %v", err)
+	}
+
+	body := <-received
+	if _, err := signing.VerifyHMAC(signature, body, secret); err != nil {
+		t.Errorf("callback signature invalid: %v", err)
+	}
+	var callback asyncCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		t.Fatal(err)
+	}
+	var resp OpenAIResponse
+	json.Unmarshal(callback.Response, &resp)
+	if callback.ID != "async-test-1" || callback.Status != "completed" || len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "later" {
+		t.Errorf("callback = %+v, response = %+v", callback, resp)
+	}
+
+	// 完成后的结果保留在队列中，可以通过管理接口查询
+	queue.Complete(job.ID, result, time.Now())
+	withAdminKeys(t, "admin-secret", "")
+	req := httptest.NewRequest(http.MethodGet, "/admin/jobs/async-test-1", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rec = httptest.NewRecorder()
+	Handler(rec, req)
+	var summary jobSummary
+	json.Unmarshal(rec.Body.Bytes(), &summary)
+	if rec.Code != http.StatusOK || summary.State != jobqueue.Done || !bytes.Equal(summary.Result, body) {
+		t.Errorf("GET /admin/jobs/async-test-1 = %d %s, want done with the callback as result", rec.Code, rec.Body)
+	}
+}
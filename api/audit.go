@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	audit "you2api/audit"
+	logger "you2api/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	auditOnce  sync.Once
+	auditStore *audit.Store
+)
+
+// getAuditStore 返回审计存储；未启用审计时返回 nil。
+func getAuditStore() *audit.Store {
+	auditOnce.Do(func() {
+		conf := currentConfig().Audit
+		if !conf.EnableAudit {
+			return
+		}
+		store, err := audit.NewStore(conf.AuditSize, conf.AuditFile)
+		if err != nil {
+			log.Printf("初始化审计日志失败: %v", err)
+			return
+		}
+		auditStore = store
+	})
+	return auditStore
+}
+
+// closeAuditStore 关闭审计文件，在进程退出前调用。
+func closeAuditStore() error {
+	auditOnce.Do(func() {}) // 尚未初始化时不再初始化
+	if auditStore == nil {
+		return nil
+	}
+	return auditStore.Close()
+}
+
+// newAuditEntry 根据 OpenAI 请求创建审计记录（尚未写入存储）。
+func newAuditEntry(openAIReq OpenAIRequest) *audit.Entry {
+	messages := make([]audit.Message, 0, len(openAIReq.Messages))
+	for _, msg := range openAIReq.Messages {
+		messages = append(messages, audit.Message{Role: msg.Role, Content: msg.Content})
+	}
+	return &audit.Entry{
+		ID:       uuid.NewString(),
+		Time:     time.Now(),
+		Model:    openAIReq.Model,
+		Stream:   openAIReq.Stream,
+		Messages: messages,
+	}
+}
+
+// recordAudit 补全审计记录的结果并写入存储。
+func recordAudit(entry *audit.Entry, content string, err error) {
+	store := getAuditStore()
+	if store == nil {
+		return
+	}
+	entry.Response = content
+	entry.DurationMS = time.Since(entry.Time).Milliseconds()
+	if err != nil {
+		entry.Error = logger.ScrubError(err)
+	}
+	if err := store.Add(entry); err != nil {
+		log.Printf("写入审计日志失败: %v", err)
+	}
+}
+
+// Replay 使用相同的模型与消息重新执行一条审计记录，返回新的回复内容。
+func Replay(ctx context.Context, entry *audit.Entry, dsToken string) (string, error) {
+	openAIReq := OpenAIRequest{Model: entry.Model}
+	for _, msg := range entry.Messages {
+		openAIReq.Messages = append(openAIReq.Messages, Message{Role: msg.Role, Content: msg.Content})
+	}
+
+	youReq, err := buildYouRequest(ctx, openAIReq, mapModelName(openAIReq.Model), dsToken)
+	if err != nil {
+		return "", err
+	}
+	result, err := fetchCompletion(youReq)
+	return result.Content, err
+}
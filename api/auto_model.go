@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	config "you2api/config"
+)
+
+// autoModelName 是按请求内容自动选择上游模型的虚拟模型名称。
+const autoModelName = "auto"
+
+// autoModelHeader 在响应中报告 auto 实际选择的模型。
+const autoModelHeader = "X-U2API-Auto-Model"
+
+// auto 模型的选择原因。
+const (
+	autoReasonReasoning  = "reasoning"
+	autoReasonCode       = "code"
+	autoReasonLongPrompt = "long_prompt"
+	autoReasonDefault    = "default"
+)
+
+type autoModelKey struct{}
+
+// chooseAutoModel 按以下顺序选择模型：推理提示词、代码、长提示词、默认模型。
+// 选中的模型最近调用失败或受订阅等级限制时，依次尝试 AUTO_MODEL_FALLBACKS 中的候选模型。
+func chooseAutoModel(conf config.AutoModelConfig, messages []Message) *AutoModelDecision {
+	prompt := lastUserMessage(messages)
+	decision := &AutoModelDecision{
+		Model:        conf.DefaultModel,
+		Reason:       autoReasonDefault,
+		PromptTokens: countMessagesTokens(mapModelName(conf.DefaultModel), messages),
+	}
+	switch {
+	case hasReasoningMarker(prompt, conf.ReasoningMarkers):
+		decision.Model, decision.Reason = conf.ReasoningModel, autoReasonReasoning
+	case looksLikeCode(prompt):
+		decision.Model, decision.Reason = conf.CodeModel, autoReasonCode
+	case conf.LongPromptTokens > 0 && decision.PromptTokens >= conf.LongPromptTokens:
+		decision.Model, decision.Reason = conf.LongModel, autoReasonLongPrompt
+	}
+
+	if modelHealthy(decision.Model) {
+		return decision
+	}
+	for _, candidate := range strings.Split(conf.Fallbacks, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate != "" && candidate != decision.Model && modelHealthy(candidate) {
+			decision.FallbackFrom, decision.Model = decision.Model, candidate
+			break
+		}
+	}
+	return decision
+}
+
+// modelHealthy 判断模型是否存在且最近没有失败记录。
+func modelHealthy(model string) bool {
+	youModel, ok := getModelMap()[model]
+	if !ok {
+		return false
+	}
+	status := modelStatus.get(youModel).Status
+	return status != availabilityFailing && status != availabilityTierRestricted
+}
+
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// hasReasoningMarker 判断提示词是否包含需要推理模型的关键词（不区分大小写）。
+func hasReasoningMarker(prompt, markers string) bool {
+	lower := strings.ToLower(prompt)
+	for _, marker := range strings.Split(markers, ",") {
+		marker = strings.ToLower(strings.TrimSpace(marker))
+		if marker != "" && strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// codeLinePrefixes 是常见编程语言中代码行的开头。
+var codeLinePrefixes = []string{
+	"def ", "func ", "class ", "import ", "from ", "package ", "#include", "return ",
+	"const ", "let ", "var ", "public ", "private ", "function ", "if (", "for (", "select ",
+}
+
+// looksLikeCode 判断提示词是否主要是代码：包含代码块，或至少 3 行且三成以上的行看起来像代码。
+func looksLikeCode(prompt string) bool {
+	if strings.Contains(prompt, "```") {
+		return true
+	}
+	lines, codeLines := 0, 0
+	for _, line := range strings.Split(prompt, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines++
+		if isCodeLine(line) {
+			codeLines++
+		}
+	}
+	return codeLines >= 3 && codeLines*10 >= lines*3
+}
+
+func isCodeLine(line string) bool {
+	switch line[len(line)-1] {
+	case ';', '{', '}':
+		return true
+	}
+	lower := strings.ToLower(line)
+	for _, prefix := range codeLinePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// autoModelDecision 返回请求上下文中 auto 模型的选择结果，非 auto 请求返回 nil。
+func autoModelDecision(ctx context.Context) *AutoModelDecision {
+	d, _ := ctx.Value(autoModelKey{}).(*AutoModelDecision)
+	return d
+}
+
+// withAutoModel 把 auto 模型的选择结果附加到响应元数据中。
+func withAutoModel(ctx context.Context, meta *ProviderMetadata) *ProviderMetadata {
+	d := autoModelDecision(ctx)
+	if d == nil {
+		return meta
+	}
+	if meta == nil {
+		meta = &ProviderMetadata{}
+	}
+	meta.AutoModel = d
+	return meta
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	config "you2api/config"
+)
+
+func TestChooseAutoModel(t *testing.T) {
+	conf := config.AutoModelConfig{
+		DefaultModel:     "gpt-4o-mini",
+		CodeModel:        "qwen-2.5-coder-32b",
+		ReasoningModel:   "deepseek-reasoner",
+		LongModel:        "claude-3.5-sonnet",
+		LongPromptTokens: 50,
+		ReasoningMarkers: "step by step,证明",
+	}
+	tests := []struct {
+		name   string
+		prompt string
+		model  string
+		reason string
+	}{
+		{"default", "What's the capital of France?", "gpt-4o-mini", autoReasonDefault},
+		{"reasoning", "Solve this step by step: 12*13", "deepseek-reasoner", autoReasonReasoning},
+		{"reasoning zh", "请证明根号2是无理数", "deepseek-reasoner", autoReasonReasoning},
+		{"code fence", "Fix this:\n```go\nx := 1\n```", "qwen-2.5-coder-32b", autoReasonCode},
+		{"code lines", "why does this fail\nfunc main() {\n\tfmt.Println(x);\n}", "qwen-2.5-coder-32b", autoReasonCode},
+		{"long", strings.Repeat("lorem ipsum dolor sit amet ", 100), "claude-3.5-sonnet", autoReasonLongPrompt},
+	}
+	for _, tt := range tests {
+		d := chooseAutoModel(conf, []Message{{Role: "user", Content: tt.prompt}})
+		if d.Model != tt.model || d.Reason != tt.reason {
+			t.Errorf("%s: got %s (%s), want %s (%s)", tt.name, d.Model, d.Reason, tt.model, tt.reason)
+		}
+	}
+}
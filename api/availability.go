@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierror "you2api/apierror"
+	logger "you2api/logger"
+)
+
+// errTierRestricted 表示当前账号的订阅等级无法使用该模型。
+var errTierRestricted = errors.New("model is not available for this account's subscription tier")
+
+// errRateLimited 表示上游因请求过多拒绝了当前账号的请求，账号池中的账号会因此冷却，见 pool.go。
+var errRateLimited = errors.New("upstream rate limited this account")
+
+// errTokenExpired 表示上游以 401 拒绝了账号的 DS token，token 可能已过期或被注销。
+var errTokenExpired = errors.New("upstream rejected the DS token")
+
+// errUpstreamBlocked 表示请求被上游的防护拦截（Cloudflare 质询），与订阅等级无关。
+var errUpstreamBlocked = errors.New("upstream protection blocked the request")
+
+// rateLimitError 是上游返回的 429，RetryAfter 来自 Retry-After 响应头（秒数），没有时为 0。
+type rateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string { return errRateLimited.Error() + " (upstream status 429)" }
+
+func (e *rateLimitError) Unwrap() error { return errRateLimited }
+
+// checkUpstreamStatus 把 You.com 的非 200 响应转换为错误：401 视为 token 失效，带 Cf-Mitigated 头的 403 视为被防护拦截，
+// 其余 402/403 视为订阅等级限制，429 视为限流。
+func checkUpstreamStatus(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%w (upstream status %d)", errTokenExpired, resp.StatusCode)
+	case resp.StatusCode == http.StatusForbidden && resp.Header.Get("Cf-Mitigated") != "":
+		return fmt.Errorf("%w (upstream status %d)", errUpstreamBlocked, resp.StatusCode)
+	case resp.StatusCode == http.StatusPaymentRequired || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w (upstream status %d)", errTierRestricted, resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &rateLimitError{RetryAfter: time.Duration(max(seconds, 0)) * time.Second}
+	default:
+		return fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+}
+
+// upstreamErrorCode 返回补全失败时响应的 HTTP 状态码与错误码（见 apierror.Catalog）。
+func upstreamErrorCode(err error) (int, string) {
+	code := apierror.CodeUpstreamError
+	switch {
+	case errors.Is(err, errTokenExpired):
+		code = apierror.CodeTokenExpired
+	case errors.Is(err, errUpstreamBlocked):
+		code = apierror.CodeUpstreamBlocked
+	case errors.Is(err, errTierRestricted):
+		code = apierror.CodeTierRestricted
+	case errors.Is(err, errRateLimited):
+		code = apierror.CodeUpstreamRateLimited
+	case errors.Is(err, errEmptyCompletion):
+		code = apierror.CodeEmptyCompletion
+	case errors.Is(err, errFirstTokenTimeout):
+		code = apierror.CodeStreamStalled
+	case errors.Is(err, errIncompleteStream):
+		code = apierror.CodeStreamInterrupted
+	}
+	info, _ := apierror.Lookup(code)
+	return info.Status, code
+}
+
+// 模型可用状态。
+const (
+	availabilityUnknown        = "unknown"
+	availabilityAvailable      = "available"
+	availabilityFailing        = "failing"
+	availabilityTierRestricted = "tier_restricted"
+)
+
+// ModelAvailability 描述某个模型最近的调用结果。
+type ModelAvailability struct {
+	Status         string     `json:"status"`
+	LastSuccess    *time.Time `json:"last_success,omitempty"`
+	LastFailure    *time.Time `json:"last_failure,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	TierRestricted bool       `json:"tier_restricted"`
+}
+
+// modelAvailabilityTracker 按 You.com 模型记录最近一次成功与失败。
+type modelAvailabilityTracker struct {
+	mu     sync.RWMutex
+	models map[string]*ModelAvailability
+}
+
+var modelStatus = &modelAvailabilityTracker{models: make(map[string]*ModelAvailability)}
+
+// record 记录一次补全的结果。客户端主动断开或取消不代表模型不可用，因此不计入。
+func (t *modelAvailabilityTracker) record(youModel string, err error) {
+	var accountErr *accountError
+	if errors.Is(err, context.Canceled) || errors.As(err, &accountErr) {
+		return // 客户端取消或没有可用账号，与模型是否可用无关
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.models[youModel]
+	if !ok {
+		a = &ModelAvailability{}
+		t.models[youModel] = a
+	}
+	if err == nil {
+		a.LastSuccess = &now
+		a.TierRestricted = false
+		a.Status = availabilityAvailable
+		return
+	}
+	a.LastFailure = &now
+	a.LastError = logger.ScrubError(err)
+	a.TierRestricted = errors.Is(err, errTierRestricted)
+	a.Status = availabilityFailing
+	if a.TierRestricted {
+		a.Status = availabilityTierRestricted
+	}
+}
+
+// get 返回模型的可用状态，从未调用过的模型状态为 unknown。
+func (t *modelAvailabilityTracker) get(youModel string) ModelAvailability {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if a, ok := t.models[youModel]; ok {
+		return *a
+	}
+	return ModelAvailability{Status: availabilityUnknown}
+}
+
+// ModelDetailWithAvailability 是 GET /v1/models/{id} 的响应：OpenAI 模型对象加上可用状态。
+type ModelDetailWithAvailability struct {
+	ModelDetail
+	Availability ModelAvailability `json:"availability"`
+}
+
+// handleModelDetail 处理 GET /v1/models/{id}，返回模型列表中的单个模型及其可用状态，
+// 客户端可据此避开当前账号下不可用的模型；不在列表中的模型返回 404。
+func handleModelDetail(w http.ResponseWriter, r *http.Request, id string) {
+	detail, known := lookupModel(id)
+	if !known {
+		clientParamError(w, r, http.StatusNotFound, apierror.CodeModelNotFound, "model", "Model not found: %s", id)
+		return
+	}
+
+	youModel, mapped := getModelMap()[id]
+	if !mapped {
+		youModel = id // 上游发现的模型以 You.com 名称列出
+	}
+	if vm, isVirtual := getVirtualModels()[id]; isVirtual {
+		youModel = vm.upstreamModel()
+	} else if id == autoModelName {
+		// auto 没有固定的上游模型，报告默认模型的可用状态
+		youModel = mapModelName(currentConfig().AutoModel.DefaultModel)
+	}
+	writeJSON(w, http.StatusOK, ModelDetailWithAvailability{
+		ModelDetail:  detail,
+		Availability: modelStatus.get(youModel),
+	})
+}
+
+// modelIDFromPath 从 /v1/models/{id} 路径中提取模型 ID。
+func modelIDFromPath(path string) (string, bool) {
+	for _, prefix := range []string{"/v1/models/", "/api/v1/models/"} {
+		if id, ok := strings.CutPrefix(path, prefix); ok && id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestModelAvailabilityTracker(t *testing.T) {
+	tracker := &modelAvailabilityTracker{models: make(map[string]*ModelAvailability)}
+	if got := tracker.get("m").Status; got != availabilityUnknown {
+		t.Errorf("initial status = %q", got)
+	}
+
+	tracker.record("m", context.Canceled)
+	if got := tracker.get("m").Status; got != availabilityUnknown {
+		t.Errorf("client cancellation changed status to %q", got)
+	}
+
+	tracker.record("m", errTierRestricted)
+	if a := tracker.get("m"); a.Status != availabilityTierRestricted || !a.TierRestricted || a.LastFailure == nil {
+		t.Errorf("after tier error: %+v", a)
+	}
+	tracker.record("m", errors.New("boom"))
+	if a := tracker.get("m"); a.Status != availabilityFailing || a.TierRestricted || a.LastError != "boom" {
+		t.Errorf("after failure: %+v", a)
+	}
+	tracker.record("m", nil)
+	if a := tracker.get("m"); a.Status != availabilityAvailable || a.LastSuccess == nil || a.LastFailure == nil {
+		t.Errorf("after success: %+v", a)
+	}
+}
+
+func TestModelIDFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"/v1/models/gpt-4o", "gpt-4o", true},
+		{"/api/v1/models/claude-3-opus", "claude-3-opus", true},
+		{"/v1/models/", "", false},
+		{"/v1/models", "", false},
+		{"/v1/chat/completions", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := modelIDFromPath(tt.path); got != tt.want || ok != tt.ok {
+			t.Errorf("modelIDFromPath(%q) = %q, %v", tt.path, got, ok)
+		}
+	}
+}
+
+func TestModelDetailEndpoint(t *testing.T) {
+	withMockUpstream(t, "echo")
+	decodeCompletion(t, postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+
+	rec := httptest.NewRecorder()
+	Handler(rec, httptest.NewRequest(http.MethodGet, "/v1/models/gpt-4o", nil))
+	var detail ModelDetailWithAvailability
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decode: %v; body %s", err, rec.Body)
+	}
+	if detail.ID != "gpt-4o" || detail.Availability.Status != availabilityAvailable {
+		t.Errorf("detail = %+v", detail)
+	}
+
+	rec = httptest.NewRecorder()
+	Handler(rec, httptest.NewRequest(http.MethodGet, "/v1/models/no-such-model", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown model status = %d, want 404", rec.Code)
+	}
+}
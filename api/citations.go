@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 引用来源：You.com 的搜索事件（thirdPartySearchResults、youChatSerpResults 等）带有回答引用的网页，
+// 这里把它们提取出来，以两种形式返回给客户端：
+//   - 消息中的 annotations（OpenAI 的 url_citation 格式），只包含回复中以 [n] 标注引用的来源，位置指向标注本身
+//   - 响应顶层的 citations（Perplexity 风格），按出现顺序列出全部来源的 URL，[n] 对应第 n 个
+// 流式响应在最后一个内容块之后以单独的块发送。
+
+// Source 是一个引用来源。
+type Source struct {
+	URL   string
+	Title string
+}
+
+// sourceURLKeys 与 sourceTitleKeys 是搜索结果中表示网页地址与标题的字段名。
+var (
+	sourceURLKeys   = []string{"url", "link"}
+	sourceTitleKeys = []string{"name", "title"}
+)
+
+// extractSources 递归查找事件 JSON 数据中的搜索结果（带有 http(s) 地址的对象）。
+func extractSources(data string) []Source {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return nil
+	}
+	var sources []Source
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if src, ok := sourceFromObject(val); ok {
+				sources = append(sources, src)
+				return
+			}
+			// 按字段名排序遍历，保证来源的顺序（即 [n] 的编号）稳定
+			keys := make([]string, 0, len(val))
+			for key := range val {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(val[key])
+			}
+		case []interface{}:
+			for _, child := range val {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+	return sources
+}
+
+func sourceFromObject(obj map[string]interface{}) (Source, bool) {
+	var src Source
+	for _, key := range sourceURLKeys {
+		if u, ok := obj[key].(string); ok && (strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://")) {
+			src.URL = u
+			break
+		}
+	}
+	if src.URL == "" {
+		return src, false
+	}
+	for _, key := range sourceTitleKeys {
+		if title, ok := obj[key].(string); ok && title != "" {
+			src.Title = title
+			break
+		}
+	}
+	return src, true
+}
+
+// appendSources 追加尚未出现过的来源（按 URL 去重）。
+func appendSources(list []Source, items ...Source) []Source {
+	for _, item := range items {
+		seen := false
+		for _, s := range list {
+			if s.URL == item.URL {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// citationURLs 返回响应顶层 citations 字段的内容。
+func citationURLs(sources []Source) []string {
+	if len(sources) == 0 {
+		return nil
+	}
+	urls := make([]string, len(sources))
+	for i, s := range sources {
+		urls[i] = s.URL
+	}
+	return urls
+}
+
+// citationAnnotations 为回复中每个 [n] 引用标注生成 url_citation 注解，位置以字符（而非字节）计算。
+func citationAnnotations(content string, sources []Source) []Annotation {
+	var annotations []Annotation
+	for i, src := range sources {
+		marker := fmt.Sprintf("[%d]", i+1)
+		offset := 0
+		for {
+			idx := strings.Index(content[offset:], marker)
+			if idx < 0 {
+				break
+			}
+			start := offset + idx
+			offset = start + len(marker)
+			startIndex := utf8.RuneCountInString(content[:start])
+			annotations = append(annotations, Annotation{
+				Type: "url_citation",
+				URLCitation: &URLCitation{
+					URL:        src.URL,
+					Title:      src.Title,
+					StartIndex: startIndex,
+					EndIndex:   startIndex + len(marker),
+				},
+			})
+		}
+	}
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i].URLCitation.StartIndex < annotations[j].URLCitation.StartIndex
+	})
+	return annotations
+}
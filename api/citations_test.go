@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractSources(t *testing.T) {
+	data := `{"youChatSerpResults":[{"name":"Go","url":"https://go.dev","snippet":"x"},{"title":"Spec","link":"https://go.dev/ref/spec"},{"url":"javascript:alert(1)"}]}`
+	want := []Source{{URL: "https://go.dev", Title: "Go"}, {URL: "https://go.dev/ref/spec", Title: "Spec"}}
+	if got := extractSources(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCitationAnnotations(t *testing.T) {
+	sources := []Source{{URL: "https://a.example"}, {URL: "https://b.example"}}
+	content := "引用 [2] 与 [1]，再次 [2]"
+	var got [][3]interface{}
+	for _, a := range citationAnnotations(content, sources) {
+		got = append(got, [3]interface{}{a.URLCitation.URL, a.URLCitation.StartIndex, a.URLCitation.EndIndex})
+	}
+	want := [][3]interface{}{
+		{"https://b.example", 3, 6},
+		{"https://a.example", 9, 12},
+		{"https://b.example", 16, 19},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
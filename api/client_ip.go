@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	clientip "you2api/clientip"
+)
+
+var (
+	clientIPOnce     sync.Once
+	clientIPResolver *clientip.Resolver
+)
+
+// clientIP 返回请求的真实客户端 IP，仅在对端属于 TRUSTED_PROXIES 时采信转发头。
+func clientIP(r *http.Request) string {
+	clientIPOnce.Do(func() {
+		resolver, err := clientip.NewResolver(currentConfig().TrustedProxies)
+		if err != nil {
+			log.Printf("解析 TRUSTED_PROXIES 失败，不信任任何代理: %v", err)
+			resolver, _ = clientip.NewResolver("")
+		}
+		clientIPResolver = resolver
+	})
+	return clientIPResolver.ClientIP(r)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescedHeader 标记响应复用了另一个相同请求的上游结果。
+const coalescedHeader = "X-U2API-Coalesced"
+
+// completionGroup 合并同时到达的相同非流式请求（常见于客户端重试风暴），只请求一次上游。
+var completionGroup singleflight.Group
+
+// coalesceKey 由上游 URL（包含模型、问题与历史）、客户端 key、响应大小限制与停止序列共同决定，
+// 不同客户端或不同限制的请求不会被合并。合并发生在占用账号之前，URL 中没有与账号有关的参数。
+func coalesceKey(youReq *http.Request, clientKeyID string) string {
+	limit := fmt.Sprint(youReq.Context().Value(responseLimitKey{}))
+	stops := fmt.Sprintf("%q", stopSequencesFrom(youReq.Context()))
+	sum := sha256.Sum256([]byte(youReq.URL.String() + "\n" + clientKeyID + "\n" + limit + "\n" + stops))
+	return hex.EncodeToString(sum[:])
+}
+
+// sharedFetch 记录一个被合并的上游请求还有多少等待者，所有等待者都断开时取消该请求。
+type sharedFetch struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+var sharedFetches = struct {
+	sync.Mutex
+	m map[string]*sharedFetch
+}{m: make(map[string]*sharedFetch)}
+
+// fetchCompletionCoalesced 与 fetchCompletion 相同，但会合并并发的相同请求。
+// rs.Deferred 为 true 时 youReq 还没有占用账号，只有实际请求上游的一方通过 rs.Rebuild 占用账号。
+// 共享的上游请求不随单个发起者断开而取消，以免影响其他等待者；所有等待者都断开后才取消。
+func fetchCompletionCoalesced(youReq *http.Request, rs *requestState) (*upstreamResult, bool, error) {
+	if !rs.Deferred {
+		result, err := fetchCompletion(youReq)
+		return result, false, err
+	}
+	key := coalesceKey(youReq, rs.ClientKeyID)
+
+	sharedFetches.Lock()
+	f, ok := sharedFetches.m[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.WithoutCancel(youReq.Context()))
+		f = &sharedFetch{ctx: ctx, cancel: cancel}
+		sharedFetches.m[key] = f
+	}
+	f.waiters++
+	sharedFetches.Unlock()
+
+	ch := completionGroup.DoChan(key, func() (interface{}, error) {
+		result, err := fetchWithAccount(youReq.WithContext(f.ctx), rs)
+		sharedFetches.Lock()
+		if sharedFetches.m[key] == f {
+			delete(sharedFetches.m, key)
+		}
+		sharedFetches.Unlock()
+		return result, err
+	})
+
+	select {
+	case res := <-ch:
+		leaveSharedFetch(key, f)
+		return res.Val.(*upstreamResult), res.Shared, res.Err
+	case <-youReq.Context().Done():
+		if leaveSharedFetch(key, f) {
+			completionGroup.Forget(key) // 之后到达的相同请求重新发起上游请求
+		}
+		return &upstreamResult{}, false, context.Cause(youReq.Context())
+	}
+}
+
+// fetchWithAccount 占用账号并按 youReq 的上下文请求上游，结束后按结果更新账号状态。
+func fetchWithAccount(youReq *http.Request, rs *requestState) (*upstreamResult, error) {
+	req, lease, err := rs.Rebuild(rs.UpstreamModel)
+	if err != nil {
+		return &upstreamResult{}, &accountError{err: err}
+	}
+	defer lease.release()
+	result, err := fetchCompletion(req.WithContext(youReq.Context()))
+	lease.record(err)
+	return result, err
+}
+
+// leaveSharedFetch 减少等待者计数，最后一个等待者离开时取消上游请求并返回 true。
+func leaveSharedFetch(key string, f *sharedFetch) bool {
+	sharedFetches.Lock()
+	defer sharedFetches.Unlock()
+	f.waiters--
+	if f.waiters > 0 {
+		return false
+	}
+	f.cancel()
+	if sharedFetches.m[key] == f {
+		delete(sharedFetches.m, key)
+	}
+	return true
+}
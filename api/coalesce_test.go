@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	upstream "you2api/internal/upstream"
+)
+
+// gatedTransport 在 release 关闭前阻塞聊天请求，用于构造并发的相同请求。
+type gatedTransport struct {
+	calls    atomic.Int32
+	started  chan struct{}
+	release  chan struct{}
+	canceled chan struct{}
+}
+
+func newGatedTransport() *gatedTransport {
+	return &gatedTransport{started: make(chan struct{}, 8), release: make(chan struct{}), canceled: make(chan struct{}, 8)}
+}
+
+func (g *gatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	g.calls.Add(1)
+	g.started <- struct{}{}
+	select {
+	case <-g.release:
+		return (&mockTransport{style: "echo"}).RoundTrip(req)
+	case <-req.Context().Done():
+		g.canceled <- struct{}{}
+		return nil, req.Context().Err()
+	}
+}
+
+func withGatedUpstream(t *testing.T) *gatedTransport {
+	t.Helper()
+	gated := newGatedTransport()
+	prev := upstreamClient
+	upstreamClient = func(timeout time.Duration) upstream.Doer {
+		return &http.Client{Transport: gated, Timeout: timeout}
+	}
+	t.Cleanup(func() { upstreamClient = prev })
+
+	prevConf := currentConfig()
+	conf := *prevConf
+	conf.CoalesceRequests = true
+	setConfig(&conf)
+	t.Cleanup(func() { setConfig(prevConf) })
+	return gated
+}
+
+// newCoalesceRequest 返回尚未占用账号的上游请求，实际请求上游时使用 testDSToken。
+func newCoalesceRequest(t *testing.T, ctx context.Context, prompt string) (*http.Request, *requestState) {
+	t.Helper()
+	openAIReq := OpenAIRequest{Messages: []Message{{Role: "user", Content: prompt}}}
+	req, err := buildYouRequest(ctx, openAIReq, "gpt_4o", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := &requestState{UpstreamModel: "gpt_4o", ClientKeyID: keyID("client-key"), Deferred: true}
+	rs.Rebuild = func(youModel string) (*http.Request, *tokenLease, error) {
+		req, err := buildYouRequest(ctx, openAIReq, youModel, testDSToken)
+		return req, nil, err
+	}
+	return req, rs
+}
+
+func TestCoalesceIdenticalRequests(t *testing.T) {
+	gated := withGatedUpstream(t)
+
+	type outcome struct {
+		result *upstreamResult
+		shared bool
+		err    error
+	}
+	results := make(chan outcome, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, shared, err := fetchCompletionCoalesced(newCoalesceRequest(t, context.Background(), "coalesce me"))
+			results <- outcome{result, shared, err}
+		}()
+	}
+	<-gated.started
+	time.Sleep(20 * time.Millisecond) // 等待第二个请求加入
+	close(gated.release)
+	wg.Wait()
+	close(results)
+
+	if got := gated.calls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+	for res := range results {
+		if res.err != nil || res.result.Content != "coalesce me" || !res.shared {
+			t.Errorf("result = %+v, shared = %v, err = %v", res.result, res.shared, res.err)
+		}
+	}
+}
+
+func TestCoalesceCancelsWhenAllWaitersLeave(t *testing.T) {
+	gated := withGatedUpstream(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := fetchCompletionCoalesced(newCoalesceRequest(t, ctx, "abandoned"))
+		done <- err
+	}()
+	<-gated.started
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	select {
+	case <-gated.canceled:
+	case <-time.After(time.Second):
+		t.Error("shared upstream request was not canceled after the last waiter left")
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	ctx := context.Background()
+	key := func(prompt, client string) string {
+		req, _ := newCoalesceRequest(t, ctx, prompt)
+		return coalesceKey(req, keyID(client))
+	}
+	base := key("same", "client-a")
+	if got := key("same", "client-a"); got != base {
+		t.Error("identical requests produced different keys")
+	}
+	if got := key("different", "client-a"); got == base {
+		t.Error("different prompts share a key")
+	}
+	if got := key("same", "client-b"); got == base {
+		t.Error("requests from different clients share a key")
+	}
+}
+
+func TestCoalesceBeforeAcquiringAccount(t *testing.T) {
+	gated := withGatedUpstream(t)
+	accessKey := withTestPool(t, "only-account") // 只有一个并发名额
+
+	// 被合并的请求不占用账号，两个相同的请求都能完成
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"coalesce me"}]}`
+	recs := make(chan *httptest.ResponseRecorder, 2)
+	for i := 0; i < 2; i++ {
+		go func() { recs <- postChat(t, body, "Authorization", "Bearer "+accessKey) }()
+	}
+	<-gated.started
+	time.Sleep(20 * time.Millisecond) // 等待第二个请求加入
+	close(gated.release)
+	for i := 0; i < 2; i++ {
+		if rec := <-recs; rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200; body %s", rec.Code, rec.Body)
+		}
+	}
+	if got := gated.calls.Load(); got != 1 {
+		t.Errorf("upstream calls = %d, want 1", got)
+	}
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// You.com 的回复中经常出现没有标注语言的代码块（只有 ```），聊天界面因此无法高亮。
+// codeFenceTagger 在流式输出中识别这样的开始围栏，暂存代码块开头的一段内容，
+// 用简单的启发式规则猜测语言后补上标注，再继续按块输出。已标注语言的代码块原样输出。
+
+// codeFenceDetectBytes 是猜测语言前最多暂存的代码块内容，代码块在此之前结束时按完整内容猜测。
+const codeFenceDetectBytes = 256
+
+// codeFenceTagger 为未标注语言的代码块补上语言标注。为 nil 时不做任何处理。
+type codeFenceTagger struct {
+	out         strings.Builder
+	atLineStart bool
+	backticks   int              // 行首已暂存的反引号数量
+	info        *strings.Builder // 正在读取围栏行的 info 字符串，不在围栏行时为 nil
+	inBlock     bool             // 位于代码块内
+	detecting   bool             // 位于未标注语言的代码块开头，内容暂存在 pending 中
+	pending     strings.Builder
+}
+
+// newCodeFenceTagger 在 enabled 为 false 时返回 nil（即不处理）。
+func newCodeFenceTagger(enabled bool) *codeFenceTagger {
+	if !enabled {
+		return nil
+	}
+	return &codeFenceTagger{atLineStart: true}
+}
+
+// push 处理一个内容块，返回可以立即发送的部分。
+func (t *codeFenceTagger) push(chunk string) string {
+	if t == nil {
+		return chunk
+	}
+	for _, r := range chunk {
+		switch {
+		case t.info != nil:
+			if r == '\n' {
+				t.fenceLine(t.info.String())
+				t.info = nil
+			} else {
+				t.info.WriteRune(r)
+			}
+			continue
+		case t.atLineStart && r == '`':
+			t.backticks++
+			if t.backticks == 3 {
+				t.backticks = 0
+				t.info = &strings.Builder{}
+			}
+			continue
+		}
+		if t.backticks > 0 {
+			t.emit(strings.Repeat("`", t.backticks))
+			t.backticks = 0
+		}
+		t.emit(string(r))
+		t.atLineStart = r == '\n'
+	}
+	out := t.out.String()
+	t.out.Reset()
+	return out
+}
+
+// flush 在回复结束时输出所有暂存的内容。
+func (t *codeFenceTagger) flush() string {
+	if t == nil {
+		return ""
+	}
+	if t.info != nil {
+		t.emit("```" + t.info.String())
+		t.info = nil
+	}
+	if t.backticks > 0 {
+		t.emit(strings.Repeat("`", t.backticks))
+		t.backticks = 0
+	}
+	if t.detecting {
+		t.resolve()
+	}
+	out := t.out.String()
+	t.out.Reset()
+	return out
+}
+
+// tag 一次性处理完整内容（非流式响应）。
+func (t *codeFenceTagger) tag(content string) string {
+	if t == nil {
+		return content
+	}
+	return t.push(content) + t.flush()
+}
+
+// emit 输出内容；位于待猜测语言的代码块开头时先暂存，暂存足够多后再猜测语言。
+func (t *codeFenceTagger) emit(s string) {
+	if !t.detecting {
+		t.out.WriteString(s)
+		return
+	}
+	t.pending.WriteString(s)
+	if t.pending.Len() >= codeFenceDetectBytes {
+		t.resolve()
+	}
+}
+
+// fenceLine 处理一整行围栏（``` 加 info 字符串）。
+func (t *codeFenceTagger) fenceLine(info string) {
+	line := "```" + info + "\n"
+	t.atLineStart = true
+	switch {
+	case t.inBlock && strings.TrimSpace(info) != "":
+		t.emit(line) // 代码块内带 info 的围栏不是结束围栏，按普通内容处理
+	case t.inBlock:
+		if t.detecting {
+			t.resolve()
+		}
+		t.out.WriteString(line)
+		t.inBlock = false
+	case strings.TrimSpace(info) == "":
+		t.inBlock = true
+		t.detecting = true
+	default:
+		t.out.WriteString(line)
+		t.inBlock = true
+	}
+}
+
+// resolve 根据暂存的代码块内容猜测语言，输出带标注的开始围栏与暂存内容。
+func (t *codeFenceTagger) resolve() {
+	code := t.pending.String()
+	t.pending.Reset()
+	t.detecting = false
+	t.out.WriteString("```" + detectCodeLanguage(code) + "\n")
+	t.out.WriteString(code)
+}
+
+// languageRule 是一条语言特征，命中时为该语言累加权重。
+type languageRule struct {
+	language string
+	weight   int
+	pattern  *regexp.Regexp
+}
+
+// languageRules 是猜测代码语言的特征表，只覆盖聊天回复中常见的语言。
+var languageRules = func() []languageRule {
+	rules := []struct {
+		language string
+		weight   int
+		pattern  string
+	}{
+		{"go", 3, `(?m)^package \w+$`},
+		{"go", 2, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`},
+		{"go", 1, `:= |fmt\.\w+\(|\berr != nil\b`},
+		{"python", 3, `(?m)^\s*def \w+\(.*\):\s*$`},
+		{"python", 2, `(?m)^(from \w[\w.]* )?import \w[\w.]*( as \w+)?$|(?m)^\s*class \w+(\(.*\))?:\s*$`},
+		{"python", 1, `\bprint\(|\bself\.|\belif\b|(?m)^if __name__ == `},
+		{"javascript", 2, `\bconsole\.log\(|\brequire\(['"]|(?m)^export (default )?(function|const|class)\b`},
+		{"javascript", 1, `(?m)^\s*(const|let|var) \w+ = |=> \{?|\bfunction \w*\(`},
+		{"typescript", 3, `(?m)^\s*(export )?(interface|type) \w+ (=|\{)|: (string|number|boolean)\b`},
+		{"rust", 3, `(?m)^\s*(pub )?fn \w+(<.*>)?\(|\blet mut\b|\bprintln!\(`},
+		{"rust", 1, `\bimpl\b|::new\(|&str\b`},
+		{"java", 3, `\bpublic (static )?(class|void)\b|\bSystem\.out\.print`},
+		{"csharp", 3, `(?m)^using System|\bConsole\.Write(Line)?\(|\bnamespace \w+`},
+		{"cpp", 3, `#include <(iostream|vector|string)>|\bstd::|\bcout <<`},
+		{"c", 3, `#include <(stdio|stdlib|string)\.h>|\bprintf\(`},
+		{"php", 4, `<\?php`},
+		{"bash", 4, `^#!/(usr/)?bin/(env )?(ba|z)?sh`},
+		{"bash", 2, `(?m)^\s*(sudo |apt(-get)? |brew |npm (install|run) |pip3? install |cd |export \w+=|echo )`},
+		{"sql", 3, `(?i)\bselect\b[\s\S]+\bfrom\b|\binsert into\b|\bcreate table\b|\bupdate \w+ set\b`},
+		{"html", 3, `(?i)<!doctype html|<html\b|<(div|span|body|head)\b[^>]*>`},
+		{"css", 2, `(?m)^[.#]?[\w-]+(\s*[.#:>][\w-]+)*\s*\{\s*$|(?m)^\s*[\w-]+: [^;]+;\s*$`},
+		{"dockerfile", 3, `(?m)^FROM \S+`},
+		{"dockerfile", 1, `(?m)^(RUN|COPY|WORKDIR|CMD|ENTRYPOINT) `},
+		{"json", 2, `^\s*[\[{]\s*"[^"\n]+"\s*:`}, // 超过暂存长度、尚不完整的 JSON
+	}
+	compiled := make([]languageRule, 0, len(rules))
+	for _, r := range rules {
+		compiled = append(compiled, languageRule{r.language, r.weight, regexp.MustCompile(r.pattern)})
+	}
+	return compiled
+}()
+
+// detectCodeLanguage 猜测代码的语言，没有足够把握时返回空字符串（保持不标注）。
+func detectCodeLanguage(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" {
+		return ""
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	scores := make(map[string]int)
+	for _, rule := range languageRules {
+		if rule.pattern.MatchString(code) {
+			scores[rule.language] += rule.weight
+		}
+	}
+	// TypeScript 是 JavaScript 的超集，同时命中时以 TypeScript 为准
+	if scores["typescript"] > 0 {
+		scores["typescript"] += scores["javascript"]
+	}
+
+	best, bestScore := "", 1 // 至少需要 2 分，避免只凭一条弱特征标注
+	for _, rule := range languageRules {
+		if score := scores[rule.language]; score > bestScore {
+			best, bestScore = rule.language, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectCodeLanguage(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"package main\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n", "go"},
+		{"def add(a, b):\n    return a + b\n", "python"},
+		{"import os\nprint(os.getcwd())\n", "python"},
+		{"const x = 1;\nconsole.log(x);\n", "javascript"},
+		{"interface User {\n  name: string;\n}\nconst u: User = { name: 'a' };\n", "typescript"},
+		{"fn main() {\n    println!(\"hi\");\n}\n", "rust"},
+		{"#include <stdio.h>\nint main() { printf(\"hi\"); }\n", "c"},
+		{"#!/bin/bash\necho hi\n", "bash"},
+		{"SELECT id, name\nFROM users\nWHERE id = 1;\n", "sql"},
+		{"{\"a\": 1, \"b\": [1, 2]}\n", "json"},
+		{"FROM golang:1.22\nRUN go build\n", "dockerfile"},
+		{"hello world\n", ""},
+		{"x = 1\n", ""},
+	}
+	for _, tt := range tests {
+		if got := detectCodeLanguage(tt.code); got != tt.want {
+			t.Errorf("detectCodeLanguage(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCodeFenceTaggerStreaming(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			"Here:\n```\ndef f():\n    pass\n```\nDone `inline` code.",
+			"Here:\n```python\ndef f():\n    pass\n```\nDone `inline` code.",
+		},
+		{
+			// 已标注语言的代码块与无法识别的代码块保持原样
+			"```go\nx := 1\n```\n```\nsome text\n```\n",
+			"```go\nx := 1\n```\n```\nsome text\n```\n",
+		},
+		{
+			// 回复在代码块中途结束
+			"```\nfn main() {\n    println!(\"hi\");",
+			"```rust\nfn main() {\n    println!(\"hi\");",
+		},
+		{"``not a fence``\n``", "``not a fence``\n``"},
+	}
+	for _, tt := range tests {
+		// 按每 1~5 个字符切分 token，模拟围栏跨 token 的情况
+		for size := 1; size <= 5; size++ {
+			tagger := newCodeFenceTagger(true)
+			var out strings.Builder
+			runes := []rune(tt.input)
+			for i := 0; i < len(runes); i += size {
+				out.WriteString(tagger.push(string(runes[i:min(i+size, len(runes))])))
+			}
+			out.WriteString(tagger.flush())
+			if out.String() != tt.want {
+				t.Errorf("size %d: tagged %q = %q, want %q", size, tt.input, out.String(), tt.want)
+			}
+		}
+	}
+}
+
+func TestCodeFenceTaggerLongBlockStreamsAfterDetection(t *testing.T) {
+	tagger := newCodeFenceTagger(true)
+	out := tagger.push("```\npackage main\n" + strings.Repeat("// comment\n", 30))
+	if !strings.HasPrefix(out, "```go\npackage main\n") {
+		t.Fatalf("long block not released after detection: %q", out)
+	}
+	if got := tagger.push("x := 1\n"); got != "x := 1\n" {
+		t.Errorf("content after detection = %q", got)
+	}
+}
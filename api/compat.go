@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// 兼容模式（COMPAT_MODE）：
+//   - strict：请求中出现本服务无法处理的参数时返回 400，适合 CI 中尽早发现问题
+//   - lenient：静默忽略这些参数，最大化客户端兼容性（默认）
+const (
+	compatStrict  = "strict"
+	compatLenient = "lenient"
+)
+
+// paramSupport 描述某个 OpenAI 请求参数在本服务中的处理方式。
+type paramSupport int
+
+const (
+	paramSupported   paramSupport = iota // 参数被完整处理
+	paramUnsupported                     // 已知的 OpenAI 参数，但 You.com 无法支持
+)
+
+// chatParams 是聊天请求参数的处理方式表。未出现在表中的参数视为未知参数，
+// 在 strict 模式下同样会被拒绝。采样参数（见 sampling.go）不在表中，是否支持取决于 SAMPLING_PARAMS。
+var chatParams = map[string]paramSupport{
+	"model":    paramSupported,
+	"messages": paramSupported,
+	"stream":   paramSupported,
+	"dry_run":  paramSupported,
+	"n":        paramSupported,
+
+	"previous_response_id":  paramSupported,
+	"async":                 paramSupported,
+	"callback_url":          paramSupported,
+	"instructions":          paramSupported,
+	"persona":               paramSupported,
+	"response_format":       paramSupported,
+	"reasoning":             paramSupported,
+	"include_reasoning":     paramSupported,
+	"reasoning_effort":      paramSupported,
+	"tools":                 paramSupported,
+	"tool_choice":           paramSupported,
+	"parallel_tool_calls":   paramSupported,
+	"max_tokens":            paramSupported,
+	"max_completion_tokens": paramSupported,
+	"stop":                  paramSupported,
+
+	"logit_bias":     paramUnsupported,
+	"logprobs":       paramUnsupported,
+	"top_logprobs":   paramUnsupported,
+	"user":           paramUnsupported,
+	"functions":      paramUnsupported,
+	"function_call":  paramUnsupported,
+	"stream_options": paramUnsupported,
+}
+
+// unsupportedReasons 说明已知参数无法支持的原因，strict 模式的错误信息中逐个列出。
+var unsupportedReasons = map[string]string{
+	"logit_bias":     "You.com does not expose token logits",
+	"logprobs":       "You.com does not return token log probabilities",
+	"top_logprobs":   "You.com does not return token log probabilities",
+	"user":           "end-user IDs are not forwarded upstream",
+	"functions":      "deprecated, use tools instead",
+	"function_call":  "deprecated, use tool_choice instead",
+	"stream_options": "streamed usage chunks are not supported",
+}
+
+// compatReason 返回参数在 strict 模式下被拒绝的原因。
+func compatReason(name string) string {
+	if isSamplingParam(name) {
+		return "not forwarded to You.com, see SAMPLING_PARAMS"
+	}
+	if reason, ok := unsupportedReasons[name]; ok {
+		return reason
+	}
+	return "unknown parameter"
+}
+
+// describeRejected 把被拒绝的参数及原因拼接为错误信息，如 "logit_bias (You.com does not expose token logits)"。
+func describeRejected(rejected []string) string {
+	parts := make([]string, 0, len(rejected))
+	for _, name := range rejected {
+		parts = append(parts, name+" ("+compatReason(name)+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkCompat 返回在当前兼容模式下应被拒绝的参数（按名称排序）；lenient 模式下总是返回空。
+func checkCompat(mode string, body []byte) []string {
+	if mode != compatStrict {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil // 结构错误由 Schema 校验负责报告
+	}
+	var rejected []string
+	forwarded := forwardedSamplingParams()
+	for name := range fields {
+		if isSamplingParam(name) {
+			if _, ok := forwarded[name]; !ok {
+				rejected = append(rejected, name)
+			}
+			continue
+		}
+		if support, known := chatParams[name]; !known || support != paramSupported {
+			rejected = append(rejected, name)
+		}
+	}
+	sort.Strings(rejected)
+	return rejected
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckCompat(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		body string
+		want []string
+	}{
+		{
+			name: "strict 接受支持的参数",
+			mode: compatStrict,
+			body: `{"model":"gpt-4o","messages":[],"stream":true,"dry_run":false}`,
+			want: nil,
+		},
+		{
+			name: "strict 拒绝不支持的参数",
+			mode: compatStrict,
+			body: `{"model":"gpt-4o","messages":[],"temperature":0.2,"top_p":1,"logit_bias":{}}`,
+			want: []string{"logit_bias"},
+		},
+		{
+			name: "strict 拒绝未转发的采样参数",
+			mode: compatStrict,
+			body: `{"messages":[],"temperature":0.2,"seed":42,"presence_penalty":0}`,
+			want: []string{"presence_penalty", "seed"},
+		},
+		{
+			name: "strict 拒绝未知参数",
+			mode: compatStrict,
+			body: `{"messages":[],"foo":1}`,
+			want: []string{"foo"},
+		},
+		{
+			name: "lenient 忽略不支持的参数",
+			mode: compatLenient,
+			body: `{"messages":[],"temperature":0.2,"foo":1}`,
+			want: nil,
+		},
+		{
+			name: "未设置模式时按 lenient 处理",
+			mode: "",
+			body: `{"messages":[],"seed":42}`,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkCompat(tt.mode, []byte(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkCompat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestChatParamsTable 确保参数表中的每一项在两种模式下的行为符合预期。
+func TestChatParamsTable(t *testing.T) {
+	for name, support := range chatParams {
+		body := []byte(`{"` + name + `":null}`)
+		strict := checkCompat(compatStrict, body)
+		if support == paramSupported && len(strict) != 0 {
+			t.Errorf("strict mode rejected supported parameter %q", name)
+		}
+		if support == paramUnsupported && !reflect.DeepEqual(strict, []string{name}) {
+			t.Errorf("strict mode did not reject unsupported parameter %q: %v", name, strict)
+		}
+		if lenient := checkCompat(compatLenient, body); len(lenient) != 0 {
+			t.Errorf("lenient mode rejected parameter %q", name)
+		}
+	}
+}
+
+func TestDescribeRejected(t *testing.T) {
+	got := describeRejected([]string{"foo", "logit_bias", "seed"})
+	want := "foo (unknown parameter), logit_bias (You.com does not expose token logits), seed (not forwarded to You.com, see SAMPLING_PARAMS)"
+	if got != want {
+		t.Errorf("describeRejected() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"log"
+	"sync"
+
+	config "you2api/config"
+)
+
+var (
+	cfgOnce sync.Once
+	cfg     *config.Config
+	// cfgErr 是从环境变量加载配置失败的原因。此时 Handler 以 500 config_error 拒绝所有请求，Start 返回该错误
+	cfgErr error
+)
+
+// currentConfig 返回处理器使用的配置，首次调用时从环境变量加载。
+// 加载失败时返回的空配置只用于避免空指针，不会用来处理请求，见 configError。
+func currentConfig() *config.Config {
+	cfgOnce.Do(func() {
+		loaded, err := config.Load()
+		if err != nil {
+			log.Printf("加载配置失败，拒绝处理请求: %v", err)
+			cfgErr = err
+			loaded = &config.Config{}
+		}
+		cfg = loaded
+	})
+	return cfg
+}
+
+// configError 返回从环境变量加载配置的错误。
+func configError() error {
+	currentConfig()
+	return cfgErr
+}
+
+// setConfig 替换处理器使用的配置，见 WithConfig。
+func setConfig(c *config.Config) {
+	cfgOnce.Do(func() {})
+	cfg, cfgErr = c, nil
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// 上下文窗口相关的响应头，前端可据此在历史被截断前提醒用户。
+const (
+	contextWindowHeader    = "X-Context-Window"
+	contextRemainingHeader = "X-Context-Remaining-Tokens"
+)
+
+// defaultContextWindow 用于未在 contextWindows 中登记的模型。
+const defaultContextWindow = 32000
+
+// contextWindows 记录 You.com 模型的上下文窗口大小（token 数）。
+var contextWindows = map[string]int{
+	"deepseek_r1":                64000,
+	"deepseek_v3":                64000,
+	"openai_o3_mini_high":        200000,
+	"openai_o3_mini_medium":      200000,
+	"openai_o1":                  200000,
+	"openai_o1_mini":             128000,
+	"openai_o1_preview":          128000,
+	"gpt_4o":                     128000,
+	"gpt_4o_mini":                128000,
+	"gpt_4_turbo":                128000,
+	"gpt_3.5":                    16385,
+	"claude_3_opus":              200000,
+	"claude_3_sonnet":            200000,
+	"claude_3_5_sonnet":          200000,
+	"claude_3_5_haiku":           200000,
+	"claude_3_7_sonnet":          200000,
+	"claude_3_7_sonnet_thinking": 200000,
+	"gemini_1_5_pro":             2000000,
+	"gemini_1_5_flash":           1000000,
+	"llama3_2_90b":               128000,
+	"llama3_1_405b":              128000,
+	"mistral_large_2":            128000,
+	"qwen2p5_72b":                32768,
+	"qwen2p5_coder_32b":          32768,
+	"command_r_plus":             128000,
+}
+
+// contextWindowFor 返回模型的上下文窗口大小。
+func contextWindowFor(youModel string) int {
+	if window, ok := contextWindows[youModel]; ok {
+		return window
+	}
+	return defaultContextWindow
+}
+
+// setContextBudgetHeaders 根据已累积的对话历史估算剩余上下文窗口并写入响应头。
+func setContextBudgetHeaders(w http.ResponseWriter, youModel string, messages []Message) {
+	window := contextWindowFor(youModel)
+	remaining := window - countMessagesTokens(youModel, messages)
+	if remaining < 0 {
+		remaining = 0
+	}
+	w.Header().Set(contextWindowHeader, strconv.Itoa(window))
+	w.Header().Set(contextRemainingHeader, strconv.Itoa(remaining))
+}
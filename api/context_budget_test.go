@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestContextBudgetHeaders(t *testing.T) {
+	tests := []struct {
+		model      string
+		messages   []Message
+		wantWindow int
+	}{
+		{"claude_3_5_sonnet", []Message{{Role: "user", Content: "hello"}}, 200000},
+		{"unknown_model", []Message{{Role: "user", Content: "hello"}}, defaultContextWindow},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		setContextBudgetHeaders(rec, tt.model, tt.messages)
+		window, _ := strconv.Atoi(rec.Header().Get(contextWindowHeader))
+		remaining, _ := strconv.Atoi(rec.Header().Get(contextRemainingHeader))
+		if window != tt.wantWindow {
+			t.Errorf("%s: window = %d, want %d", tt.model, window, tt.wantWindow)
+		}
+		if want := window - countMessagesTokens(tt.model, tt.messages); remaining != want || remaining >= window {
+			t.Errorf("%s: remaining = %d, want %d", tt.model, remaining, want)
+		}
+	}
+}
+
+func TestContextBudgetNeverNegative(t *testing.T) {
+	rec := httptest.NewRecorder()
+	huge := []Message{{Role: "user", Content: strings.Repeat("token ", contextWindowFor("gpt_3.5"))}}
+	setContextBudgetHeaders(rec, "gpt_3.5", huge)
+	if got := rec.Header().Get(contextRemainingHeader); got != "0" {
+		t.Errorf("remaining = %q, want 0", got)
+	}
+}
+
+func TestContextBudgetInResponse(t *testing.T) {
+	withMockUpstream(t, "echo")
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	for _, enabled := range []bool{false, true} {
+		prev := currentConfig()
+		conf := *prev
+		conf.ReportContextBudget = enabled
+		setConfig(&conf)
+
+		rec := postChat(t, body)
+		setConfig(prev)
+		if got := rec.Header().Get(contextWindowHeader) != ""; got != enabled {
+			t.Errorf("REPORT_CONTEXT_BUDGET=%v: %s present = %v", enabled, contextWindowHeader, got)
+		}
+		if got := rec.Header().Get(contextRemainingHeader) != ""; got != enabled {
+			t.Errorf("REPORT_CONTEXT_BUDGET=%v: %s present = %v", enabled, contextRemainingHeader, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	translate "you2api/internal/translate"
+	logger "you2api/logger"
+	metrics "you2api/metrics"
+)
+
+// StoredConversation 是一次补全结束时的完整对话（包括本次回复），供后续请求通过 previous_response_id 继续。
+type StoredConversation struct {
+	KeyID    string
+	Messages []Message
+	Created  time.Time
+}
+
+// ConversationStore 按响应 ID 保存对话，见 WithConversationStore。多个实例共享同一个存储时，
+// 后续请求落在任意实例上都能通过 previous_response_id 继续对话。
+type ConversationStore interface {
+	// Get 返回响应 ID 对应的对话，不存在时返回 false。
+	Get(ctx context.Context, id string) (*StoredConversation, bool, error)
+	// Put 保存响应 ID 对应的对话。
+	Put(ctx context.Context, id string, conv *StoredConversation) error
+}
+
+// conversations 按响应 ID（即 X-Request-ID）保存对话，超过 CONVERSATION_STORE_SIZE 时淘汰最久未使用的记录。
+// 同一个响应 ID 可以被多次引用，从而形成树状的对话分支（重新生成、分叉）。
+var conversations = &conversationStore{
+	lru: newLRU[string, *StoredConversation]("conversations", func() int { return currentConfig().ConversationStoreSize }).
+		withSizer(conversationSize),
+}
+
+// conversationSize 估算一条对话占用的内存：消息内容加上每条消息固定的结构开销。
+func conversationSize(id string, conv *StoredConversation) int {
+	size := len(id) + len(conv.KeyID)
+	for _, msg := range conv.Messages {
+		size += len(msg.Role) + len(msg.Content) + 64
+	}
+	return size
+}
+
+type conversationStore struct {
+	lru *lruCache[string, *StoredConversation]
+	// external 由 WithConversationStore 设置，非空时代替进程内的 lru
+	external ConversationStore
+}
+
+// setConversationStore 使用外部对话存储，应在开始处理请求之前调用。
+func setConversationStore(store ConversationStore) {
+	conversations.external = store
+}
+
+// save 保存一次补全后的对话，消息数超过 CONVERSATION_MAX_MESSAGES 时丢弃最早的非系统消息。
+func (s *conversationStore) save(ctx context.Context, id, keyID string, history []Message, reply string) {
+	messages := make([]Message, 0, len(history)+1)
+	messages = append(messages, history...)
+	messages = append(messages, Message{Role: "assistant", Content: reply})
+	messages = trimHistory(messages, currentConfig().ConversationMaxMessages)
+
+	s.put(ctx, id, &StoredConversation{KeyID: keyID, Messages: messages, Created: time.Now()})
+}
+
+// put 写入一条对话。外部存储写入失败只记录日志：补全本身已经成功，只是之后无法引用这次响应。
+func (s *conversationStore) put(ctx context.Context, id string, conv *StoredConversation) {
+	if s.external == nil {
+		s.lru.put(id, conv)
+		return
+	}
+	if err := s.external.Put(ctx, id, conv); err != nil {
+		logger.L().Warn("保存对话失败", zap.String("response_id", id), zap.Error(err))
+	}
+}
+
+// get 返回属于 keyID 的对话；其他 key 的对话视为不存在，避免跨账号读取历史。外部存储读取失败时同样视为不存在。
+func (s *conversationStore) get(ctx context.Context, id, keyID string) ([]Message, bool) {
+	var (
+		conv *StoredConversation
+		ok   bool
+	)
+	if s.external == nil {
+		conv, ok = s.lru.get(id)
+	} else {
+		var err error
+		if conv, ok, err = s.external.Get(ctx, id); err != nil {
+			logger.L().Warn("读取对话失败", zap.String("response_id", id), zap.Error(err))
+			return nil, false
+		}
+	}
+	if !ok || conv == nil || conv.KeyID != keyID {
+		return nil, false
+	}
+	return append([]Message(nil), conv.Messages...), true
+}
+
+// snapshot 返回进程内保存的对话，供 /admin/state 导出；使用外部存储时对话由外部存储自行持久化，不导出。
+func (s *conversationStore) snapshot() map[string]*StoredConversation {
+	if s.external != nil {
+		return nil
+	}
+	return s.lru.entries()
+}
+
+// trimHistory 把消息数限制在 limit 以内：保留开头的系统消息，从最早的对话轮次开始丢弃。
+func trimHistory(messages []Message, limit int) []Message {
+	if limit <= 0 || len(messages) <= limit {
+		return messages
+	}
+	system := 0
+	for system < len(messages) && translate.IsSystemRole(messages[system].Role) {
+		system++
+	}
+	if system >= limit {
+		system = 0 // 系统消息本身已超过上限时不再特殊对待
+	}
+	dropped := len(messages) - limit
+	metrics.StoreEvictions.WithLabelValues("conversation_messages").Add(float64(dropped))
+
+	trimmed := make([]Message, 0, limit)
+	trimmed = append(trimmed, messages[:system]...)
+	return append(trimmed, messages[system+dropped:]...)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestTrimHistory(t *testing.T) {
+	msgs := func(roles ...string) []Message {
+		result := make([]Message, len(roles))
+		for i, role := range roles {
+			result[i] = Message{Role: role, Content: string(rune('a' + i))}
+		}
+		return result
+	}
+	contents := func(messages []Message) string {
+		var s string
+		for _, m := range messages {
+			s += m.Content
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		limit    int
+		want     string
+	}{
+		{"under limit", msgs("user", "assistant"), 4, "ab"},
+		{"no limit", msgs("user", "assistant", "user"), 0, "abc"},
+		{"drop oldest turns", msgs("user", "assistant", "user", "assistant"), 2, "cd"},
+		{"keep system", msgs("system", "user", "assistant", "user"), 3, "acd"},
+		{"system over limit", msgs("system", "system", "user"), 2, "bc"},
+	}
+	for _, tt := range tests {
+		if got := contents(trimHistory(tt.messages, tt.limit)); got != tt.want {
+			t.Errorf("%s: trimHistory = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConversationStoreKeyIsolation(t *testing.T) {
+	conversations.save(context.Background(), "conv-isolation", "key-a", []Message{{Role: "user", Content: "hi"}}, "hello")
+	history, ok := conversations.get(context.Background(), "conv-isolation", "key-a")
+	if !ok || len(history) != 2 || history[1].Role != "assistant" || history[1].Content != "hello" {
+		t.Errorf("history = %+v, ok = %v", history, ok)
+	}
+	if _, ok := conversations.get(context.Background(), "conv-isolation", "key-b"); ok {
+		t.Error("conversation readable by another key")
+	}
+}
+
+func TestPreviousResponseID(t *testing.T) {
+	withMockUpstream(t, "echo")
+	first := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"first turn"}]}`)
+	id := first.Header().Get("X-Request-ID")
+	if first.Code != http.StatusOK || id == "" {
+		t.Fatalf("status = %d, X-Request-ID = %q", first.Code, id)
+	}
+
+	rec := postChat(t, `{"model":"gpt-4o","dry_run":true,"previous_response_id":"`+id+`","messages":[{"role":"user","content":"second turn"}]}`)
+	var dry dryRunResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &dry); err != nil {
+		t.Fatalf("decode: %v; body %s", err, rec.Body)
+	}
+	if dry.MessageCount != 3 {
+		t.Errorf("message_count = %d, want 3 (stored user + assistant + new user)", dry.MessageCount)
+	}
+
+	rec = postChat(t, `{"model":"gpt-4o","previous_response_id":"missing","messages":[{"role":"user","content":"x"}]}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown previous_response_id status = %d, want 404", rec.Code)
+	}
+}
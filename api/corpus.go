@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	corpus "you2api/corpus"
+	sse "you2api/internal/sse"
+	logger "you2api/logger"
+)
+
+// 回归测试语料（CORPUS_DIR、CORPUS_SAMPLE_RATE）：抽中的补全请求在结束后保存请求体、
+// 上游的原始事件流与客户端收到的响应。事件流按账号信息清理的规则（见 pii.go）脱敏并去掉所有邮箱地址；
+// 请求或响应中出现需要脱敏的内容时不保存。请求头只保留影响响应内容的几个，不保存 Authorization 与 Cookie。
+// 只保存一次上游尝试即成功的补全，重试拼接的响应无法用单个事件流重放。
+// ReplayFixture 用当前构建重放一条语料，api/corpus_test.go 对 testdata/corpus 中的语料逐条比较。
+
+// maxCorpusBytes 是单条语料中事件流与响应各自的最大字节数，超出时不保存。
+const maxCorpusBytes = 4 << 20
+
+// corpusHeaders 是保存到语料中的请求头。
+var corpusHeaders = []string{"Accept", "Accept-Language"}
+
+// corpusRecorder 记录单个被抽中的补全。为 nil 时不做任何处理。
+type corpusRecorder struct {
+	request json.RawMessage
+	headers map[string]string
+
+	mu       sync.Mutex
+	streams  []*bytes.Buffer // 每次上游尝试的事件流
+	response bytes.Buffer
+	overflow bool
+}
+
+type corpusRecorderKey struct{}
+
+// corpusReplayKey 在请求上下文中携带重放时上游返回的事件流。
+type corpusReplayKey struct{}
+
+// newCorpusRecorder 按抽样比例决定是否记录本次补全，未抽中或未开启时返回 nil。
+func newCorpusRecorder(r *http.Request, body []byte) *corpusRecorder {
+	conf := currentConfig().Corpus
+	if conf.Dir == "" || conf.SampleRate <= 0 || rand.Float64() >= conf.SampleRate {
+		return nil
+	}
+	if _, replaying := r.Context().Value(corpusReplayKey{}).(string); replaying {
+		return nil
+	}
+	headers := make(map[string]string)
+	for _, name := range corpusHeaders {
+		if v := r.Header.Get(name); v != "" {
+			headers[name] = v
+		}
+	}
+	return &corpusRecorder{request: append(json.RawMessage{}, body...), headers: headers}
+}
+
+// context 让上游请求通过 corpusTransport 记录事件流。
+func (rec *corpusRecorder) context(ctx context.Context) context.Context {
+	if rec == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, corpusRecorderKey{}, rec)
+}
+
+// writer 包装 ResponseWriter，记录客户端收到的响应。
+func (rec *corpusRecorder) writer(w http.ResponseWriter) http.ResponseWriter {
+	if rec == nil {
+		return w
+	}
+	return &corpusResponseWriter{ResponseWriter: w, rec: rec}
+}
+
+// save 在补全成功时脱敏并保存语料。
+func (rec *corpusRecorder) save(id string, err error) {
+	if rec == nil || err != nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.overflow || len(rec.streams) != 1 {
+		return
+	}
+	// 请求或响应本身需要脱敏时，脱敏后的内容与 usage、引用位置等派生字段不再一致，无法作为语料
+	redact := newCorpusRedactor(rec.streams[0].String())
+	request, response := redact(string(rec.request)), rec.response.String()
+	if request != string(rec.request) || redact(response) != response {
+		logger.L().Debug("补全内容包含账号信息或邮箱地址，不保存为回归测试语料", zap.String("request_id", id))
+		return
+	}
+	fixture := &corpus.Fixture{
+		Captured: time.Now(),
+		Headers:  rec.headers,
+		Request:  rec.request,
+		Upstream: redact(rec.streams[0].String()),
+		Response: response,
+	}
+	path, saveErr := corpus.Save(currentConfig().Corpus.Dir, id, fixture)
+	if saveErr != nil {
+		logger.L().Warn("保存回归测试语料失败", zap.String("error", saveErr.Error()))
+		return
+	}
+	logger.L().Debug("已保存回归测试语料", zap.String("request_id", id), zap.String("path", path))
+}
+
+// newCorpusRedactor 从上游事件流中学习账号信息，返回对语料文本脱敏的函数。
+func newCorpusRedactor(stream string) func(string) string {
+	scrubber := newPIIScrubber(true)
+	reader := sse.NewReader(strings.NewReader(stream))
+	for {
+		ev, err := reader.Next()
+		if errors.Is(err, sse.ErrEventTooLarge) {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if ev.Event != "youChatToken" && ev.Event != "youChatUpdate" {
+			scrubber.learn(ev.Data)
+		}
+	}
+	return func(text string) string {
+		return emailPattern.ReplaceAllString(scrubber.scrub(text), redactedPlaceholder)
+	}
+}
+
+func (rec *corpusRecorder) addStream() *bytes.Buffer {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	buf := &bytes.Buffer{}
+	rec.streams = append(rec.streams, buf)
+	return buf
+}
+
+func (rec *corpusRecorder) write(buf *bytes.Buffer, p []byte) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if buf.Len()+len(p) > maxCorpusBytes {
+		rec.overflow = true
+		return
+	}
+	buf.Write(p)
+}
+
+// corpusResponseWriter 在写出响应的同时记录响应体。
+type corpusResponseWriter struct {
+	http.ResponseWriter
+	rec *corpusRecorder
+}
+
+func (cw *corpusResponseWriter) Write(p []byte) (int, error) {
+	cw.rec.write(&cw.rec.response, p)
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *corpusResponseWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// corpusStreamBody 在读取上游响应体的同时记录事件流。
+type corpusStreamBody struct {
+	io.ReadCloser
+	rec *corpusRecorder
+	buf *bytes.Buffer
+}
+
+func (b *corpusStreamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.rec.write(b.buf, p[:n])
+	}
+	return n, err
+}
+
+// isChatStreamRequest 判断是否是 You.com 的补全请求（streamingSearch）。
+func isChatStreamRequest(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/streamingSearch")
+}
+
+// corpusTransport 记录被抽中的补全的上游事件流；重放语料时直接返回记录的事件流，不连接上游。
+type corpusTransport struct {
+	base http.RoundTripper
+}
+
+func (t *corpusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if stream, ok := req.Context().Value(corpusReplayKey{}).(string); ok {
+		if !isChatStreamRequest(req) {
+			return nil, fmt.Errorf("corpus replay has no recording for %s", req.URL.Path)
+		}
+		return mockResponse(req, "text/event-stream", stream), nil
+	}
+	resp, err := t.base.RoundTrip(req)
+	rec, ok := req.Context().Value(corpusRecorderKey{}).(*corpusRecorder)
+	if err != nil || !ok || !isChatStreamRequest(req) {
+		return resp, err
+	}
+	resp.Body = &corpusStreamBody{ReadCloser: resp.Body, rec: rec, buf: rec.addStream()}
+	return resp, nil
+}
+
+// ReplayFixture 用当前构建处理语料中的请求，上游返回记录的事件流，返回规范化后的新响应与记录的响应。
+func ReplayFixture(f *corpus.Fixture) (replayed, recorded string) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(f.Request))
+	req = req.WithContext(context.WithValue(req.Context(), corpusReplayKey{}, f.Upstream))
+	req.Header.Set("Authorization", "Bearer corpus-replay")
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range f.Headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	Handler(w, req)
+	return corpus.Normalize(w.Body.String()), corpus.Normalize(f.Response)
+}
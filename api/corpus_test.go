@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"os"
+	"testing"
+
+	audit "you2api/audit"
+	corpus "you2api/corpus"
+)
+
+// TestCorpusReplay 重放回归测试语料并与记录的响应比较。默认使用 testdata/corpus，
+// 设置 CORPUS_REPLAY_DIR 可以重放线上采集的语料（CORPUS_DIR）。
+func TestCorpusReplay(t *testing.T) {
+	dir := os.Getenv("CORPUS_REPLAY_DIR")
+	if dir == "" {
+		dir = "testdata/corpus"
+	}
+	fixtures, err := corpus.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fixtures {
+		t.Run(f.Name, func(t *testing.T) {
+			replayed, recorded := ReplayFixture(f)
+			if replayed != recorded {
+				t.Errorf("response differs from the recording:\n%s", audit.Diff(recorded, replayed))
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logger "you2api/logger"
+
+	"go.uber.org/zap"
+)
+
+// 每日汇总：没有完整监控系统的小规模部署也能了解服务情况。汇总包括补全请求数、错误率、
+// 请求最多的模型、token 用量、p95 耗时与账号池事件（冷却、无可用账号），
+// 每天在 DAILY_SUMMARY_AT（本地时间 HH:MM，为空时不自动发送）写入日志，
+// 配置了 DAILY_SUMMARY_WEBHOOK_URL 时同时以 JSON POST 到 webhook（可以接入邮件或聊天通知）。
+// 发送后重新计数；GET /admin/summary 随时查看当前周期的汇总。统计只保存在内存中，重启后清零。
+
+// summaryLatencySamples 是用于计算 p95 的耗时样本数，超出后按蓄水池抽样替换。
+const summaryLatencySamples = 4096
+
+// summaryTopModels 是汇总中列出的模型数。
+const summaryTopModels = 5
+
+// modelUsage 是单个模型在汇总周期内的用量。
+type modelUsage struct {
+	Model            string `json:"model"`
+	Requests         int64  `json:"requests"`
+	Errors           int64  `json:"errors"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+}
+
+// UsageSummary 是一个汇总周期的统计。
+type UsageSummary struct {
+	From              time.Time        `json:"from"`
+	To                time.Time        `json:"to"`
+	Requests          int64            `json:"requests"`
+	Errors            int64            `json:"errors"`
+	ErrorRate         float64          `json:"error_rate"`
+	ClientDisconnects int64            `json:"client_disconnects"`
+	PromptTokens      int64            `json:"prompt_tokens"`
+	CompletionTokens  int64            `json:"completion_tokens"`
+	P95LatencyMS      int64            `json:"p95_latency_ms"`
+	TopModels         []modelUsage     `json:"top_models"`
+	PoolIncidents     map[string]int64 `json:"pool_incidents,omitempty"`
+}
+
+// usageSummaryTracker 累积当前汇总周期的统计。
+type usageSummaryTracker struct {
+	mu          sync.Mutex
+	from        time.Time
+	requests    int64
+	errors      int64
+	disconnects int64
+	models      map[string]*modelUsage
+	latencies   []time.Duration
+	seen        int64 // 周期内的耗时样本总数，用于蓄水池抽样
+	incidents   map[string]int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var dailySummary = newUsageSummaryTracker(time.Now())
+
+func newUsageSummaryTracker(from time.Time) *usageSummaryTracker {
+	return &usageSummaryTracker{from: from, models: make(map[string]*modelUsage), incidents: make(map[string]int64)}
+}
+
+// record 记录一次补全。客户端主动断开不计入错误。
+func (t *usageSummaryTracker) record(model string, d time.Duration, promptTokens, completionTokens int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage, ok := t.models[model]
+	if !ok {
+		usage = &modelUsage{Model: model}
+		t.models[model] = usage
+	}
+	t.requests++
+	usage.Requests++
+	usage.PromptTokens += int64(promptTokens)
+	usage.CompletionTokens += int64(completionTokens)
+	switch {
+	case errors.Is(err, errClientDisconnected):
+		t.disconnects++
+	case err != nil:
+		t.errors++
+		usage.Errors++
+	}
+
+	t.seen++
+	if len(t.latencies) < summaryLatencySamples {
+		t.latencies = append(t.latencies, d)
+	} else if i := rand.Int63n(t.seen); i < summaryLatencySamples {
+		t.latencies[i] = d
+	}
+}
+
+// recordIncident 记录一次账号池事件，如 cooldown_rate_limited、no_account_available。
+func (t *usageSummaryTracker) recordIncident(kind string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.incidents[kind]++
+}
+
+// snapshot 返回截至 now 的汇总。
+func (t *usageSummaryTracker) snapshot(now time.Time) UsageSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := UsageSummary{
+		From:              t.from,
+		To:                now,
+		Requests:          t.requests,
+		Errors:            t.errors,
+		ClientDisconnects: t.disconnects,
+		TopModels:         []modelUsage{},
+	}
+	if t.requests > 0 {
+		s.ErrorRate = float64(t.errors) / float64(t.requests)
+	}
+	for _, usage := range t.models {
+		s.PromptTokens += usage.PromptTokens
+		s.CompletionTokens += usage.CompletionTokens
+		s.TopModels = append(s.TopModels, *usage)
+	}
+	sort.Slice(s.TopModels, func(i, j int) bool {
+		a, b := s.TopModels[i], s.TopModels[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Model < b.Model
+	})
+	if len(s.TopModels) > summaryTopModels {
+		s.TopModels = s.TopModels[:summaryTopModels]
+	}
+	if n := len(t.latencies); n > 0 {
+		sorted := append([]time.Duration(nil), t.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		s.P95LatencyMS = sorted[(n*95+99)/100-1].Milliseconds()
+	}
+	if len(t.incidents) > 0 {
+		s.PoolIncidents = make(map[string]int64, len(t.incidents))
+		for kind, n := range t.incidents {
+			s.PoolIncidents[kind] = n
+		}
+	}
+	return s
+}
+
+// rotate 返回截至 now 的汇总并开始新的周期。
+func (t *usageSummaryTracker) rotate(now time.Time) UsageSummary {
+	s := t.snapshot(now)
+	fresh := newUsageSummaryTracker(now)
+	t.mu.Lock()
+	t.from, t.requests, t.errors, t.disconnects = fresh.from, 0, 0, 0
+	t.models, t.incidents = fresh.models, fresh.incidents
+	t.latencies, t.seen = nil, 0
+	t.mu.Unlock()
+	return s
+}
+
+// start 按 DAILY_SUMMARY_AT 每天发送一次汇总，未配置时不做任何处理。
+func (t *usageSummaryTracker) start() {
+	at := currentConfig().DailySummaryAt
+	if at == "" {
+		return
+	}
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		logger.L().Warn("DAILY_SUMMARY_AT 格式无效，每日汇总未启用", zap.String("value", at))
+		return
+	}
+	t.stop, t.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(t.done)
+		for {
+			timer := time.NewTimer(time.Until(nextDailyRun(time.Now(), clock.Hour(), clock.Minute())))
+			select {
+			case <-timer.C:
+				emitUsageSummary(t.rotate(time.Now()))
+			case <-t.stop:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// shutdown 停止定时发送。
+func (t *usageSummaryTracker) shutdown() {
+	if t.stop == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+	t.stop = nil
+}
+
+// nextDailyRun 返回 now 之后下一个本地时间 hour:minute。
+func nextDailyRun(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// emitUsageSummary 把汇总写入日志，并在配置了 webhook 时发送。
+func emitUsageSummary(s UsageSummary) {
+	models := make([]string, 0, len(s.TopModels))
+	for _, m := range s.TopModels {
+		models = append(models, m.Model)
+	}
+	logger.L().Info("每日汇总",
+		zap.Time("from", s.From),
+		zap.Time("to", s.To),
+		zap.Int64("requests", s.Requests),
+		zap.Float64("error_rate", s.ErrorRate),
+		zap.Int64("prompt_tokens", s.PromptTokens),
+		zap.Int64("completion_tokens", s.CompletionTokens),
+		zap.Int64("p95_latency_ms", s.P95LatencyMS),
+		zap.String("top_models", strings.Join(models, ",")),
+		zap.Any("pool_incidents", s.PoolIncidents),
+	)
+
+	webhook := currentConfig().DailySummaryWebhookURL
+	if webhook == "" {
+		return
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"kind": "daily_summary", "summary": s})
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		logger.L().Warn("发送每日汇总 webhook 失败", zap.Error(err))
+		return
+	}
+	resp.Body.Close()
+}
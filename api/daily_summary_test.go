@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUsageSummary(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := newUsageSummaryTracker(start)
+	tr.record("gpt_4o", 100*time.Millisecond, 10, 20, nil)
+	tr.record("gpt_4o", 300*time.Millisecond, 10, 0, errors.New("boom"))
+	tr.record("claude_3_opus", 200*time.Millisecond, 5, 5, errClientDisconnected)
+	tr.recordIncident("cooldown_rate_limited")
+
+	s := tr.rotate(start.Add(time.Hour))
+	if s.Requests != 3 || s.Errors != 1 || s.ClientDisconnects != 1 || s.ErrorRate != 1.0/3 {
+		t.Errorf("counts = %+v", s)
+	}
+	if s.PromptTokens != 25 || s.CompletionTokens != 25 || s.P95LatencyMS != 300 {
+		t.Errorf("tokens/latency = %+v", s)
+	}
+	if len(s.TopModels) != 2 || s.TopModels[0].Model != "gpt_4o" || s.TopModels[0].Errors != 1 {
+		t.Errorf("top models = %+v", s.TopModels)
+	}
+	if s.PoolIncidents["cooldown_rate_limited"] != 1 {
+		t.Errorf("incidents = %v", s.PoolIncidents)
+	}
+
+	if next := tr.snapshot(start.Add(2 * time.Hour)); next.Requests != 0 || !next.From.Equal(start.Add(time.Hour)) {
+		t.Errorf("after rotate = %+v", next)
+	}
+}
+
+func TestNextDailyRun(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC)
+	if got := nextDailyRun(now, 9, 0); !got.Equal(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("later today = %v", got)
+	}
+	if got := nextDailyRun(now, 8, 30); !got.Equal(time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("same minute = %v", got)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// activeStreams 记录当前正在进行的流式响应数量。
+var activeStreams atomic.Int64
+
+// processStart 用于在运行时信息中报告进程运行时长。
+var processStart = time.Now()
+
+// handleDebug 处理 /debug/ 下的调试接口：
+//
+//	/debug/pprof/...   标准 net/http/pprof 接口
+//	/debug/goroutines  全部 goroutine 的调用栈（文本）
+//	/debug/runtime     内存、GC、goroutine 与活跃流数量
+func handleDebug(w http.ResponseWriter, r *http.Request) {
+	w, done, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+
+	switch path := r.URL.Path; {
+	case path == "/debug/pprof/cmdline":
+		pprof.Cmdline(w, r)
+	case path == "/debug/pprof/profile":
+		pprof.Profile(w, r)
+	case path == "/debug/pprof/symbol":
+		pprof.Symbol(w, r)
+	case path == "/debug/pprof/trace":
+		pprof.Trace(w, r)
+	case strings.HasPrefix(path, "/debug/pprof/"):
+		// pprof.Index 依赖 /debug/pprof/ 前缀解析 profile 名称
+		pprof.Index(w, r)
+	case path == "/debug/goroutines":
+		pprof.Handler("goroutine").ServeHTTP(w, withQuery(r, "debug", "2"))
+	case path == "/debug/runtime":
+		handleRuntimeStats(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// withQuery 返回设置了指定查询参数的请求副本。
+func withQuery(r *http.Request, key, value string) *http.Request {
+	clone := r.Clone(r.Context())
+	q := clone.URL.Query()
+	q.Set(key, value)
+	clone.URL.RawQuery = q.Encode()
+	return clone
+}
+
+func handleRuntimeStats(w http.ResponseWriter) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"uptime_seconds": int64(time.Since(processStart).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"active_streams": activeStreams.Load(),
+		"sessions":       sessions.lru.len(),
+		"conversations":  conversations.lru.len(),
+		"heap_alloc":     mem.HeapAlloc,
+		"heap_inuse":     mem.HeapInuse,
+		"heap_objects":   mem.HeapObjects,
+		"sys":            mem.Sys,
+		"num_gc":         mem.NumGC,
+		"gc_pause_total": time.Duration(mem.PauseTotalNs).String(),
+		"go_version":     runtime.Version(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+	})
+}
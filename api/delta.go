@@ -0,0 +1,126 @@
+package handler
+
+// 部分模型不发送增量 token，而是通过 youChatUpdate 事件反复发送到目前为止的完整回答，
+// 并且可能修改已经发送过的中间部分。简单的后缀比较在这种情况下会重复或回退客户端已收到的文本，
+// 因此这里用 Myers 差分算法把上一次的快照对齐到新快照上，只发送对齐点之后的新内容。
+
+// maxDiffEdits 限制差分的编辑距离，超过时退化为按长度对齐，避免大段改写消耗过多内存。
+const maxDiffEdits = 256
+
+// 差分操作：保留、删除（仅在旧文本中）、插入（仅在新文本中）。
+const (
+	diffEqual  = '='
+	diffDelete = '-'
+	diffInsert = '+'
+)
+
+// cumulativeDiffer 把累积快照转换为追加式的增量。
+// 差分的基准是客户端已经收到的文本而不是上一个快照，这样上游先回退再恢复时也不会重复发送。
+type cumulativeDiffer struct {
+	sent []rune
+}
+
+// push 处理一个新快照，返回需要追加发送给客户端的文本。
+// 已发送部分的修改无法撤回，只会被跳过；快照变短时不发送任何内容。
+func (c *cumulativeDiffer) push(snapshot string) string {
+	next := []rune(snapshot)
+	end := alignEnd(c.sent, next)
+	if end >= len(next) {
+		return ""
+	}
+	c.sent = append(c.sent, next[end:]...)
+	return string(next[end:])
+}
+
+// alignEnd 返回旧文本末尾在新文本中对应的位置。
+func alignEnd(a, b []rune) int {
+	if len(b) >= len(a) && string(b[:len(a)]) == string(a) {
+		return len(a) // 纯追加，最常见的情况
+	}
+	ops, ok := diffRunes(a, b, maxDiffEdits)
+	if !ok {
+		return min(len(a), len(b))
+	}
+	ia, ib := 0, 0
+	for _, op := range ops {
+		if ia == len(a) {
+			break // 剩余的都是追加在末尾的插入
+		}
+		switch op {
+		case diffEqual:
+			ia++
+			ib++
+		case diffDelete:
+			ia++
+		case diffInsert:
+			ib++
+		}
+	}
+	return ib
+}
+
+// diffRunes 用 Myers O(ND) 算法计算把 a 变为 b 的最短编辑脚本，每个操作对应一个字符。
+// 编辑距离超过 maxEdits 时返回 false。
+func diffRunes(a, b []rune, maxEdits int) ([]byte, bool) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // 向下：插入
+			} else {
+				x = v[offset+k-1] + 1 // 向右：删除
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(trace, offset, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrackDiff 根据每一轮的 V 数组从终点回溯出编辑脚本。
+func backtrackDiff(trace [][]int, offset, x, y int) []byte {
+	var ops []byte
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffEqual)
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffInsert)
+			} else {
+				ops = append(ops, diffDelete)
+			}
+			x, y = prevX, prevY
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,42 @@
+package handler
+
+import "testing"
+
+func TestDiffRunes(t *testing.T) {
+	tests := []struct{ a, b, want string }{
+		{"", "abc", "+++"},
+		{"abc", "", "---"},
+		{"abc", "abc", "==="},
+		{"abc", "abxc", "==+="},
+		{"abxc", "abc", "==-="},
+	}
+	for _, tt := range tests {
+		ops, ok := diffRunes([]rune(tt.a), []rune(tt.b), maxDiffEdits)
+		if !ok || string(ops) != tt.want {
+			t.Errorf("diffRunes(%q, %q) = %q, want %q", tt.a, tt.b, ops, tt.want)
+		}
+	}
+}
+
+func TestCumulativeDiffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots []string
+		want      string
+	}{
+		{"append", []string{"Hello", "Hello wor", "Hello world"}, "Hello world"},
+		{"mid-string edit", []string{"The quick fox", "The quikc fox jumps", "The quick fox jumps over"}, "The quick fox jumps over"},
+		{"regression", []string{"Hello world", "Hello", "Hello world!"}, "Hello world!"},
+		{"multibyte", []string{"你好", "你们好，世界"}, "你好，世界"},
+	}
+	for _, tt := range tests {
+		var c cumulativeDiffer
+		got := ""
+		for _, s := range tt.snapshots {
+			got += c.push(s)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errClientDisconnected 表示客户端在补全完成前断开了连接。
+var errClientDisconnected = errors.New("client disconnected")
+
+// disconnectKey 用于在请求上下文中携带 *disconnector。
+type disconnectKey struct{}
+
+// disconnector 在客户端断开时取消上游请求，只处理第一次断开。
+type disconnector struct {
+	cancel context.CancelCauseFunc
+	once   sync.Once
+
+	mu   sync.Mutex
+	hold func(cancel func()) bool
+}
+
+func (d *disconnector) disconnect() {
+	d.once.Do(func() {
+		d.mu.Lock()
+		hold := d.hold
+		d.mu.Unlock()
+		cancel := func() { d.cancel(errClientDisconnected) }
+		if hold != nil && hold(cancel) {
+			return
+		}
+		cancel()
+	})
+}
+
+// withDisconnect 返回一个在客户端断开时以 errClientDisconnected 取消的上下文，上游请求基于该上下文构造，
+// 客户端断开后立即中止，不再消耗账号额度。服务器检测到连接关闭时会取消请求上下文，
+// 流式响应写入失败时由 markDisconnected 提前取消。返回的函数必须在请求结束时调用。
+func withDisconnect(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(parent))
+	d := &disconnector{cancel: cancel}
+	stop := context.AfterFunc(parent, d.disconnect)
+	return context.WithValue(ctx, disconnectKey{}, d), func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// holdDisconnect 设置客户端断开时的处理函数：hold 返回 true 时由它负责在之后调用 cancel（或不再调用），
+// 上游请求继续进行。流式响应在等待客户端断线重连时使用，见 transcripts.go。
+func holdDisconnect(ctx context.Context, hold func(cancel func()) bool) {
+	if d, ok := ctx.Value(disconnectKey{}).(*disconnector); ok {
+		d.mu.Lock()
+		d.hold = hold
+		d.mu.Unlock()
+	}
+}
+
+// markDisconnected 在写入响应失败时把请求标记为客户端已断开，并取消上游请求。
+func markDisconnected(ctx context.Context) {
+	if d, ok := ctx.Value(disconnectKey{}).(*disconnector); ok {
+		d.disconnect()
+	}
+}
+
+// clientDisconnected 报告请求是否因客户端断开而被取消。
+func clientDisconnected(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errClientDisconnected)
+}
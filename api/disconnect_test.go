@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	upstream "you2api/internal/upstream"
+)
+
+func TestWithDisconnect(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, release := withDisconnect(parent)
+	defer release()
+
+	cancelParent()
+	<-ctx.Done()
+	if !clientDisconnected(ctx) {
+		t.Errorf("cause = %v, want errClientDisconnected", context.Cause(ctx))
+	}
+
+	// 请求正常结束时取消上下文，但不算作客户端断开
+	ctx, release = withDisconnect(context.Background())
+	release()
+	<-ctx.Done()
+	if clientDisconnected(ctx) {
+		t.Error("release must not report a client disconnect")
+	}
+}
+
+func TestHoldDisconnect(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, release := withDisconnect(parent)
+	defer release()
+
+	held := make(chan func(), 1)
+	holdDisconnect(ctx, func(cancel func()) bool {
+		held <- cancel
+		return true
+	})
+	cancelParent()
+	cancel := <-held
+	if ctx.Err() != nil {
+		t.Fatal("held disconnect must keep the upstream request running")
+	}
+	cancel()
+	<-ctx.Done()
+	if !clientDisconnected(ctx) {
+		t.Errorf("cause = %v, want errClientDisconnected", context.Cause(ctx))
+	}
+}
+
+func TestClientDisconnectCancelsUpstream(t *testing.T) {
+	upstreamDone := make(chan struct{}, 1)
+	prev := upstreamClient
+	upstreamClient = func(timeout time.Duration) upstream.Doer {
+		return &http.Client{Timeout: timeout, Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := (&mockTransport{style: "lorem", delay: 20 * time.Millisecond}).RoundTrip(req)
+			go func() {
+				<-req.Context().Done()
+				upstreamDone <- struct{}{}
+			}()
+			return resp, err
+		})}
+	}
+	t.Cleanup(func() { upstreamClient = prev })
+	// 流式响应默认会等待客户端断线重连，这里关闭等待
+	prevConf := currentConfig()
+	conf := *prevConf
+	conf.StreamResume.GraceMS = 0
+	setConfig(&conf)
+	t.Cleanup(func() { setConfig(prevConf) })
+
+	for _, stream := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		body := `{"model":"gpt-4o","stream":` + strconv.FormatBool(stream) + `,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+testDSToken)
+		time.AfterFunc(60*time.Millisecond, cancel)
+
+		start := time.Now()
+		Handler(httptest.NewRecorder(), req)
+		// lorem 共约 20 个事件，完整读取需要 400ms 以上
+		if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+			t.Errorf("stream=%v: handler returned after %v, upstream was not aborted", stream, elapsed)
+		}
+		select {
+		case <-upstreamDone:
+		case <-time.After(time.Second):
+			t.Errorf("stream=%v: upstream request context was never canceled", stream)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+
+	logger "you2api/logger"
+)
+
+// dryRunResponse 描述一次 dry run 的结果：请求经过校验、模型映射与历史构建后，
+// 原本会发送给 You.com 的参数（凭据已脱敏）。
+type dryRunResponse struct {
+	Object                string         `json:"object"`
+	RequestedModel        string         `json:"requested_model"`
+	UpstreamModel         string         `json:"upstream_model"`
+	ResponseModel         string         `json:"response_model"`
+	KeyAlias              bool           `json:"key_alias"`
+	VirtualModel          string         `json:"virtual_model,omitempty"`
+	Stream                bool           `json:"stream"`
+	MessageCount          int            `json:"message_count"`
+	EstimatedPromptTokens int            `json:"estimated_prompt_tokens"`
+	MockMode              bool           `json:"mock_mode"`
+	Upstream              dryRunUpstream `json:"upstream"`
+}
+
+type dryRunUpstream struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Query   map[string][]string `json:"query"`
+	Headers map[string]string   `json:"headers"`
+}
+
+// writeDryRun 返回 dry run 结果而不调用 You.com。
+func writeDryRun(w http.ResponseWriter, openAIReq OpenAIRequest, youReq *http.Request, rs *requestState) {
+	resp := dryRunResponse{
+		Object:                "chat.completion.dry_run",
+		RequestedModel:        rs.RequestedModel,
+		UpstreamModel:         youReq.URL.Query().Get("selectedAiModel"),
+		ResponseModel:         rs.Model,
+		KeyAlias:              rs.Aliased,
+		Stream:                openAIReq.Stream,
+		MessageCount:          len(openAIReq.Messages),
+		EstimatedPromptTokens: countMessagesTokens(youReq.URL.Query().Get("selectedAiModel"), openAIReq.Messages),
+		MockMode:              currentConfig().MockMode,
+		Upstream: dryRunUpstream{
+			Method:  youReq.Method,
+			URL:     youReq.URL.Scheme + "://" + youReq.URL.Host + youReq.URL.Path,
+			Query:   youReq.URL.Query(),
+			Headers: logger.ScrubHeaders(youReq.Header),
+		},
+	}
+	if rs.VM != nil {
+		resp.VirtualModel = rs.VM.Name
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
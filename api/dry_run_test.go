@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	upstream "you2api/internal/upstream"
+)
+
+func TestDryRun(t *testing.T) {
+	// dry run 不应调用上游
+	prev := upstreamClient
+	upstreamClient = func(time.Duration) upstream.Doer {
+		t.Fatal("dry run contacted the upstream")
+		return nil
+	}
+	t.Cleanup(func() { upstreamClient = prev })
+
+	rec := postChat(t, `{"model":"gpt-4o","dry_run":true,"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hello"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), testDSToken) {
+		t.Error("dry run response leaked the DS token")
+	}
+
+	var resp dryRunResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Object != "chat.completion.dry_run" || resp.RequestedModel != "gpt-4o" || resp.MessageCount != 2 {
+		t.Errorf("resp = %+v", resp)
+	}
+	if resp.UpstreamModel != mapModelName("gpt-4o") {
+		t.Errorf("upstream_model = %q, want %q", resp.UpstreamModel, mapModelName("gpt-4o"))
+	}
+	if got := resp.Upstream.Query["q"]; len(got) != 1 || got[0] != "hello" {
+		t.Errorf("query q = %v", got)
+	}
+	if resp.EstimatedPromptTokens <= 0 {
+		t.Errorf("estimated_prompt_tokens = %d", resp.EstimatedPromptTokens)
+	}
+}
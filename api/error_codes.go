@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	apierror "you2api/apierror"
+)
+
+// handleErrorCodes 处理 GET /v1/error_codes，返回错误响应中可能出现的全部 code 及其含义，
+// 便于客户端按错误码分支处理。目录与稳定性约定见 apierror.Catalog。
+func handleErrorCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		clientError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": apierror.Catalog})
+}
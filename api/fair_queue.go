@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"container/heap"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	metrics "you2api/metrics"
+	pool "you2api/pool"
+)
+
+// 账号池公平排队（FAIR_QUEUE_ENABLED）：多个租户共用一个账号池时，没有空闲账号的请求排队等待，
+// 账号名额释放后按加权公平排队（WFQ）放行：每个请求按 max(虚拟时间, 该租户上一个请求的完成标记) + 1/权重
+// 得到完成标记，标记最小的请求先拿到账号。一个租户一次性排入大量请求时，这些请求的标记依次增大，
+// 其他租户随后到达的请求会插到它们前面。
+// 租户取自凭据：JWT 认证的用户（见 oidc.go），否则是 API key 的 key ID。只有 TRUSTED_TENANT_KEYS 中的 key
+// （如在前面完成用户认证的内部网关）可以通过 X-Tenant-ID 请求头指定租户，其他客户端发送的请求头被忽略，
+// 不能冒充权重更高的租户。TENANT_WEIGHTS 为租户设置权重。各租户的等待时间记录在 fair_queue_wait_seconds 指标中：
+// 只有 TENANT_WEIGHTS 中列出的租户单独作为标签，其余租户合并为 other，避免网关转发的租户制造无限多的时间序列。
+// 只有通过校验、即将请求上游的补全才会排队（见 handleChatCompletions），无效请求、异步请求与 dry run
+// 在排队之前就已返回，不会占用 FAIR_QUEUE_MAX_WAITING 的名额，也不会在队列中等到超时才收到 400。
+
+// errQueueFull 表示排队的请求已达到 FAIR_QUEUE_MAX_WAITING。
+var errQueueFull = errors.New("too many requests are waiting for a pool account")
+
+// tenantHeader 是 TRUSTED_TENANT_KEYS 中的 key 指定租户的请求头。
+const tenantHeader = "X-Tenant-ID"
+
+// fairQueuePoll 是排队请求重新检查账号的间隔，用于发现冷却结束的账号。
+const fairQueuePoll = 250 * time.Millisecond
+
+type tenantKey struct{}
+
+// withTenant 把请求的租户放入上下文。
+func withTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// requestTenant 返回请求的租户。
+func requestTenant(r *http.Request, apiKey string) string {
+	if p := principalFrom(r.Context()); p != nil {
+		return p.key
+	}
+	if tenant := strings.TrimSpace(r.Header.Get(tenantHeader)); tenant != "" && trustedTenantKey(apiKey) {
+		return tenant
+	}
+	return keyID(apiKey)
+}
+
+// trustedTenantKey 报告 apiKey 是否在 TRUSTED_TENANT_KEYS 中，即是否可以通过 X-Tenant-ID 指定租户。
+func trustedTenantKey(apiKey string) bool {
+	if apiKey == "" {
+		return false
+	}
+	for _, key := range strings.Split(currentConfig().FairQueue.TrustedTenantKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantFrom 返回上下文中的租户，没有时使用 API key 的 key ID。
+func tenantFrom(ctx context.Context, apiKey string) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		return tenant
+	}
+	if p := principalFrom(ctx); p != nil {
+		return p.key
+	}
+	return keyID(apiKey)
+}
+
+var (
+	tenantWeightsOnce sync.Once
+	tenantWeights     map[string]float64
+)
+
+// otherTenantLabel 是未在 TENANT_WEIGHTS 中列出的租户在指标中的标签。
+const otherTenantLabel = "other"
+
+// getTenantWeights 解析 TENANT_WEIGHTS。
+func getTenantWeights() map[string]float64 {
+	tenantWeightsOnce.Do(func() {
+		raw := currentConfig().FairQueue.TenantWeights
+		if raw == "" {
+			return
+		}
+		if err := json.Unmarshal([]byte(raw), &tenantWeights); err != nil {
+			log.Printf("解析 TENANT_WEIGHTS 失败: %v", err)
+		}
+	})
+	return tenantWeights
+}
+
+// tenantWeight 返回租户在 TENANT_WEIGHTS 中的权重，默认为 1。
+func tenantWeight(tenant string) float64 {
+	if w := getTenantWeights()[tenant]; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// tenantMetricLabel 返回租户在指标中的标签，租户本身只用于排队。
+func tenantMetricLabel(tenant string) string {
+	if _, ok := getTenantWeights()[tenant]; ok {
+		return tenant
+	}
+	return otherTenantLabel
+}
+
+// queueGrant 是分配给排队请求的账号。
+type queueGrant struct {
+	token string
+	lease *tokenLease
+}
+
+// queueWaiter 是一个排队的请求。
+type queueWaiter struct {
+	tenant string
+	tag    float64 // 完成标记
+	seq    uint64  // 标记相同时按到达顺序
+	index  int     // 在堆中的位置，出队后为 -1
+	grant  chan queueGrant
+}
+
+// waiterHeap 是按完成标记排序的最小堆。
+type waiterHeap []*queueWaiter
+
+func (h waiterHeap) Len() int { return len(h) }
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].tag != h[j].tag {
+		return h[i].tag < h[j].tag
+	}
+	return h[i].seq < h[j].seq
+}
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *waiterHeap) Push(x any) {
+	w := x.(*queueWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+func (h *waiterHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
+
+// fairScheduler 保存排队的请求与各租户的完成标记。
+type fairScheduler struct {
+	mu      sync.Mutex
+	vtime   float64            // 虚拟时间：最近一次放行的请求的完成标记
+	finish  map[string]float64 // 各租户最后一个排队请求的完成标记
+	waiting waiterHeap
+	seq     uint64
+}
+
+var fairQueue = newFairScheduler()
+
+func newFairScheduler() *fairScheduler {
+	return &fairScheduler{finish: make(map[string]float64)}
+}
+
+// push 按租户权重计算完成标记并排队，调用方必须持有 mu。
+func (s *fairScheduler) push(tenant string, weight float64) *queueWaiter {
+	start := max(s.vtime, s.finish[tenant])
+	s.seq++
+	w := &queueWaiter{tenant: tenant, tag: start + 1/weight, seq: s.seq, grant: make(chan queueGrant, 1)}
+	s.finish[tenant] = w.tag
+	heap.Push(&s.waiting, w)
+	metrics.FairQueueWaiting.Set(float64(len(s.waiting)))
+	return w
+}
+
+// pop 取出完成标记最小的请求并推进虚拟时间，调用方必须持有 mu。
+func (s *fairScheduler) pop() *queueWaiter {
+	w := heap.Pop(&s.waiting).(*queueWaiter)
+	s.vtime = w.tag
+	if len(s.waiting) == 0 {
+		clear(s.finish) // 队列清空后所有租户重新从虚拟时间开始
+	}
+	metrics.FairQueueWaiting.Set(float64(len(s.waiting)))
+	return w
+}
+
+// acquire 占用一个账号：队列为空且有空闲账号时立即返回，否则排队等待。
+func (s *fairScheduler) acquire(ctx context.Context, p *pool.Pool, tenant string) (string, *tokenLease, error) {
+	start := time.Now()
+	conf := currentConfig().FairQueue
+	// 账号状态可能保存在 Redis 中，占用账号时不持有 mu
+	if !s.queued() {
+		if token, lease, ok := tryAcquireAccount(ctx, p); ok {
+			metrics.FairQueueWaitSeconds.WithLabelValues(tenantMetricLabel(tenant)).Observe(0)
+			return token, s.wrap(lease), nil
+		}
+	}
+	s.mu.Lock()
+	if conf.MaxWaiting > 0 && len(s.waiting) >= conf.MaxWaiting {
+		s.mu.Unlock()
+		return "", nil, errQueueFull
+	}
+	w := s.push(tenant, tenantWeight(tenant))
+	s.mu.Unlock()
+
+	timeout := time.NewTimer(time.Duration(conf.TimeoutMS) * time.Millisecond)
+	defer timeout.Stop()
+	poll := time.NewTicker(fairQueuePoll)
+	defer poll.Stop()
+	for {
+		select {
+		case g := <-w.grant:
+			metrics.FairQueueWaitSeconds.WithLabelValues(tenantMetricLabel(tenant)).Observe(time.Since(start).Seconds())
+			return g.token, g.lease, nil
+		case <-poll.C:
+			s.dispatch()
+		case <-timeout.C:
+			s.cancel(w)
+			return "", nil, errNoReadyAccount
+		case <-ctx.Done():
+			s.cancel(w)
+			return "", nil, ctx.Err()
+		}
+	}
+}
+
+// cancel 把放弃等待的请求移出队列；请求已经分到账号时释放该账号。
+func (s *fairScheduler) cancel(w *queueWaiter) {
+	s.mu.Lock()
+	if w.index >= 0 {
+		heap.Remove(&s.waiting, w.index)
+		metrics.FairQueueWaiting.Set(float64(len(s.waiting)))
+	}
+	s.mu.Unlock()
+	select {
+	case g := <-w.grant:
+		g.lease.release()
+	default:
+	}
+}
+
+// dispatch 在有空闲账号时按完成标记依次放行排队的请求。
+func (s *fairScheduler) dispatch() {
+	p := getTokenPool()
+	if p == nil {
+		return
+	}
+	for s.queued() {
+		token, lease, ok := tryAcquireAccount(context.Background(), p)
+		if !ok {
+			return
+		}
+		// 占用账号期间排队的请求可能已经取消或被其他 dispatch 放行
+		s.mu.Lock()
+		if len(s.waiting) == 0 {
+			s.mu.Unlock()
+			lease.release()
+			return
+		}
+		s.pop().grant <- queueGrant{token: token, lease: s.wrap(lease)}
+		s.mu.Unlock()
+	}
+}
+
+// queued 报告是否有排队的请求。
+func (s *fairScheduler) queued() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.waiting) > 0
+}
+
+// wrap 让 lease 释放名额后立即放行下一个排队的请求。
+func (s *fairScheduler) wrap(lease *tokenLease) *tokenLease {
+	free := lease.free
+	var once sync.Once
+	lease.free = func() {
+		once.Do(func() {
+			free()
+			s.dispatch()
+		})
+	}
+	return lease
+}
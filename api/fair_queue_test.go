@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFairSchedulerOrder(t *testing.T) {
+	s := newFairScheduler()
+	// 租户 a 先一次性排入 4 个请求，b（权重 2）与 c 随后各排入 2 个
+	for i := 0; i < 4; i++ {
+		s.push("a", 1)
+	}
+	s.push("b", 2)
+	s.push("b", 2)
+	s.push("c", 1)
+	s.push("c", 1)
+
+	var order []string
+	for len(s.waiting) > 0 {
+		order = append(order, s.pop().tenant)
+	}
+	want := []string{"b", "a", "b", "c", "a", "c", "a", "a"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if len(s.finish) != 0 {
+		t.Errorf("finish tags not reset after the queue drained: %v", s.finish)
+	}
+}
+
+func TestFairSchedulerCancel(t *testing.T) {
+	s := newFairScheduler()
+	first := s.push("a", 1)
+	s.push("b", 1)
+	s.cancel(first)
+	if w := s.pop(); w.tenant != "b" {
+		t.Errorf("popped %q after cancelling a, want b", w.tenant)
+	}
+}
+
+func TestTenantMetricLabel(t *testing.T) {
+	getTenantWeights()
+	prev := tenantWeights
+	tenantWeights = map[string]float64{"team-a": 2}
+	t.Cleanup(func() { tenantWeights = prev })
+
+	// 网关可以转发任意 X-Tenant-ID，未配置权重的租户不会各自产生时间序列
+	for tenant, want := range map[string]string{"team-a": "team-a", "random-1234": otherTenantLabel, "": otherTenantLabel} {
+		if got := tenantMetricLabel(tenant); got != want {
+			t.Errorf("tenantMetricLabel(%q) = %q, want %q", tenant, got, want)
+		}
+	}
+}
+
+func TestRequestTenant(t *testing.T) {
+	accessKey := withTestPool(t, "pool-token")
+	prev := currentConfig()
+	conf := *prev
+	conf.FairQueue.TrustedTenantKeys = "gateway-key, other-gateway"
+	setConfig(&conf)
+	t.Cleanup(func() { setConfig(prev) })
+
+	tests := []struct {
+		apiKey, header, want string
+	}{
+		// 共用账号池 key 的客户端不能通过请求头冒充其他租户
+		{accessKey, "team-a", keyID(accessKey)},
+		{"client-key", "team-a", keyID("client-key")},
+		{"gateway-key", "team-a", "team-a"},
+		{"other-gateway", " team-b ", "team-b"},
+		{"gateway-key", "", keyID("gateway-key")},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if tt.header != "" {
+			r.Header.Set(tenantHeader, tt.header)
+		}
+		if got := requestTenant(r, tt.apiKey); got != tt.want {
+			t.Errorf("requestTenant(%q, %q) = %q, want %q", tt.apiKey, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFairQueueEnteredAfterValidation(t *testing.T) {
+	withMockUpstream(t, "echo")
+	accessKey := withBusyPool(t)
+	prev := currentConfig()
+	conf := *prev
+	conf.FairQueue.Enabled = true
+	conf.FairQueue.MaxWaiting = 1
+	conf.FairQueue.TimeoutMS = 2000
+	setConfig(&conf)
+	t.Cleanup(func() { setConfig(prev) })
+
+	for _, body := range []string{
+		`{"model":"gpt-4o","messages":"hi"}`,
+		`{"model":"gpt-4o","dry_run":true,"messages":[{"role":"user","content":"hi"}]}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+accessKey)
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		Handler(rec, req)
+		// 账号一直被占用，进入队列的请求要等到 FAIR_QUEUE_TIMEOUT_MS 才会返回
+		if elapsed := time.Since(start); elapsed > time.Second || rec.Code == http.StatusServiceUnavailable {
+			t.Errorf("%s: status %d after %v, request waited in the fair queue", body, rec.Code, elapsed)
+		}
+	}
+}
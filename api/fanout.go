@@ -0,0 +1,302 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	apierror "you2api/apierror"
+	logger "you2api/logger"
+)
+
+// n>1 时并行发起 n 个上游请求，每个 choice 使用各自的序号，流式与非流式响应都支持。
+// 使用账号池时每个 choice 占用账号的一个并发名额（TOKEN_MAX_CONCURRENCY），名额不足时多出的 choice 排队执行。
+//
+// 部分 choice 失败的处理策略（FANOUT_POLICY）：
+//   - best_effort：返回成功的 choice，失败的 choice 记录在 choice_errors 扩展字段中（默认）
+//   - all_or_nothing：任一 choice 失败即整体返回错误。流式响应已发送的内容无法撤回，
+//     只有在所有 choice 都在开始输出前失败时才返回错误响应，其余情况与 best_effort 相同
+const (
+	fanoutBestEffort   = "best_effort"
+	fanoutAllOrNothing = "all_or_nothing"
+)
+
+// choiceSlots 为 n 个 choice 占用账号的并发名额，返回可以同时执行的 choice 数与释放名额的函数。
+// 请求本身已占用一个名额，其余 choice 在账号达到并发上限之前各自再占用一个；未使用账号池时不限制。
+func choiceSlots(ctx context.Context, lease *tokenLease, n int) (int, func()) {
+	if lease == nil {
+		return n, func() {}
+	}
+	var frees []func()
+	for len(frees) < n-1 {
+		free, ok, err := getTokenState().Acquire(ctx, lease.account, currentConfig().TokenState.MaxConcurrency)
+		if err != nil || !ok {
+			break
+		}
+		frees = append(frees, free)
+	}
+	return 1 + len(frees), func() {
+		for _, free := range frees {
+			free()
+		}
+		if len(frees) > 0 && currentConfig().FairQueue.Enabled {
+			fairQueue.dispatch() // 额外的名额释放后放行排队的请求，见 fair_queue.go
+		}
+	}
+}
+
+// runChoices 按账号的并发名额执行 n 个 choice，拿不到名额的 choice 等前面的 choice 结束后复用其名额。
+func runChoices(ctx context.Context, lease *tokenLease, n int, run func(i int)) {
+	parallel, release := choiceSlots(ctx, lease, n)
+	defer release()
+	slots := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			run(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// fanoutError 把失败的 choice 合并为一个错误。
+func fanoutError(failed []ChoiceError) error {
+	messages := make([]string, 0, len(failed))
+	for _, ce := range failed {
+		messages = append(messages, fmt.Sprintf("choice %d: %s", ce.Index, ce.Message))
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// handleFanoutResponse 并行发起 n 个上游请求，按 FANOUT_POLICY 汇总结果，返回第一个成功 choice 的内容。
+func handleFanoutResponse(w http.ResponseWriter, youReq *http.Request, rs *requestState, lease *tokenLease, n int) (string, error) {
+	results := make([]*upstreamResult, n)
+	errs := make([]error, n)
+	runChoices(youReq.Context(), lease, n, func(i int) {
+		results[i], errs[i] = fetchCompletion(youReq.Clone(youReq.Context()))
+	})
+
+	resp := OpenAIResponse{
+		ID:      completionID(youReq.Context()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   rs.Model,
+	}
+	var searchQueries []string
+	var contents []string
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			resp.ChoiceErrors = append(resp.ChoiceErrors, ChoiceError{Index: i, Message: logger.ScrubError(errs[i])})
+			continue
+		}
+		content := newWhitespaceNormalizer(currentConfig().NormalizeWhitespace).normalize(rs.VM.sanitize(newReasoningFilter(rs.ReasoningMode, requestLanguage(youReq)).apply(repairEncoding(results[i].Content))))
+		content = newCodeFenceTagger(currentConfig().TagCodeFences).tag(content)
+		text, toolCalls := rs.Tools.split(content)
+		finishReason := results[i].finishReason()
+		if len(toolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+		resp.Choices = append(resp.Choices, OpenAIChoice{
+			Message: Message{
+				Role:             "assistant",
+				Content:          text,
+				ReasoningContent: rs.reasoningContent(repairEncoding(results[i].Reasoning)),
+				// 各个候选的来源编号互不相同，只在消息中给出注解，不设置顶层的 citations
+				Annotations: citationAnnotations(text, results[i].Sources),
+				ToolCalls:   toolCalls,
+			},
+			Index:        i,
+			FinishReason: finishReason,
+		})
+		searchQueries = appendUnique(searchQueries, results[i].SearchQueries...)
+		contents = append(contents, content)
+	}
+
+	if len(resp.ChoiceErrors) > 0 && (len(resp.Choices) == 0 || currentConfig().FanoutPolicy == fanoutAllOrNothing) {
+		err := fanoutError(resp.ChoiceErrors)
+		clientError(w, youReq, http.StatusBadGateway, apierror.CodeUpstreamError, "%d of %d choices failed: %v", len(resp.ChoiceErrors), n, err)
+		return "", err
+	}
+
+	resp.Usage = rs.usage(contents...)
+	if len(searchQueries) > 0 {
+		resp.ProviderMetadata = &ProviderMetadata{SearchQueries: searchQueries}
+	}
+	resp.ProviderMetadata = withIgnoredParams(withAutoModel(youReq.Context(), resp.ProviderMetadata), rs.IgnoredParams)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		return resp.Choices[0].Message.Content, err
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// handleFanoutStream 并行发起 n 个流式请求，把各个 choice 的块交错写入同一个响应，所有 choice 结束后发送 [DONE]。
+// 在开始输出前失败的 choice 以最后一个带 choice_errors 的块报告，返回第一个成功 choice 的内容。
+func handleFanoutStream(w http.ResponseWriter, youReq *http.Request, rs *requestState, lease *tokenLease, n int) (string, error) {
+	mux := &streamMux{w: w, id: completionID(youReq.Context()), created: time.Now().Unix(), model: rs.Model}
+	writers := make([]*choiceWriter, n)
+	contents := make([]string, n)
+	errs := make([]error, n)
+	for i := range writers {
+		writers[i] = &choiceWriter{mux: mux, index: i, header: make(http.Header)}
+	}
+	runChoices(youReq.Context(), lease, n, func(i int) {
+		choiceRS := *rs
+		choiceRS.Structured = rs.Structured.fork() // 流式校验状态按 choice 区分
+		contents[i], errs[i] = handleStreamingResponse(writers[i], youReq, &choiceRS)
+	})
+
+	var failed, unreported []ChoiceError
+	content, succeeded := "", 0
+	for i, err := range errs {
+		if err != nil {
+			ce := ChoiceError{Index: i, Message: logger.ScrubError(err)}
+			failed = append(failed, ce)
+			if !writers[i].reported {
+				unreported = append(unreported, ce)
+			}
+			continue
+		}
+		if succeeded == 0 {
+			content = contents[i]
+		}
+		succeeded++
+	}
+
+	if succeeded == 0 && !mux.started() {
+		err := fanoutError(failed)
+		status, code := upstreamErrorCode(errs[0])
+		if len(failed) > 1 {
+			status, code = http.StatusBadGateway, apierror.CodeUpstreamError
+		}
+		clientError(w, youReq, status, code, "%d of %d choices failed: %v", len(failed), n, err)
+		return "", err
+	}
+	if len(unreported) > 0 {
+		mux.write(OpenAIStreamResponse{Choices: []Choice{}, ChoiceErrors: unreported})
+	}
+	mux.done()
+	if succeeded == 0 {
+		return "", fanoutError(failed)
+	}
+	return content, nil
+}
+
+// streamMux 串行化多个 choice 对同一个流式响应的写入，第一次写入时设置流式响应头。
+type streamMux struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	id      string
+	created int64
+	model   string
+	begun   bool
+}
+
+// started 判断是否已经开始输出。
+func (m *streamMux) started() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.begun
+}
+
+// write 发送一个块并立即刷新。
+func (m *streamMux) write(chunk OpenAIStreamResponse) error {
+	chunk.ID, chunk.Object, chunk.Created, chunk.Model = m.id, "chat.completion.chunk", m.created, m.model
+	data, _ := json.Marshal(chunk)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.begun {
+		m.w.Header().Set("Content-Type", "text/event-stream")
+		m.w.Header().Set("Cache-Control", "no-cache")
+		m.w.Header().Set("Connection", "keep-alive")
+		m.begun = true
+	}
+	if _, err := fmt.Fprintf(m.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	m.w.(http.Flusher).Flush()
+	return nil
+}
+
+// done 发送 [DONE] 结束标记。
+func (m *streamMux) done() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sendDone(m.w)
+}
+
+// choiceWriter 是单个 choice 的流式响应写入器，把 handleStreamingResponse 写出的块转发到 streamMux：
+// 块中的 choice 序号改为该 choice 的序号，错误块改为 choice_errors，[DONE] 由 handleFanoutStream 统一发送。
+// 开始输出前的错误响应（非 200 状态码）不转发，由 handleFanoutStream 根据返回的错误汇总。
+type choiceWriter struct {
+	mux      *streamMux
+	index    int
+	header   http.Header // 各个 choice 的响应头互不影响，实际的响应头由 streamMux 设置
+	failed   bool
+	pending  []byte // 尚未以空行结束的部分事件
+	reported bool   // 已以 choice_errors 块报告失败
+}
+
+func (c *choiceWriter) Header() http.Header { return c.header }
+
+func (c *choiceWriter) WriteHeader(status int) {
+	if status != http.StatusOK {
+		c.failed = true
+	}
+}
+
+func (c *choiceWriter) Write(p []byte) (int, error) {
+	if c.failed {
+		return len(p), nil
+	}
+	c.pending = append(c.pending, p...)
+	for {
+		end := bytes.Index(c.pending, []byte("\n\n"))
+		if end < 0 {
+			return len(p), nil
+		}
+		frame := string(c.pending[:end])
+		c.pending = c.pending[end+2:]
+		if err := c.forward(frame); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush 不做任何处理，streamMux 在每个块之后刷新。
+func (c *choiceWriter) Flush() {}
+
+// forward 转换并转发一个事件。
+func (c *choiceWriter) forward(frame string) error {
+	data, ok := strings.CutPrefix(frame, "data: ")
+	if !ok || data == "[DONE]" {
+		return nil
+	}
+	var errResp apierror.Response
+	if json.Unmarshal([]byte(data), &errResp) == nil && errResp.Error.Message != "" {
+		c.reported = true
+		return c.mux.write(OpenAIStreamResponse{
+			Choices:      []Choice{},
+			ChoiceErrors: []ChoiceError{{Index: c.index, Message: errResp.Error.Message}},
+		})
+	}
+	var chunk OpenAIStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return nil
+	}
+	for i := range chunk.Choices {
+		chunk.Choices[i].Index = c.index
+	}
+	return c.mux.write(chunk)
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	upstream "you2api/internal/upstream"
+)
+
+func TestChoiceWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	mux := &streamMux{w: rec, id: "chatcmpl-1", created: 1, model: "m"}
+	c := &choiceWriter{mux: mux, index: 2}
+
+	// 一个事件可能分多次写入
+	fmt.Fprint(c, `data: {"id":"x","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"delta":{"content":"hi"},"index":0,"fin`)
+	fmt.Fprint(c, `ish_reason":""}]}`+"\n\n")
+	fmt.Fprint(c, `data: {"error":{"message":"boom","type":"server_error","param":null,"code":"upstream_error"}}`+"\n\n")
+	fmt.Fprint(c, "data: [DONE]\n\n")
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `"content":"hi"},"index":2`) {
+		t.Errorf("choice index not rewritten: %s", body)
+	}
+	if !strings.Contains(body, `"choice_errors":[{"index":2,"message":"boom"}]`) || !c.reported {
+		t.Errorf("error chunk not converted: %s", body)
+	}
+	if strings.Contains(body, "[DONE]") {
+		t.Errorf("per-choice [DONE] forwarded: %s", body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+// flakyTransport 让第 failOn 个聊天请求（从 1 开始）失败，其余请求返回合成回复。
+type flakyTransport struct {
+	mu     sync.Mutex
+	calls  int
+	failOn int
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/api/streamingSearch" {
+		f.mu.Lock()
+		f.calls++
+		fail := f.calls == f.failOn
+		f.mu.Unlock()
+		if fail {
+			return nil, errors.New("connection reset by peer")
+		}
+	}
+	return (&mockTransport{style: "echo"}).RoundTrip(req)
+}
+
+func withFlakyUpstream(t *testing.T, failOn int) {
+	t.Helper()
+	prev := upstreamClient
+	flaky := &flakyTransport{failOn: failOn}
+	upstreamClient = func(timeout time.Duration) upstream.Doer {
+		return &http.Client{Transport: flaky, Timeout: timeout}
+	}
+	t.Cleanup(func() { upstreamClient = prev })
+}
+
+func TestFanoutResponse(t *testing.T) {
+	withMockUpstream(t, "echo")
+	resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","n":3,"messages":[{"role":"user","content":"hi"}]}`))
+	if len(resp.Choices) != 3 || len(resp.ChoiceErrors) != 0 {
+		t.Fatalf("choices = %d, errors = %v", len(resp.Choices), resp.ChoiceErrors)
+	}
+	for i, choice := range resp.Choices {
+		if choice.Index != i || choice.Message.Content != "hi" {
+			t.Errorf("choice %d = %+v", i, choice)
+		}
+	}
+}
+
+func TestFanoutResponsePartialFailure(t *testing.T) {
+	withFlakyUpstream(t, 1)
+	resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","n":2,"messages":[{"role":"user","content":"hi"}]}`))
+	if len(resp.Choices) != 1 || len(resp.ChoiceErrors) != 1 {
+		t.Fatalf("choices = %+v, errors = %+v", resp.Choices, resp.ChoiceErrors)
+	}
+	if resp.Choices[0].Index == resp.ChoiceErrors[0].Index {
+		t.Errorf("failed and succeeded choice share index %d", resp.Choices[0].Index)
+	}
+	if !strings.Contains(resp.ChoiceErrors[0].Message, "connection reset") {
+		t.Errorf("choice error = %q", resp.ChoiceErrors[0].Message)
+	}
+}
+
+func TestFanoutResponseAllOrNothing(t *testing.T) {
+	prev := currentConfig()
+	conf := *prev
+	conf.FanoutPolicy = fanoutAllOrNothing
+	setConfig(&conf)
+	t.Cleanup(func() { setConfig(prev) })
+
+	withFlakyUpstream(t, 2)
+	rec := postChat(t, `{"model":"gpt-4o","n":2,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502; body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestFanoutTooManyChoices(t *testing.T) {
+	withMockUpstream(t, "echo")
+	body := fmt.Sprintf(`{"model":"gpt-4o","n":%d,"messages":[{"role":"user","content":"hi"}]}`, currentConfig().MaxChoices+1)
+	if rec := postChat(t, body); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	apierror "you2api/apierror"
+	features "you2api/features"
+	logger "you2api/logger"
+)
+
+var (
+	featuresOnce sync.Once
+	featureFlags *features.Registry
+)
+
+// getFeatureFlags 返回功能开关注册表，首次调用时从 FEATURE_FLAGS 加载。
+func getFeatureFlags() *features.Registry {
+	featuresOnce.Do(func() {
+		registry, err := features.NewRegistry(currentConfig().FeatureFlags)
+		if err != nil {
+			logger.L().Warn("解析 FEATURE_FLAGS 失败，使用默认值", zap.Error(err))
+			registry, _ = features.NewRegistry("")
+		}
+		featureFlags = registry
+	})
+	return featureFlags
+}
+
+// featureEnabled 报告实验性功能是否在当前部署中开启。
+func featureEnabled(flag features.Flag) bool {
+	return getFeatureFlags().Enabled(flag)
+}
+
+// handleFeatures 处理 /admin/features：
+//   - GET              列出所有功能开关及其状态
+//   - PUT    /{flag}   运行时开关功能，请求体为 {"enabled": true}
+//   - DELETE /{flag}   移除运行时覆盖，恢复部署配置
+func handleFeatures(w http.ResponseWriter, r *http.Request, subpath string) {
+	registry := getFeatureFlags()
+	flag := features.Flag(strings.TrimPrefix(subpath, "/"))
+
+	var err error
+	switch {
+	case flag == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, registry.Snapshot())
+		return
+	case flag != "" && r.Method == http.MethodPut:
+		var body struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if decodeErr := json.NewDecoder(r.Body).Decode(&body); decodeErr != nil || body.Enabled == nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Request body must contain enabled")
+			return
+		}
+		err = registry.Set(flag, *body.Enabled)
+	case flag != "" && r.Method == http.MethodDelete:
+		err = registry.Reset(flag)
+	default:
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if errors.Is(err, features.ErrUnknownFlag) {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, err.Error())
+		return
+	}
+	logger.L().Info("功能开关已更新", zap.String("flag", string(flag)), zap.Bool("enabled", registry.Enabled(flag)))
+	writeJSON(w, http.StatusOK, registry.Snapshot()[flag])
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	apierror "you2api/apierror"
+	upstream "you2api/internal/upstream"
+	logger "you2api/logger"
+
+	"github.com/google/uuid"
+)
+
+// maxUploadBytes 是单个上传文件的大小上限。
+const maxUploadBytes = 10 << 20
+
+// OpenAIFile 定义了 OpenAI /v1/files 接口返回的文件对象。
+type OpenAIFile struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+// uploadToYou 将文件上传到 You.com，返回可在聊天请求中引用的 source。
+func uploadToYou(ctx context.Context, dsToken, filename string, content []byte) (youSource, error) {
+	client := upstreamClient(60 * time.Second)
+
+	// 上传前需要先获取一次性的 nonce
+	nonceReq, err := http.NewRequestWithContext(ctx, "GET", upstream.NonceURL, nil)
+	if err != nil {
+		return youSource{}, err
+	}
+	nonceReq.Header.Set("Cookie", upstream.CookieHeader(dsToken))
+	nonceResp, err := client.Do(nonceReq)
+	if err != nil {
+		return youSource{}, err
+	}
+	nonce, err := io.ReadAll(nonceResp.Body)
+	nonceResp.Body.Close()
+	if err != nil {
+		return youSource{}, err
+	}
+	if nonceResp.StatusCode != http.StatusOK {
+		return youSource{}, fmt.Errorf("get upload nonce failed: status %d", nonceResp.StatusCode)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return youSource{}, err
+	}
+	part.Write(content)
+	form.Close()
+
+	uploadReq, err := http.NewRequestWithContext(ctx, "POST", upstream.UploadURL, &body)
+	if err != nil {
+		return youSource{}, err
+	}
+	uploadReq.Header.Set("Content-Type", form.FormDataContentType())
+	uploadReq.Header.Set("X-Upload-Nonce", strings.TrimSpace(string(nonce)))
+	uploadReq.Header.Set("Cookie", upstream.CookieHeader(dsToken))
+	uploadResp, err := client.Do(uploadReq)
+	if err != nil {
+		return youSource{}, err
+	}
+	defer uploadResp.Body.Close()
+	if uploadResp.StatusCode != http.StatusOK {
+		return youSource{}, fmt.Errorf("upload failed: status %d", uploadResp.StatusCode)
+	}
+
+	var result struct {
+		Filename     string `json:"filename"`
+		UserFilename string `json:"user_filename"`
+	}
+	if err := json.NewDecoder(uploadResp.Body).Decode(&result); err != nil {
+		return youSource{}, fmt.Errorf("decode upload response: %w", err)
+	}
+	return youSource{
+		SourceType:   "user_files",
+		Filename:     result.Filename,
+		UserFilename: result.UserFilename,
+		SizeBytes:    int64(len(content)),
+	}, nil
+}
+
+// handleFileUpload 处理 POST /v1/files：将文件上传到 You.com。
+// 请求携带 X-Session-ID 时文件会绑定到该会话，并自动附加到会话后续的每一轮对话中。
+func handleFileUpload(w http.ResponseWriter, r *http.Request) {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		clientError(w, r, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Missing or invalid authorization header")
+		return
+	}
+	apiKey := strings.TrimPrefix(authHeader, "Bearer ")
+	principal, err := authenticateJWT(r.Context(), apiKey)
+	if err != nil {
+		writeAuthError(w, r, err)
+		return
+	}
+	if principal != nil {
+		r = r.WithContext(withPrincipal(r.Context(), principal))
+	}
+	dsToken, err := resolveDSToken(r.Context(), apiKey)
+	if err != nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.CodeNoAccountAvailable, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Request must be multipart/form-data with a file field")
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxUploadBytes+1))
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Failed to read uploaded file")
+		return
+	}
+	if len(content) > maxUploadBytes {
+		clientError(w, r, http.StatusRequestEntityTooLarge, apierror.CodeFileTooLarge, "File exceeds the %d byte limit", maxUploadBytes)
+		return
+	}
+
+	src, err := uploadToYou(r.Context(), dsToken, header.Filename, content)
+	if err != nil {
+		apierror.Write(w, http.StatusBadGateway, apierror.CodeUpstreamError, logger.ScrubError(err, dsToken))
+		return
+	}
+
+	fileObj := OpenAIFile{
+		ID:        "file-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Object:    "file",
+		Bytes:     int64(len(content)),
+		CreatedAt: time.Now().Unix(),
+		Filename:  header.Filename,
+		Purpose:   r.FormValue("purpose"),
+	}
+	if sessionID := r.Header.Get(sessionHeader); sessionID != "" {
+		sessions.attach(sessionOwner(r.Context(), apiKey), sessionID, dsToken, src)
+	}
+	writeJSON(w, http.StatusOK, fileObj)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// uploadSessionFile 以 apiKey 上传 notes.txt 并绑定到会话 sessionID。
+func uploadSessionFile(t *testing.T, apiKey, sessionID string) OpenAIFile {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("hello file"))
+	form.WriteField("purpose", "assistants")
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", &body)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set(sessionHeader, sessionID)
+	rec := httptest.NewRecorder()
+	Handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload status = %d, body %s", rec.Code, rec.Body)
+	}
+	var file OpenAIFile
+	if err := json.Unmarshal(rec.Body.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// dryRunSources 以 apiKey 在会话 sessionID 中发送 dry run 请求，返回预览中的 sources 参数。
+func dryRunSources(t *testing.T, apiKey, sessionID string) []string {
+	t.Helper()
+	rec := postChat(t, `{"model":"gpt-4o","dry_run":true,"messages":[{"role":"user","content":"summarize"}]}`,
+		"Authorization", "Bearer "+apiKey, sessionHeader, sessionID)
+	var dry dryRunResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &dry); err != nil {
+		t.Fatal(err)
+	}
+	return dry.Upstream.Query["sources"]
+}
+
+func TestFileUploadReattachedToSession(t *testing.T) {
+	withMockUpstream(t, "echo")
+
+	file := uploadSessionFile(t, testDSToken, "files-test-session")
+	if !strings.HasPrefix(file.ID, "file-") || file.Bytes != 10 || file.Filename != "notes.txt" || file.Purpose != "assistants" {
+		t.Errorf("file = %+v", file)
+	}
+
+	// 同一会话的后续请求会携带已上传的文件
+	sources := dryRunSources(t, testDSToken, "files-test-session")
+	if len(sources) != 1 || !strings.Contains(sources[0], "mock-notes.txt") {
+		t.Errorf("sources = %v", sources)
+	}
+}
+
+func TestSessionFilesScopedToClient(t *testing.T) {
+	withMockUpstream(t, "echo")
+
+	uploadSessionFile(t, testDSToken, "shared-session-id")
+	// 其他客户端使用相同的会话 ID 读不到这些文件
+	if sources := dryRunSources(t, "other-client-key", "shared-session-id"); len(sources) != 0 {
+		t.Errorf("sources leaked to another client: %v", sources)
+	}
+	if sources := dryRunSources(t, testDSToken, "shared-session-id"); len(sources) != 1 {
+		t.Errorf("sources = %v, want the uploaded file", sources)
+	}
+}
+
+func TestFileUploadRequiresFile(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", strings.NewReader("not multipart"))
+	req.Header.Set("Authorization", "Bearer "+testDSToken)
+	rec := httptest.NewRecorder()
+	Handler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestSessionAttach(t *testing.T) {
+	limit := currentConfig().SessionMaxFiles
+	if limit <= 0 {
+		t.Skip("SESSION_MAX_FILES disabled")
+	}
+	owner, id := keyID("attach-test-key"), "attach-test-session"
+	sessions.attach(owner, id, "account-a", youSource{Filename: "a"})
+	sessions.attach(owner, id, "account-a", youSource{Filename: "a"})
+	if got := len(sessions.get(owner, id)); got != 1 {
+		t.Errorf("duplicate file attached: %d files", got)
+	}
+	for i := 0; i < limit+2; i++ {
+		sessions.attach(owner, id, "account-a", youSource{Filename: strings.Repeat("f", i+2)})
+	}
+	files := sessions.get(owner, id)
+	if len(files) != limit || files[0].Filename == "a" {
+		t.Errorf("files = %d (first %q), want the %d newest", len(files), files[0].Filename, limit)
+	}
+}
+
+func TestSourcesForAccount(t *testing.T) {
+	owner, id := keyID("account-test-key"), "account-test-session"
+	sessions.attach(owner, id, "account-a", youSource{Filename: "from-a"})
+	sessions.attach(owner, id, "account-b", youSource{Filename: "from-b"})
+	files := sessions.get(owner, id)
+	// 文件引用只在上传它的账号下有效
+	if got := sourcesFor(files, "account-a"); len(got) != 1 || got[0].Filename != "from-a" {
+		t.Errorf("account-a sources = %+v", got)
+	}
+	if got := sourcesFor(files, "account-c"); len(got) != 0 {
+		t.Errorf("account-c sources = %+v, want none", got)
+	}
+}
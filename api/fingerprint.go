@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+
+	fingerprint "you2api/fingerprint"
+	pool "you2api/pool"
+)
+
+// 浏览器指纹（FINGERPRINT_MODE）：
+//   - fixed：所有请求使用网页版 Windows Edge 的指纹，搜索地区取 UPSTREAM_PARAMS 中的 mkt（默认）
+//   - sampled：按 DS token 加权抽样一个指纹（同一账号始终相同），并按账号池中账号的 market
+//     （未设置时按 timezone 推断）选择 mkt 与匹配的 Accept-Language，使请求特征与账号所在地区一致
+const (
+	fingerprintFixed   = "fixed"
+	fingerprintSampled = "sampled"
+)
+
+// requestFingerprint 是一次上游请求使用的指纹与搜索地区。
+type requestFingerprint struct {
+	profile fingerprint.Profile
+	market  string // 为空时不发送 Accept-Language
+	sampled bool
+}
+
+// newRequestFingerprint 按 FINGERPRINT_MODE 为 DS token 选择指纹。
+func newRequestFingerprint(dsToken string) *requestFingerprint {
+	if currentConfig().FingerprintMode != fingerprintSampled {
+		return &requestFingerprint{profile: fingerprint.Profiles[0]}
+	}
+	fp := &requestFingerprint{profile: fingerprint.Pick(keyID(dsToken)), sampled: true}
+	if account := poolAccount(dsToken); account != nil {
+		fp.market = account.Market
+		if fp.market == "" {
+			fp.market = fingerprint.MarketForTimezone(account.Location.String())
+		}
+	}
+	return fp
+}
+
+// setMarket 用账号所在地区覆盖查询参数中的 mkt；无法判断地区时反过来按 mkt 选择 Accept-Language。
+func (fp *requestFingerprint) setMarket(q url.Values) {
+	if !fp.sampled {
+		return
+	}
+	if fp.market == "" {
+		fp.market = q.Get("mkt")
+	} else if q.Has("mkt") {
+		q.Set("mkt", fp.market)
+	}
+}
+
+// apply 把指纹写入上游请求头。
+func (fp *requestFingerprint) apply(h http.Header) {
+	fp.profile.Apply(h, fp.market)
+}
+
+// poolAccount 返回账号池中使用该 DS token 的账号，不在账号池中时返回 nil。
+func poolAccount(dsToken string) *pool.Account {
+	p := getTokenPool()
+	if p == nil {
+		return nil
+	}
+	for _, account := range p.Accounts() {
+		if account.Token == dsToken {
+			return account
+		}
+	}
+	return nil
+}
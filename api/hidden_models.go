@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	apierror "you2api/apierror"
+	logger "you2api/logger"
+)
+
+// hiddenModelStore 记录在运行时被隐藏的模型。隐藏的模型不再出现在 /v1/models 中，
+// 但已经写死模型名称的客户端仍然可以继续使用。
+type hiddenModelStore struct {
+	mu     sync.RWMutex
+	models map[string]bool
+	path   string
+}
+
+var (
+	hiddenModelsOnce sync.Once
+	hiddenModels     *hiddenModelStore
+)
+
+// getHiddenModels 返回隐藏模型存储，首次调用时从 HIDDEN_MODELS_FILE 加载。
+func getHiddenModels() *hiddenModelStore {
+	hiddenModelsOnce.Do(func() {
+		hiddenModels = &hiddenModelStore{
+			models: make(map[string]bool),
+			path:   currentConfig().HiddenModelsFile,
+		}
+		if err := hiddenModels.load(); err != nil {
+			logger.L().Warn("加载隐藏模型文件失败", zap.String("file", hiddenModels.path), zap.Error(err))
+		}
+	})
+	return hiddenModels
+}
+
+func (s *hiddenModelStore) isHidden(model string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.models[model]
+}
+
+// set 隐藏或恢复一个模型，并持久化到文件。
+func (s *hiddenModelStore) set(model string, hidden bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hidden {
+		s.models[model] = true
+	} else {
+		delete(s.models, model)
+	}
+	modelListVersion.Add(1)
+	return s.saveLocked()
+}
+
+// replaceAll 用导入的状态替换全部隐藏模型。
+func (s *hiddenModelStore) replaceAll(models []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models = make(map[string]bool, len(models))
+	for _, model := range models {
+		s.models[model] = true
+	}
+	modelListVersion.Add(1)
+	return s.saveLocked()
+}
+
+func (s *hiddenModelStore) list() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	models := make([]string, 0, len(s.models))
+	for model := range s.models {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
+
+func (s *hiddenModelStore) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var models []string
+	if err := json.Unmarshal(data, &models); err != nil {
+		return err
+	}
+	for _, model := range models {
+		s.models[model] = true
+	}
+	return nil
+}
+
+func (s *hiddenModelStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	models := make([]string, 0, len(s.models))
+	for model := range s.models {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	data, err := json.MarshalIndent(models, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// modelExists 判断模型是否为已知的全局模型、按思考强度区分的基础模型或虚拟模型。
+func modelExists(id string) bool {
+	if _, ok := getModelMap()[id]; ok {
+		return true
+	}
+	if _, ok := reasoningEffortModels[id]; ok {
+		return true
+	}
+	_, ok := getVirtualModels()[id]
+	return ok
+}
+
+// handleModelDelete 处理 DELETE /v1/models/{id}（需要管理密钥），将模型从列表中隐藏。
+// 返回与 OpenAI 删除模型接口相同的结构。
+func handleModelDelete(w http.ResponseWriter, r *http.Request, id string) {
+	w, done, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	if !modelExists(id) {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeModelNotFound, "Model not found: "+id)
+		return
+	}
+	if err := getHiddenModels().set(id, true); err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodePersistFailed, "Failed to persist hidden models: "+err.Error())
+		return
+	}
+	logger.L().Info("模型已隐藏", zap.String("model", id))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      id,
+		"object":  "model",
+		"deleted": true,
+	})
+}
+
+// handleHiddenModels 处理 /admin/hidden-models 管理接口：
+//
+//	GET    /admin/hidden-models       列出被隐藏的模型
+//	DELETE /admin/hidden-models/{id}  恢复模型，使其重新出现在 /v1/models 中
+func handleHiddenModels(w http.ResponseWriter, r *http.Request, path string) {
+	store := getHiddenModels()
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, store.list())
+	case strings.HasPrefix(path, "/") && r.Method == http.MethodDelete:
+		id := strings.TrimPrefix(path, "/")
+		if !store.isHidden(id) {
+			apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, "Model is not hidden: "+id)
+			return
+		}
+		if err := store.set(id, false); err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodePersistFailed, "Failed to persist hidden models: "+err.Error())
+			return
+		}
+		logger.L().Info("模型已恢复", zap.String("model", id))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestHideModel(t *testing.T) {
+	withAdminKeys(t, "admin-secret", "")
+	store := getHiddenModels()
+	t.Cleanup(func() { store.replaceAll(nil) })
+
+	do := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		Handler(rec, req)
+		return rec
+	}
+	listed := func() bool {
+		var list ModelResponse
+		json.Unmarshal(do(http.MethodGet, "/v1/models", "").Body.Bytes(), &list)
+		return slices.ContainsFunc(list.Data, func(m ModelDetail) bool { return m.ID == "gpt-4o" })
+	}
+
+	if !listed() {
+		t.Fatal("gpt-4o missing from /v1/models before hiding")
+	}
+	if rec := do(http.MethodDelete, "/v1/models/gpt-4o", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("DELETE without admin key = %d, want 401", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/v1/models/no-such-model", "admin-secret"); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE unknown model = %d, want 404", rec.Code)
+	}
+
+	rec := do(http.MethodDelete, "/v1/models/gpt-4o", "admin-secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE status = %d, body %s", rec.Code, rec.Body)
+	}
+	if listed() {
+		t.Error("hidden model still listed")
+	}
+	var hidden []string
+	json.Unmarshal(do(http.MethodGet, "/admin/hidden-models", "admin-secret").Body.Bytes(), &hidden)
+	if !slices.Equal(hidden, []string{"gpt-4o"}) {
+		t.Errorf("hidden models = %v", hidden)
+	}
+
+	if rec := do(http.MethodDelete, "/admin/hidden-models/gpt-4o", "admin-secret"); rec.Code != http.StatusNoContent {
+		t.Errorf("restore status = %d", rec.Code)
+	}
+	if !listed() {
+		t.Error("restored model not listed")
+	}
+	if rec := do(http.MethodDelete, "/admin/hidden-models/gpt-4o", "admin-secret"); rec.Code != http.StatusNotFound {
+		t.Errorf("restore of visible model = %d, want 404", rec.Code)
+	}
+}
+
+func TestHiddenModelsPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hidden.json")
+	store := &hiddenModelStore{models: map[string]bool{}, path: path}
+	if err := store.set("gpt-4o", true); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := &hiddenModelStore{models: map[string]bool{}, path: path}
+	if err := reloaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.isHidden("gpt-4o") {
+		t.Error("hidden model not persisted")
+	}
+}
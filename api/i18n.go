@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	apierror "you2api/apierror"
+	i18n "you2api/i18n"
+)
+
+// 面向客户端的错误信息（以及思考过程 <details> 块的标题）按 Accept-Language 本地化，目前支持英文（默认）与中文。
+// 管理接口只供运维使用，错误信息保持英文。新增语言时在这里登记一份译文即可。
+
+func init() {
+	i18n.Register("zh", map[string]string{
+		"Method not allowed":                                               "不支持的请求方法",
+		"Missing or invalid authorization header":                          "缺少 Authorization 请求头或格式无效",
+		"Invalid request body":                                             "请求体无效",
+		"Invalid request body: %s":                                         "请求体无效: %s",
+		"Invalid request body: n must be at most %d":                       "请求体无效: n 不能大于 %d",
+		"Invalid request body: async cannot be combined with stream":       "请求体无效: async 不能与 stream 同时使用",
+		"Unsupported parameter(s) in strict compatibility mode: %s":        "严格兼容模式下不支持的参数: %s",
+		"previous_response_id not found: %s":                               "未找到 previous_response_id: %s",
+		"Failed to upload image: %s":                                       "上传图片失败: %s",
+		"Error encoding response":                                          "响应编码失败",
+		"Model not found: %s":                                              "模型不存在: %s",
+		"%d of %d choices failed: %v":                                      "%[2]d 个候选中有 %[1]d 个失败: %[3]v",
+		"Request must be multipart/form-data with a file field":            "请求必须是包含 file 字段的 multipart/form-data",
+		"Failed to read uploaded file":                                     "读取上传的文件失败",
+		"File exceeds the %d byte limit":                                   "文件超过 %d 字节的大小限制",
+		"Async completions require RESPONSE_SIGNING_KEY to sign callbacks": "异步补全需要配置 RESPONSE_SIGNING_KEY 以便对回调签名",
+		"Job queue unavailable: %s":                                        "任务队列不可用: %s",
+		"Stream not found or expired: %s":                                  "流式响应不存在或已过期: %s",
+		"Invalid token: %s":                                                "token 无效: %s",
+		"Token has none of the required scopes":                            "token 不带任何所需的 scope",
+		"Rate limit exceeded: %d requests per minute":                      "超过请求频率限制: 每分钟 %d 次",
+		"Model not allowed for this token: %s":                             "该 token 无权使用模型: %s",
+		"Request rejected by plugin: %s":                                   "插件拒绝了请求: %s",
+		"Response rejected by plugin: %s":                                  "插件拒绝了回复: %s",
+		"Thinking":                                                         "思考过程",
+	})
+}
+
+// languageKey 用于在请求上下文中携带错误信息使用的语言。
+type languageKey struct{}
+
+// withLanguage 根据 Accept-Language 选择语言并放入请求上下文，
+// 之后基于该上下文构造的上游请求也能以客户端的语言报告错误。
+func withLanguage(r *http.Request) *http.Request {
+	lang := i18n.Match(r.Header.Get("Accept-Language"))
+	return r.WithContext(context.WithValue(r.Context(), languageKey{}, lang))
+}
+
+// requestLanguage 返回请求使用的语言，上下文中没有时解析 Accept-Language。
+func requestLanguage(r *http.Request) string {
+	if lang, ok := r.Context().Value(languageKey{}).(string); ok {
+		return lang
+	}
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
+
+// clientError 以客户端的语言返回 OpenAI 格式的错误，code 为机器可读的错误码，
+// format 为英文格式字符串，同时也是译文目录中的键。
+func clientError(w http.ResponseWriter, r *http.Request, status int, code, format string, args ...interface{}) {
+	clientParamError(w, r, status, code, "", format, args...)
+}
+
+// clientParamError 与 clientError 相同，并在错误的 param 字段中指出导致错误的请求参数。
+func clientParamError(w http.ResponseWriter, r *http.Request, status int, code, param, format string, args ...interface{}) {
+	lang := requestLanguage(r)
+	w.Header().Set("Content-Language", lang)
+	apierror.WriteParam(w, status, code, param, i18n.Sprintf(lang, format, args...))
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// errInvalidImage 表示请求中的内联图片无法被接受（格式、类型或大小不符合要求）。
+var errInvalidImage = errors.New("invalid image")
+
+// allowedImageTypes 是允许上传的图片 MIME 类型及其文件扩展名。
+var allowedImageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// inlineImage 是从 data: URL 中解码出的图片。
+type inlineImage struct {
+	MIMEType string
+	Data     []byte
+}
+
+// filename 按内容哈希生成稳定的文件名，同一张图片总是得到相同的名字。
+func (img inlineImage) filename() string {
+	sum := sha256.Sum256(img.Data)
+	return "image-" + hex.EncodeToString(sum[:8]) + allowedImageTypes[img.MIMEType]
+}
+
+// decodeInlineImages 解码所有消息中的 data: 图片，并校验类型与大小。
+// You.com 无法访问远程图片，因此 http(s) 图片 URL 同样会被拒绝。
+func decodeInlineImages(messages []Message) ([]inlineImage, error) {
+	var images []inlineImage
+	for _, msg := range messages {
+		for _, url := range msg.ImageURLs {
+			img, err := decodeDataURL(url)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+// decodeDataURL 解析形如 data:image/png;base64,... 的图片。
+func decodeDataURL(url string) (inlineImage, error) {
+	meta, payload, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !strings.HasPrefix(url, "data:") || !ok || !strings.HasSuffix(meta, ";base64") {
+		return inlineImage{}, fmt.Errorf("%w: only base64 data: URLs are supported", errInvalidImage)
+	}
+	mimeType := strings.TrimSuffix(meta, ";base64")
+	if _, allowed := allowedImageTypes[mimeType]; !allowed {
+		return inlineImage{}, fmt.Errorf("%w: unsupported image type %q", errInvalidImage, mimeType)
+	}
+	if base64.StdEncoding.DecodedLen(len(payload)) > maxUploadBytes+2 {
+		return inlineImage{}, fmt.Errorf("%w: image exceeds the %d byte limit", errInvalidImage, maxUploadBytes)
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return inlineImage{}, fmt.Errorf("%w: %v", errInvalidImage, err)
+	}
+	if len(data) > maxUploadBytes {
+		return inlineImage{}, fmt.Errorf("%w: image exceeds the %d byte limit", errInvalidImage, maxUploadBytes)
+	}
+	// 以实际内容为准，防止声明的类型与数据不符
+	if detected := http.DetectContentType(data); detected != mimeType {
+		return inlineImage{}, fmt.Errorf("%w: declared %s but content is %s", errInvalidImage, mimeType, detected)
+	}
+	return inlineImage{MIMEType: mimeType, Data: data}, nil
+}
+
+// maxUploadedImages 是已上传图片缓存的容量上限。
+const maxUploadedImages = 1024
+
+// uploadedImages 缓存已上传图片对应的 source，避免对话历史中的同一张图片每轮都重新上传。
+var uploadedImages = newLRU[string, youSource]("uploaded_images", func() int { return maxUploadedImages }).
+	withSizer(func(key string, src youSource) int { return len(key) + src.size() })
+
+// uploadInlineImages 将解码后的图片上传到 You.com，返回对应的 sources。
+func uploadInlineImages(ctx context.Context, dsToken string, images []inlineImage) ([]youSource, error) {
+	var sources []youSource
+	for _, img := range images {
+		cacheKey := keyID(dsToken) + "/" + img.filename()
+
+		src, cached := uploadedImages.get(cacheKey)
+		if !cached {
+			var err error
+			src, err = uploadToYou(ctx, dsToken, img.filename(), img.Data)
+			if err != nil {
+				return nil, err
+			}
+			uploadedImages.put(cacheKey, src)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	upstream "you2api/internal/upstream"
+)
+
+// testPNG 只包含 PNG 文件签名，足以通过内容类型检测。
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDecodeDataURL(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString(testPNG)
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"png", "data:image/png;base64," + png, false},
+		{"remote url", "https://example.com/cat.png", true},
+		{"not base64", "data:image/png," + png, true},
+		{"unsupported type", "data:image/bmp;base64," + png, true},
+		{"declared type mismatch", "data:image/jpeg;base64," + png, true},
+		{"bad payload", "data:image/png;base64,!!!", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decodeDataURL(tt.url)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidImage) {
+					t.Errorf("err = %v, want errInvalidImage", err)
+				}
+				return
+			}
+			if err != nil || img.MIMEType != "image/png" || !strings.HasSuffix(img.filename(), ".png") {
+				t.Errorf("img = %+v, err = %v", img, err)
+			}
+		})
+	}
+}
+
+// countingUploads 统计上传接口的调用次数。
+type countingUploads struct {
+	uploads atomic.Int32
+}
+
+func (c *countingUploads) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/api/upload" {
+		c.uploads.Add(1)
+	}
+	return (&mockTransport{style: "echo"}).RoundTrip(req)
+}
+
+func TestInlineImagesUploadedOnce(t *testing.T) {
+	counter := &countingUploads{}
+	prev := upstreamClient
+	upstreamClient = func(timeout time.Duration) upstream.Doer {
+		return &http.Client{Transport: counter, Timeout: timeout}
+	}
+	t.Cleanup(func() { upstreamClient = prev })
+
+	// 每次测试使用不同的图片内容，避免命中其他测试留下的缓存
+	image := append(append([]byte(nil), testPNG...), []byte(t.Name())...)
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"text","text":"what is this"},` +
+		`{"type":"image_url","image_url":{"url":"data:image/png;base64,` + base64.StdEncoding.EncodeToString(image) + `"}}]}]}`
+
+	for i := 0; i < 2; i++ {
+		decodeCompletion(t, postChat(t, body))
+	}
+	if got := counter.uploads.Load(); got != 1 {
+		t.Errorf("uploads = %d, want 1 (second request should reuse the cached source)", got)
+	}
+}
+
+func TestInlineImageRejected(t *testing.T) {
+	withMockUpstream(t, "echo")
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}]}`
+	if rec := postChat(t, body); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400; body %s", rec.Code, rec.Body)
+	}
+}
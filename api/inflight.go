@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apierror "you2api/apierror"
+)
+
+// inflightCompletion 描述一个正在进行的补全请求。
+type inflightCompletion struct {
+	ID       string `json:"id"`
+	Model    string `json:"model"`
+	KeyID    string `json:"key_id"`
+	ClientIP string `json:"client_ip"`
+	// ResponseID 是响应中的补全 ID，客户端可以通过 X-Request-ID 或 Idempotency-Key 指定
+	ResponseID string    `json:"response_id"`
+	Stream     bool      `json:"stream"`
+	StartTime  time.Time `json:"start_time"`
+
+	tokens atomic.Int64
+	cancel context.CancelFunc
+	stages *stageTimer
+}
+
+// inflightRegistry 是并发安全的进行中补全表，用于观测与取消。
+type inflightRegistry struct {
+	mu          sync.RWMutex
+	completions map[string]*inflightCompletion
+}
+
+var inflight = &inflightRegistry{completions: make(map[string]*inflightCompletion)}
+
+type inflightKey struct{}
+
+// errDuplicateCompletion 表示相同 ID 的补全仍在进行中（客户端重复使用了请求 ID）。
+var errDuplicateCompletion = errors.New("a completion with this ID is already in progress")
+
+// register 登记一个补全请求，返回携带该记录且可被取消的上下文，以及完成时必须调用的清理函数。
+func (reg *inflightRegistry) register(ctx context.Context, c *inflightCompletion) (context.Context, func(), error) {
+	reg.mu.Lock()
+	if _, exists := reg.completions[c.ID]; exists {
+		reg.mu.Unlock()
+		return nil, nil, errDuplicateCompletion
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	reg.completions[c.ID] = c
+	reg.mu.Unlock()
+
+	return context.WithValue(ctx, inflightKey{}, c), func() {
+		reg.mu.Lock()
+		delete(reg.completions, c.ID)
+		reg.mu.Unlock()
+		cancel()
+	}, nil
+}
+
+// cancel 取消指定 ID 的补全，返回是否找到该请求。
+func (reg *inflightRegistry) cancel(id string) bool {
+	reg.mu.RLock()
+	c, ok := reg.completions[id]
+	reg.mu.RUnlock()
+	if ok {
+		c.cancel()
+	}
+	return ok
+}
+
+func (reg *inflightRegistry) count() int {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return len(reg.completions)
+}
+
+// inflightSnapshot 是 GET /admin/streams 返回的单条记录。
+type inflightSnapshot struct {
+	ID          string    `json:"id"`
+	Model       string    `json:"model"`
+	KeyID       string    `json:"key_id"`
+	Stream      bool      `json:"stream"`
+	StartTime   time.Time `json:"start_time"`
+	ElapsedMS   int64     `json:"elapsed_ms"`
+	TokensSoFar int64     `json:"tokens_so_far"`
+	// StagesMS 是已完成阶段的耗时（毫秒），见 stages.go
+	StagesMS map[string]float64 `json:"stages_ms,omitempty"`
+}
+
+// snapshot 按开始时间排序返回所有进行中的补全。
+func (reg *inflightRegistry) snapshot() []inflightSnapshot {
+	reg.mu.RLock()
+	result := make([]inflightSnapshot, 0, len(reg.completions))
+	for _, c := range reg.completions {
+		result = append(result, inflightSnapshot{
+			ID:          c.ID,
+			Model:       c.Model,
+			KeyID:       c.KeyID,
+			Stream:      c.Stream,
+			StartTime:   c.StartTime,
+			ElapsedMS:   time.Since(c.StartTime).Milliseconds(),
+			TokensSoFar: c.tokens.Load(),
+			StagesMS:    c.stages.snapshot(),
+		})
+	}
+	reg.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].StartTime.Before(result[j].StartTime) })
+	return result
+}
+
+// countToken 为上下文中的进行中补全累加一个 token，第一个 token 同时结束 first_token 阶段。
+func countToken(ctx context.Context) {
+	if c, ok := ctx.Value(inflightKey{}).(*inflightCompletion); ok {
+		c.tokens.Add(1)
+		c.stages.mark(stageFirstToken)
+	}
+}
+
+// completionID 返回上下文中补全的响应 ID；不在登记范围内的请求（如重放）生成新的 ID。
+func completionID(ctx context.Context) string {
+	if c, ok := ctx.Value(inflightKey{}).(*inflightCompletion); ok && c.ResponseID != "" {
+		return c.ResponseID
+	}
+	return newCompletionID()
+}
+
+// handleStreams 处理 /admin/streams 管理接口：
+//
+//	GET    /admin/streams       列出进行中的补全
+//	DELETE /admin/streams/{id}  取消指定补全并关闭上游连接
+func handleStreams(w http.ResponseWriter, r *http.Request, path string) {
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, inflight.snapshot())
+	case strings.HasPrefix(path, "/") && r.Method == http.MethodDelete:
+		if !inflight.cancel(strings.TrimPrefix(path, "/")) {
+			apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, "Completion not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInflightRegistry(t *testing.T) {
+	reg := &inflightRegistry{completions: make(map[string]*inflightCompletion)}
+	c := &inflightCompletion{ID: "req-1", Model: "gpt-4o", ResponseID: "chatcmpl-fixed", StartTime: time.Now(), stages: newStageTimer(context.Background(), time.Now())}
+
+	ctx, done, err := reg.register(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := reg.register(context.Background(), &inflightCompletion{ID: "req-1"}); !errors.Is(err, errDuplicateCompletion) {
+		t.Errorf("duplicate register error = %v", err)
+	}
+
+	countToken(ctx)
+	countToken(ctx)
+	snap := reg.snapshot()
+	if len(snap) != 1 || snap[0].TokensSoFar != 2 || snap[0].Model != "gpt-4o" {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	if got := completionID(ctx); got != "chatcmpl-fixed" {
+		t.Errorf("completionID = %q", got)
+	}
+
+	if !reg.cancel("req-1") {
+		t.Fatal("cancel returned false for a registered completion")
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("context not canceled: %v", ctx.Err())
+	}
+	done()
+	if reg.count() != 0 || reg.cancel("req-1") {
+		t.Error("completion still registered after done")
+	}
+}
+
+func TestHandleStreamsCancel(t *testing.T) {
+	c := &inflightCompletion{ID: "req-admin", StartTime: time.Now(), stages: newStageTimer(context.Background(), time.Now())}
+	ctx, done, err := inflight.register(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	rec := httptest.NewRecorder()
+	handleStreams(rec, httptest.NewRequest(http.MethodDelete, "/admin/streams/req-admin", nil), "/req-admin")
+	if rec.Code != http.StatusNoContent || ctx.Err() == nil {
+		t.Errorf("DELETE status = %d, ctx err = %v", rec.Code, ctx.Err())
+	}
+
+	rec = httptest.NewRecorder()
+	handleStreams(rec, httptest.NewRequest(http.MethodDelete, "/admin/streams/missing", nil), "/missing")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE missing status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	translate "you2api/internal/translate"
+)
+
+// 单次请求的自定义指令（人设）：请求体中的 instructions（或别名 persona）
+// 作为 You.com 网页端的自定义指令参数发送，而不是拼进聊天历史，
+// 这样模型会把它当作系统级的设定，也不会占用对话上下文的长度。
+// system 消息同理（SYSTEM_AS_INSTRUCTIONS，默认开启）：You.com 对聊天历史中的 question 基本不做区分，
+// system 消息放在其中几乎不起作用，因此把它们从聊天历史中取出，附加在 instructions 之后一起发送。
+
+// customInstructions 返回请求的自定义指令。instructions 与 persona 同时设置且内容不同时返回错误。
+func customInstructions(req OpenAIRequest) (string, error) {
+	instructions := strings.TrimSpace(req.Instructions)
+	persona := strings.TrimSpace(req.Persona)
+	if instructions != "" && persona != "" && instructions != persona {
+		return "", errors.New("instructions and persona cannot both be set")
+	}
+	if instructions != "" {
+		return instructions, nil
+	}
+	return persona, nil
+}
+
+// splitSystemMessages 在开启 SYSTEM_AS_INSTRUCTIONS 时把 system 消息从聊天历史中取出，返回 system 消息的内容与其余消息。
+func splitSystemMessages(messages []Message) (system []string, chat []Message) {
+	if !currentConfig().SystemAsInstructions {
+		return nil, messages
+	}
+	return translate.SplitSystemMessages(messages)
+}
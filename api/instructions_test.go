@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	translate "you2api/internal/translate"
+)
+
+func TestCustomInstructions(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions string
+		persona      string
+		want         string
+		wantErr      bool
+	}{
+		{"none", "", "", "", false},
+		{"instructions", "Be brief.", "", "Be brief.", false},
+		{"persona alias", "", " Be a pirate. ", "Be a pirate.", false},
+		{"same value in both", "Be brief.", "Be brief.", "Be brief.", false},
+		{"conflicting values", "Be brief.", "Be verbose.", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := customInstructions(OpenAIRequest{Instructions: tt.instructions, Persona: tt.persona})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("customInstructions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstructionsSentOutsideChatHistory(t *testing.T) {
+	req := OpenAIRequest{Persona: "Be a pirate.", Messages: []Message{{Role: "user", Content: "hi"}}}
+	youReq, err := buildYouRequest(context.Background(), req, "gpt_4o", testDSToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := youReq.URL.Query()
+	if got := q.Get(translate.CustomInstructionsParam); got != "Be a pirate." {
+		t.Errorf("%s = %q, want the persona", translate.CustomInstructionsParam, got)
+	}
+	if got := q.Get("chat"); got != `[{"answer":"","question":"hi"}]` {
+		t.Errorf("chat = %s, persona must not be added to the history", got)
+	}
+}
+
+func TestConflictingInstructionsRejected(t *testing.T) {
+	withMockUpstream(t, "echo")
+	rec := postChat(t, `{"model":"gpt-4o","instructions":"a","persona":"b","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400; body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSystemMessagesAsInstructions(t *testing.T) {
+	req := OpenAIRequest{
+		Instructions: "Be brief.",
+		Messages: []Message{
+			{Role: "system", Content: "You are a pirate."},
+			{Role: "user", Content: "hi"},
+		},
+	}
+	tests := []struct {
+		name             string
+		enabled          bool
+		wantInstructions string
+		wantChat         string
+	}{
+		{"enabled", true, "Be brief.\n\nYou are a pirate.", `[{"answer":"","question":"hi"}]`},
+		{"disabled", false, "Be brief.", `[{"answer":"","question":"You are a pirate."},{"answer":"","question":"hi"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := currentConfig()
+			conf := *prev
+			conf.SystemAsInstructions = tt.enabled
+			setConfig(&conf)
+			t.Cleanup(func() { setConfig(prev) })
+
+			youReq, err := buildYouRequest(context.Background(), req, "gpt_4o", testDSToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := youReq.URL.Query()
+			if got := q.Get(translate.CustomInstructionsParam); got != tt.wantInstructions {
+				t.Errorf("instructions = %q, want %q", got, tt.wantInstructions)
+			}
+			if got := q.Get("chat"); got != tt.wantChat {
+				t.Errorf("chat = %s, want %s", got, tt.wantChat)
+			}
+		})
+	}
+}
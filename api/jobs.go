@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	apierror "you2api/apierror"
+	jobqueue "you2api/jobqueue"
+	logger "you2api/logger"
+)
+
+// 后台任务（目前是异步补全）保存在 SQLite 任务队列中，进程重启后未完成的任务会继续执行。
+// 任务执行失败时按指数退避重试，重试次数用尽后进入死信状态，可通过 /admin/jobs 查看与重新排队。
+// 已完成的任务连同结果保留 JOB_DONE_RETENTION_MS，死信任务保留 JOB_DEAD_RETENTION_MS，之后由后台定期清理。
+
+// jobHandler 执行一个任务，返回保存在队列中的结果。ctx 在进程退出时取消，此时任务会被放回队列而不是记为失败。
+type jobHandler func(ctx context.Context, job *jobqueue.Job) (json.RawMessage, error)
+
+// jobPurgeInterval 是清理过期任务的间隔。
+const jobPurgeInterval = time.Minute
+
+// jobHandlers 按任务类型分发。
+var jobHandlers = map[string]jobHandler{
+	asyncCompletionJob: runAsyncCompletionJob,
+}
+
+var (
+	jobQueueOnce sync.Once
+	jobQueue     *jobqueue.Queue
+	jobQueueErr  error
+)
+
+// getJobQueue 返回任务队列，首次调用时打开 JOB_QUEUE_FILE 数据库并恢复未完成的任务。
+func getJobQueue() (*jobqueue.Queue, error) {
+	jobQueueOnce.Do(func() {
+		conf := currentConfig()
+		jobQueue, jobQueueErr = jobqueue.Open(conf.JobQueueFile, jobqueue.Options{
+			VisibilityTimeout: time.Duration(conf.JobVisibilityTimeoutMS) * time.Millisecond,
+			MaxAttempts:       conf.JobMaxAttempts,
+			RetryBackoff:      time.Duration(conf.JobRetryBackoffMS) * time.Millisecond,
+			DoneRetention:     time.Duration(conf.JobDoneRetentionMS) * time.Millisecond,
+			DeadRetention:     time.Duration(conf.JobDeadRetentionMS) * time.Millisecond,
+		})
+	})
+	return jobQueue, jobQueueErr
+}
+
+// setJobQueue 使用给定的任务队列，代替按 JOB_QUEUE_FILE 打开的数据库，应在 Start 之前调用。
+func setJobQueue(q *jobqueue.Queue) {
+	jobQueueOnce.Do(func() {})
+	jobQueue, jobQueueErr = q, nil
+}
+
+// jobWorkerPool 从队列中取出任务并执行。
+type jobWorkerPool struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wake   chan struct{}
+	wg     sync.WaitGroup
+}
+
+var jobWorkers = &jobWorkerPool{wake: make(chan struct{}, 1)}
+
+// start 启动 JOB_WORKERS 个后台 worker 与过期任务的清理。
+func (p *jobWorkerPool) start() error {
+	queue, err := getJobQueue()
+	if err != nil {
+		return fmt.Errorf("打开任务队列失败: %w", err)
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	for i := 0; i < currentConfig().JobWorkers; i++ {
+		p.wg.Add(1)
+		go p.run(queue)
+	}
+	p.wg.Add(1)
+	go p.purge(queue)
+	return nil
+}
+
+// purge 定期删除超过保留时长的已完成任务与死信任务。
+func (p *jobWorkerPool) purge(queue *jobqueue.Queue) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(jobPurgeInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := queue.Purge(time.Now()); err != nil {
+			logger.L().Warn("清理过期任务失败", zap.Error(err))
+		} else if n > 0 {
+			logger.L().Info("已清理过期任务", zap.Int("jobs", n))
+		}
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// notify 唤醒一个空闲的 worker，新任务入队后调用。
+func (p *jobWorkerPool) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *jobWorkerPool) run(queue *jobqueue.Queue) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		job, ok := queue.Dequeue(time.Now())
+		if !ok {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-p.wake:
+			case <-ticker.C:
+			}
+			continue
+		}
+		p.execute(queue, job)
+	}
+}
+
+func (p *jobWorkerPool) execute(queue *jobqueue.Queue, job *jobqueue.Job) {
+	handle, ok := jobHandlers[job.Kind]
+	if !ok {
+		queue.Fail(job.ID, fmt.Errorf("unknown job kind %q", job.Kind), time.Now())
+		return
+	}
+	result, err := handle(p.ctx, job)
+	if p.ctx.Err() != nil {
+		// 进程退出时中断的任务放回队列，重启后重新执行
+		queue.Release(job.ID, time.Now())
+		return
+	}
+	if err != nil {
+		logger.L().Warn("任务执行失败",
+			zap.String("job_id", job.ID), zap.String("kind", job.Kind), zap.Int("attempt", job.Attempts),
+			zap.String("error", logger.ScrubError(err)))
+		queue.Fail(job.ID, err, time.Now())
+		return
+	}
+	queue.Complete(job.ID, result, time.Now())
+}
+
+// stop 中断所有 worker，等待它们把进行中的任务放回队列后关闭队列数据库。
+func (p *jobWorkerPool) stop(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if queue, err := getJobQueue(); err == nil {
+		return queue.Close()
+	}
+	return nil
+}
+
+// jobSummary 是 GET /admin/jobs 返回的单条记录，不包含可能带有凭据的 payload。
+// Result 只在查询单个任务时返回。
+type jobSummary struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	State       jobqueue.State  `json:"state"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	VisibleAt   time.Time       `json:"visible_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Result      json.RawMessage `json:"result,omitempty"`
+}
+
+func newJobSummary(job *jobqueue.Job) jobSummary {
+	return jobSummary{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		State:       job.State,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		VisibleAt:   job.VisibleAt,
+		LastError:   job.LastError,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	}
+}
+
+// handleJobs 处理 /admin/jobs 管理接口：
+//
+//	GET  /admin/jobs             列出队列中的任务（包括保留期内已完成与死信的任务）
+//	GET  /admin/jobs/{id}        查询单个任务，已完成的任务包含执行结果
+//	POST /admin/jobs/{id}/retry  把死信任务重新排队
+func handleJobs(w http.ResponseWriter, r *http.Request, rest string) {
+	queue, err := getJobQueue()
+	if err != nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.CodeJobQueueUnavailable, "Job queue unavailable: "+err.Error())
+		return
+	}
+
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		jobs := queue.List()
+		summaries := make([]jobSummary, 0, len(jobs))
+		for _, job := range jobs {
+			summaries = append(summaries, newJobSummary(job))
+		}
+		writeJSON(w, http.StatusOK, summaries)
+	case strings.Count(rest, "/") == 1 && r.Method == http.MethodGet:
+		id := strings.TrimPrefix(rest, "/")
+		job, ok := queue.Get(id)
+		if !ok {
+			apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, "Job not found: "+id)
+			return
+		}
+		summary := newJobSummary(job)
+		summary.Result = job.Result
+		writeJSON(w, http.StatusOK, summary)
+	case strings.HasSuffix(rest, "/retry") && r.Method == http.MethodPost:
+		id := strings.TrimSuffix(strings.TrimPrefix(rest, "/"), "/retry")
+		err := queue.Retry(id, time.Now())
+		switch {
+		case errors.Is(err, jobqueue.ErrNotFound):
+			apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, "Job not found: "+id)
+		case err != nil:
+			apierror.Write(w, http.StatusConflict, apierror.CodeConflict, err.Error())
+		default:
+			jobWorkers.notify()
+			writeJSON(w, http.StatusOK, map[string]interface{}{"id": id, "state": jobqueue.Pending})
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	apierror "you2api/apierror"
+)
+
+// keyAliasStore 保存按 API key 划分的模型别名，优先于全局模型映射生效。
+// key 以哈希后的 key ID 存储，避免在内存与持久化文件中保留明文凭据。
+type keyAliasStore struct {
+	mu      sync.RWMutex
+	aliases map[string]map[string]string // key ID -> 别名 -> 模型
+	path    string
+}
+
+var (
+	keyAliasesOnce sync.Once
+	keyAliases     *keyAliasStore
+)
+
+// getKeyAliases 返回按 key 划分的别名存储，首次调用时从 KEY_ALIASES_FILE 加载。
+func getKeyAliases() *keyAliasStore {
+	keyAliasesOnce.Do(func() {
+		keyAliases = &keyAliasStore{
+			aliases: make(map[string]map[string]string),
+			path:    currentConfig().KeyAliasesFile,
+		}
+		if err := keyAliases.load(); err != nil {
+			log.Printf("加载 key 别名文件失败: %v", err)
+		}
+	})
+	return keyAliases
+}
+
+// keyID 返回 API key 的稳定标识（SHA-256 前 16 位十六进制）。
+func keyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// resolveModel 将客户端请求的模型名称解析为 You.com 模型名称。
+// 先查找该 key 的自定义别名，别名目标可以是 OpenAI 模型名称或 You.com 模型名称；
+// 未命中时回退到全局模型映射。
+func resolveModel(apiKey, requested string) (youModel string, aliased bool) {
+	if target, ok := getKeyAliases().lookup(keyID(apiKey), requested); ok {
+		if mapped, exists := getModelMap()[target]; exists {
+			return mapped, true
+		}
+		return target, true
+	}
+	return mapModelName(requested), false
+}
+
+func (s *keyAliasStore) lookup(id, model string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	target, ok := s.aliases[id][model]
+	return target, ok
+}
+
+func (s *keyAliasStore) snapshot() map[string]map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]map[string]string, len(s.aliases))
+	for id, aliases := range s.aliases {
+		result[id] = copyAliases(aliases)
+	}
+	return result
+}
+
+// set 替换某个 key 的全部别名，aliases 为空时删除该 key 的配置。
+func (s *keyAliasStore) set(id string, aliases map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(aliases) == 0 {
+		delete(s.aliases, id)
+	} else {
+		s.aliases[id] = copyAliases(aliases)
+	}
+	return s.saveLocked()
+}
+
+// replaceAll 用导入的状态替换全部别名。
+func (s *keyAliasStore) replaceAll(aliases map[string]map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aliases = make(map[string]map[string]string, len(aliases))
+	for id, keyAliases := range aliases {
+		s.aliases[id] = copyAliases(keyAliases)
+	}
+	return s.saveLocked()
+}
+
+// copyAliases 复制调用方传入的别名，之后调用方修改自己的 map 不会影响存储。
+func copyAliases(aliases map[string]string) map[string]string {
+	copied := make(map[string]string, len(aliases))
+	for alias, target := range aliases {
+		copied[alias] = target
+	}
+	return copied
+}
+
+func (s *keyAliasStore) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.aliases)
+}
+
+func (s *keyAliasStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// handleKeyAliases 处理 /admin/key-aliases 管理接口：
+//
+//	GET    /admin/key-aliases           列出所有 key 的别名
+//	PUT    /admin/key-aliases           {"key" 或 "key_id", "aliases": {"gpt-4": "claude_3_5_sonnet"}}
+//	DELETE /admin/key-aliases/{key_id}  删除某个 key 的全部别名
+func handleKeyAliases(w http.ResponseWriter, r *http.Request, path string) {
+	store := getKeyAliases()
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, store.snapshot())
+	case path == "" && r.Method == http.MethodPut:
+		var body struct {
+			Key     string            `json:"key"`
+			KeyID   string            `json:"key_id"`
+			Aliases map[string]string `json:"aliases"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+			return
+		}
+		id := body.KeyID
+		if body.Key != "" {
+			id = keyID(body.Key)
+		}
+		if id == "" {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Request body must contain key or key_id")
+			return
+		}
+		if err := store.set(id, body.Aliases); err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodePersistFailed, "Failed to persist aliases: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"key_id": id, "aliases": body.Aliases})
+	case strings.HasPrefix(path, "/") && r.Method == http.MethodDelete:
+		if err := store.set(strings.TrimPrefix(path, "/"), nil); err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodePersistFailed, "Failed to persist aliases: "+err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	translate "you2api/internal/translate"
+)
+
+func TestKeyAliasesResolve(t *testing.T) {
+	store := getKeyAliases()
+	t.Cleanup(func() { store.replaceAll(map[string]map[string]string{}) })
+
+	// 两个 key 使用同名别名指向不同模型；别名与全局模型重名时只覆盖该 key
+	if err := store.set(keyID("key-a"), map[string]string{"smart": "gpt-4o", "gpt-4o-mini": "claude_3_5_sonnet"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.set(keyID("key-b"), map[string]string{"smart": "openai_o1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, model  string
+		want        string
+		wantAliased bool
+	}{
+		{"key-a", "smart", translate.BuiltinModels["gpt-4o"], true},
+		{"key-b", "smart", "openai_o1", true},
+		{"key-a", "gpt-4o-mini", "claude_3_5_sonnet", true},
+		{"key-b", "gpt-4o-mini", translate.BuiltinModels["gpt-4o-mini"], false},
+		{"key-c", "gpt-4o-mini", translate.BuiltinModels["gpt-4o-mini"], false},
+	}
+	for _, tt := range tests {
+		got, aliased := resolveModel(tt.key, tt.model)
+		if got != tt.want || aliased != tt.wantAliased {
+			t.Errorf("resolveModel(%s, %s) = %q, %v; want %q, %v", tt.key, tt.model, got, aliased, tt.want, tt.wantAliased)
+		}
+	}
+}
+
+func TestKeyAliasesCopyCallerMaps(t *testing.T) {
+	store := getKeyAliases()
+	t.Cleanup(func() { store.replaceAll(map[string]map[string]string{}) })
+
+	// 调用方之后修改自己的 map 不会影响存储
+	aliases := map[string]string{"smart": "gpt-4o"}
+	if err := store.set(keyID("key-a"), aliases); err != nil {
+		t.Fatal(err)
+	}
+	aliases["smart"] = "changed"
+	all := map[string]map[string]string{keyID("key-b"): {"fast": "gpt-4o-mini"}}
+	if err := store.replaceAll(all); err != nil {
+		t.Fatal(err)
+	}
+	all[keyID("key-b")]["fast"] = "changed"
+	all[keyID("key-c")] = map[string]string{"x": "y"}
+	if got, _ := store.lookup(keyID("key-b"), "fast"); got != "gpt-4o-mini" {
+		t.Errorf("lookup after changing the replaceAll map = %q", got)
+	}
+	if _, ok := store.lookup(keyID("key-c"), "x"); ok {
+		t.Error("key added to the caller's map after replaceAll")
+	}
+
+	store.set(keyID("key-a"), aliases)
+	aliases["smart"] = "changed again"
+	if got, _ := store.lookup(keyID("key-a"), "smart"); got != "changed" {
+		t.Errorf("lookup after changing the set map = %q", got)
+	}
+
+	// 导入的状态没有 key 别名时，之后仍然可以设置
+	if err := store.replaceAll(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.set(keyID("key-a"), map[string]string{"smart": "gpt-4o"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyAliasesHandler(t *testing.T) {
+	store := getKeyAliases()
+	t.Cleanup(func() { store.replaceAll(map[string]map[string]string{}) })
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/admin/key-aliases", strings.NewReader(`{"key":"secret","aliases":{"fast":"gpt-4o-mini"}}`))
+	handleKeyAliases(rec, req, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, body %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Error("response must not echo the plaintext key")
+	}
+	id := keyID("secret")
+	if target, ok := store.lookup(id, "fast"); !ok || target != "gpt-4o-mini" {
+		t.Fatalf("lookup after PUT = %q, %v", target, ok)
+	}
+
+	rec = httptest.NewRecorder()
+	handleKeyAliases(rec, httptest.NewRequest(http.MethodPut, "/admin/key-aliases", strings.NewReader(`{"aliases":{}}`)), "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT without key status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handleKeyAliases(rec, httptest.NewRequest(http.MethodDelete, "/admin/key-aliases/"+id, nil), "/"+id)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d", rec.Code)
+	}
+	if _, ok := store.lookup(id, "fast"); ok {
+		t.Error("alias still present after DELETE")
+	}
+}
+
+func TestKeyAliasesPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.json")
+	store := &keyAliasStore{aliases: map[string]map[string]string{}, path: path}
+	if err := store.set("id1", map[string]string{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := &keyAliasStore{aliases: map[string]map[string]string{}, path: path}
+	if err := reloaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if target, ok := reloaded.lookup("id1", "a"); !ok || target != "b" {
+		t.Errorf("reloaded lookup = %q, %v", target, ok)
+	}
+}
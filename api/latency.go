@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errFirstTokenTimeout 表示上游在自适应首 token 超时时间内没有返回任何内容。
+var errFirstTokenTimeout = errors.New("upstream did not produce a first token in time")
+
+// latencySampleSize 是每个模型保留的首 token 延迟样本数。
+const latencySampleSize = 100
+
+// latencyTracker 按模型记录最近的首 token 延迟（TTFT），用于计算自适应超时。
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	next    map[string]int
+}
+
+var firstTokenLatency = &latencyTracker{
+	samples: make(map[string][]time.Duration),
+	next:    make(map[string]int),
+}
+
+func (t *latencyTracker) record(model string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples[model]) < latencySampleSize {
+		t.samples[model] = append(t.samples[model], d)
+		return
+	}
+	t.samples[model][t.next[model]] = d
+	t.next[model] = (t.next[model] + 1) % latencySampleSize
+}
+
+// p95 返回模型最近样本的 95 分位延迟，样本不足时返回 false。
+func (t *latencyTracker) p95(model string) (time.Duration, bool) {
+	t.mu.Lock()
+	sorted := append([]time.Duration(nil), t.samples[model]...)
+	t.mu.Unlock()
+	if len(sorted) < 5 {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*95+99)/100-1], true
+}
+
+// timeoutFor 返回模型的首 token 超时：p95 乘以系数，并限制在配置的上下限之间；
+// 样本不足时使用上限，避免冷启动时误杀慢速推理模型。
+func (t *latencyTracker) timeoutFor(model string) time.Duration {
+	conf := currentConfig()
+	minTimeout := time.Duration(conf.FirstTokenTimeoutMinMS) * time.Millisecond
+	maxTimeout := time.Duration(conf.FirstTokenTimeoutMaxMS) * time.Millisecond
+
+	p95, ok := t.p95(model)
+	if !ok {
+		return maxTimeout
+	}
+	timeout := time.Duration(float64(p95) * conf.FirstTokenTimeoutFactor)
+	return min(max(timeout, minTimeout), maxTimeout)
+}
+
+// firstTokenGuard 在超时前未收到首个 token 时取消上游请求，并在收到首个 token 时记录延迟。
+type firstTokenGuard struct {
+	model  string
+	start  time.Time
+	timer  *time.Timer
+	cancel context.CancelFunc
+	fired  atomic.Bool
+	once   sync.Once
+}
+
+// guardFirstToken 为上游请求挂上首 token 超时，返回绑定了可取消上下文的请求副本。
+// 请求结束后必须调用 guard.stop()。
+func guardFirstToken(youReq *http.Request) (*http.Request, *firstTokenGuard) {
+	ctx, cancel := context.WithCancel(youReq.Context())
+	g := &firstTokenGuard{
+		model:  youReq.URL.Query().Get("selectedAiModel"),
+		start:  time.Now(),
+		cancel: cancel,
+	}
+	g.timer = time.AfterFunc(firstTokenLatency.timeoutFor(g.model), func() {
+		g.fired.Store(true)
+		cancel()
+	})
+	return youReq.WithContext(ctx), g
+}
+
+// tokenReceived 在每个 token 到达时调用，仅首次调用生效。
+func (g *firstTokenGuard) tokenReceived() {
+	g.once.Do(func() {
+		if g.timer.Stop() {
+			firstTokenLatency.record(g.model, time.Since(g.start))
+		}
+	})
+}
+
+// wrap 在超时触发时将上游错误替换为 errFirstTokenTimeout。
+func (g *firstTokenGuard) wrap(err error) error {
+	if err != nil && g.fired.Load() {
+		return errFirstTokenTimeout
+	}
+	return err
+}
+
+func (g *firstTokenGuard) stop() {
+	g.timer.Stop()
+	g.cancel()
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestLatencyTracker() *latencyTracker {
+	return &latencyTracker{samples: make(map[string][]time.Duration), next: make(map[string]int)}
+}
+
+func TestLatencyTrackerP95(t *testing.T) {
+	tracker := newTestLatencyTracker()
+	for i := 1; i <= 4; i++ {
+		tracker.record("m", time.Duration(i)*time.Millisecond)
+	}
+	if _, ok := tracker.p95("m"); ok {
+		t.Error("p95 should need at least 5 samples")
+	}
+	for i := 5; i <= 100; i++ {
+		tracker.record("m", time.Duration(i)*time.Millisecond)
+	}
+	if got, _ := tracker.p95("m"); got != 95*time.Millisecond {
+		t.Errorf("p95 = %v, want 95ms", got)
+	}
+
+	// 超过样本数后覆盖最旧的样本
+	for i := 0; i < latencySampleSize; i++ {
+		tracker.record("m", time.Second)
+	}
+	if got, _ := tracker.p95("m"); got != time.Second {
+		t.Errorf("p95 after rollover = %v, want 1s", got)
+	}
+}
+
+func TestLatencyTrackerTimeoutBounds(t *testing.T) {
+	conf := currentConfig()
+	minTimeout := time.Duration(conf.FirstTokenTimeoutMinMS) * time.Millisecond
+	maxTimeout := time.Duration(conf.FirstTokenTimeoutMaxMS) * time.Millisecond
+
+	tracker := newTestLatencyTracker()
+	if got := tracker.timeoutFor("cold"); got != maxTimeout {
+		t.Errorf("cold start timeout = %v, want max %v", got, maxTimeout)
+	}
+	for i := 0; i < 10; i++ {
+		tracker.record("fast", time.Microsecond)
+		tracker.record("slow", time.Hour)
+	}
+	if got := tracker.timeoutFor("fast"); got != minTimeout {
+		t.Errorf("fast model timeout = %v, want min %v", got, minTimeout)
+	}
+	if got := tracker.timeoutFor("slow"); got != maxTimeout {
+		t.Errorf("slow model timeout = %v, want max %v", got, maxTimeout)
+	}
+}
+
+func TestFirstTokenGuard(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/streamingSearch?selectedAiModel=guard_test_model", nil)
+
+	guarded, g := guardFirstToken(req)
+	g.tokenReceived()
+	g.stop()
+	if err := g.wrap(context.Canceled); errors.Is(err, errFirstTokenTimeout) {
+		t.Error("guard fired although a token arrived in time")
+	}
+	if guarded.Context().Err() == nil {
+		t.Error("stop should cancel the guarded request context")
+	}
+
+	// 模拟超时触发
+	_, g = guardFirstToken(req)
+	defer g.stop()
+	g.timer.Reset(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if err := g.wrap(context.Canceled); !errors.Is(err, errFirstTokenTimeout) {
+		t.Errorf("wrap = %v, want errFirstTokenTimeout", err)
+	}
+	if g.wrap(nil) != nil {
+		t.Error("wrap(nil) should stay nil")
+	}
+}
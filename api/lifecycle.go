@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"errors"
+)
+
+// Start 检查 OIDC 配置并加载 PLUGINS 中的插件（失败时拒绝启动），启动后台任务 worker，并恢复上次退出时未完成的任务；
+// 开启模型发现时预先拉取上游模型列表；配置了 MODEL_MAP_FILE 时开始检查文件变化；
+// 配置了 DAILY_SUMMARY_AT 时开始定时发送每日汇总。
+func Start(ctx context.Context) error {
+	if err := configError(); err != nil {
+		return err
+	}
+	// WithConfig 传入的配置可能没有经过 config.Load
+	if err := currentConfig().Normalize(); err != nil {
+		return err
+	}
+	if _, err := loadPlugins(); err != nil {
+		return err
+	}
+	modelDiscovery.start()
+	modelMapReloader.start()
+	dailySummary.start()
+	return jobWorkers.start()
+}
+
+// Shutdown 在进程退出前停止后台任务、取消所有进行中的补全并关闭需要落盘的存储。
+// 应在 HTTP 服务器停止接收新请求之后调用。
+func Shutdown(ctx context.Context) error {
+	jobsErr := jobWorkers.stop(ctx)
+	dailySummary.shutdown()
+	modelDiscovery.shutdown()
+	modelMapReloader.shutdown()
+	for _, c := range inflight.snapshot() {
+		inflight.cancel(c.ID)
+	}
+	return errors.Join(jobsErr, closeAuditStore(), closePlugins(ctx))
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"hash/maphash"
+	"log"
+	"sync"
+
+	metrics "you2api/metrics"
+)
+
+// lruCache 是并发安全、容量有限的分片 LRU 缓存，会话、对话历史与已上传图片等内存存储共用这一实现。
+//
+// 条目按 key 的哈希分布到多个分片，每个分片有独立的锁与 LRU 链表，容量与字节预算平均分给各个分片，
+// 因此淘汰顺序只在分片内严格遵循 LRU。容量较小时只使用一个分片。
+// capacity 在每次写入时求值，便于从配置读取；返回值 <= 0 表示不缓存任何内容。
+// 通过 withSizer 设置条目大小的估算函数后，按 CACHE_BYTE_BUDGETS 中该缓存的字节预算淘汰条目。
+//
+// 指标：store_evictions_total{store} 淘汰的条目数，cache_lookups_total{store,result} 命中与未命中次数，
+// cache_entries{store} 与 cache_bytes{store} 当前的条目数与估算字节数。
+type lruCache[K comparable, V any] struct {
+	name     string
+	capacity func() int
+	sizeOf   func(K, V) int
+
+	once   sync.Once
+	seed   maphash.Seed
+	shards []*lruShard[K, V]
+}
+
+type lruShard[K comparable, V any] struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[K]*list.Element
+	bytes int
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+	size  int
+}
+
+// 分片数量：每个分片至少分到 lruShardMinEntries 个条目，最多 maxLRUShards 个分片。
+const (
+	lruShardMinEntries = 64
+	maxLRUShards       = 16
+)
+
+func newLRU[K comparable, V any](name string, capacity func() int) *lruCache[K, V] {
+	return &lruCache[K, V]{name: name, capacity: capacity}
+}
+
+// withSizer 设置条目大小（字节）的估算函数，返回缓存本身以便在声明时链式调用。
+func (c *lruCache[K, V]) withSizer(sizeOf func(K, V) int) *lruCache[K, V] {
+	c.sizeOf = sizeOf
+	return c
+}
+
+// init 在第一次使用时按当时的容量确定分片数量，避免在包初始化时读取配置。
+func (c *lruCache[K, V]) init() {
+	c.once.Do(func() {
+		n := min(max(c.capacity()/lruShardMinEntries, 1), maxLRUShards)
+		c.seed = maphash.MakeSeed()
+		c.shards = make([]*lruShard[K, V], n)
+		for i := range c.shards {
+			c.shards[i] = &lruShard[K, V]{ll: list.New(), items: make(map[K]*list.Element)}
+		}
+	})
+}
+
+func (c *lruCache[K, V]) shard(key K) *lruShard[K, V] {
+	c.init()
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	var h uint64
+	if s, ok := any(key).(string); ok {
+		h = maphash.String(c.seed, s)
+	} else {
+		h = maphash.String(c.seed, fmt.Sprint(key))
+	}
+	return c.shards[h%uint64(len(c.shards))]
+}
+
+// get 返回缓存的值，并将其标记为最近使用。
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.ll.MoveToFront(el)
+		metrics.CacheLookups.WithLabelValues(c.name, "hit").Inc()
+		return el.Value.(*lruEntry[K, V]).value, true
+	}
+	metrics.CacheLookups.WithLabelValues(c.name, "miss").Inc()
+	var zero V
+	return zero, false
+}
+
+// put 写入一个值，超出容量或字节预算时淘汰最久未使用的条目。
+func (c *lruCache[K, V]) put(key K, value V) {
+	c.update(key, func(V, bool) V { return value })
+}
+
+// update 在锁内根据旧值计算新值并写入，用于需要读-改-写的场景。
+func (c *lruCache[K, V]) update(key K, fn func(old V, exists bool) V) {
+	limit := c.capacity()
+	budget := cacheByteBudget(c.name)
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		entry := el.Value.(*lruEntry[K, V])
+		entry.value = fn(entry.value, true)
+		c.resize(s, entry)
+		s.ll.MoveToFront(el)
+	} else {
+		if limit <= 0 {
+			return
+		}
+		var zero V
+		entry := &lruEntry[K, V]{key: key, value: fn(zero, false)}
+		s.items[key] = s.ll.PushFront(entry)
+		metrics.CacheEntries.WithLabelValues(c.name).Inc()
+		c.resize(s, entry)
+	}
+	c.evict(s, perShard(limit, len(c.shards)), perShard(budget, len(c.shards)))
+}
+
+// resize 重新估算条目的大小并更新分片的字节数。
+func (c *lruCache[K, V]) resize(s *lruShard[K, V], entry *lruEntry[K, V]) {
+	if c.sizeOf == nil {
+		return
+	}
+	size := c.sizeOf(entry.key, entry.value)
+	s.bytes += size - entry.size
+	metrics.CacheBytes.WithLabelValues(c.name).Add(float64(size - entry.size))
+	entry.size = size
+}
+
+// evict 淘汰分片中最久未使用的条目，直到条目数与字节数都不超过限制（budget <= 0 表示不限字节数）。
+// 单个条目超过字节预算时同样会被淘汰，即不缓存。
+func (c *lruCache[K, V]) evict(s *lruShard[K, V], limit, budget int) {
+	for s.ll.Len() > 0 && (s.ll.Len() > limit || (budget > 0 && s.bytes > budget)) {
+		oldest := s.ll.Back()
+		entry := oldest.Value.(*lruEntry[K, V])
+		s.ll.Remove(oldest)
+		delete(s.items, entry.key)
+		s.bytes -= entry.size
+		metrics.StoreEvictions.WithLabelValues(c.name).Inc()
+		metrics.CacheEntries.WithLabelValues(c.name).Dec()
+		metrics.CacheBytes.WithLabelValues(c.name).Sub(float64(entry.size))
+	}
+}
+
+// perShard 把总量平均分给各个分片（向上取整）。
+func perShard(total, shards int) int {
+	if total <= 0 {
+		return total
+	}
+	return (total + shards - 1) / shards
+}
+
+// entries 返回全部条目的副本，不影响使用顺序。
+func (c *lruCache[K, V]) entries() map[K]V {
+	c.init()
+	result := make(map[K]V)
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for key, el := range s.items {
+			result[key] = el.Value.(*lruEntry[K, V]).value
+		}
+		s.mu.Unlock()
+	}
+	return result
+}
+
+// len 返回当前缓存的条目数。
+func (c *lruCache[K, V]) len() int {
+	c.init()
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.ll.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+var (
+	cacheByteBudgetsOnce sync.Once
+	cacheByteBudgets     map[string]int
+)
+
+// cacheByteBudget 返回缓存的字节预算，0 表示不限制。预算来自 CACHE_BYTE_BUDGETS：
+// 以缓存名称（conversations、sessions、uploaded_images）为键的 JSON 对象，如 {"conversations": 33554432}。
+func cacheByteBudget(name string) int {
+	cacheByteBudgetsOnce.Do(func() {
+		cacheByteBudgets = make(map[string]int)
+		raw := currentConfig().CacheByteBudgets
+		if raw == "" {
+			return
+		}
+		if err := json.Unmarshal([]byte(raw), &cacheByteBudgets); err != nil {
+			log.Printf("解析 CACHE_BYTE_BUDGETS 失败: %v", err)
+		}
+	})
+	return cacheByteBudgets[name]
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRU[string, int]("test", func() int { return 2 })
+	c.put("a", 1)
+	c.put("b", 2)
+	c.get("a") // a 变为最近使用
+	c.put("c", 3)
+
+	if _, ok := c.get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if v, ok := c.get("a"); !ok || v != 1 {
+		t.Errorf("get(a) = %v, %v", v, ok)
+	}
+	if c.len() != 2 {
+		t.Errorf("len() = %d, want 2", c.len())
+	}
+}
+
+func TestLRUShardsAndByteBudget(t *testing.T) {
+	sharded := newLRU[string, int]("test", func() int { return 1024 })
+	for i := 0; i < 4096; i++ {
+		sharded.put(fmt.Sprint(i), i)
+	}
+	if len(sharded.shards) != maxLRUShards {
+		t.Errorf("shards = %d, want %d", len(sharded.shards), maxLRUShards)
+	}
+	if n := sharded.len(); n > 1024 || n < 900 {
+		t.Errorf("len() = %d, want close to 1024", n)
+	}
+
+	c := newLRU[string, string]("test", func() int { return 10 }).withSizer(func(_, v string) int { return len(v) })
+	c.put("a", "xxxx")
+	c.put("b", "yyyy")
+	c.evict(c.shards[0], 10, 6)
+	if _, ok := c.get("a"); ok {
+		t.Error("a should have been evicted by the byte budget")
+	}
+	if c.shards[0].bytes != 4 {
+		t.Errorf("bytes = %d, want 4", c.shards[0].bytes)
+	}
+}
+
+func TestTrimHistoryKeepsSystemMessages(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "s"},
+		{Role: "user", Content: "1"},
+		{Role: "assistant", Content: "2"},
+		{Role: "user", Content: "3"},
+		{Role: "assistant", Content: "4"},
+	}
+	got := trimHistory(messages, 3)
+	if len(got) != 3 || got[0].Content != "s" || got[1].Content != "3" || got[2].Content != "4" {
+		t.Errorf("trimHistory() = %+v", got)
+	}
+}
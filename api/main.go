@@ -1,116 +1,34 @@
 package handler
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
-)
-
-// YouChatResponse 定义了从 You.com API 接收的单个 token 的结构。
-type YouChatResponse struct {
-	YouChatToken string `json:"youChatToken"`
-}
-
-// OpenAIStreamResponse 定义了 OpenAI API 流式响应的结构。
-type OpenAIStreamResponse struct {
-	ID      string   `json:"id"`
-	Object  string   `json:"object"`
-	Created int64    `json:"created"`
-	Model   string   `json:"model"`
-	Choices []Choice `json:"choices"`
-}
-
-// Choice 定义了 OpenAI 流式响应中 choices 数组的单个元素的结构。
-type Choice struct {
-	Delta        Delta  `json:"delta"`
-	Index        int    `json:"index"`
-	FinishReason string `json:"finish_reason"`
-}
-
-// Delta 定义了流式响应中表示增量内容的结构。
-type Delta struct {
-	Content string `json:"content"`
-}
-
-// OpenAIRequest 定义了 OpenAI API 请求体的结构。
-type OpenAIRequest struct {
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
-	Model    string    `json:"model"`
-}
-
-// Message 定义了 OpenAI 聊天消息的结构。
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-// OpenAIResponse 定义了 OpenAI API 非流式响应的结构。
-type OpenAIResponse struct {
-	ID      string         `json:"id"`
-	Object  string         `json:"object"`
-	Created int64          `json:"created"`
-	Model   string         `json:"model"`
-	Choices []OpenAIChoice `json:"choices"`
-}
-
-// OpenAIChoice 定义了 OpenAI 非流式响应中 choices 数组的单个元素的结构。
-type OpenAIChoice struct {
-	Message      Message `json:"message"`
-	Index        int     `json:"index"`
-	FinishReason string  `json:"finish_reason"`
-}
 
-// ModelResponse 定义了 /v1/models 响应的结构。
-type ModelResponse struct {
-	Object string        `json:"object"`
-	Data   []ModelDetail `json:"data"`
-}
+	apierror "you2api/apierror"
+	features "you2api/features"
+	translate "you2api/internal/translate"
+	upstream "you2api/internal/upstream"
+	logger "you2api/logger"
+	metrics "you2api/metrics"
+	tracing "you2api/tracing"
 
-// ModelDetail 定义了模型列表中单个模型的详细信息。
-type ModelDetail struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	OwnedBy string `json:"owned_by"`
-}
-
-// modelMap 存储 OpenAI 模型名称到 You.com 模型名称的映射。
-var modelMap = map[string]string{
-	"deepseek-reasoner":       "deepseek_r1",
-	"deepseek-chat":           "deepseek_v3",
-	"o3-mini-high":            "openai_o3_mini_high",
-	"o3-mini-medium":          "openai_o3_mini_medium",
-	"o1":                      "openai_o1",
-	"o1-mini":                 "openai_o1_mini",
-	"o1-preview":              "openai_o1_preview",
-	"gpt-4o":                  "gpt_4o",
-	"gpt-4o-mini":             "gpt_4o_mini",
-	"gpt-4-turbo":             "gpt_4_turbo",
-	"gpt-3.5-turbo":           "gpt_3.5",
-	"claude-3-opus":           "claude_3_opus",
-	"claude-3-sonnet":         "claude_3_sonnet",
-	"claude-3.5-sonnet":       "claude_3_5_sonnet",
-	"claude-3.5-haiku":        "claude_3_5_haiku",
-	"gemini-1.5-pro":          "gemini_1_5_pro",
-	"gemini-1.5-flash":        "gemini_1_5_flash",
-	"llama-3.2-90b":           "llama3_2_90b",
-	"llama-3.1-405b":          "llama3_1_405b",
-	"mistral-large-2":         "mistral_large_2",
-	"qwen-2.5-72b":            "qwen2p5_72b",
-	"qwen-2.5-coder-32b":      "qwen2p5_coder_32b",
-	"command-r-plus":          "command_r_plus",
-	"claude-3-7-sonnet":       "claude_3_7_sonnet",
-	"claude-3-7-sonnet-think": "claude_3_7_sonnet_thinking",
-}
+	"go.uber.org/zap"
+)
 
-// getReverseModelMap 创建并返回 modelMap 的反向映射（You.com 模型名称 -> OpenAI 模型名称）。
+// getReverseModelMap 创建并返回模型映射的反向映射（You.com 模型名称 -> OpenAI 模型名称）。
 func getReverseModelMap() map[string]string {
-	reverse := make(map[string]string, len(modelMap))
-	for k, v := range modelMap {
+	models := getModelMap()
+	reverse := make(map[string]string, len(models))
+	for k, v := range models {
 		reverse[v] = k
 	}
 	return reverse
@@ -118,10 +36,39 @@ func getReverseModelMap() map[string]string {
 
 // mapModelName 将 OpenAI 模型名称映射到 You.com 模型名称。
 func mapModelName(openAIModel string) string {
-	if mappedModel, exists := modelMap[openAIModel]; exists {
+	if mappedModel, exists := getModelMap()[openAIModel]; exists {
 		return mappedModel
 	}
-	return "deepseek_v3" // 默认模型
+	if modelDiscovery.has(openAIModel) {
+		return openAIModel // 上游发现的模型直接使用 You.com 名称
+	}
+	if youModel, ok := reasoningEffortModel(openAIModel, ""); ok {
+		return youModel
+	}
+	return defaultUpstreamModel()
+}
+
+// defaultUpstreamModel 返回未知模型名称回退到的 You.com 模型（DEFAULT_MODEL）。
+func defaultUpstreamModel() string {
+	model := currentConfig().DefaultModel
+	if mapped, exists := getModelMap()[model]; exists {
+		return mapped
+	}
+	if model == "" {
+		return "deepseek_v3"
+	}
+	return model
+}
+
+// isKnownModel 判断请求的模型是否有明确的映射，而不是回退到默认模型。
+func isKnownModel(model string) bool {
+	if mapped, ok := getModelMap()[model]; ok && modelDiscovery.retired(mapped) {
+		return false // 上游已不再提供，见 model_discovery.go
+	}
+	if modelExists(model) || modelDiscovery.has(model) {
+		return true
+	}
+	return model == autoModelName && currentConfig().AutoModel.Enabled
 }
 
 // reverseMapModelName 将 You.com 模型名称映射回 OpenAI 模型名称。
@@ -133,285 +80,942 @@ func reverseMapModelName(youModel string) string {
 	return "deepseek-chat" // 默认模型
 }
 
-// originalModel 存储原始的 OpenAI 模型名称。
-var originalModel string
+// requestState 是单个补全请求在模型解析后确定的状态，由 handleChatCompletions 创建并传给各响应处理函数。
+// 这些状态不能放在包级变量中，否则并发请求会互相覆盖，响应中报告错误的模型。
+type requestState struct {
+	// RequestedModel 是客户端请求的模型名称
+	RequestedModel string
+	// UpstreamModel 是实际请求的 You.com 模型
+	UpstreamModel string
+	// Model 是响应中报告的模型名称
+	Model string
+	// Aliased 表示模型通过 key 级别的别名解析
+	Aliased bool
+	// VM 是请求使用的虚拟模型，未使用时为 nil
+	VM *virtualModel
+	// Structured 是 response_format 要求的结构化输出，未要求时为 nil
+	Structured *structuredOutput
+	// PromptTokens 是发送给上游的消息的估算 token 数，用于响应中的 usage
+	PromptTokens int
+	// ReasoningMode 是思考过程的处理方式（inline 或 exclude）
+	ReasoningMode string
+	// Tools 是请求中的工具定义，未提供工具时为 nil
+	Tools *toolEmulation
+	// IgnoredParams 是请求中设置了但没有转发给上游的采样参数
+	IgnoredParams []string
+	// Rebuild 用账号池中的另一个账号与给定的 You.com 模型重新构建上游请求，用于坏输出重试，见 output_quality.go
+	Rebuild func(youModel string) (*http.Request, *tokenLease, error)
+	// ClientKeyID 是客户端凭据的 key ID，不同客户端的请求不会被合并，见 coalesce.go
+	ClientKeyID string
+	// Deferred 表示上游请求还没有占用账号：相同的非流式请求先合并，实际发出请求时才通过 Rebuild 占用账号
+	Deferred bool
+}
 
-// Handler 是处理所有传入 HTTP 请求的主处理函数。
-func Handler(w http.ResponseWriter, r *http.Request) {
-	// 处理 /v1/models 请求（列出可用模型）
-	if r.URL.Path == "/v1/models" || r.URL.Path == "/api/v1/models" {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "*")
+// handleChatCompletions 处理 /v1/chat/completions 请求，CORS 头部与 OPTIONS 预检由路由中的中间件处理，见 routes.go。
+func handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	// 验证 Authorization 头部
+	authHeader := r.Header.Get("Authorization")
+	if currentConfig().MockMode && authHeader == "" {
+		authHeader = "Bearer mock" // 模拟模式下无需 DS token
+	}
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		clientError(w, r, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "Missing or invalid authorization header")
+		return
+	}
+	apiKey := strings.TrimPrefix(authHeader, "Bearer ") // 客户端凭据：DS token、账号池访问密钥或 SSO 签发的 JWT
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+	// 客户端 JWT：校验通过后以用户身份作为 key，并从账号池中选择账号，见 oidc.go
+	principal, err := authenticateJWT(r.Context(), apiKey)
+	if err != nil {
+		writeAuthError(w, r, err)
+		return
+	}
+	if principal != nil {
+		if !principal.allow(time.Now()) {
+			clientError(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "Rate limit exceeded: %d requests per minute", principal.policy.RequestsPerMinute)
 			return
 		}
+		apiKey = principal.key
+		r = r.WithContext(withPrincipal(r.Context(), principal))
+	}
 
-		models := make([]ModelDetail, 0, len(modelMap))
-		created := time.Now().Unix()
-		for modelID := range modelMap {
-			models = append(models, ModelDetail{
-				ID:      modelID,
-				Object:  "model",
-				Created: created,
-				OwnedBy: "organization-owner",
-			})
-		}
+	// 流式响应的断线重连：从事件记录中补发 Last-Event-ID 之后的事件，见 transcripts.go
+	if resumeStream(w, r, apiKey) {
+		return
+	}
 
-		response := ModelResponse{
-			Object: "list",
-			Data:   models,
-		}
+	// 分阶段计时，见 stages.go
+	// 补全的 span 挂在请求头 traceparent 指定的调用方 span 下
+	stages := newStageTimer(tracing.Extract(r.Context(), r.Header), time.Now())
+	defer stages.end("", "", nil)
 
-		json.NewEncoder(w).Encode(response)
+	// 读取并校验 OpenAI 请求体
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+		return
+	}
+	if errs := validateChatRequest(body); len(errs) > 0 {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: %s", strings.Join(errs, "; "))
+		return
+	}
+	if rejected := checkCompat(currentConfig().CompatMode, body); len(rejected) > 0 {
+		clientParamError(w, r, http.StatusBadRequest, apierror.CodeUnsupportedParam, rejected[0], "Unsupported parameter(s) in strict compatibility mode: %s", describeRejected(rejected))
 		return
 	}
 
-	// 处理非 /v1/chat/completions 请求（服务状态检查）
-	if r.URL.Path != "/v1/chat/completions" && r.URL.Path != "/none/v1/chat/completions" && r.URL.Path != "/such/chat/completions" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"status":  "You2Api Service Running...",
-			"message": "MoLoveSze...",
-		})
+	// 解析 OpenAI 请求体
+	var openAIReq OpenAIRequest
+	if err := json.Unmarshal(body, &openAIReq); err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
 		return
 	}
 
-	// 设置 CORS 头部
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "*")
+	if _, err := customInstructions(openAIReq); err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: %s", err)
+		return
+	}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	structured, err := newStructuredOutput(openAIReq.ResponseFormat)
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: %s", err)
 		return
 	}
 
-	// 验证 Authorization 头部
-	authHeader := r.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		http.Error(w, "Missing or invalid authorization header", http.StatusUnauthorized)
+	tools, err := newToolEmulation(openAIReq)
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: %s", err)
 		return
 	}
-	dsToken := strings.TrimPrefix(authHeader, "Bearer ") // 提取 DS token
 
-	// 解析 OpenAI 请求体
-	var openAIReq OpenAIRequest
-	if err := json.NewDecoder(r.Body).Decode(&openAIReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	requestedLimit, err := requestResponseLimit(openAIReq)
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: %s", err)
 		return
 	}
 
-	originalModel = openAIReq.Model                                // 保存原始模型名称
-	lastMessage := openAIReq.Messages[len(openAIReq.Messages)-1].Content // 获取最后一条消息
+	stops, err := parseStop(openAIReq.Stop)
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: %s", err)
+		return
+	}
 
-	// 构建 You.com 聊天历史
-	var chatHistory []map[string]interface{}
-	for _, msg := range openAIReq.Messages {
-		chatMsg := map[string]interface{}{
-			"question": msg.Content,
-			"answer":   "",
-		}
-		// 如果是 assistant 的消息, 则交换 question 和 answer
-		if msg.Role == "assistant" {
-			chatMsg["question"] = ""
-			chatMsg["answer"] = msg.Content
+	effort, err := reasoningEffort(openAIReq)
+	if err != nil {
+		clientParamError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "reasoning_effort", "Invalid request body: %s", err)
+		return
+	}
+
+	if maxChoices := currentConfig().MaxChoices; openAIReq.N > maxChoices {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: n must be at most %d", maxChoices)
+		return
+	}
+
+	if openAIReq.Async {
+		handleAsyncCompletion(w, r, body, openAIReq, apiKey)
+		return
+	}
+
+	// 从引用的历史响应处继续对话，客户端只需发送新增的消息
+	if openAIReq.PreviousResponseID != "" {
+		history, ok := conversations.get(r.Context(), openAIReq.PreviousResponseID, keyID(apiKey))
+		if !ok {
+			clientError(w, r, http.StatusNotFound, apierror.CodeResponseNotFound, "previous_response_id not found: %s", openAIReq.PreviousResponseID)
+			return
 		}
-		chatHistory = append(chatHistory, chatMsg)
+		openAIReq.Messages = append(history, openAIReq.Messages...)
+	}
+	history := openAIReq.Messages // 虚拟模型附加的系统提示词不计入保存的对话
+	if err := applyPreRequestPlugins(r.Context(), &openAIReq); errors.Is(err, errPluginsUnavailable) {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternalError, err.Error())
+		return
+	} else if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodePluginRejected, "Request rejected by plugin: %s", err)
+		return
 	}
 
-	chatHistoryJSON, _ := json.Marshal(chatHistory) // 将聊天历史序列化为 JSON
+	// 解析模型名称，key 级别的自定义别名优先于虚拟模型与全局映射
+	if !principal.allowsModel(openAIReq.Model) {
+		clientParamError(w, r, http.StatusForbidden, apierror.CodeModelNotAllowed, "model", "Model not allowed for this token: %s", openAIReq.Model)
+		return
+	}
+	rs := &requestState{RequestedModel: openAIReq.Model, Structured: structured, ReasoningMode: reasoningMode(openAIReq), Tools: tools}
+	if rs.IgnoredParams = ignoredSamplingParams(openAIReq.SamplingParams); len(rs.IgnoredParams) > 0 {
+		w.Header().Set("X-Ignored-Parameters", strings.Join(rs.IgnoredParams, ", "))
+	}
+	rs.UpstreamModel, rs.Aliased = resolveModel(apiKey, openAIReq.Model)
+	if !rs.Aliased && currentConfig().RejectUnknownModels && !isKnownModel(openAIReq.Model) {
+		clientParamError(w, r, http.StatusNotFound, apierror.CodeModelNotFound, "model", "Model not found: %s", openAIReq.Model)
+		return
+	}
+	if youModel, ok := reasoningEffortModel(openAIReq.Model, effort); ok && !rs.Aliased {
+		rs.UpstreamModel = youModel // 基础推理模型按 reasoning_effort 选择强度
+	}
+	rs.Model = reverseMapModelName(rs.UpstreamModel) // 响应中报告实际使用的模型
+
+	// 虚拟模型：替换为基础模型，并附加系统提示词
+	if vm, isVirtual := getVirtualModels()[openAIReq.Model]; isVirtual && !rs.Aliased {
+		rs.UpstreamModel = vm.upstreamModel()
+		rs.Model = vm.Name
+		rs.VM = vm
+		openAIReq = vm.apply(openAIReq)
+	}
+
+	// 虚拟模型 auto：按提示词内容与模型健康状态选择上游模型
+	var autoDecision *AutoModelDecision
+	if openAIReq.Model == autoModelName && !rs.Aliased && rs.VM == nil && currentConfig().AutoModel.Enabled {
+		autoDecision = chooseAutoModel(currentConfig().AutoModel, openAIReq.Messages)
+		rs.UpstreamModel = mapModelName(autoDecision.Model)
+		rs.Model = autoDecision.Model
+		w.Header().Set(autoModelHeader, autoDecision.Model)
+	}
 
-	// 创建 You.com API 请求
-	youReq, _ := http.NewRequest("GET", "https://you.com/api/streamingSearch", nil)
+	// 工具调用：附加工具定义与调用格式的说明；结构化输出：在提问后附加格式说明
+	openAIReq.Messages = rs.Tools.apply(openAIReq.Messages)
+	openAIReq.Messages = rs.Structured.apply(openAIReq.Messages)
+	rs.PromptTokens = countMessagesTokens(rs.UpstreamModel, openAIReq.Messages)
 
-	// 构建 You.com API 查询参数
-	q := youReq.URL.Query()
-	q.Add("q", lastMessage) // 主要查询参数 (最后一条消息)
-	q.Add("page", "1")
-	q.Add("count", "10")
-	q.Add("safeSearch", "Moderate")
-	q.Add("mkt", "zh-HK")             // 地区
-	q.Add("enable_worklow_generation_ux", "true")
-	q.Add("domain", "youchat")
-	q.Add("use_personalization_extraction", "true")
-	q.Add("pastChatLength", fmt.Sprintf("%d", len(chatHistory)-1)) // 过去的聊天记录长度
-	q.Add("selectedChatMode", "custom")                            // 聊天模式
-	q.Add("selectedAiModel", mapModelName(openAIReq.Model))         // 映射后的模型名称
-	q.Add("enable_agent_clarification_questions", "true")
-	q.Add("use_nested_youchat_updates", "true")
-	q.Add("chat", string(chatHistoryJSON)) // 聊天历史 (JSON 格式)
-	youReq.URL.RawQuery = q.Encode()        // 编码查询参数
-
-	// 设置 You.com API 请求头
-	youReq.Header = http.Header{
-		"sec-ch-ua-platform":         {"Windows"},
-		"Cache-Control":              {"no-cache"},
-		"sec-ch-ua":                  {`"Not(A:Brand";v="99", "Microsoft Edge";v="133", "Chromium";v="133"`},
-		"sec-ch-ua-bitness":          {"64"},
-		"sec-ch-ua-model":            {""},
-		"sec-ch-ua-mobile":           {"?0"},
-		"sec-ch-ua-arch":             {"x86"},
-		"sec-ch-ua-full-version":     {"133.0.3065.39"},
-		"Accept":                     {"text/event-stream"}, // 重要：接受 SSE 流
-		"User-Agent":                 {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0"},
-		"sec-ch-ua-platform-version": {"19.0.0"},
-		"Sec-Fetch-Site":             {"same-origin"},
-		"Sec-Fetch-Mode":             {"cors"},
-		"Sec-Fetch-Dest":             {"empty"},
-		"Host":                       {"you.com"},
-	}
-
-	// 设置 You.com API 请求的 Cookie
-	cookies := getCookies(dsToken)
-	var cookieStrings []string
-	for name, value := range cookies {
-		cookieStrings = append(cookieStrings, fmt.Sprintf("%s=%s", name, value))
-	}
-	youReq.Header.Add("Cookie", strings.Join(cookieStrings, ";"))
-
-	// 根据 OpenAI 请求的 stream 参数选择处理函数
-	if !openAIReq.Stream {
-		handleNonStreamingResponse(w, youReq) // 处理非流式响应
+	// 客户端断开时取消上游请求
+	ctx, release := withDisconnect(r.Context())
+	defer release()
+
+	// 视觉请求中的内联图片需要先上传为 You.com 附件，这里只做解码与校验，dry run 不会上传
+	images, err := decodeInlineImages(openAIReq.Messages)
+	if err != nil {
+		clientError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: %s", err)
 		return
 	}
 
-	handleStreamingResponse(w, youReq) // 处理流式响应
-}
+	if currentConfig().ReportContextBudget {
+		setContextBudgetHeaders(w, rs.UpstreamModel, openAIReq.Messages)
+	}
 
-// getCookies 根据提供的 DS token 生成所需的 Cookie。
-func getCookies(dsToken string) map[string]string {
-	return map[string]string{
-		"guest_has_seen_legal_disclaimer": "true",
-		"youchat_personalization":         "true",
-		"DS":                              dsToken, // 关键的 DS token
-		"you_subscription":                "youpro_standard_year", // 示例订阅信息
-		"youpro_subscription":             "true",
-		"ai_model":                        "deepseek_r1", // 示例 AI 模型
-		"youchat_smart_learn":             "true",
+	// 自动附加会话中已上传的文件，只附加由实际发出请求的账号上传的文件
+	var sessionFiles []sessionFile
+	if sessionID := r.Header.Get(sessionHeader); sessionID != "" && featureEnabled(features.SessionStickiness) {
+		sessionFiles = sessions.get(sessionOwner(r.Context(), apiKey), sessionID)
 	}
-}
 
-// handleNonStreamingResponse 处理非流式请求。
-func handleNonStreamingResponse(w http.ResponseWriter, youReq *http.Request) {
-	client := &http.Client{
-		Timeout: 60 * time.Second, // 设置超时时间
+	// dry run 不占用账号：预览以客户端凭据构建，请求头中的凭据已脱敏，采样的浏览器指纹可能与实际请求不同
+	if openAIReq.DryRun {
+		preview, err := buildYouRequest(ctx, openAIReq, rs.UpstreamModel, apiKey)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternalError, err.Error())
+			return
+		}
+		addSources(preview, sourcesFor(sessionFiles, apiKey))
+		writeDryRun(w, openAIReq, preview, rs)
+		return
 	}
-	resp, err := client.Do(youReq)
+	stages.mark(stageParse)
+
+	// 账号池：选择未在冷却且未达到并发上限的账号，补全结束后按结果更新账号状态。
+	// 请求在校验、异步与 dry run 分支之后才占用账号，无效请求不会占用并发名额或账号额度。
+	// 可以合并的非流式请求先合并再占用账号，被合并的请求不占用账号，见 coalesce.go
+	fanout := openAIReq.N > 1 && featureEnabled(features.Batching) // 并行生成多个候选回复
+	rs.ClientKeyID = keyID(apiKey)
+	rs.Deferred = currentConfig().CoalesceRequests && !openAIReq.Stream && !fanout && len(images) == 0
+	var dsToken string
+	var lease *tokenLease
+	if !rs.Deferred {
+		dsToken, lease, err = acquireDSToken(withTenant(r.Context(), requestTenant(r, apiKey)), apiKey)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+	}
+	defer lease.release()
+	stages.mark(stageAcquire)
+
+	youReq, err := buildYouRequest(ctx, openAIReq, rs.UpstreamModel, dsToken)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternalError, err.Error())
 		return
 	}
-	defer resp.Body.Close()
+	sources := sourcesFor(sessionFiles, dsToken)
+	addSources(youReq, sources)
+
+	var imageSources []youSource
+	if len(images) > 0 {
+		imageSources, err = uploadInlineImages(ctx, dsToken, images)
+		if err != nil {
+			clientError(w, r, http.StatusBadGateway, apierror.CodeUpstreamUploadFailed, "Failed to upload image: %s", logger.ScrubError(err, dsToken))
+			return
+		}
+		sources = append(sources, imageSources...)
+		addSources(youReq, sources)
+	}
+	rs.Rebuild = func(youModel string) (*http.Request, *tokenLease, error) {
+		token, retryLease, err := acquireDSToken(withTenant(r.Context(), requestTenant(r, apiKey)), apiKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		req, err := buildYouRequest(ctx, openAIReq, youModel, token)
+		if err != nil {
+			retryLease.release()
+			return nil, nil, err
+		}
+		addSources(req, append(sourcesFor(sessionFiles, token), imageSources...))
+		return req, retryLease, nil
+	}
 
-	var fullResponse strings.Builder
-	scanner := bufio.NewScanner(resp.Body)
+	// 为请求分配 ID，审计日志与重放工具通过该 ID 关联请求
+	// 客户端可以通过 X-Request-ID 或 Idempotency-Key 指定 ID，响应、日志与审计记录都使用该 ID
+	// 未指定时生成基于 UUID 的 ID，同一次补全的所有流式块与非流式响应都使用它
+	entry := newAuditEntry(openAIReq)
+	responseID := requestedResponseID(r, apiKey)
+	if responseID == "" {
+		responseID = newCompletionID()
+	}
+	entry.ID = responseID
+	entry.ClientIP = clientIP(r)
+	w.Header().Set(requestIDHeader, entry.ID)
+	logger.L().Info("收到补全请求",
+		zap.String("request_id", entry.ID),
+		zap.String("client_ip", entry.ClientIP),
+		zap.String("model", rs.RequestedModel),
+		zap.Bool("stream", openAIReq.Stream))
+
+	// 登记为进行中的补全，管理员可以通过 /admin/streams 查看或取消
+	ctx, done, err := inflight.register(ctx, &inflightCompletion{
+		ID:         entry.ID,
+		Model:      rs.Model,
+		KeyID:      keyID(apiKey),
+		ClientIP:   entry.ClientIP,
+		Stream:     openAIReq.Stream,
+		StartTime:  entry.Time,
+		ResponseID: responseID,
+		stages:     stages,
+	})
+	if err != nil {
+		apierror.Write(w, http.StatusConflict, apierror.CodeDuplicateRequestID, err.Error())
+		return
+	}
+	defer done()
+	if autoDecision != nil {
+		ctx = context.WithValue(ctx, autoModelKey{}, autoDecision)
+	}
+	ctx = withResponseLimit(ctx, responseLimitFor(apiKey).tighter(principal.responseLimit()).tighter(requestedLimit))
+	ctx = withStopSequences(ctx, stops)
+	// 按 CORPUS_SAMPLE_RATE 抽样记录回归测试语料，见 corpus.go
+	rec := newCorpusRecorder(r, body)
+	ctx = rec.context(ctx)
+	stages.mark(stageBuild)
+	ctx = withStageTimer(ctx, stages)
+	youReq = youReq.WithContext(ctx)
+
+	// 客户端接受时压缩流式响应，见 sse_gzip.go
+	if openAIReq.Stream {
+		gw, finish := newGzipEventWriter(w, r)
+		defer finish()
+		w = gw
+	}
+	// 配置了签名密钥时对响应签名，便于下游校验响应未被篡改
+	if s := getSigner(); s != nil {
+		sw := newSignedResponseWriter(w, s, openAIReq.Stream)
+		defer sw.finish()
+		w = sw
+	}
+	w = rec.writer(w)
+	// 流式响应的事件带有 id 与 retry 字段，并保存为事件记录供客户端断线重连
+	if openAIReq.Stream {
+		tw, finish := newTranscriptWriter(ctx, w, responseID, keyID(apiKey))
+		defer finish()
+		w = tw
+	}
+
+	// 分阶段耗时在响应结束后才完整，以 trailer 返回
+	timing := wantsTiming(r)
+	if timing {
+		w.Header().Add("Trailer", serverTimingHeader)
+	}
+
+	// 根据 OpenAI 请求的 stream 与 n 参数选择处理函数
+	var content string
+	if fanout && !openAIReq.Stream {
+		content, err = handleFanoutResponse(w, youReq, rs, lease, openAIReq.N)
+	} else if fanout {
+		content, err = handleFanoutStream(w, youReq, rs, lease, openAIReq.N)
+	} else if !openAIReq.Stream {
+		plain := wantsPlainText(r.Header.Get("Accept"))
+		content, err = handleNonStreamingResponse(w, youReq, rs, plain) // 处理非流式响应
+	} else {
+		content, err = handleStreamingResponse(w, youReq, rs) // 处理流式响应
+	}
+	stages.mark(stageComplete)
+	stages.observe(entry.ID, rs.UpstreamModel)
+	if timing {
+		w.Header().Set(serverTimingHeader, stages.serverTiming())
+	}
+	if clientDisconnected(ctx) {
+		err = errClientDisconnected
+		metrics.ClientDisconnects.WithLabelValues(strconv.FormatBool(openAIReq.Stream)).Inc()
+		logger.L().Info("客户端已断开，已取消上游请求", zap.String("request_id", entry.ID))
+	}
+	stages.end(entry.ID, rs.UpstreamModel, err)
+	recordAudit(entry, content, err)
+	rec.save(entry.ID, err)
+	modelStatus.record(rs.UpstreamModel, err)
+	lease.record(err)
+	completionTokens := countTokens(rs.UpstreamModel, content)
+	dailySummary.record(rs.UpstreamModel, time.Since(entry.Time), rs.PromptTokens, completionTokens, err)
+	if err == nil {
+		outputStats.record(rs.UpstreamModel, completionTokens)
+		conversations.save(context.WithoutCancel(r.Context()), entry.ID, keyID(apiKey), history, content)
+	}
+}
+
+// buildYouRequest 根据 OpenAI 请求构建 You.com streamingSearch 请求，youModel 为已解析的 You.com 模型名称。
+func buildYouRequest(ctx context.Context, openAIReq OpenAIRequest, youModel, dsToken string) (*http.Request, error) {
+	// 构建 You.com API 查询参数
+	q := url.Values{}
+	system, chat := splitSystemMessages(openAIReq.Messages) // system 消息作为自定义指令发送
+	translate.SetChatQuery(q, chat)
+	for _, p := range currentConfig().UpstreamParams {
+		if p.Enabled {
+			q.Add(p.Name, p.Value) // 固定参数，可通过 UPSTREAM_PARAMS 调整
+		}
+	}
+	q.Add("selectedAiModel", youModel) // 映射后的模型名称
+	addSamplingQuery(q, openAIReq.SamplingParams)
+	explicit, _ := customInstructions(openAIReq)
+	if instructions := translate.JoinInstructions(explicit, system); instructions != "" {
+		q.Add(translate.CustomInstructionsParam, instructions) // 自定义指令
+	}
+	fp := newRequestFingerprint(dsToken)
+	fp.setMarket(q) // 按账号所在地区选择搜索地区，见 fingerprint.go
 
-	// 设置 scanner 的缓冲区大小（可选，但对于大型响应很重要）
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
+	youReq, err := upstream.NewStreamingRequest(ctx, q, dsToken)
+	if err != nil {
+		return nil, err
+	}
+	fp.apply(youReq.Header) // User-Agent 与客户端提示来自浏览器指纹
+	return youReq, nil
+}
 
-	// 逐行扫描响应，寻找 youChatToken 事件
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "event: youChatToken") {
-			scanner.Scan() // 读取下一行 (data 行)
-			data := scanner.Text()
-			if !strings.HasPrefix(data, "data: ") {
-				continue // 如果不是 data 行，则跳过
+// logSkippedEvent 记录一个因超过 SSE_MAX_EVENT_BYTES 而被跳过的上游事件。
+func logSkippedEvent(ctx context.Context) {
+	logger.L().Warn("上游事件超过最大大小，已跳过",
+		zap.String("response_id", completionID(ctx)),
+		zap.Int("max_bytes", currentConfig().SSEMaxEventBytes))
+}
+
+// fetchCompletion 请求 You.com 并收集完整的回复内容与元数据（非流式）。
+func fetchCompletion(youReq *http.Request) (*upstreamResult, error) {
+	youReq, guard := guardFirstToken(youReq)
+	defer guard.stop()
+
+	result := &upstreamResult{}
+	stream, err := completionClient(60 * time.Second).Stream(youReq)
+	if err != nil {
+		return result, guard.wrap(err)
+	}
+	defer stream.Close()
+
+	var fullResponse, thinking strings.Builder
+	latestUpdate := "" // 使用 youChatUpdate 累积更新的模型的最新完整回答
+	budget := newResponseBudget(youReq.Context(), youReq.URL.Query().Get("selectedAiModel"))
+	stops := &stopScanner{seqs: stopSequencesFrom(youReq.Context())}
+	scrubber := newPIIScrubber(currentConfig().ScrubAccountData)
+
+	// 逐个读取事件，寻找回复内容与搜索事件
+	var readErr error
+	upstreamDone := false
+events:
+	for {
+		ev, err := stream.Next()
+		if err != nil {
+			if err != io.EOF {
+				readErr = err
 			}
-			var token YouChatResponse
-			if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &token); err != nil {
-				continue // 如果解析失败，则跳过
+			break
+		}
+		schemaDrift.observe(ev.Name, ev.Data)
+		if ev.Kind != upstream.KindToken && ev.Kind != upstream.KindUpdate && ev.Kind != upstream.KindInvalid && ev.Kind != upstream.KindThinking {
+			scrubber.learn(ev.Data) // 元数据事件可能带有账号信息
+		}
+
+		switch ev.Kind {
+		case upstream.KindToken:
+			allowed, exhausted := budget.take(ev.Text)
+			fullResponse.WriteString(allowed) // 将 token 添加到完整响应中
+			guard.tokenReceived()
+			countToken(youReq.Context())
+			if i := stops.scan(fullResponse.String()); i >= 0 {
+				// 出现停止序列，截断回复并不再读取剩余的输出
+				result.Content = scrubber.scrub(fullResponse.String()[:i])
+				result.Reasoning = scrubber.scrub(thinking.String())
+				result.SearchQueries = scrubber.scrubMetadata(result.SearchQueries)
+				return result, nil
 			}
-			fullResponse.WriteString(token.YouChatToken) // 将 token 添加到完整响应中
+			if exhausted {
+				result.Truncated = true
+				budget.logTruncated(youReq.Context())
+				result.Content = scrubber.scrub(fullResponse.String())
+				result.Reasoning = scrubber.scrub(thinking.String())
+				result.SearchQueries = scrubber.scrubMetadata(result.SearchQueries)
+				return result, nil // 不再读取剩余的输出
+			}
+		case upstream.KindUpdate:
+			latestUpdate = ev.Text // 累积更新只需要保留最后一个快照
+			guard.tokenReceived()
+			countToken(youReq.Context())
+			if i := stops.scan(latestUpdate); i >= 0 {
+				latestUpdate = latestUpdate[:i]
+				upstreamDone = true
+				break events
+			}
+		case upstream.KindThinking:
+			thinking.WriteString(ev.Text)
+			guard.tokenReceived()
+		case upstream.KindSearch:
+			result.SearchQueries = appendUnique(result.SearchQueries, extractSearchQueries(ev.Data)...)
+			result.Sources = appendSources(result.Sources, extractSources(ev.Data)...)
+		case upstream.KindError:
+			return result, ev.Err
+		case upstream.KindSafety:
+			result.DoneReason = ev.FinishReason // 上游拦截了回复，保留已生成的部分
+			upstreamDone = true
+			break events
+		case upstream.KindDone:
+			result.DoneReason = ev.FinishReason
+			upstreamDone = true
 		}
 	}
 
-	if scanner.Err() != nil {
-		http.Error(w, "Error reading response", http.StatusInternalServerError)
-		return
+	result.Content = fullResponse.String()
+	if result.Content == "" && latestUpdate != "" {
+		result.Content, result.Truncated = budget.take(latestUpdate)
+		if result.Truncated {
+			budget.logTruncated(youReq.Context())
+		}
+	}
+	result.Content = scrubber.scrub(result.Content)
+	result.Reasoning = scrubber.scrub(thinking.String())
+	result.SearchQueries = scrubber.scrubMetadata(result.SearchQueries)
+	if readErr != nil {
+		return result, fmt.Errorf("Error reading response: %w", guard.wrap(readErr))
+	}
+	if !upstreamDone {
+		return result, errIncompleteStream // 连接在 done 事件之前结束，回复可能不完整
+	}
+	return result, nil
+}
+
+// handleNonStreamingResponse 处理非流式请求，返回完整的回复内容。
+// plain 为 true 时（客户端 Accept: text/plain）只返回回复文本，便于 shell 脚本使用。
+func handleNonStreamingResponse(w http.ResponseWriter, youReq *http.Request, rs *requestState, plain bool) (string, error) {
+	result, shared, err := fetchCompletionCoalesced(youReq, rs)
+	if shared {
+		w.Header().Set(coalescedHeader, "true")
+	}
+	var accountErr *accountError
+	if errors.As(err, &accountErr) {
+		writeAccountError(w, accountErr.err)
+		return "", err
+	}
+	if err != nil {
+		status, code := upstreamErrorCode(err)
+		apierror.Write(w, status, code, logger.ScrubError(err))
+		return result.Content, err
+	}
+	result, outputRetry := retryBadOutput(youReq.Context(), w, rs, result)
+	content := newWhitespaceNormalizer(currentConfig().NormalizeWhitespace).normalize(rs.VM.sanitize(newReasoningFilter(rs.ReasoningMode, requestLanguage(youReq)).apply(repairEncoding(result.Content))))
+	var structuredReport *StructuredOutputReport
+	if rs.Structured != nil {
+		content, structuredReport = rs.Structured.ensure(youReq, content)
+	}
+	content = newCodeFenceTagger(currentConfig().TagCodeFences).tag(content)
+	if content, err = applyPostResponsePlugins(youReq.Context(), rs.Model, content); err != nil {
+		clientError(w, youReq, http.StatusBadGateway, apierror.CodeResponseRejected, "Response rejected by plugin: %s", err)
+		return "", err
+	}
+	text, toolCalls := rs.Tools.split(content)                                      // 返回的 content 保留工具调用标记，保存到对话历史中
+	finishReason := structuredFinishReason(structuredReport, result.finishReason()) // 停止原因，超出最大响应大小时为 length
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	if plain {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, text)
+		return content, nil
 	}
 
 	// 构建 OpenAI 格式的非流式响应
 	openAIResp := OpenAIResponse{
-		ID:      "chatcmpl-" + fmt.Sprintf("%d", time.Now().Unix()),
+		ID:      completionID(youReq.Context()),
 		Object:  "chat.completion",
 		Created: time.Now().Unix(),
-		Model:   reverseMapModelName(mapModelName(originalModel)), // 映射回 OpenAI 模型名称
+		Model:   rs.Model, // 映射回 OpenAI 模型名称
 		Choices: []OpenAIChoice{
 			{
 				Message: Message{
-					Role:    "assistant",
-					Content: fullResponse.String(), // 完整的响应内容
+					Role:             "assistant",
+					Content:          text, // 完整的响应内容
+					ReasoningContent: rs.reasoningContent(repairEncoding(result.Reasoning)),
+					Annotations:      citationAnnotations(text, result.Sources),
+					ToolCalls:        toolCalls,
 				},
 				Index:        0,
-				FinishReason: "stop", // 停止原因
+				FinishReason: finishReason,
 			},
 		},
+		Usage:            rs.usage(content),
+		Citations:        citationURLs(result.Sources),
+		ProviderMetadata: withOutputRetry(withIgnoredParams(withStructuredOutput(withAutoModel(youReq.Context(), result.providerMetadata()), structuredReport), rs.IgnoredParams), outputRetry),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(openAIResp); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-		return
+		clientError(w, youReq, http.StatusInternalServerError, apierror.CodeInternalError, "Error encoding response")
+		return content, err
 	}
+	return content, nil
 }
 
-// handleStreamingResponse 处理流式请求。
-func handleStreamingResponse(w http.ResponseWriter, youReq *http.Request) {
-	client := &http.Client{} // 流式请求不需要设置超时，因为它会持续接收数据
-	resp, err := client.Do(youReq)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// sendDone 发送 OpenAI 流式响应的结束标记。
+func sendDone(w http.ResponseWriter) {
+	io.WriteString(w, "data: [DONE]\n\n")
+	w.(http.Flusher).Flush()
+}
+
+// handleStreamingResponse 处理流式请求，返回已发送给客户端的完整内容。
+// 上游连接失败、中途断开或返回空内容时按 UPSTREAM_RETRIES 重试，
+// 重试产生的内容通过 streamSplicer 与已发送部分拼接。
+func handleStreamingResponse(w http.ResponseWriter, youReq *http.Request, rs *requestState) (string, error) {
+	client := completionClient(0) // 流式请求不需要设置超时，因为它会持续接收数据
+
+	activeStreams.Add(1)
+	defer activeStreams.Add(-1)
+
+	// 同一次补全的所有块（包括重试后的块）共享相同的 ID 与创建时间
+	id := completionID(youReq.Context())
+	created := time.Now().Unix()
+
+	splicer := &streamSplicer{}
+	thinking := &streamSplicer{} // 思考过程与回复内容分别拼接
+	normalizer := newWhitespaceNormalizer(currentConfig().NormalizeWhitespace)
+	tagger := newCodeFenceTagger(currentConfig().TagCodeFences)
+	scrubber := newPIIScrubber(currentConfig().ScrubAccountData)
+	reasoning := newReasoningFilter(rs.ReasoningMode, requestLanguage(youReq))
+	sanitizer := newSanitizeStream(rs.VM)
+	toolStream := newToolCallStream(rs.Tools) // 可能是工具调用的内容暂不发送
+	headersSent := false
+	var searchQueries []string
+	var sources []Source
+	var sent strings.Builder // 已发送给客户端的回复内容，用于计算引用标注的位置
+	var lastErr error
+	lastEventID := "" // 上游最后一个事件的 ID，重连时通过 Last-Event-ID 请求断点续传
+	budget := newResponseBudget(youReq.Context(), youReq.URL.Query().Get("selectedAiModel"))
+	truncated := false // 超出最大响应大小，停止生成
+	stopper := newStopStream(stopSequencesFrom(youReq.Context()))
+	stopped := false  // 出现停止序列，停止生成
+	roleSent := false // 第一个块需要带上 role，与 OpenAI 的流式格式一致
+
+	// send 发送一个流式响应块并立即刷新，写入失败说明客户端已断开，此时立即取消上游请求
+	send := func(chunk OpenAIStreamResponse) {
+		if !roleSent && len(chunk.Choices) > 0 {
+			chunk.Choices[0].Delta.Role = "assistant"
+			roleSent = true
+		}
+		respBytes, _ := json.Marshal(chunk)
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", respBytes); err != nil {
+			markDisconnected(youReq.Context())
+			return
+		}
+		w.(http.Flusher).Flush()
 	}
-	defer resp.Body.Close()
-
-	// 设置流式响应的头部
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
-	scanner := bufio.NewScanner(resp.Body)
-	// 逐行扫描响应，寻找 youChatToken 事件
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if strings.HasPrefix(line, "event: youChatToken") {
-			scanner.Scan()        // 读取下一行 (data 行)
-			data := scanner.Text() // 获取数据行
-
-			var token YouChatResponse
-			json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &token) // 解析 JSON
-
-			// 构建 OpenAI 格式的流式响应块
-			openAIResp := OpenAIStreamResponse{
-				ID:      "chatcmpl-" + fmt.Sprintf("%d", time.Now().Unix()),
-				Object:  "chat.completion.chunk",
-				Created: time.Now().Unix(),
-				Model:   reverseMapModelName(mapModelName(originalModel)), // 映射回 OpenAI 模型名称
-				Choices: []Choice{
-					{
-						Delta: Delta{
-							Content: token.YouChatToken, // 增量内容
-						},
-						Index:        0,
-						FinishReason: "", // 流式响应中通常为空
+
+	// sendDelta 把处理后的内容转换为 OpenAI 格式的流式响应块并立即发送
+	sendDelta := func(delta string) {
+		delta, truncated = budget.take(delta)
+		if delta == "" {
+			return
+		}
+		rs.Structured.observe(delta)
+		sent.WriteString(delta)
+
+		// 构建 OpenAI 格式的流式响应块
+		openAIResp := OpenAIStreamResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   rs.Model, // 映射回 OpenAI 模型名称
+			Choices: []Choice{
+				{
+					Delta: Delta{
+						Content: delta, // 增量内容
 					},
+					Index:        0,
+					FinishReason: "", // 流式响应中通常为空
 				},
+			},
+		}
+
+		send(openAIResp)
+	}
+
+	// writeDelta 截断停止序列后发送内容
+	writeDelta := func(delta string) {
+		delta, stopped = stopper.push(delta)
+		sendDelta(delta)
+	}
+
+	// writeContent 依次经过各个内容处理步骤后发送
+	writeContent := func(text string) {
+		writeDelta(toolStream.push(tagger.push(normalizer.push(scrubber.scrub(sanitizer.push(reasoning.push(text)))))))
+	}
+
+	// writeToken 处理上游 token 后发送，重试时重复生成的前缀会被丢弃
+	writeToken := func(token string) {
+		writeContent(splicer.accept(token))
+	}
+
+	// writeThinking 把思考过程 token 作为 reasoning_content 发送，不经过回复内容的处理流程
+	writeThinking := func(token string) {
+		delta := rs.reasoningContent(scrubber.scrub(thinking.accept(token)))
+		if delta == "" {
+			return
+		}
+		send(OpenAIStreamResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   rs.Model,
+			Choices: []Choice{{Delta: Delta{ReasoningContent: delta}}},
+		})
+	}
+
+	// writeToolCalls 在回复结束时发送识别到的工具调用
+	writeToolCalls := func(calls []ToolCall) {
+		send(OpenAIStreamResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   rs.Model,
+			Choices: []Choice{{Delta: Delta{ToolCalls: toolCallDeltas(calls)}}},
+		})
+	}
+
+	// writeCitations 在回复结束时发送引用标注与来源列表
+	writeCitations := func() {
+		if len(sources) == 0 {
+			return
+		}
+		send(OpenAIStreamResponse{
+			ID:        id,
+			Object:    "chat.completion.chunk",
+			Created:   created,
+			Model:     rs.Model,
+			Choices:   []Choice{{Delta: Delta{Annotations: citationAnnotations(sent.String(), sources)}}},
+			Citations: citationURLs(sources),
+		})
+	}
+
+	// finish 发送带 finish_reason 的最后一个块与 [DONE] 结束标记，OpenAI SDK 依赖它们判断流结束
+	finish := func(reason string) {
+		send(OpenAIStreamResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   rs.Model,
+			Choices: []Choice{{Delta: Delta{}, Index: 0, FinishReason: reason}},
+		})
+		sendDone(w)
+	}
+
+	// writeMetadata 以不含 choices 的单独块发送响应元数据
+	writeMetadata := func(meta *ProviderMetadata) {
+		metaResp := OpenAIStreamResponse{
+			ID:               id,
+			Object:           "chat.completion.chunk",
+			Created:          created,
+			Model:            rs.Model,
+			Choices:          []Choice{},
+			ProviderMetadata: meta,
+		}
+		send(metaResp)
+	}
+
+	for attempt := 0; attempt <= currentConfig().UpstreamRetries; attempt++ {
+		attemptReq, guard := guardFirstToken(youReq)
+		if lastEventID != "" {
+			attemptReq = attemptReq.Clone(attemptReq.Context())
+			attemptReq.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resumeChecked := false
+		upstreamDone := false // 是否收到上游的终止事件
+		doneReason := "stop"  // 终止事件给出的 finish_reason
+		stream, err := client.Stream(attemptReq)
+		if err != nil {
+			guard.stop()
+			lastErr = guard.wrap(err)
+			if errors.Is(err, errTierRestricted) {
+				break // 订阅等级限制，重试也无济于事
+			}
+			continue
+		}
+		splicer.beginAttempt()
+		thinking.beginAttempt()
+		fixer := newMojibakeFixer(currentConfig().FixMojibake)
+		differ := &cumulativeDiffer{}
+
+		if !headersSent {
+			// 设置流式响应的头部
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Connection", "keep-alive")
+			headersSent = true
+			var meta *ProviderMetadata
+			if d := autoModelDecision(youReq.Context()); d != nil {
+				meta = &ProviderMetadata{AutoModel: d}
+			}
+			if meta = withIgnoredParams(meta, rs.IgnoredParams); meta != nil {
+				writeMetadata(meta)
+			}
+		}
+
+		var readErr, eventErr error
+		// 逐个读取事件，寻找回复内容
+	events:
+		for {
+			ev, err := stream.Next()
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				break
 			}
 
-			respBytes, _ := json.Marshal(openAIResp)                       // 将响应块序列化为 JSON
-			fmt.Fprintf(w, "data: %s\n\n", string(respBytes))              // 写入响应数据
-			w.(http.Flusher).Flush()                                     // 立即刷新输出
+			prevEventID := lastEventID
+			if ev.ID != "" {
+				if !resumeChecked {
+					resumeChecked = true
+					if isResumedEvent(lastEventID, ev.ID) {
+						splicer.resume()
+						thinking.resume()
+					}
+				}
+				lastEventID = ev.ID
+			}
+			schemaDrift.observe(ev.Name, ev.Data)
+
+			switch ev.Kind {
+			case upstream.KindToken:
+				guard.tokenReceived()
+				countToken(youReq.Context())
+
+				writeToken(fixer.push(ev.Text))
+				if truncated || stopped {
+					break events
+				}
+			case upstream.KindUpdate:
+				guard.tokenReceived()
+				countToken(youReq.Context())
+
+				// 累积快照转换为增量后与 youChatToken 走相同的处理流程
+				writeToken(fixer.push(differ.push(ev.Text)))
+				if truncated || stopped {
+					break events
+				}
+			case upstream.KindThinking:
+				guard.tokenReceived()
+				writeThinking(ev.Text)
+			case upstream.KindSearch:
+				scrubber.learn(ev.Data)
+				sources = appendSources(sources, extractSources(ev.Data)...)
+				queries := scrubber.scrubMetadata(extractSearchQueries(ev.Data))
+
+				// 只发送尚未发送过的查询（重试时上游会重复执行搜索）
+				var fresh []string
+				for _, q := range queries {
+					if !slices.Contains(searchQueries, q) {
+						searchQueries = append(searchQueries, q)
+						fresh = append(fresh, q)
+					}
+				}
+				if len(fresh) == 0 {
+					continue
+				}
+
+				writeMetadata(&ProviderMetadata{SearchQueries: fresh})
+			case upstream.KindDone:
+				upstreamDone, doneReason = true, ev.FinishReason // 上游生成结束
+			case upstream.KindSafety:
+				upstreamDone, doneReason = true, ev.FinishReason // 上游拦截了回复，已发送的部分无法撤回
+				break events
+			case upstream.KindError:
+				eventErr = ev.Err
+				lastEventID = prevEventID // 重试时从错误事件之前续传
+				break events
+			case upstream.KindInvalid:
+				continue
+			default:
+				scrubber.learn(ev.Data) // 其他事件只用于结构漂移检测与学习需要清理的账号信息
+			}
+		}
+		stream.Close()
+		guard.stop()
+		if truncated {
+			// 以 finish_reason 为 length 的块结束响应
+			budget.logTruncated(youReq.Context())
+			writeCitations()
+			finish("length")
+			return splicer.content(), nil
+		}
+		writeToken(fixer.flush())
+		if stopped {
+			upstreamDone = true // 停止序列截断了回复，无需等待上游结束
+		}
+
+		lastErr = guard.wrap(readErr)
+		if lastErr == nil {
+			lastErr = eventErr // 上游报告生成失败，按失败处理（可以重试）
+		}
+		if lastErr == nil && splicer.attemptRunes == 0 && !stopped && doneReason != "content_filter" {
+			lastErr = errEmptyCompletion
+		}
+		if lastErr == nil && !upstreamDone {
+			lastErr = errIncompleteStream // 连接在 done 事件之前结束，按中途断开重试
+		}
+		if lastErr == nil {
+			writeDelta(toolStream.push(tagger.push(normalizer.push(scrubber.scrub(sanitizer.push(reasoning.flush()))))))
+			writeDelta(toolStream.push(tagger.push(normalizer.push(scrubber.scrub(sanitizer.flush())))))
+			writeDelta(toolStream.push(tagger.flush()))
+			text, toolCalls := toolStream.flush()
+			writeDelta(text)
+			sendDelta(stopper.flush())
+			report := rs.Structured.streamReport()
+			if report != nil {
+				writeMetadata(&ProviderMetadata{StructuredOutput: report})
+			}
+			writeCitations()
+			if len(toolCalls) > 0 {
+				writeToolCalls(toolCalls)
+				finish("tool_calls")
+				return splicer.content(), nil
+			}
+			finish(structuredFinishReason(report, doneReason))
+			return splicer.content(), nil
+		}
+		if youReq.Context().Err() != nil {
+			break // 客户端已断开，无需重试
 		}
 	}
 
-	// 通常情况下，流式响应不需要在这里处理 scanner.Err()，
-	// 因为连接会保持打开状态，直到客户端关闭或发生错误。
-	// 如果需要处理错误，可以在这里添加，但要确保正确处理连接关闭。
+	status, code := upstreamErrorCode(lastErr)
+	if !headersSent {
+		apierror.Write(w, status, code, logger.ScrubError(lastErr))
+	} else if !clientDisconnected(youReq.Context()) {
+		// 已经开始流式输出时以错误块结束，避免客户端一直等待
+		data, _ := json.Marshal(apierror.Response{Error: apierror.Detail{
+			Message: logger.ScrubError(lastErr),
+			Type:    apierror.Type(status),
+			Code:    code,
+		}})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		sendDone(w)
+	}
+	return splicer.content(), lastErr
 }
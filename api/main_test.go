@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	pool "you2api/pool"
+)
+
+func TestConcurrentRequestsReportOwnModel(t *testing.T) {
+	withMockUpstream(t, "model")
+	models := []string{"gpt-4o", "claude-3.5-sonnet", "claude-3-opus"}
+
+	recs := make([]*httptest.ResponseRecorder, 30)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recs[i] = postChat(t, fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":"request %d"}]}`, models[i%len(models)], i))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, rec := range recs {
+		model := models[i%len(models)]
+		resp := decodeCompletion(t, rec)
+		if want := reverseMapModelName(mapModelName(model)); resp.Model != want {
+			t.Errorf("request %d: response model = %q, want %q", i, resp.Model, want)
+		}
+		// 上游收到的也必须是本请求的模型
+		want := fmt.Sprintf("[mock:%s] request %d", mapModelName(model), i)
+		if content := resp.Choices[0].Message.Content; !strings.HasPrefix(content, want) {
+			t.Errorf("request %d: content = %q, want prefix %q", i, content, want)
+		}
+	}
+}
+
+// decodeStream 解析流式补全响应，返回各数据块与流是否以 [DONE] 结束。
+func decodeStream(t *testing.T, rec *httptest.ResponseRecorder) (chunks []OpenAIStreamResponse, done bool) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if done {
+			t.Fatalf("data after [DONE]: %s", data)
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk OpenAIStreamResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("decode chunk %q: %v", data, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, done
+}
+
+func TestStreamEndsWithFinishReasonAndDone(t *testing.T) {
+	withMockUpstream(t, "echo")
+	chunks, done := decodeStream(t, postChat(t, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"ping pong"}]}`))
+	if !done {
+		t.Fatal("stream did not end with data: [DONE]")
+	}
+
+	var content strings.Builder
+	finishes := 0
+	for i, chunk := range chunks {
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			if choice.FinishReason == "" {
+				continue
+			}
+			finishes++
+			if choice.FinishReason != "stop" {
+				t.Errorf("finish_reason = %q, want stop", choice.FinishReason)
+			}
+			if choice.Delta.Content != "" {
+				t.Errorf("final chunk carries content %q", choice.Delta.Content)
+			}
+			for _, later := range chunks[i+1:] {
+				if len(later.Choices) > 0 && later.Choices[0].Delta.Content != "" {
+					t.Errorf("content %q sent after the finish_reason chunk", later.Choices[0].Delta.Content)
+				}
+			}
+		}
+	}
+	if finishes != 1 {
+		t.Errorf("got %d chunks with finish_reason, want exactly 1", finishes)
+	}
+	if got := content.String(); got != "ping pong" {
+		t.Errorf("streamed content = %q, want %q", got, "ping pong")
+	}
+}
+
+func TestStreamRoleOnlyInFirstDelta(t *testing.T) {
+	withMockUpstream(t, "echo")
+	chunks, _ := decodeStream(t, postChat(t, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"one two three"}]}`))
+
+	first := true
+	for _, chunk := range chunks {
+		if len(chunk.Choices) == 0 {
+			continue // provider_metadata 等扩展块不带 choices
+		}
+		role := chunk.Choices[0].Delta.Role
+		if first && role != "assistant" {
+			t.Errorf("first delta role = %q, want assistant", role)
+		}
+		if !first && role != "" {
+			t.Errorf("later delta repeats role %q", role)
+		}
+		first = false
+	}
+	if first {
+		t.Fatal("stream carried no choice chunks")
+	}
+}
+
+func TestCompletionIDSharedAcrossChunks(t *testing.T) {
+	withMockUpstream(t, "echo")
+	body := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"one two three"}]}`
+
+	seen := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		rec := postChat(t, body)
+		chunks, _ := decodeStream(t, rec)
+		id := rec.Header().Get(requestIDHeader)
+		if !strings.HasPrefix(id, "chatcmpl-") {
+			t.Fatalf("%s = %q, want a chatcmpl- ID", requestIDHeader, id)
+		}
+		for _, chunk := range chunks {
+			if chunk.ID != id {
+				t.Errorf("chunk ID = %q, want %q for every chunk", chunk.ID, id)
+			}
+		}
+		if seen[id] {
+			t.Errorf("ID %q reused by a second request", id)
+		}
+		seen[id] = true
+	}
+
+	rec := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if resp := decodeCompletion(t, rec); resp.ID != rec.Header().Get(requestIDHeader) || seen[resp.ID] {
+		t.Errorf("non-stream ID = %q, want the new request ID %q", resp.ID, rec.Header().Get(requestIDHeader))
+	}
+}
+
+// withTestPool 用给定 token 的账号替换账号池，返回访问账号池的 key。
+func withTestPool(t *testing.T, tokens ...string) string {
+	t.Helper()
+	const accessKey = "pool-access-key"
+	prev := currentConfig()
+	conf := *prev
+	conf.PoolAccessKey = accessKey
+	conf.TokenState.MaxConcurrency = 1
+	setConfig(&conf)
+
+	var accounts []*pool.Account
+	for _, token := range tokens {
+		accounts = append(accounts, &pool.Account{Name: token, Token: token})
+	}
+	getTokenPool()
+	tokenPoolMu.Lock()
+	prevPool, prevData := tokenPool, tokenPoolData
+	tokenPool, tokenPoolData = pool.New(accounts...), nil
+	tokenPoolMu.Unlock()
+	t.Cleanup(func() {
+		tokenPoolMu.Lock()
+		tokenPool, tokenPoolData = prevPool, prevData
+		tokenPoolMu.Unlock()
+		setConfig(prev)
+	})
+	return accessKey
+}
+
+// withBusyPool 在测试期间使用只有一个账号、且唯一的并发名额已被占用的账号池，返回账号池访问密钥。
+func withBusyPool(t *testing.T) string {
+	t.Helper()
+	accessKey := withTestPool(t, "busy-pool-token")
+	_, lease, ok := tryAcquireAccount(context.Background(), getTokenPool())
+	if !ok {
+		t.Fatal("could not occupy the pool account")
+	}
+	t.Cleanup(lease.release)
+	return accessKey
+}
+
+func TestAcquireDSTokenRotation(t *testing.T) {
+	accessKey := withTestPool(t, "token-a", "token-b", "token-c", "token-d")
+	for _, fair := range []bool{false, true} {
+		conf := *currentConfig()
+		conf.FairQueue.Enabled = fair
+		setConfig(&conf)
+
+		// 每次占用账号只推进一次轮询位置
+		var got []string
+		for i := 0; i < 5; i++ {
+			token, lease, err := acquireDSToken(context.Background(), accessKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lease.release()
+			got = append(got, token)
+		}
+		seen := map[string]bool{}
+		for _, token := range got[:4] {
+			seen[token] = true
+		}
+		if len(seen) != 4 || got[4] != got[0] {
+			t.Errorf("fair queue %v: tokens = %v, want each account once in turn", fair, got)
+		}
+	}
+}
+
+func TestLeaseAcquiredAfterValidation(t *testing.T) {
+	withMockUpstream(t, "echo")
+	accessKey := withBusyPool(t)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"invalid body", `{"model":"gpt-4o","messages":"hi"}`, http.StatusBadRequest},
+		{"unsupported n", `{"model":"gpt-4o","n":1000,"messages":[{"role":"user","content":"hi"}]}`, http.StatusBadRequest},
+		{"async without callback", `{"model":"gpt-4o","async":true,"messages":[{"role":"user","content":"hi"}]}`, http.StatusBadRequest},
+		{"dry run", `{"model":"gpt-4o","dry_run":true,"messages":[{"role":"user","content":"hi"}]}`, http.StatusOK},
+		// 只有真正请求上游时才需要账号
+		{"completion", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+accessKey)
+			rec := httptest.NewRecorder()
+			Handler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
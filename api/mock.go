@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	upstream "you2api/internal/upstream"
+)
+
+// mockTransport 在 MOCK_MODE 下替代真实的 You.com 连接，按 You.com 的 SSE 格式返回合成的回复，
+// 这样请求仍会经过完整的解析与转换流程，前端开发无需 DS token 即可联调。
+type mockTransport struct {
+	style string        // echo | lorem | model | update | error | thinking | safety
+	delay time.Duration // 每个事件之间的间隔
+}
+
+const loremText = "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua."
+
+func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.URL.Path {
+	case "/api/get_nonce":
+		return mockResponse(req, "text/plain", "mock-nonce"), nil
+	case "/api/upload":
+		return mockUpload(req)
+	case "/api/get_ai_models":
+		return mockModels(req), nil
+	}
+
+	query := req.URL.Query()
+	prompt := query.Get("q")
+	model := query.Get("selectedAiModel")
+
+	var text string
+	switch t.style {
+	case "lorem":
+		text = loremText
+	case "model":
+		text = fmt.Sprintf("[mock:%s] %s", model, prompt)
+	default:
+		text = prompt
+	}
+
+	// 每个事件带递增的 id，并像支持断点续传的服务端一样跳过 Last-Event-ID 之前的事件
+	resumeAfter, err := strconv.Atoi(req.Header.Get("Last-Event-ID"))
+	if err != nil {
+		resumeAfter = -1
+	}
+	var events []string
+	nextID := 0
+	writeEvent := func(event, data string) {
+		if nextID > resumeAfter {
+			events = append(events, fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", nextID, event, data))
+		}
+		nextID++
+	}
+	searchData, _ := json.Marshal(map[string]interface{}{"search": map[string]interface{}{
+		"query": prompt,
+		"third_party_search_results": []map[string]string{
+			{"name": "Mock source", "url": "https://example.com/mock-source", "snippet": "Search result for " + prompt},
+		},
+	}})
+	writeEvent("thirdPartySearchResults", string(searchData))
+	if t.style == "thinking" {
+		// 模拟推理模型：先以单独的事件发送思考过程
+		for _, word := range strings.SplitAfter("Thinking about: "+prompt, " ") {
+			data, _ := json.Marshal(map[string]string{"youChatThinkingToken": word})
+			writeEvent("youChatThinkingToken", string(data))
+		}
+	}
+	var snapshot strings.Builder
+	for _, word := range strings.SplitAfter(text, " ") {
+		if t.style == "update" {
+			// 模拟以 youChatUpdate 发送累积快照的模型
+			snapshot.WriteString(word)
+			data, _ := json.Marshal(upstream.UpdateEvent{Text: snapshot.String()})
+			writeEvent("youChatUpdate", string(data))
+			continue
+		}
+		data, _ := json.Marshal(upstream.TokenEvent{YouChatToken: word})
+		writeEvent("youChatToken", string(data))
+	}
+	if t.style == "error" {
+		// 模拟生成中途失败：发送 youChatError 后不再发送 done
+		writeEvent("youChatError", `{"error":"mock upstream failure"}`)
+	} else if t.style == "safety" {
+		// 模拟安全策略拦截：以 youChatSafety 结束而不发送 done
+		writeEvent("youChatSafety", `{"reason":"safety"}`)
+	} else {
+		writeEvent("done", "I'm Mr. Meeseeks. Look at me.")
+	}
+
+	if t.delay > 0 {
+		resp := mockResponse(req, "text/event-stream", "")
+		resp.ContentLength = -1
+		resp.Body = &slowEventReader{ctx: req.Context(), events: events, delay: t.delay}
+		return resp, nil
+	}
+	return mockResponse(req, "text/event-stream", strings.Join(events, "")), nil
+}
+
+// slowEventReader 按固定间隔逐个返回事件，请求上下文取消时像真实连接一样中止读取。
+type slowEventReader struct {
+	ctx     context.Context
+	events  []string
+	delay   time.Duration
+	pending string
+}
+
+func (r *slowEventReader) Read(p []byte) (int, error) {
+	if r.pending == "" {
+		if len(r.events) == 0 {
+			return 0, io.EOF
+		}
+		select {
+		case <-time.After(r.delay):
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+		r.pending, r.events = r.events[0], r.events[1:]
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *slowEventReader) Close() error { return nil }
+
+// mockModels 返回本地映射表中的模型与一个映射表中没有的模型，用于验证模型发现。
+func mockModels(req *http.Request) *http.Response {
+	models := []map[string]string{{"id": "mock_discovered_model"}}
+	for _, youModel := range getModelMap() {
+		models = append(models, map[string]string{"id": youModel})
+	}
+	data, _ := json.Marshal(map[string]interface{}{"models": models})
+	return mockResponse(req, "application/json", string(data))
+}
+
+// mockUpload 模拟 You.com 的文件上传接口。
+func mockUpload(req *http.Request) (*http.Response, error) {
+	if err := req.ParseMultipartForm(maxUploadBytes); err != nil {
+		return nil, err
+	}
+	_, header, err := req.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(map[string]string{
+		"filename":      "mock-" + header.Filename,
+		"user_filename": header.Filename,
+	})
+	return mockResponse(req, "application/json", string(data)), nil
+}
+
+func mockResponse(req *http.Request, contentType, body string) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
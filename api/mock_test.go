@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	upstream "you2api/internal/upstream"
+)
+
+// testDSToken 是测试请求使用的客户端凭据，未配置账号池时直接作为 DS token 使用。
+const testDSToken = "test-ds-token"
+
+// withMockUpstream 在测试期间把上游替换为 MOCK_MODE 使用的合成回复。
+func withMockUpstream(t *testing.T, style string) {
+	t.Helper()
+	prev := upstreamClient
+	upstreamClient = func(timeout time.Duration) upstream.Doer {
+		return &http.Client{Transport: &mockTransport{style: style}, Timeout: timeout}
+	}
+	t.Cleanup(func() { upstreamClient = prev })
+}
+
+// postChat 通过完整的路由发送一次聊天补全请求，header 按名称、值成对传入。
+func postChat(t *testing.T, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testDSToken)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	Handler(rec, req)
+	return rec
+}
+
+// decodeCompletion 解析非流式补全响应。
+func decodeCompletion(t *testing.T, rec *httptest.ResponseRecorder) OpenAIResponse {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp OpenAIResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v; body %s", err, rec.Body)
+	}
+	return resp
+}
+
+func TestMockUpstreamStyles(t *testing.T) {
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{"echo", "ping pong"},
+		{"lorem", loremText},
+		{"update", "ping pong"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			withMockUpstream(t, tt.style)
+			resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"ping pong"}]}`))
+			if len(resp.Choices) != 1 || resp.Choices[0].Message.Content != tt.want {
+				t.Errorf("choices = %+v, want content %q", resp.Choices, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockUpstreamModelStyle(t *testing.T) {
+	withMockUpstream(t, "model")
+	resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	want := "[mock:" + mapModelName("gpt-4o") + "] hi"
+	if got := resp.Choices[0].Message.Content; got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestMockUpstreamError(t *testing.T) {
+	withMockUpstream(t, "error")
+	rec := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code < http.StatusInternalServerError {
+		t.Errorf("status = %d, want a 5xx for a mid-stream upstream failure; body %s", rec.Code, rec.Body)
+	}
+}
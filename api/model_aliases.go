@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	apierror "you2api/apierror"
+	logger "you2api/logger"
+)
+
+// modelAliasStore 保存通过 /admin/models/aliases 在运行时添加的全局模型别名，
+// 用于在不重新部署的情况下开放 You.com 新上线的模型。
+// 别名是模型映射的最后一层，优先于 MODEL_MAP 与 MODEL_MAP_FILE；目标可以是 OpenAI 模型名称或 You.com 模型名称。
+type modelAliasStore struct {
+	mu      sync.RWMutex
+	aliases map[string]string // 别名 -> 目标模型
+	path    string
+}
+
+var (
+	modelAliasesOnce sync.Once
+	modelAliases     *modelAliasStore
+)
+
+// getModelAliases 返回全局模型别名存储，首次调用时从 MODEL_ALIASES_FILE 加载。
+func getModelAliases() *modelAliasStore {
+	modelAliasesOnce.Do(func() {
+		modelAliases = &modelAliasStore{
+			aliases: make(map[string]string),
+			path:    currentConfig().ModelAliasesFile,
+		}
+		if err := modelAliases.load(); err != nil {
+			logger.L().Warn("加载模型别名文件失败", zap.String("file", modelAliases.path), zap.Error(err))
+		}
+	})
+	return modelAliases
+}
+
+// applyModelAliases 把别名加入合并后的模型映射。目标是映射中的 OpenAI 模型名称时解析为对应的 You.com 模型。
+func applyModelAliases(merged map[string]string) {
+	for alias, target := range getModelAliases().snapshot() {
+		if mapped, ok := merged[target]; ok {
+			target = mapped
+		}
+		merged[alias] = target
+	}
+}
+
+func (s *modelAliasStore) lookup(alias string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	target, ok := s.aliases[alias]
+	return target, ok
+}
+
+func (s *modelAliasStore) snapshot() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]string, len(s.aliases))
+	for alias, target := range s.aliases {
+		result[alias] = target
+	}
+	return result
+}
+
+// set 添加、更新或删除（target 为空）一个别名，持久化到文件后重新生成模型映射。
+func (s *modelAliasStore) set(alias, target string) error {
+	s.mu.Lock()
+	if target == "" {
+		delete(s.aliases, alias)
+	} else {
+		s.aliases[alias] = target
+	}
+	err := s.saveLocked()
+	s.mu.Unlock()
+	refreshModelMap()
+	return err
+}
+
+// replaceAll 用导入的状态替换全部别名。
+func (s *modelAliasStore) replaceAll(aliases map[string]string) error {
+	s.mu.Lock()
+	s.aliases = make(map[string]string, len(aliases))
+	for alias, target := range aliases {
+		s.aliases[alias] = target
+	}
+	err := s.saveLocked()
+	s.mu.Unlock()
+	refreshModelMap()
+	return err
+}
+
+func (s *modelAliasStore) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.aliases)
+}
+
+func (s *modelAliasStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// handleModelAliases 处理 /admin/models/aliases 管理接口：
+//
+//	GET    /admin/models/aliases          列出所有全局别名
+//	POST   /admin/models/aliases          {"alias": "gpt-5", "model": "openai_gpt_5"} 添加或更新别名
+//	DELETE /admin/models/aliases/{alias}  删除别名
+func handleModelAliases(w http.ResponseWriter, r *http.Request, path string) {
+	store := getModelAliases()
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, store.snapshot())
+	case path == "" && r.Method == http.MethodPost:
+		var body struct {
+			Alias string `json:"alias"`
+			Model string `json:"model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+			return
+		}
+		body.Alias, body.Model = strings.TrimSpace(body.Alias), strings.TrimSpace(body.Model)
+		if body.Alias == "" || body.Model == "" {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Request body must contain alias and model")
+			return
+		}
+		_, exists := store.lookup(body.Alias)
+		if err := store.set(body.Alias, body.Model); err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodePersistFailed, "Failed to persist model aliases: "+err.Error())
+			return
+		}
+		status := http.StatusCreated
+		if exists {
+			status = http.StatusOK
+		}
+		logger.L().Info("模型别名已保存", zap.String("alias", body.Alias), zap.String("model", body.Model))
+		writeJSON(w, status, map[string]interface{}{"alias": body.Alias, "model": body.Model, "you_model": getModelMap()[body.Alias]})
+	case strings.HasPrefix(path, "/") && r.Method == http.MethodDelete:
+		alias := strings.TrimPrefix(path, "/")
+		model, ok := store.lookup(alias)
+		if !ok {
+			apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, "Model alias not found: "+alias)
+			return
+		}
+		if err := store.set(alias, ""); err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodePersistFailed, "Failed to persist model aliases: "+err.Error())
+			return
+		}
+		logger.L().Info("模型别名已删除", zap.String("alias", alias), zap.String("model", model))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,323 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	upstream "you2api/internal/upstream"
+	logger "you2api/logger"
+	metrics "you2api/metrics"
+	pool "you2api/pool"
+)
+
+// 上游模型发现（MODEL_DISCOVERY_ENABLED）：从 You.com 拉取当前可用的模型，补充到 /v1/models 中。
+// /v1/models 只读取内存中的快照，从不等待上游：快照过期（MODEL_DISCOVERY_TTL_MS）后先返回旧快照，
+// 同时在后台刷新（stale-while-revalidate）；服务启动后按同样的周期主动刷新，没有请求时快照也保持最新。
+// 拉取需要登录的会话，使用 DS token 账号池中的账号，未配置账号池时不会发现任何模型。
+// 不同订阅等级（账号池文件中的 tier）可用的模型不同，每个等级各用一个账号拉取，列表取所有等级的并集；
+// 某个等级拉取失败时沿用该等级上一次的结果。MODEL_DISCOVERY_PRUNE 开启后，内置映射中上游已不再提供的模型
+// 从列表中移除。
+
+// modelSnapshot 是一次成功拉取的上游模型列表（You.com 模型名称）。
+type modelSnapshot struct {
+	models    []string            // 所有订阅等级的并集
+	tiers     map[string][]string // 按订阅等级划分的模型列表
+	refreshed time.Time
+}
+
+// modelDiscoverer 维护上游模型列表快照。
+type modelDiscoverer struct {
+	mu         sync.RWMutex
+	snapshot   *modelSnapshot
+	lastErr    string
+	refreshing atomic.Bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var modelDiscovery = &modelDiscoverer{}
+
+// models 返回当前快照中的模型，没有快照或未开启时返回 nil。
+func (d *modelDiscoverer) models() []string {
+	if !currentConfig().ModelDiscovery.Enabled {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.snapshot == nil {
+		return nil
+	}
+	return d.snapshot.models
+}
+
+// has 判断 You.com 模型名称是否在快照中。
+func (d *modelDiscoverer) has(youModel string) bool {
+	return slices.Contains(d.models(), youModel)
+}
+
+// retired 判断内置映射中的 You.com 模型是否已不再由上游提供（仅在开启 MODEL_DISCOVERY_PRUNE 且已有快照时）。
+func (d *modelDiscoverer) retired(youModel string) bool {
+	if !currentConfig().ModelDiscovery.Prune {
+		return false
+	}
+	models := d.models()
+	return models != nil && !slices.Contains(models, youModel)
+}
+
+// start 立即拉取一次模型列表，之后每个 TTL 周期刷新一次，未开启时不做任何处理。
+func (d *modelDiscoverer) start() {
+	conf := currentConfig().ModelDiscovery
+	if !conf.Enabled {
+		return
+	}
+	d.revalidate()
+	if conf.TTLMS <= 0 {
+		return
+	}
+	d.stop, d.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(time.Duration(conf.TTLMS) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.revalidate()
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// shutdown 停止定时刷新。
+func (d *modelDiscoverer) shutdown() {
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	d.stop = nil
+}
+
+// revalidate 在快照不存在或已过期时启动后台刷新，立即返回。同一时间只有一个刷新在进行。
+func (d *modelDiscoverer) revalidate() {
+	conf := currentConfig().ModelDiscovery
+	if !conf.Enabled {
+		return
+	}
+	d.mu.RLock()
+	fresh := d.snapshot != nil && time.Since(d.snapshot.refreshed) < time.Duration(conf.TTLMS)*time.Millisecond
+	d.mu.RUnlock()
+	if fresh || !d.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer d.refreshing.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.TimeoutMS)*time.Millisecond)
+		defer cancel()
+		d.refresh(ctx)
+	}()
+}
+
+// refresh 拉取各订阅等级的上游模型列表并替换快照，列表变化时使缓存的 /v1/models 响应失效。全部失败时保留旧快照。
+func (d *modelDiscoverer) refresh(ctx context.Context) error {
+	d.mu.RLock()
+	var previous map[string][]string
+	if d.snapshot != nil {
+		previous = d.snapshot.tiers
+	}
+	d.mu.RUnlock()
+
+	tiers, err := fetchUpstreamModels(ctx, previous)
+	if err != nil {
+		metrics.ModelSnapshotRefreshes.WithLabelValues("error").Inc()
+		logger.L().Warn("刷新上游模型列表失败", zap.String("error", logger.ScrubError(err)))
+		d.mu.Lock()
+		d.lastErr = logger.ScrubError(err)
+		d.mu.Unlock()
+		return err
+	}
+	metrics.ModelSnapshotRefreshes.WithLabelValues("success").Inc()
+	now := time.Now()
+	metrics.SetModelSnapshotRefreshed(now)
+
+	var models []string
+	for _, tierModels := range tiers {
+		for _, model := range tierModels {
+			if !slices.Contains(models, model) {
+				models = append(models, model)
+			}
+		}
+	}
+	sort.Strings(models)
+
+	d.mu.Lock()
+	changed := d.snapshot == nil || !slices.Equal(d.snapshot.models, models)
+	d.snapshot = &modelSnapshot{models: models, tiers: tiers, refreshed: now}
+	d.lastErr = ""
+	d.mu.Unlock()
+	if changed {
+		modelListVersion.Add(1)
+	}
+	return nil
+}
+
+// fetchUpstreamModels 为账号池中的每个订阅等级选一个账号请求 You.com 的模型列表，返回按等级划分的模型名称。
+// 某个等级失败时使用 previous 中该等级的结果，所有等级都没有结果时返回错误。
+func fetchUpstreamModels(ctx context.Context, previous map[string][]string) (map[string][]string, error) {
+	p := getTokenPool()
+	if p == nil {
+		return nil, errors.New("model discovery requires TOKEN_POOL_FILE")
+	}
+	tiers := make(map[string][]string)
+	var errs []error
+	for tier, account := range tierAccounts(p, time.Now()) {
+		models, err := fetchAccountModels(ctx, account)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tier %q: %w", tier, err))
+			if old, ok := previous[tier]; ok {
+				tiers[tier] = old
+			}
+			continue
+		}
+		tiers[tier] = models
+	}
+	if len(tiers) == 0 {
+		if len(errs) == 0 {
+			return nil, pool.ErrNoAvailableAccount
+		}
+		return nil, errors.Join(errs...)
+	}
+	if len(errs) > 0 {
+		logger.L().Warn("部分订阅等级的模型列表拉取失败，沿用上一次的结果", zap.String("error", logger.ScrubError(errors.Join(errs...))))
+	}
+	return tiers, nil
+}
+
+// tierAccounts 为每个订阅等级选择一个账号，优先选择当前处于可用时间段内的账号。
+func tierAccounts(p *pool.Pool, now time.Time) map[string]*pool.Account {
+	accounts := make(map[string]*pool.Account)
+	for _, account := range p.Accounts() {
+		if _, ok := accounts[account.Tier]; !ok && account.AvailableAt(now) {
+			accounts[account.Tier] = account
+		}
+	}
+	for _, account := range p.Accounts() {
+		if _, ok := accounts[account.Tier]; !ok {
+			accounts[account.Tier] = account // 模型列表不是补全请求，不受可用时间段限制
+		}
+	}
+	return accounts
+}
+
+// fetchAccountModels 使用指定账号请求模型列表，返回排序去重后的模型名称。
+func fetchAccountModels(ctx context.Context, account *pool.Account) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", currentConfig().ModelDiscovery.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Cookie", upstream.CookieHeader(account.Token))
+	resp, err := upstreamClient(0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	models, err := parseUpstreamModels(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, errors.New("upstream returned an empty model list")
+	}
+	return models, nil
+}
+
+// parseUpstreamModels 解析模型列表。接口没有公开文档，这里宽松地接受几种常见形式：
+// ["gpt_4o", ...]、[{"id": "gpt_4o"}, ...]，以及把这两种列表放在 models 或 data 字段中的对象。
+func parseUpstreamModels(data []byte) ([]string, error) {
+	var wrapper struct {
+		Models json.RawMessage `json:"models"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, err
+		}
+		data = wrapper.Models
+		if len(data) == 0 {
+			data = wrapper.Data
+		}
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("unexpected model list format: %w", err)
+	}
+	seen := make(map[string]bool)
+	var models []string
+	for _, entry := range entries {
+		var id string
+		if json.Unmarshal(entry, &id) != nil {
+			var obj struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(entry, &obj)
+			id = obj.ID
+		}
+		if id != "" && !seen[id] {
+			seen[id] = true
+			models = append(models, id)
+		}
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// modelDiscoveryStatus 是 GET /admin/model-discovery 返回的快照状态。
+type modelDiscoveryStatus struct {
+	Enabled    bool                `json:"enabled"`
+	Models     []string            `json:"models"`
+	Tiers      map[string][]string `json:"tiers,omitempty"` // 按订阅等级划分的模型，未设置 tier 的账号为 ""
+	Refreshed  *time.Time          `json:"refreshed,omitempty"`
+	AgeSeconds float64             `json:"age_seconds,omitempty"`
+	Refreshing bool                `json:"refreshing"`
+	LastError  string              `json:"last_error,omitempty"`
+}
+
+func (d *modelDiscoverer) status() modelDiscoveryStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	status := modelDiscoveryStatus{
+		Enabled:    currentConfig().ModelDiscovery.Enabled,
+		Models:     []string{},
+		Refreshing: d.refreshing.Load(),
+		LastError:  d.lastErr,
+	}
+	if d.snapshot != nil {
+		status.Models = d.snapshot.models
+		status.Tiers = d.snapshot.tiers
+		status.Refreshed = &d.snapshot.refreshed
+		status.AgeSeconds = time.Since(d.snapshot.refreshed).Seconds()
+	}
+	return status
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	pool "you2api/pool"
+)
+
+func TestParseUpstreamModels(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{`["gpt_4o","claude_3_opus","gpt_4o"]`, []string{"claude_3_opus", "gpt_4o"}},
+		{`[{"id":"gpt_4o","name":"GPT-4o"},{"id":""}]`, []string{"gpt_4o"}},
+		{`{"models":[{"id":"o1"}]}`, []string{"o1"}},
+		{`{"data":["o3_mini"]}`, []string{"o3_mini"}},
+	}
+	for _, tt := range tests {
+		got, err := parseUpstreamModels([]byte(tt.data))
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseUpstreamModels(%s) = %v, %v, want %v", tt.data, got, err, tt.want)
+		}
+	}
+	if _, err := parseUpstreamModels([]byte(`{"error":"unauthorized"}`)); err == nil {
+		t.Error("parseUpstreamModels accepted an object without a model list")
+	}
+}
+
+func TestTierAccounts(t *testing.T) {
+	night, _ := pool.ParseWindow("22:00-07:00")
+	p := pool.New(
+		&pool.Account{Name: "pro-night", Tier: "pro", Windows: []pool.Window{night}},
+		&pool.Account{Name: "pro-day", Tier: "pro"},
+		&pool.Account{Name: "free", Tier: "free"},
+		&pool.Account{Name: "untiered-night", Windows: []pool.Window{night}},
+	)
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	got := make(map[string]string)
+	for tier, account := range tierAccounts(p, noon) {
+		got[tier] = account.Name
+	}
+	want := map[string]string{"pro": "pro-day", "free": "free", "": "untiered-night"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tierAccounts = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// modelListVersion 在模型列表可能变化时递增（例如隐藏或恢复模型），缓存的 /v1/models 响应据此失效。
+var modelListVersion atomic.Int64
+
+// modelListCache 缓存编码后的 /v1/models 响应及其 ETag，频繁轮询模型列表的客户端
+// 可以通过 If-None-Match / If-Modified-Since 得到 304，无需重新传输。
+type modelListCache struct {
+	mu           sync.Mutex
+	version      int64
+	built        bool
+	contentHash  string // 不含 created 的列表内容摘要，内容未变时保留 Last-Modified
+	body         []byte
+	etag         string
+	lastModified time.Time
+}
+
+var modelList = &modelListCache{}
+
+// get 返回当前模型列表的响应体、ETag 与最后修改时间，版本变化时重新生成。
+func (c *modelListCache) get() ([]byte, string, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	version := modelListVersion.Load()
+	if c.built && c.version == version {
+		return c.body, c.etag, c.lastModified
+	}
+
+	models := listModels()
+	content, _ := json.Marshal(models)
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if hash != c.contentHash {
+		c.contentHash = hash
+		c.lastModified = time.Now().UTC().Truncate(time.Second) // HTTP 日期精确到秒
+	}
+
+	created := c.lastModified.Unix()
+	for i := range models {
+		models[i].Created = created
+	}
+	body, _ := json.Marshal(ModelResponse{Object: "list", Data: models})
+	c.body = append(body, '\n')
+	c.etag = `"` + hash[:32] + `"`
+	c.version = version
+	c.built = true
+	return c.body, c.etag, c.lastModified
+}
+
+// listModels 按 ID 排序列出未隐藏的全局模型与虚拟模型，created 由调用方填写。
+func listModels() []ModelDetail {
+	hidden := getHiddenModels()
+	models := make([]ModelDetail, 0, len(getModelMap()))
+	for modelID, youModel := range getModelMap() {
+		if hidden.isHidden(modelID) || modelDiscovery.retired(youModel) {
+			continue
+		}
+		models = append(models, ModelDetail{
+			ID:      modelID,
+			Object:  "model",
+			OwnedBy: "organization-owner",
+		})
+	}
+	for name, variants := range reasoningEffortModels {
+		if hidden.isHidden(name) || modelDiscovery.retired(variants["medium"]) {
+			continue
+		}
+		models = append(models, ModelDetail{
+			ID:      name,
+			Object:  "model",
+			OwnedBy: "organization-owner",
+		})
+	}
+	for name := range getVirtualModels() {
+		if hidden.isHidden(name) {
+			continue
+		}
+		models = append(models, ModelDetail{
+			ID:      name,
+			Object:  "model",
+			OwnedBy: "virtual",
+		})
+	}
+	// 上游发现的、本地映射表中没有的模型以 You.com 名称列出
+	mapped := getReverseModelMap()
+	for _, youModel := range modelDiscovery.models() {
+		if _, ok := mapped[youModel]; ok || hidden.isHidden(youModel) {
+			continue
+		}
+		models = append(models, ModelDetail{
+			ID:      youModel,
+			Object:  "model",
+			OwnedBy: "you.com",
+		})
+	}
+	if currentConfig().AutoModel.Enabled && !hidden.isHidden(autoModelName) {
+		models = append(models, ModelDetail{
+			ID:      autoModelName,
+			Object:  "model",
+			OwnedBy: "virtual",
+		})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models
+}
+
+// lookupModel 返回模型列表中的单个模型，与 /v1/models 的规则相同（隐藏的模型不存在，包括上游发现的模型），
+// created 与列表一致。
+func lookupModel(id string) (ModelDetail, bool) {
+	models := listModels()
+	i := sort.Search(len(models), func(i int) bool { return models[i].ID >= id })
+	if i == len(models) || models[i].ID != id {
+		return ModelDetail{}, false
+	}
+	_, _, lastModified := modelList.get()
+	models[i].Created = lastModified.Unix()
+	return models[i], true
+}
+
+// handleModelList 处理 GET /v1/models，支持 ETag 与 Last-Modified 条件请求。
+func handleModelList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	modelDiscovery.revalidate() // 上游模型快照过期时在后台刷新，本次请求仍使用旧快照
+	body, etag, lastModified := modelList.get()
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache") // 允许缓存，但每次使用前需要重新验证
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(body)
+}
+
+// notModified 判断条件请求是否命中缓存。同时出现时 If-None-Match 优先于 If-Modified-Since。
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		return err == nil && !lastModified.After(since)
+	}
+	return false
+}
+
+// etagMatches 按弱比较判断 If-None-Match 中是否包含 etag，支持逗号分隔的列表与 "*"。
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
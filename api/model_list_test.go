@@ -0,0 +1,51 @@
+package handler
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`*`, true},
+		{`"xyz"`, false},
+		{`abc`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLookupModel(t *testing.T) {
+	detail, ok := lookupModel("gpt-4o")
+	if !ok || detail.ID != "gpt-4o" || detail.Object != "model" || detail.Created == 0 {
+		t.Errorf("lookupModel(gpt-4o) = %+v, %v", detail, ok)
+	}
+	if _, ok := lookupModel("no-such-model"); ok {
+		t.Error("lookupModel found an unknown model")
+	}
+}
+
+func TestIsKnownModel(t *testing.T) {
+	tests := map[string]bool{
+		"gpt-4o":      true,
+		"auto":        true,
+		"gpt-4o-mni":  false,
+		"gpt_4o":      false,
+		"deepseek_v3": false,
+	}
+	for model, want := range tests {
+		if got := isKnownModel(model); got != want {
+			t.Errorf("isKnownModel(%q) = %v, want %v", model, got, want)
+		}
+	}
+	if got := mapModelName("gpt-4o-mni"); got != "deepseek_v3" {
+		t.Errorf("unknown model mapped to %q, want the DEFAULT_MODEL default deepseek_v3", got)
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	translate "you2api/internal/translate"
+	logger "you2api/logger"
+
+	"go.uber.org/zap"
+)
+
+// 可配置的模型映射：MODEL_MAP（JSON 对象）与 MODEL_MAP_FILE（JSON 文件，扩展名为 .yaml/.yml 时按
+// "openai-name: you_model" 的简单 YAML 映射解析）在内置映射之上添加或覆盖条目，值为空字符串时删除该内置条目。
+// 生效顺序为 内置映射 < WithModelMap < MODEL_MAP < MODEL_MAP_FILE < 运行时别名（见 model_aliases.go）。
+// 文件每隔 MODEL_MAP_RELOAD_MS 检查一次修改时间，变化后重新加载，无需重启；新文件无法解析时保留当前映射。
+
+var (
+	modelMapOnce  sync.Once
+	modelMapValue atomic.Pointer[map[string]string]
+
+	// embeddedModelMap 是 WithModelMap 设置的映射
+	embeddedModelMapMu sync.Mutex
+	embeddedModelMap   map[string]string
+)
+
+// getModelMap 返回当前生效的 OpenAI 模型名称到 You.com 模型名称的映射，调用方不能修改返回值。
+func getModelMap() map[string]string {
+	modelMapOnce.Do(func() {
+		if err := reloadModelMap(); err != nil {
+			logger.L().Warn("加载模型映射失败，使用内置映射", zap.Error(err))
+			merged := translate.MergeModelMaps(translate.BuiltinModels, currentEmbeddedModelMap())
+			applyModelAliases(merged)
+			modelMapValue.Store(&merged)
+		}
+	})
+	return *modelMapValue.Load()
+}
+
+func currentEmbeddedModelMap() map[string]string {
+	embeddedModelMapMu.Lock()
+	defer embeddedModelMapMu.Unlock()
+	return embeddedModelMap
+}
+
+// setEmbeddedModelMap 设置 WithModelMap 的映射并重新生成生效的映射。
+func setEmbeddedModelMap(m map[string]string) {
+	embeddedModelMapMu.Lock()
+	embeddedModelMap = m
+	embeddedModelMapMu.Unlock()
+	refreshModelMap()
+}
+
+// refreshModelMap 在映射的某一层变化后重新生成生效的映射。
+func refreshModelMap() {
+	getModelMap()
+	if err := reloadModelMap(); err != nil {
+		logger.L().Warn("加载模型映射失败，保留当前映射", zap.Error(err))
+	}
+}
+
+// reloadModelMap 读取 MODEL_MAP、MODEL_MAP_FILE 与运行时别名并替换生效的映射，映射变化时使缓存的 /v1/models 响应失效。
+func reloadModelMap() error {
+	conf := currentConfig()
+	layers := []map[string]string{translate.BuiltinModels, currentEmbeddedModelMap()}
+	if conf.ModelMap != "" {
+		var m map[string]string
+		if err := json.Unmarshal([]byte(conf.ModelMap), &m); err != nil {
+			return fmt.Errorf("parse MODEL_MAP: %w", err)
+		}
+		layers = append(layers, m)
+	}
+	if conf.ModelMapFile != "" {
+		data, err := os.ReadFile(conf.ModelMapFile)
+		if err != nil {
+			return err
+		}
+		m, err := translate.ParseModelMapFile(conf.ModelMapFile, data)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", conf.ModelMapFile, err)
+		}
+		layers = append(layers, m)
+	}
+	merged := translate.MergeModelMaps(layers...)
+	applyModelAliases(merged)
+	if old := modelMapValue.Swap(&merged); old != nil && !translate.EqualModelMaps(*old, merged) {
+		modelListVersion.Add(1)
+	}
+	return nil
+}
+
+// modelMapWatcher 定期检查 MODEL_MAP_FILE 的修改时间，变化后重新加载。
+type modelMapWatcher struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+var modelMapReloader = &modelMapWatcher{}
+
+// start 在配置了 MODEL_MAP_FILE 与 MODEL_MAP_RELOAD_MS 时开始检查文件变化。
+func (m *modelMapWatcher) start() {
+	conf := currentConfig()
+	if conf.ModelMapFile == "" || conf.ModelMapReloadMS <= 0 {
+		return
+	}
+	getModelMap()
+	stat := func() (time.Time, int64) {
+		info, err := os.Stat(conf.ModelMapFile)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+	m.stop, m.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(time.Duration(conf.ModelMapReloadMS) * time.Millisecond)
+		defer ticker.Stop()
+		modTime, size := stat()
+		for {
+			select {
+			case <-ticker.C:
+				t, s := stat()
+				if t.Equal(modTime) && s == size {
+					continue
+				}
+				modTime, size = t, s
+				if err := reloadModelMap(); err != nil {
+					logger.L().Warn("重新加载模型映射失败，保留当前映射", zap.Error(err))
+					continue
+				}
+				logger.L().Info("模型映射已重新加载", zap.String("file", conf.ModelMapFile), zap.Int("models", len(getModelMap())))
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// shutdown 停止检查文件变化。
+func (m *modelMapWatcher) shutdown() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop = nil
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"testing"
+
+	translate "you2api/internal/translate"
+)
+
+func TestModelAliases(t *testing.T) {
+	store := getModelAliases()
+	t.Cleanup(func() { store.replaceAll(nil) })
+	if err := store.set("gpt-new", "openai_gpt_new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.set("fast", "gpt-4o-mini"); err != nil {
+		t.Fatal(err)
+	}
+	if got := getModelMap()["gpt-new"]; got != "openai_gpt_new" {
+		t.Errorf("alias to You.com model = %q, want openai_gpt_new", got)
+	}
+	if got, want := getModelMap()["fast"], translate.BuiltinModels["gpt-4o-mini"]; got != want {
+		t.Errorf("alias to OpenAI model = %q, want %q", got, want)
+	}
+	if err := store.set("gpt-new", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := getModelMap()["gpt-new"]; ok {
+		t.Error("deleted alias still mapped")
+	}
+}
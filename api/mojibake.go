@@ -0,0 +1,125 @@
+package handler
+
+import "unicode/utf8"
+
+// 上游偶尔会把 UTF-8 字节按 Latin-1 / Windows-1252 解码后再编码，
+// 导致中文、日文、阿拉伯文等变成 "ä½ å¥½" 这样的乱码（mojibake）。
+// 修复方法是把每个字符还原为原始字节，如果得到合法的多字节 UTF-8，就用还原后的文本替换。
+
+// cp1252Bytes 是 Windows-1252 在 0x80~0x9F 区间与 Latin-1 不同的字符到原始字节的映射。
+var cp1252Bytes = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// mojibakeBytes 把文本还原为单字节编码下的原始字节；包含无法还原的字符时返回 false，说明文本不是乱码。
+func mojibakeBytes(s string) ([]byte, bool) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x100:
+			b = append(b, byte(r))
+		default:
+			c, ok := cp1252Bytes[r]
+			if !ok {
+				return nil, false
+			}
+			b = append(b, c)
+		}
+	}
+	return b, true
+}
+
+// fixMojibake 修复整段文本中的乱码，文本不是乱码时原样返回。
+func fixMojibake(s string) string {
+	b, ok := mojibakeBytes(s)
+	if !ok || !hasMultibyte(b) || !utf8.Valid(b) {
+		return s
+	}
+	return string(b)
+}
+
+func hasMultibyte(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// repairEncoding 在开启 FIX_MOJIBAKE 时修复非流式回复中的乱码。
+func repairEncoding(s string) string {
+	if !currentConfig().FixMojibake {
+		return s
+	}
+	return fixMojibake(s)
+}
+
+// mojibakeFixer 在流式输出中修复乱码。一个多字节字符的乱码可能被拆分到相邻的 token 中，
+// 因此末尾不完整的字节序列会暂存到下一个 token。nil 表示不修复。
+type mojibakeFixer struct {
+	pending     []byte // 尚未组成完整 UTF-8 字符的原始字节
+	pendingText string // pending 对应的原始文本，确认不是乱码时原样输出
+}
+
+func newMojibakeFixer(enabled bool) *mojibakeFixer {
+	if !enabled {
+		return nil
+	}
+	return &mojibakeFixer{}
+}
+
+// push 处理一个 token，返回可以立即输出的文本。
+func (f *mojibakeFixer) push(token string) string {
+	if f == nil {
+		return token
+	}
+	b, ok := mojibakeBytes(token)
+	if !ok {
+		return f.flush() + token
+	}
+	b = append(f.pending, b...)
+	text := f.pendingText + token
+
+	// 找出末尾不完整的 UTF-8 序列
+	complete := len(b)
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	if !utf8.Valid(b[:complete]) {
+		f.pending, f.pendingText = nil, ""
+		return text
+	}
+
+	// 暂存不完整的尾部，它对应 text 末尾的 len(b)-complete 个字符
+	tailRunes := len(b) - complete
+	split := len(text)
+	for i := 0; i < tailRunes; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:split])
+		split -= size
+	}
+	f.pending = append([]byte(nil), b[complete:]...)
+	f.pendingText = text[split:]
+	if !hasMultibyte(b[:complete]) {
+		return text[:split] // 纯 ASCII 或尚无法判断
+	}
+	return string(b[:complete])
+}
+
+// flush 返回暂存的内容（按原样），在流结束时调用。
+func (f *mojibakeFixer) flush() string {
+	if f == nil {
+		return ""
+	}
+	text := f.pendingText
+	f.pending, f.pendingText = nil, ""
+	return text
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+// multilingualSamples 覆盖中文、日文、阿拉伯文、希伯来文（从右到左）、emoji 和带重音的拉丁文。
+var multilingualSamples = []string{
+	"你好，世界！这是一个测试。",
+	"こんにちは、世界。カタカナとひらがな。",
+	"مرحبا بالعالم",
+	"שלום עולם",
+	"混合 mixed العربية 日本語 🎉",
+	"café naïve résumé",
+	"‫RTL embedding‬",
+}
+
+// latin1Mojibake 模拟把 UTF-8 字节按 Latin-1/Windows-1252 解码得到的乱码。
+func latin1Mojibake(s string) string {
+	reverse := make(map[byte]rune, len(cp1252Bytes))
+	for r, b := range cp1252Bytes {
+		reverse[b] = r
+	}
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		if r, ok := reverse[b]; ok {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune(rune(b))
+		}
+	}
+	return sb.String()
+}
+
+func TestFixMojibakePassesThroughValidText(t *testing.T) {
+	for _, s := range multilingualSamples {
+		if got := fixMojibake(s); got != s {
+			t.Errorf("fixMojibake(%q) = %q", s, got)
+		}
+	}
+}
+
+func TestFixMojibakeRepairsGarbledText(t *testing.T) {
+	for _, s := range multilingualSamples {
+		garbled := latin1Mojibake(s)
+		if garbled == s {
+			continue // 纯 ASCII 不会产生乱码
+		}
+		if got := fixMojibake(garbled); got != s {
+			t.Errorf("fixMojibake(%q) = %q, want %q", garbled, got, s)
+		}
+	}
+}
+
+func TestMojibakeFixerStreaming(t *testing.T) {
+	for _, s := range multilingualSamples {
+		for _, input := range []string{s, latin1Mojibake(s)} {
+			// 按每 1~4 个字符切分 token，模拟多字节字符跨 token 的情况
+			for size := 1; size <= 4; size++ {
+				f := newMojibakeFixer(true)
+				runes := []rune(input)
+				var got strings.Builder
+				for i := 0; i < len(runes); i += size {
+					end := i + size
+					if end > len(runes) {
+						end = len(runes)
+					}
+					got.WriteString(f.push(string(runes[i:end])))
+				}
+				got.WriteString(f.flush())
+				if got.String() != s {
+					t.Errorf("size %d: stream(%q) = %q, want %q", size, input, got.String(), s)
+				}
+			}
+		}
+	}
+}
+
+func TestMojibakeFixerDisabled(t *testing.T) {
+	var f *mojibakeFixer = newMojibakeFixer(false)
+	garbled := latin1Mojibake("你好")
+	if got := f.push(garbled) + f.flush(); got != garbled {
+		t.Errorf("disabled fixer changed %q to %q", garbled, got)
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// wantsPlainText 根据 Accept 头判断非流式响应是否只返回纯文本内容。
+// 只有 text/plain 的权重严格高于 application/json 时才返回纯文本，
+// 未指定、通配或无法识别的 Accept 一律返回完整的 JSON 对象。
+func wantsPlainText(accept string) bool {
+	if accept == "" {
+		return false
+	}
+	textQ, jsonQ := -1.0, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch mediaType {
+		case "text/plain":
+			textQ = max(textQ, q)
+		case "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return textQ > 0 && textQ > jsonQ
+}
+
+// acceptsGzip 判断客户端的 Accept-Encoding 是否接受 gzip（gzip 或通配，且权重不为 0）。
+func acceptsGzip(acceptEncoding string) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gzipQ = max(gzipQ, q)
+		case "*":
+			anyQ = max(anyQ, q)
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWantsPlainText(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"text/plain", true},
+		{"text/plain; charset=utf-8", true},
+		{"text/plain, application/json", false},
+		{"text/plain, application/json;q=0.5", true},
+		{"text/plain;q=0.5, */*;q=0.8", false},
+		{"text/plain;q=0", false},
+		{"not a media type", false},
+	}
+	for _, tt := range tests {
+		if got := wantsPlainText(tt.accept); got != tt.want {
+			t.Errorf("wantsPlainText(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestPlainTextCompletion(t *testing.T) {
+	withMockUpstream(t, "echo")
+	rec := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"plain please"}]}`, "Accept", "text/plain")
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if got := rec.Body.String(); got != "plain please" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestPlainTextCompletionToolCalls(t *testing.T) {
+	withMockUpstream(t, "echo")
+	body := `{"model":"gpt-4o","tools":[{"type":"function","function":{"name":"get_weather"}}],` +
+		`"messages":[{"role":"user","content":"Checking.\n[tool_calls]\n[{\"name\": \"get_weather\", \"arguments\": {}}]"}]}`
+	rec := postChat(t, body, "Accept", "text/plain")
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	// 纯文本响应不包含工具调用标记
+	if got := rec.Body.String(); got != "Checking." {
+		t.Errorf("body = %q", got)
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import "strings"
+
+// whitespaceNormalizer 对回复内容做空白规范化：去掉开头的空白，并把连续超过两个的换行压缩为两个。
+// 它按块处理流式输出，末尾的换行会暂存到下一个块到来时再决定是否输出，因此可以安全地用于流式响应。
+// 为 nil 时不做任何处理。
+type whitespaceNormalizer struct {
+	started         bool // 是否已经输出过非空白字符
+	pendingNewlines int  // 暂存的连续换行数
+}
+
+// newWhitespaceNormalizer 在 enabled 为 false 时返回 nil（即不处理）。
+func newWhitespaceNormalizer(enabled bool) *whitespaceNormalizer {
+	if !enabled {
+		return nil
+	}
+	return &whitespaceNormalizer{}
+}
+
+// push 处理一个内容块，返回可以立即发送的部分。
+func (n *whitespaceNormalizer) push(chunk string) string {
+	if n == nil {
+		return chunk
+	}
+	if !n.started {
+		chunk = strings.TrimLeft(chunk, " \t\r\n")
+		if chunk == "" {
+			return ""
+		}
+		n.started = true
+	}
+
+	var out strings.Builder
+	for _, r := range chunk {
+		if r == '\n' {
+			n.pendingNewlines++
+			continue
+		}
+		if n.pendingNewlines > 0 {
+			out.WriteString(strings.Repeat("\n", min(n.pendingNewlines, 2)))
+			n.pendingNewlines = 0
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// normalize 一次性规范化完整内容（非流式响应），末尾多余的换行会被丢弃。
+func (n *whitespaceNormalizer) normalize(content string) string {
+	if n == nil {
+		return content
+	}
+	return n.push(content)
+}
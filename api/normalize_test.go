@@ -0,0 +1,40 @@
+package handler
+
+import "testing"
+
+func TestWhitespaceNormalizer(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"leading whitespace", []string{"  \n", "\tHello"}, "Hello"},
+		{"collapse newlines", []string{"a\n\n\n\nb"}, "a\n\nb"},
+		{"newlines split across chunks", []string{"a\n", "\n", "\n", "b"}, "a\n\nb"},
+		{"single newline kept", []string{"a\n", "b"}, "a\nb"},
+		{"trailing newlines dropped", []string{"a\n\n"}, "a"},
+		{"inner spaces kept", []string{"a  b"}, "a  b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newWhitespaceNormalizer(true)
+			var got string
+			for _, chunk := range tt.chunks {
+				got += n.push(chunk)
+			}
+			if got != tt.want {
+				t.Errorf("streamed = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhitespaceNormalizerDisabled(t *testing.T) {
+	n := newWhitespaceNormalizer(false)
+	if got := n.push("\n\n\n a"); got != "\n\n\n a" {
+		t.Errorf("push = %q", got)
+	}
+	if got := n.normalize("  x\n\n\n"); got != "  x\n\n\n" {
+		t.Errorf("normalize = %q", got)
+	}
+}
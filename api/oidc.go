@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	apierror "you2api/apierror"
+	logger "you2api/logger"
+	oidc "you2api/oidc"
+
+	"go.uber.org/zap"
+)
+
+// 客户端 JWT 认证（OIDC_ISSUER，必须同时设置 OIDC_AUDIENCE）：客户端可以用 SSO 签发的 JWT 代替 API key，无需额外的网关。
+// 只有 iss 与配置的签发方一致的 token 才按 JWT 校验，其他凭据（包括本身也是 JWT 的 DS token）保持原有处理。
+// 校验通过的请求以 "oidc:<sub>" 作为 key 身份（key ID、别名与会话按用户区分，不随 token 更换而变化），
+// 并从账号池中选择账号。OIDC_SCOPE_POLICIES 按 scope 限制可用的模型（支持 * 通配，如 "gpt-4o*"）、
+// 每分钟请求数与单次回复的 token 数；token 带有多个已配置的 scope 时取其中最宽松的设置。
+// 异步补全在执行时重新校验 token，届时 token 已过期的任务会失败。
+
+// errInsufficientScope 表示 token 不带任何已配置的 scope。
+var errInsufficientScope = errors.New("token has none of the configured scopes")
+
+// scopePolicy 是一个 scope 允许的模型与配额，0 与空列表表示不限制。
+type scopePolicy struct {
+	Models            []string `json:"models"`
+	RequestsPerMinute int      `json:"requests_per_minute"`
+	MaxResponseTokens int      `json:"max_response_tokens"`
+}
+
+// merge 合并两个 scope 的设置，逐项取更宽松的一个。
+func (p scopePolicy) merge(other scopePolicy) scopePolicy {
+	loosest := func(a, b int) int {
+		if a <= 0 || b <= 0 {
+			return 0
+		}
+		return max(a, b)
+	}
+	merged := scopePolicy{
+		RequestsPerMinute: loosest(p.RequestsPerMinute, other.RequestsPerMinute),
+		MaxResponseTokens: loosest(p.MaxResponseTokens, other.MaxResponseTokens),
+	}
+	if len(p.Models) > 0 && len(other.Models) > 0 {
+		merged.Models = append(append([]string{}, p.Models...), other.Models...)
+	}
+	return merged
+}
+
+// principal 是通过 JWT 认证的客户端。为 nil 时（使用 API key 或 DS token）不做任何限制。
+type principal struct {
+	key    string
+	policy *scopePolicy // 未配置 OIDC_SCOPE_POLICIES 时为 nil
+}
+
+var (
+	oidcVerifierOnce sync.Once
+	oidcVerifier     *oidc.Verifier
+
+	scopePoliciesOnce sync.Once
+	scopePolicies     map[string]scopePolicy
+)
+
+// getOIDCVerifier 返回 JWT 校验器，未配置 OIDC_ISSUER 时返回 nil。
+func getOIDCVerifier() *oidc.Verifier {
+	oidcVerifierOnce.Do(func() {
+		conf := currentConfig().OIDC
+		if conf.Issuer != "" {
+			oidcVerifier = oidc.NewVerifier(conf.Issuer, conf.Audience, conf.JWKSURL)
+		}
+	})
+	return oidcVerifier
+}
+
+// getScopePolicies 解析 OIDC_SCOPE_POLICIES。
+func getScopePolicies() map[string]scopePolicy {
+	scopePoliciesOnce.Do(func() {
+		raw := currentConfig().OIDC.ScopePolicies
+		if raw == "" {
+			return
+		}
+		if err := json.Unmarshal([]byte(raw), &scopePolicies); err != nil {
+			log.Printf("解析 OIDC_SCOPE_POLICIES 失败: %v", err)
+		}
+	})
+	return scopePolicies
+}
+
+// authenticateJWT 校验由配置的签发方签发的客户端 JWT；token 不是该签发方的 JWT 时返回 nil, nil。
+func authenticateJWT(ctx context.Context, token string) (*principal, error) {
+	v := getOIDCVerifier()
+	if v == nil || !v.Recognizes(token) {
+		return nil, nil
+	}
+	claims, err := v.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	p := &principal{key: "oidc:" + claims.Subject}
+	policies := getScopePolicies()
+	if len(policies) == 0 {
+		return p, nil
+	}
+	for _, scope := range claims.Scopes {
+		policy, ok := policies[scope]
+		if !ok {
+			continue
+		}
+		if p.policy == nil {
+			p.policy = &policy
+		} else {
+			merged := p.policy.merge(policy)
+			p.policy = &merged
+		}
+	}
+	if p.policy == nil {
+		return nil, errInsufficientScope
+	}
+	return p, nil
+}
+
+// writeAuthError 返回 JWT 认证失败的错误响应。
+func writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, oidc.ErrInvalidToken):
+		clientError(w, r, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token: %s", err)
+	case errors.Is(err, errInsufficientScope):
+		clientError(w, r, http.StatusForbidden, apierror.CodeInsufficientScope, "Token has none of the required scopes")
+	default:
+		// 无法获取签发方的公钥，不是客户端的问题
+		logger.L().Warn("校验客户端 JWT 失败", zap.Error(err))
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.CodeInternalError, "token verification is temporarily unavailable")
+	}
+}
+
+type principalKey struct{}
+
+// withPrincipal 把通过 JWT 认证的客户端放入请求上下文。
+func withPrincipal(ctx context.Context, p *principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// principalFrom 返回上下文中通过 JWT 认证的客户端，没有时返回 nil。
+func principalFrom(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalKey{}).(*principal)
+	return p
+}
+
+// allowsModel 判断客户端可以使用的模型。
+func (p *principal) allowsModel(model string) bool {
+	if p == nil || p.policy == nil || len(p.policy.Models) == 0 {
+		return true
+	}
+	for _, pattern := range p.policy.Models {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// responseLimit 返回 scope 限制的单次回复 token 数。
+func (p *principal) responseLimit() responseLimit {
+	if p == nil || p.policy == nil {
+		return responseLimit{}
+	}
+	return responseLimit{Tokens: p.policy.MaxResponseTokens}
+}
+
+// allow 按每分钟请求数限流，计数按自然分钟重置。
+func (p *principal) allow(now time.Time) bool {
+	if p == nil || p.policy == nil || p.policy.RequestsPerMinute <= 0 {
+		return true
+	}
+	return principalRequests.allow(p.key, p.policy.RequestsPerMinute, now)
+}
+
+// minuteCounter 按 key 统计当前分钟内的请求数。
+type minuteCounter struct {
+	mu     sync.Mutex
+	minute int64
+	counts map[string]int
+}
+
+var principalRequests = &minuteCounter{counts: make(map[string]int)}
+
+func (c *minuteCounter) allow(key string, limit int, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if minute := now.Unix() / 60; minute != c.minute {
+		c.minute = minute
+		clear(c.counts)
+	}
+	if c.counts[key] >= limit {
+		return false
+	}
+	c.counts[key]++
+	return true
+}
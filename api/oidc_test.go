@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScopePolicyMerge(t *testing.T) {
+	basic := scopePolicy{Models: []string{"gpt-4o-mini"}, RequestsPerMinute: 10, MaxResponseTokens: 1000}
+	pro := scopePolicy{Models: []string{"gpt-4o*", "claude-*"}, RequestsPerMinute: 60}
+	got := basic.merge(pro)
+	want := scopePolicy{Models: []string{"gpt-4o-mini", "gpt-4o*", "claude-*"}, RequestsPerMinute: 60}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merge = %+v, want %+v", got, want)
+	}
+	if got := basic.merge(scopePolicy{}); len(got.Models) != 0 || got.RequestsPerMinute != 0 {
+		t.Errorf("merge with unrestricted scope = %+v, want unrestricted", got)
+	}
+}
+
+func TestPrincipalAllowsModel(t *testing.T) {
+	p := &principal{key: "oidc:alice", policy: &scopePolicy{Models: []string{"gpt-4o*", "claude-3-haiku"}}}
+	tests := map[string]bool{
+		"gpt-4o":          true,
+		"gpt-4o-mini":     true,
+		"claude-3-haiku":  true,
+		"claude-3-opus":   false,
+		"deepseek-reason": false,
+	}
+	for model, want := range tests {
+		if got := p.allowsModel(model); got != want {
+			t.Errorf("allowsModel(%q) = %v, want %v", model, got, want)
+		}
+	}
+	var anonymous *principal
+	if !anonymous.allowsModel("claude-3-opus") {
+		t.Error("requests without a JWT must not be restricted")
+	}
+}
+
+func TestMinuteCounter(t *testing.T) {
+	c := &minuteCounter{counts: make(map[string]int)}
+	now := time.Unix(1_700_000_040, 0)
+	for i := 0; i < 2; i++ {
+		if !c.allow("oidc:alice", 2, now) {
+			t.Fatalf("request %d rejected", i+1)
+		}
+	}
+	if c.allow("oidc:alice", 2, now.Add(10*time.Second)) {
+		t.Error("third request in the same minute allowed")
+	}
+	if !c.allow("oidc:bob", 2, now) {
+		t.Error("limit shared between principals")
+	}
+	if !c.allow("oidc:alice", 2, now.Add(time.Minute)) {
+		t.Error("counter not reset in the next minute")
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	config "you2api/config"
+	jobqueue "you2api/jobqueue"
+	plugins "you2api/plugins"
+	pool "you2api/pool"
+)
+
+// Option 配置 NewHandler 返回的处理器。
+type Option func(*handlerOptions)
+
+type handlerOptions struct {
+	config        *config.Config
+	tokenPool     *pool.Pool
+	modelMap      map[string]string
+	tokenState    pool.State
+	conversations ConversationStore
+	jobQueue      *jobqueue.Queue
+	plugins       []plugins.Hook
+	middleware    []func(http.Handler) http.Handler
+}
+
+// WithConfig 使用给定的配置，代替从环境变量加载的配置。通常以 config.Load() 的结果为基础修改；
+// NewHandler 会对其调用 config.Config.Normalize 补全派生字段并检查。
+func WithConfig(c *config.Config) Option {
+	return func(o *handlerOptions) { o.config = c }
+}
+
+// WithTokenPool 使用给定的账号池，代替 TOKEN_POOL_FILE。客户端仍需携带 POOL_ACCESS_KEY 才会使用账号池。
+func WithTokenPool(p *pool.Pool) Option {
+	return func(o *handlerOptions) { o.tokenPool = p }
+}
+
+// WithModelMap 添加或覆盖 OpenAI 模型名称到 You.com 模型名称的映射，未列出的内置映射保持不变。
+func WithModelMap(m map[string]string) Option {
+	return func(o *handlerOptions) { o.modelMap = m }
+}
+
+// WithTokenState 使用给定的账号状态存储（冷却、并发与失败计数），代替按 REDIS_URL 创建的存储。
+func WithTokenState(s pool.State) Option {
+	return func(o *handlerOptions) { o.tokenState = s }
+}
+
+// WithConversationStore 使用给定的对话存储保存 previous_response_id 引用的对话，代替进程内的 LRU。
+// 存储需要自行处理过期；CONVERSATION_MAX_MESSAGES 的裁剪与按 key 隔离仍由处理器负责。
+func WithConversationStore(s ConversationStore) Option {
+	return func(o *handlerOptions) { o.conversations = s }
+}
+
+// WithJobQueue 使用给定的任务队列保存异步补全任务，代替按 JOB_QUEUE_FILE 打开的数据库。
+func WithJobQueue(q *jobqueue.Queue) Option {
+	return func(o *handlerOptions) { o.jobQueue = q }
+}
+
+// WithPlugins 按顺序注册请求改写插件，见 plugins 包。流式响应不经过插件的 PostResponse。
+func WithPlugins(hooks ...plugins.Hook) Option {
+	return func(o *handlerOptions) { o.plugins = append(o.plugins, hooks...) }
+}
+
+// WithMiddleware 用中间件包装处理器，第一个中间件位于最外层。
+func WithMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(o *handlerOptions) { o.middleware = append(o.middleware, mw...) }
+}
+
+// ErrHandlerExists 表示 NewHandler 已被调用过。
+var ErrHandlerExists = errors.New("handler: NewHandler called more than once; handler state is process-wide")
+
+// newHandlerCalled 记录 NewHandler 是否已被调用。
+var newHandlerCalled atomic.Bool
+
+// NewHandler 返回处理补全、模型与管理接口的 http.Handler，供在自己的服务中嵌入代理的 Go 程序使用。
+//
+// 处理器的状态（配置、账号池、会话与缓存等）保存在包级变量中，一个进程只有一套：选项同样作用于包级的 Handler。
+// 因此 NewHandler 只能在开始处理请求之前调用一次，再次调用返回 ErrHandlerExists，而不是悄悄覆盖前一个处理器的配置。
+// WithConfig 传入的配置检查失败时返回该错误，此时可以修正配置后再次调用。
+// 后台任务（模型发现、异步任务等）仍需通过 Start 与 Shutdown 启停。
+func NewHandler(opts ...Option) (http.Handler, error) {
+	var o handlerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.config != nil {
+		if err := o.config.Normalize(); err != nil {
+			return nil, err
+		}
+	}
+	if newHandlerCalled.Swap(true) {
+		return nil, ErrHandlerExists
+	}
+	if o.config != nil {
+		setConfig(o.config)
+	}
+	if o.tokenPool != nil {
+		setTokenPool(o.tokenPool)
+	}
+	if o.modelMap != nil {
+		setEmbeddedModelMap(o.modelMap)
+	}
+	if o.tokenState != nil {
+		setTokenState(o.tokenState)
+	}
+	if o.conversations != nil {
+		setConversationStore(o.conversations)
+	}
+	if o.jobQueue != nil {
+		setJobQueue(o.jobQueue)
+	}
+	if len(o.plugins) > 0 {
+		setPlugins(o.plugins)
+	}
+
+	var h http.Handler = http.HandlerFunc(Handler)
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		h = o.middleware[i](h)
+	}
+	return h, nil
+}
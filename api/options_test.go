@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	config "you2api/config"
+	jobqueue "you2api/jobqueue"
+)
+
+// allowNewHandler 允许测试再次调用 NewHandler，并在测试结束后恢复。
+func allowNewHandler(t *testing.T) {
+	t.Helper()
+	newHandlerCalled.Store(false)
+	t.Cleanup(func() { newHandlerCalled.Store(false) })
+}
+
+// mustNewHandler 调用 NewHandler，失败时结束测试。
+func mustNewHandler(t *testing.T, opts ...Option) http.Handler {
+	t.Helper()
+	h, err := NewHandler(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestNewHandler(t *testing.T) {
+	allowNewHandler(t)
+	var order []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	defer setEmbeddedModelMap(nil)
+
+	h := mustNewHandler(t, WithMiddleware(mw("outer"), mw("inner")), WithModelMap(map[string]string{"embedded-model": "gpt_4o"}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("middleware order = %v", order)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d", rec.Code)
+	}
+	if got := mapModelName("embedded-model"); got != "gpt_4o" {
+		t.Errorf("mapModelName = %q", got)
+	}
+}
+
+func TestNewHandlerCalledTwice(t *testing.T) {
+	allowNewHandler(t)
+	mustNewHandler(t)
+	if h, err := NewHandler(); !errors.Is(err, ErrHandlerExists) || h != nil {
+		t.Errorf("second NewHandler = %v, %v, want ErrHandlerExists", h, err)
+	}
+}
+
+func TestNewHandlerNormalizesConfig(t *testing.T) {
+	allowNewHandler(t)
+	prev := currentConfig()
+	t.Cleanup(func() { setConfig(prev) })
+
+	// 检查失败时不安装配置，修正后可以再次调用
+	bad := &config.Config{OIDC: config.OIDCConfig{Issuer: "https://issuer.example.com"}}
+	if _, err := NewHandler(WithConfig(bad)); err == nil {
+		t.Fatal("NewHandler accepted an OIDC issuer without an audience")
+	}
+	if currentConfig() != prev {
+		t.Error("invalid config installed")
+	}
+
+	conf := &config.Config{DefaultModel: "gpt-4o"}
+	mustNewHandler(t, WithConfig(conf))
+	if currentConfig() != conf {
+		t.Fatal("WithConfig config not installed")
+	}
+	if len(conf.UpstreamParams) == 0 {
+		t.Error("UpstreamParams not filled with the default upstream params")
+	}
+}
+
+// mapConversationStore 是测试用的 ConversationStore。
+type mapConversationStore struct {
+	mu    sync.Mutex
+	convs map[string]*StoredConversation
+	err   error
+}
+
+func (s *mapConversationStore) Get(ctx context.Context, id string) (*StoredConversation, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.convs[id]
+	return conv, ok, s.err
+}
+
+func (s *mapConversationStore) Put(ctx context.Context, id string, conv *StoredConversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.convs[id] = conv
+	return s.err
+}
+
+func TestWithConversationStore(t *testing.T) {
+	allowNewHandler(t)
+	withMockUpstream(t, "echo")
+	store := &mapConversationStore{convs: make(map[string]*StoredConversation)}
+	mustNewHandler(t, WithConversationStore(store))
+	t.Cleanup(func() { setConversationStore(nil) })
+
+	first := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"first turn"}]}`)
+	id := first.Header().Get(requestIDHeader)
+	if conv, ok := store.convs[id]; !ok || len(conv.Messages) != 2 {
+		t.Fatalf("stored conversation = %+v, ok = %v", conv, ok)
+	}
+	if _, ok := conversations.lru.get(id); ok {
+		t.Error("conversation also saved in the in-memory store")
+	}
+
+	if rec := postChat(t, `{"model":"gpt-4o","dry_run":true,"previous_response_id":"`+id+`","messages":[{"role":"user","content":"second turn"}]}`); rec.Code != http.StatusOK {
+		t.Errorf("continue status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	// 读取失败按不存在处理
+	store.err = errors.New("store unavailable")
+	if rec := postChat(t, `{"model":"gpt-4o","previous_response_id":"`+id+`","messages":[{"role":"user","content":"x"}]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("status with failing store = %d, want 404", rec.Code)
+	}
+}
+
+func TestWithJobQueue(t *testing.T) {
+	allowNewHandler(t)
+	prev, prevErr := getJobQueue()
+	t.Cleanup(func() { jobQueue, jobQueueErr = prev, prevErr })
+
+	queue, err := jobqueue.Open("", jobqueue.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	mustNewHandler(t, WithJobQueue(queue))
+	if got, _ := getJobQueue(); got != queue {
+		t.Error("getJobQueue did not return the configured queue")
+	}
+}
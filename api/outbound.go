@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	upstream "you2api/internal/upstream"
+	logger "you2api/logger"
+
+	"go.uber.org/zap"
+)
+
+var (
+	transportOnce sync.Once
+	transport     http.RoundTripper
+)
+
+// upstreamClient 返回请求 You.com 的客户端，timeout 为 0 时不设置超时。
+// 所有上游请求都经过这里，测试可以替换为其他 upstream.Doer。
+var upstreamClient = func(timeout time.Duration) upstream.Doer {
+	return &http.Client{Transport: upstreamTransport(), Timeout: timeout}
+}
+
+// completionClient 返回请求补全接口（streamingSearch）的客户端，timeout 为 0 时不设置超时。
+// 补全的处理流程只通过 upstream.Client 读取上游事件，测试可以替换为其他实现。
+var completionClient = func(timeout time.Duration) upstream.Client {
+	return &upstream.HTTPClient{
+		Doer:          upstreamClient(timeout),
+		CheckStatus:   checkUpstreamStatus,
+		MaxEventBytes: currentConfig().SSEMaxEventBytes,
+		OnSkipped:     logSkippedEvent,
+	}
+}
+
+// upstreamTransport 返回所有 You.com 请求共用的 Transport，会在 debug 级别记录出站请求的元数据。
+// MOCK_MODE 下不会连接 You.com，而是返回合成的回复。
+func upstreamTransport() http.RoundTripper {
+	transportOnce.Do(func() {
+		conf := currentConfig()
+		base := http.DefaultTransport
+		if conf.MockMode {
+			base = &mockTransport{style: conf.MockStyle, delay: time.Duration(conf.MockEventDelayMS) * time.Millisecond}
+		}
+		transport = &loggingTransport{base: &corpusTransport{base: base}}
+	})
+	return transport
+}
+
+// loggingTransport 记录出站请求的 URL、查询参数与请求头，Cookie 与凭据在记录前脱敏，
+// 因此 debug 日志可以直接贴到 issue 中而不会泄露 DS token。
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	log := logger.L()
+	if ce := log.Check(zap.DebugLevel, "upstream request"); ce != nil {
+		ce.Write(
+			zap.String("method", req.Method),
+			zap.String("url", logger.ScrubURL(req.URL)),
+			zap.Any("headers", logger.ScrubHeaders(req.Header)),
+		)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		log.Debug("upstream request failed",
+			zap.String("host", req.URL.Host),
+			zap.Duration("elapsed", time.Since(start)),
+			zap.String("error", logger.ScrubError(err)),
+		)
+		return nil, err
+	}
+	log.Debug("upstream response",
+		zap.String("host", req.URL.Host),
+		zap.Int("status", resp.StatusCode),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	return resp, nil
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	upstream "you2api/internal/upstream"
+	logger "you2api/logger"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestLoggingTransportRedactsCredentials(t *testing.T) {
+	const dsToken = "eyJhbGciOiJIUzI1NiJ9.secret-ds-token"
+	core, logs := observer.New(zapcore.DebugLevel)
+	t.Cleanup(logger.Replace(zap.New(core)))
+
+	tests := []struct {
+		name string
+		base roundTripFunc
+	}{
+		{"success", func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}},
+		{"failure", func(req *http.Request) (*http.Response, error) {
+			return nil, &url.Error{Op: "Get", URL: req.URL.String(), Err: errors.New("Cookie: DS=" + dsToken)}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.TakeAll()
+			q := url.Values{"q": {"hello"}, "ds_token": {dsToken}}
+			req, err := upstream.NewStreamingRequest(context.Background(), q, dsToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+dsToken)
+			req.Body = io.NopCloser(strings.NewReader(`{"DS":"` + dsToken + `"}`))
+
+			(&loggingTransport{base: tt.base}).RoundTrip(req)
+
+			entries := logs.AllUntimed()
+			if len(entries) == 0 {
+				t.Fatal("no debug log written")
+			}
+			for _, entry := range entries {
+				dump := fmt.Sprint(entry.Message, entry.ContextMap())
+				if strings.Contains(dump, "secret-ds-token") {
+					t.Errorf("log entry leaked DS token: %s", dump)
+				}
+			}
+		})
+	}
+}
+
+// fakeStream 按顺序返回预设的事件，之后返回 io.EOF。
+type fakeStream struct {
+	events []upstream.Event
+	closed bool
+}
+
+func (s *fakeStream) Next() (upstream.Event, error) {
+	if len(s.events) == 0 {
+		return upstream.Event{}, io.EOF
+	}
+	ev := s.events[0]
+	s.events = s.events[1:]
+	return ev, nil
+}
+
+func (s *fakeStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+type fakeClient func() *fakeStream
+
+func (f fakeClient) Stream(*http.Request) (upstream.Stream, error) { return f(), nil }
+
+func TestCompletionClientReplaceable(t *testing.T) {
+	withMockUpstream(t, "echo")
+	var events []upstream.Event
+	var streams []*fakeStream
+	prev := completionClient
+	completionClient = func(time.Duration) upstream.Client {
+		return fakeClient(func() *fakeStream {
+			s := &fakeStream{events: append([]upstream.Event(nil), events...)}
+			streams = append(streams, s)
+			return s
+		})
+	}
+	t.Cleanup(func() { completionClient = prev })
+
+	events = []upstream.Event{
+		{Kind: upstream.KindToken, Text: "from "},
+		{Kind: upstream.KindToken, Text: "fake"},
+		{Kind: upstream.KindDone, FinishReason: "stop"},
+	}
+	resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if got := resp.Choices[0].Message.Content; got != "from fake" {
+		t.Errorf("non-stream content = %q", got)
+	}
+	chunks, done := decodeStream(t, postChat(t, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	var content strings.Builder
+	for _, chunk := range chunks {
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+		}
+	}
+	if !done || content.String() != "from fake" {
+		t.Errorf("stream content = %q, done = %v", content.String(), done)
+	}
+
+	events = []upstream.Event{{Kind: upstream.KindError, Err: &upstream.EventError{Message: "boom"}}}
+	if rec := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusBadGateway {
+		t.Errorf("error event status = %d, want 502", rec.Code)
+	}
+	for i, s := range streams {
+		if !s.closed {
+			t.Errorf("stream %d not closed", i)
+		}
+	}
+}
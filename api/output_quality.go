@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	logger "you2api/logger"
+
+	"go.uber.org/zap"
+)
+
+// 坏输出重试（BAD_OUTPUT_RETRY）：非流式回复明显损坏时（空回复、只有空白、上游的固定失败提示、
+// 代码围栏没有闭合），换一个账号池账号（使用账号池时）并改用 BAD_OUTPUT_RETRY_MODEL（为空时使用原模型）重试一次。
+// 重试得到正常回复时使用重试的结果，否则仍返回原回复；发生重试的响应带有 X-Output-Retry 头与
+// provider_metadata.output_retry。流式回复在发现问题之前已经发送给客户端，不做重试。
+
+// outputRetryHeader 是发生坏输出重试时的响应头，值为原回复被判定为损坏的原因。
+const outputRetryHeader = "X-Output-Retry"
+
+// boilerplateMaxRunes 是按固定提示判定的回复长度上限，较长的回复即使引用了这些语句也视为正常。
+const boilerplateMaxRunes = 400
+
+// 坏输出的原因。
+const (
+	badOutputEmpty       = "empty"
+	badOutputWhitespace  = "whitespace"
+	badOutputBoilerplate = "boilerplate"
+	badOutputOpenFence   = "unclosed_code_fence"
+)
+
+// badOutputReason 返回回复被判定为损坏的原因，正常时返回空字符串。因长度限制截断的回复不检查代码围栏。
+func badOutputReason(result *upstreamResult, patterns []string) string {
+	content := result.Content
+	trimmed := strings.TrimSpace(content)
+	switch {
+	case content == "":
+		return badOutputEmpty
+	case trimmed == "":
+		return badOutputWhitespace
+	}
+	if utf8.RuneCountInString(trimmed) <= boilerplateMaxRunes {
+		lower := strings.ToLower(trimmed)
+		for _, pattern := range patterns {
+			if pattern != "" && strings.Contains(lower, strings.ToLower(pattern)) {
+				return badOutputBoilerplate
+			}
+		}
+	}
+	if !result.Truncated && openCodeFence(content) {
+		return badOutputOpenFence
+	}
+	return ""
+}
+
+// openCodeFence 判断回复中是否有未闭合的 ``` 代码围栏。
+func openCodeFence(content string) bool {
+	open := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			open = !open
+		}
+	}
+	return open
+}
+
+// badOutputPatterns 返回 BAD_OUTPUT_PATTERNS 中的固定失败提示。
+func badOutputPatterns() []string {
+	var patterns []string
+	for _, p := range strings.Split(currentConfig().BadOutputPatterns, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// retryBadOutput 在回复损坏时重试一次，返回应使用的结果与重试信息；未重试时返回原结果与 nil。
+func retryBadOutput(ctx context.Context, w http.ResponseWriter, rs *requestState, result *upstreamResult) (*upstreamResult, *OutputRetry) {
+	conf := currentConfig()
+	if !conf.BadOutputRetry || rs.Rebuild == nil {
+		return result, nil
+	}
+	reason := badOutputReason(result, badOutputPatterns())
+	if reason == "" {
+		return result, nil
+	}
+	youModel := rs.UpstreamModel
+	if conf.BadOutputRetryModel != "" {
+		youModel = mapModelName(conf.BadOutputRetryModel)
+	}
+	retry := &OutputRetry{Reason: reason, Model: reverseMapModelName(youModel)}
+	w.Header().Set(outputRetryHeader, reason)
+	logger.L().Warn("回复疑似损坏，重试一次", zap.String("reason", reason), zap.String("model", youModel))
+
+	req, lease, err := rs.Rebuild(youModel)
+	if err != nil {
+		logger.L().Warn("无法构建重试请求", zap.String("error", logger.ScrubError(err)))
+		return result, retry
+	}
+	defer lease.release()
+	retried, err := fetchCompletion(req.WithContext(ctx))
+	lease.record(err)
+	if err != nil || badOutputReason(retried, badOutputPatterns()) != "" {
+		return result, retry
+	}
+	retry.Recovered = true
+	rs.UpstreamModel, rs.Model = youModel, retry.Model
+	return retried, retry
+}
+
+func withOutputRetry(meta *ProviderMetadata, retry *OutputRetry) *ProviderMetadata {
+	if retry == nil {
+		return meta
+	}
+	if meta == nil {
+		meta = &ProviderMetadata{}
+	}
+	meta.OutputRetry = retry
+	return meta
+}
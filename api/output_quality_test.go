@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBadOutputReason(t *testing.T) {
+	patterns := []string{"I'm unable to search right now"}
+	tests := []struct {
+		content   string
+		truncated bool
+		want      string
+	}{
+		{"The answer is 42.", false, ""},
+		{"", false, badOutputEmpty},
+		{" \n\t", false, badOutputWhitespace},
+		{"Sorry, I'm UNABLE to search right now.", false, badOutputBoilerplate},
+		{strings.Repeat("long answer ", 50) + "I'm unable to search right now", false, ""},
+		{"Here you go:\n```go\nfunc main() {", false, badOutputOpenFence},
+		{"Here you go:\n```go\nfunc main() {", true, ""},
+		{"```go\nfunc main() {}\n```\nDone.", false, ""},
+	}
+	for _, tt := range tests {
+		got := badOutputReason(&upstreamResult{Content: tt.content, Truncated: tt.truncated}, patterns)
+		if got != tt.want {
+			t.Errorf("badOutputReason(%.40q, truncated=%v) = %q, want %q", tt.content, tt.truncated, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	logger "you2api/logger"
+	metrics "you2api/metrics"
+)
+
+// outputSampleSize 是每个模型保留的输出长度样本数。
+const outputSampleSize = 200
+
+// modelOutputStats 记录单个模型的输出长度分布与近乎为空的连续补全次数。
+type modelOutputStats struct {
+	samples   []int
+	next      int
+	total     int64
+	emptyRun  int  // 当前连续近乎为空的补全数
+	alerted   bool // 当前这一轮连续异常是否已经告警
+	lastAlert time.Time
+}
+
+// outputStats 按模型跟踪输出长度，连续出现近乎为空的补全时告警，
+// 这通常意味着 DS token 失效或被封禁，比用户反馈更早发现问题。
+type outputStatsTracker struct {
+	mu     sync.Mutex
+	models map[string]*modelOutputStats
+}
+
+var outputStats = &outputStatsTracker{models: make(map[string]*modelOutputStats)}
+
+// record 记录一次补全的输出 token 数，并在异常时触发告警。
+func (t *outputStatsTracker) record(model string, tokens int) {
+	metrics.OutputTokens.WithLabelValues(model).Observe(float64(tokens))
+	conf := currentConfig()
+
+	t.mu.Lock()
+	stats, ok := t.models[model]
+	if !ok {
+		stats = &modelOutputStats{}
+		t.models[model] = stats
+	}
+	if len(stats.samples) < outputSampleSize {
+		stats.samples = append(stats.samples, tokens)
+	} else {
+		stats.samples[stats.next] = tokens
+		stats.next = (stats.next + 1) % outputSampleSize
+	}
+	stats.total++
+
+	if tokens > conf.NearEmptyTokens {
+		stats.emptyRun = 0
+		stats.alerted = false
+		t.mu.Unlock()
+		return
+	}
+	stats.emptyRun++
+	streak := stats.emptyRun
+	shouldAlert := streak >= conf.AnomalyStreak && !stats.alerted
+	if shouldAlert {
+		stats.alerted = true
+		stats.lastAlert = time.Now()
+	}
+	t.mu.Unlock()
+
+	if shouldAlert {
+		raiseOutputAnomaly(model, streak)
+	}
+}
+
+// raiseOutputAnomaly 通过日志、指标与可选的 webhook 报告输出异常。
+func raiseOutputAnomaly(model string, streak int) {
+	message := fmt.Sprintf("模型 %s 连续 %d 次返回近乎为空的补全，DS token 可能已失效或被限制", model, streak)
+	logger.L().Warn("输出异常", zap.String("model", model), zap.Int("streak", streak), zap.String("message", message))
+	metrics.OutputAnomalies.WithLabelValues(model, "near_empty_streak").Inc()
+
+	webhook := currentConfig().AnomalyWebhookURL
+	if webhook == "" {
+		return
+	}
+	go func() {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"kind":    "near_empty_streak",
+			"model":   model,
+			"streak":  streak,
+			"message": message,
+			"time":    time.Now().UTC(),
+		})
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Post(webhook, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			logger.L().Warn("发送异常告警 webhook 失败", zap.String("model", model), zap.String("error", logger.ScrubError(err, webhook)))
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+// outputStatsSnapshot 是 GET /admin/output-stats 返回的单个模型统计。
+type outputStatsSnapshot struct {
+	Model         string     `json:"model"`
+	Total         int64      `json:"total"`
+	Mean          float64    `json:"mean"`
+	P50           int        `json:"p50"`
+	P95           int        `json:"p95"`
+	EmptyStreak   int        `json:"empty_streak"`
+	LastAnomalyAt *time.Time `json:"last_anomaly_at,omitempty"`
+}
+
+func (t *outputStatsTracker) snapshot() []outputStatsSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]outputStatsSnapshot, 0, len(t.models))
+	for model, stats := range t.models {
+		sorted := append([]int(nil), stats.samples...)
+		sort.Ints(sorted)
+		sum := 0
+		for _, v := range sorted {
+			sum += v
+		}
+		snap := outputStatsSnapshot{
+			Model:       model,
+			Total:       stats.total,
+			EmptyStreak: stats.emptyRun,
+		}
+		if n := len(sorted); n > 0 {
+			snap.Mean = float64(sum) / float64(n)
+			snap.P50 = sorted[(n-1)/2]
+			snap.P95 = sorted[(n*95+99)/100-1]
+		}
+		if !stats.lastAlert.IsZero() {
+			lastAlert := stats.lastAlert
+			snap.LastAnomalyAt = &lastAlert
+		}
+		result = append(result, snap)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Model < result[j].Model })
+	return result
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"testing"
+
+	metrics "you2api/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOutputStatsNearEmptyStreak(t *testing.T) {
+	conf := currentConfig()
+	if conf.AnomalyStreak <= 0 {
+		t.Skip("ANOMALY_STREAK disabled")
+	}
+	tracker := &outputStatsTracker{models: make(map[string]*modelOutputStats)}
+	model := "output_stats_test_model"
+	alerts := func() float64 {
+		return testutil.ToFloat64(metrics.OutputAnomalies.WithLabelValues(model, "near_empty_streak"))
+	}
+
+	for i := 0; i < conf.AnomalyStreak-1; i++ {
+		tracker.record(model, conf.NearEmptyTokens)
+	}
+	if alerts() != 0 {
+		t.Fatal("alerted before the streak was reached")
+	}
+	tracker.record(model, 0)
+	tracker.record(model, 0) // 同一轮连续异常只告警一次
+	if got := alerts(); got != 1 {
+		t.Errorf("alerts = %v, want 1", got)
+	}
+
+	// 正常输出重置连续计数，之后新的一轮异常再次告警
+	tracker.record(model, conf.NearEmptyTokens+100)
+	for i := 0; i < conf.AnomalyStreak; i++ {
+		tracker.record(model, 0)
+	}
+	if got := alerts(); got != 2 {
+		t.Errorf("alerts after reset = %v, want 2", got)
+	}
+
+	snap := tracker.snapshot()
+	if len(snap) != 1 || snap[0].EmptyStreak != conf.AnomalyStreak || snap[0].LastAnomalyAt == nil {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	if want := int64(2*conf.AnomalyStreak + 2); snap[0].Total != want {
+		t.Errorf("total = %d, want %d", snap[0].Total, want)
+	}
+}
+
+func TestOutputStatsPercentiles(t *testing.T) {
+	tracker := &outputStatsTracker{models: make(map[string]*modelOutputStats)}
+	for i := 1; i <= 100; i++ {
+		tracker.record("percentile_model", i*10)
+	}
+	snap := tracker.snapshot()[0]
+	if snap.P50 != 500 || snap.P95 != 950 || snap.Mean != 505 {
+		t.Errorf("p50 = %d, p95 = %d, mean = %v", snap.P50, snap.P95, snap.Mean)
+	}
+}
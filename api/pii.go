@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 账号信息清理：You.com 的部分元数据事件会带上当前登录账号的邮箱、用户名等信息。
+// 使用账号池时这些是运维人员的账号，不能出现在客户端可见的响应中。
+// piiScrubber 从每个非 token 事件中学习账号信息，把它们从回复内容与响应元数据中替换掉；
+// 元数据（如搜索查询）中的邮箱地址无论是否学习到都会被替换。
+// 流式回复按块替换，账号信息恰好被拆分到两个块中时无法识别。
+
+// redactedPlaceholder 是被清理内容的替换文本。
+const redactedPlaceholder = "[redacted]"
+
+// accountDataKeys 是事件数据中表示账号信息的字段名。
+var accountDataKeys = map[string]bool{
+	"email":         true,
+	"emailAddress":  true,
+	"email_address": true,
+	"userEmail":     true,
+	"user_email":    true,
+	"username":      true,
+	"userName":      true,
+	"user_name":     true,
+	"accountName":   true,
+	"account_name":  true,
+	"displayName":   true,
+	"display_name":  true,
+	"fullName":      true,
+	"full_name":     true,
+	"firstName":     true,
+	"first_name":    true,
+	"lastName":      true,
+	"last_name":     true,
+}
+
+// minAccountValueLen 是学习的账号信息的最短长度，过短的值（如单个字母）替换后会破坏正常内容。
+const minAccountValueLen = 3
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// piiScrubber 清理单个请求中的账号信息。为 nil 时不做任何处理。
+type piiScrubber struct {
+	values []string // 按长度从长到短排列，避免较短的值先替换后较长的值无法匹配
+}
+
+// newPIIScrubber 在 enabled 为 false 时返回 nil（即不处理）。
+func newPIIScrubber(enabled bool) *piiScrubber {
+	if !enabled {
+		return nil
+	}
+	return &piiScrubber{}
+}
+
+// learn 从事件数据中提取账号信息。
+func (s *piiScrubber) learn(data string) {
+	if s == nil || !strings.ContainsAny(data, "@\"") {
+		return
+	}
+	var doc interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return
+	}
+	var walk func(v interface{}, isAccount bool)
+	walk = func(v interface{}, isAccount bool) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for key, child := range val {
+				walk(child, accountDataKeys[key])
+			}
+		case []interface{}:
+			for _, child := range val {
+				walk(child, isAccount)
+			}
+		case string:
+			if isAccount {
+				s.add(val)
+			}
+		}
+	}
+	walk(doc, false)
+}
+
+func (s *piiScrubber) add(value string) {
+	value = strings.TrimSpace(value)
+	if len(value) < minAccountValueLen {
+		return
+	}
+	for _, known := range s.values {
+		if known == value {
+			return
+		}
+	}
+	s.values = append(s.values, value)
+	sort.Slice(s.values, func(i, j int) bool { return len(s.values[i]) > len(s.values[j]) })
+}
+
+// scrub 替换回复内容中学习到的账号信息。
+func (s *piiScrubber) scrub(text string) string {
+	if s == nil {
+		return text
+	}
+	for _, value := range s.values {
+		text = strings.ReplaceAll(text, value, redactedPlaceholder)
+	}
+	return text
+}
+
+// scrubMetadata 替换元数据中学习到的账号信息与所有邮箱地址。
+func (s *piiScrubber) scrubMetadata(values []string) []string {
+	if s == nil {
+		return values
+	}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, emailPattern.ReplaceAllString(s.scrub(v), redactedPlaceholder))
+	}
+	return out
+}
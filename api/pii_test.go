@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	sse "you2api/internal/sse"
+	upstream "you2api/internal/upstream"
+)
+
+// testdata/account_stream.sse 是从真实账号抓取的上游事件流，账号信息已替换为虚构的值。
+func TestPIIScrubberCapturedStream(t *testing.T) {
+	f, err := os.Open("testdata/account_stream.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scrubber := newPIIScrubber(true)
+	var content strings.Builder
+	var queries []string
+	reader := sse.NewReader(f)
+	for {
+		ev, err := reader.Next()
+		if err != nil {
+			break
+		}
+		event, data := ev.Event, ev.Data
+		if event == "youChatToken" {
+			var token upstream.TokenEvent
+			json.Unmarshal([]byte(data), &token)
+			content.WriteString(scrubber.scrub(token.YouChatToken))
+			continue
+		}
+		scrubber.learn(data)
+		if upstream.IsSearchEvent(event) {
+			queries = append(queries, scrubber.scrubMetadata(extractSearchQueries(data))...)
+		}
+	}
+
+	visible := content.String() + "\n" + strings.Join(queries, "\n")
+	for _, secret := range []string{"jane.doe@example.com", "Jane Doe", "janedoe42"} {
+		if strings.Contains(visible, secret) {
+			t.Errorf("client-visible output contains %q:\n%s", secret, visible)
+		}
+	}
+	// 回复中的其他邮箱地址不是账号信息，保持原样
+	if want := "Hi [redacted], your account [redacted] is on the Pro plan. Contact support@you.com for help."; content.String() != want {
+		t.Errorf("content = %q, want %q", content.String(), want)
+	}
+	if len(queries) != 2 || queries[0] != "[redacted] order status" || queries[1] != "[redacted] account settings" {
+		t.Errorf("queries = %q", queries)
+	}
+}
+
+func TestPIIScrubberDisabled(t *testing.T) {
+	scrubber := newPIIScrubber(false)
+	scrubber.learn(`{"email":"jane.doe@example.com"}`)
+	if got := scrubber.scrub("jane.doe@example.com"); got != "jane.doe@example.com" {
+		t.Errorf("disabled scrubber changed content: %q", got)
+	}
+}
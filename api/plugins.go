@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	plugins "you2api/plugins"
+)
+
+// 请求改写插件：pre_request 在解析模型之前改写模型与消息，post_response 在非流式回复返回之前改写内容。
+// 流式响应已经逐块发送，不经过 post_response；插件可以根据 plugins.Request.Stream 拒绝流式请求。
+// PLUGINS 中的 WebAssembly 插件在 WithPlugins 注册的插件之后执行。插件的宿主接口见 plugins 包。
+
+// errPluginsUnavailable 表示 PLUGINS 中的插件加载失败。此时拒绝所有补全请求，不会在缺少过滤规则的情况下运行。
+var errPluginsUnavailable = errors.New("request plugins failed to load")
+
+var (
+	// registeredPlugins 是 NewHandler 注册的插件，开始处理请求之后只读
+	registeredPlugins plugins.Chain
+
+	wasmPluginsOnce sync.Once
+	wasmPlugins     plugins.Chain
+	wasmPluginsErr  error
+	pluginsRuntime  *plugins.Runtime
+)
+
+// setPlugins 注册插件，应在开始处理请求之前调用。
+func setPlugins(hooks []plugins.Hook) {
+	registeredPlugins = hooks
+}
+
+// loadPlugins 加载 PLUGINS 中的 WebAssembly 插件，只加载一次；Start 预先加载，加载失败时服务拒绝启动。
+func loadPlugins() (plugins.Chain, error) {
+	wasmPluginsOnce.Do(func() {
+		var paths []string
+		for _, path := range strings.Split(currentConfig().Plugins, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		if len(paths) == 0 {
+			return
+		}
+		ctx := context.Background()
+		rt, err := plugins.NewRuntime(ctx, time.Duration(currentConfig().PluginTimeoutMS)*time.Millisecond)
+		if err != nil {
+			wasmPluginsErr = fmt.Errorf("%w: %v", errPluginsUnavailable, err)
+			return
+		}
+		for _, path := range paths {
+			hook, err := rt.Load(ctx, path)
+			if err != nil {
+				rt.Close(ctx)
+				wasmPluginsErr = fmt.Errorf("%w: %v", errPluginsUnavailable, err)
+				return
+			}
+			wasmPlugins = append(wasmPlugins, hook)
+		}
+		pluginsRuntime = rt
+	})
+	return wasmPlugins, wasmPluginsErr
+}
+
+// activePlugins 返回按执行顺序排列的全部插件。
+func activePlugins() (plugins.Chain, error) {
+	wasm, err := loadPlugins()
+	if err != nil {
+		return nil, err
+	}
+	if len(wasm) == 0 {
+		return registeredPlugins, nil
+	}
+	return append(append(plugins.Chain(nil), registeredPlugins...), wasm...), nil
+}
+
+// closePlugins 释放 WebAssembly 插件运行时。
+func closePlugins(ctx context.Context) error {
+	if pluginsRuntime == nil {
+		return nil
+	}
+	return pluginsRuntime.Close(ctx)
+}
+
+// applyPreRequestPlugins 让插件改写请求的模型与消息。消息数量不变时逐条更新，保留工具调用等插件看不到的字段。
+func applyPreRequestPlugins(ctx context.Context, req *OpenAIRequest) error {
+	pluginChain, err := activePlugins()
+	if err != nil || len(pluginChain) == 0 {
+		return err
+	}
+	pr := &plugins.Request{Model: req.Model, Messages: make([]plugins.Message, len(req.Messages)), Stream: req.Stream}
+	for i, m := range req.Messages {
+		pr.Messages[i] = plugins.Message{Role: m.Role, Content: m.Content}
+	}
+	if err := pluginChain.PreRequest(ctx, pr); err != nil {
+		return err
+	}
+	req.Model = pr.Model
+	messages := make([]Message, len(pr.Messages)) // 不修改原切片，保存的对话历史不包含插件的改写
+	if len(pr.Messages) == len(req.Messages) {
+		copy(messages, req.Messages)
+	}
+	for i, m := range pr.Messages {
+		messages[i].Role, messages[i].Content = m.Role, m.Content
+	}
+	req.Messages = messages
+	return nil
+}
+
+// applyPostResponsePlugins 让插件改写非流式回复的内容。
+func applyPostResponsePlugins(ctx context.Context, model, content string) (string, error) {
+	pluginChain, err := activePlugins()
+	if err != nil {
+		return "", err
+	}
+	if len(pluginChain) == 0 {
+		return content, nil
+	}
+	resp := &plugins.Response{Model: model, Content: content}
+	if err := pluginChain.PostResponse(ctx, resp); err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	apierror "you2api/apierror"
+	plugins "you2api/plugins"
+	plugintest "you2api/plugins/plugintest"
+)
+
+// policyHook 拒绝流式请求与包含 secret 的回复，并给其他回复加上后缀。
+type policyHook struct{}
+
+func (policyHook) Name() string { return "policy" }
+
+func (policyHook) PreRequest(_ context.Context, req *plugins.Request) error {
+	if req.Stream {
+		return errors.New("streaming is not filtered")
+	}
+	return nil
+}
+
+func (policyHook) PostResponse(_ context.Context, resp *plugins.Response) error {
+	if strings.Contains(resp.Content, "secret") {
+		return errors.New("blocked")
+	}
+	resp.Content += " [checked]"
+	return nil
+}
+
+func TestWithPlugins(t *testing.T) {
+	allowNewHandler(t)
+	withMockUpstream(t, "echo")
+	mustNewHandler(t, WithPlugins(policyHook{}))
+	t.Cleanup(func() { setPlugins(nil) })
+
+	resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`))
+	if got := resp.Choices[0].Message.Content; got != "hello [checked]" {
+		t.Errorf("content = %q, want the plugin suffix", got)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"response rejected", `{"model":"gpt-4o","messages":[{"role":"user","content":"the secret"}]}`, http.StatusBadGateway, apierror.CodeResponseRejected},
+		{"stream rejected", `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hello"}]}`, http.StatusBadRequest, apierror.CodePluginRejected},
+	}
+	for _, tt := range tests {
+		rec := postChat(t, tt.body)
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if rec.Code != tt.status || body.Error.Code != tt.code {
+			t.Errorf("%s: status = %d, code = %q, want %d %q", tt.name, rec.Code, body.Error.Code, tt.status, tt.code)
+		}
+	}
+}
+
+// withPluginFiles 在测试期间把 PLUGINS 设为 paths，并重新加载插件。
+func withPluginFiles(t *testing.T, paths ...string) {
+	t.Helper()
+	prev := currentConfig()
+	conf := *prev
+	conf.Plugins = strings.Join(paths, ",")
+	conf.PluginTimeoutMS = 1000
+	setConfig(&conf)
+	reset := func() {
+		closePlugins(context.Background())
+		wasmPluginsOnce, wasmPlugins, wasmPluginsErr, pluginsRuntime = sync.Once{}, nil, nil, nil
+	}
+	reset()
+	t.Cleanup(func() {
+		reset()
+		setConfig(prev)
+	})
+}
+
+func TestWASMPluginsFromConfig(t *testing.T) {
+	withMockUpstream(t, "echo")
+	path := filepath.Join(t.TempDir(), "brief.wasm")
+	module := plugintest.Module(`{"messages":[{"role":"user","content":"rewritten prompt"}]}`, `{"content":"filtered reply"}`)
+	if err := os.WriteFile(path, module, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	withPluginFiles(t, path)
+
+	resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`))
+	if got := resp.Choices[0].Message.Content; got != "filtered reply" {
+		t.Errorf("content = %q, want the post_response output", got)
+	}
+}
+
+func TestWASMPluginsLoadFailure(t *testing.T) {
+	withMockUpstream(t, "echo")
+	withPluginFiles(t, filepath.Join(t.TempDir(), "missing.wasm"))
+
+	// 插件加载失败时拒绝启动，无服务器部署中拒绝所有补全请求
+	if err := Start(context.Background()); !errors.Is(err, errPluginsUnavailable) {
+		t.Errorf("Start = %v, want errPluginsUnavailable", err)
+	}
+	if rec := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`); rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500; body %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	apierror "you2api/apierror"
+	logger "you2api/logger"
+	metrics "you2api/metrics"
+	pool "you2api/pool"
+)
+
+var (
+	tokenPoolOnce sync.Once
+	tokenPoolMu   sync.RWMutex
+	tokenPool     *pool.Pool
+	tokenPoolData []byte // 账号池文件的原始内容，导出状态时使用
+
+	tokenPoolUpdateMu sync.Mutex // 串行化账号池的替换，读取-修改-写回期间不会丢失其他更新
+)
+
+// getTokenPool 返回 DS token 账号池；未配置 TOKEN_POOL_FILE 且未导入过账号池时返回 nil。
+func getTokenPool() *pool.Pool {
+	tokenPoolOnce.Do(func() {
+		path := currentConfig().TokenPoolFile
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("加载 DS token 账号池失败: %v", err)
+			return
+		}
+		p, err := pool.Parse(data)
+		if err != nil {
+			log.Printf("加载 DS token 账号池失败: %v", err)
+			return
+		}
+		tokenPool, tokenPoolData = p, data
+	})
+	tokenPoolMu.RLock()
+	defer tokenPoolMu.RUnlock()
+	return tokenPool
+}
+
+// replaceTokenPool 用新的账号池文件内容替换当前账号池，配置了 TOKEN_POOL_FILE 时同时写回文件。
+func replaceTokenPool(data []byte) error {
+	return updateTokenPool(func([]byte) ([]byte, error) { return data, nil })
+}
+
+// updateTokenPool 以当前账号池文件内容调用 edit，并用其结果替换账号池。
+// 并发的更新依次执行，edit 总是看到上一次更新的结果。
+func updateTokenPool(edit func(data []byte) ([]byte, error)) error {
+	tokenPoolUpdateMu.Lock()
+	defer tokenPoolUpdateMu.Unlock()
+	data, err := edit(tokenPoolSnapshot()) // tokenPoolSnapshot 确保初始加载不会覆盖导入的账号池
+	if err != nil {
+		return err
+	}
+	p, err := pool.Parse(data)
+	if err != nil {
+		return err
+	}
+	if path := currentConfig().TokenPoolFile; path != "" {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return err
+		}
+	}
+	tokenPoolMu.Lock()
+	defer tokenPoolMu.Unlock()
+	tokenPool, tokenPoolData = p, data
+	return nil
+}
+
+// setTokenPool 使用以代码方式创建的账号池，见 WithTokenPool。这样的账号池没有文件内容，导出状态时不包含。
+func setTokenPool(p *pool.Pool) {
+	tokenPoolOnce.Do(func() {})
+	tokenPoolMu.Lock()
+	defer tokenPoolMu.Unlock()
+	tokenPool, tokenPoolData = p, nil
+}
+
+// tokenPoolSnapshot 返回账号池文件的原始内容，没有账号池时返回 nil。
+func tokenPoolSnapshot() []byte {
+	getTokenPool()
+	tokenPoolMu.RLock()
+	defer tokenPoolMu.RUnlock()
+	return tokenPoolData
+}
+
+// resolveDSToken 返回用于请求 You.com 的 DS token，用于文件上传等不占用并发名额的短请求。
+func resolveDSToken(ctx context.Context, apiKey string) (string, error) {
+	token, lease, err := acquireDSToken(ctx, apiKey)
+	lease.release()
+	return token, err
+}
+
+// errNoReadyAccount 表示可用时间段内的账号都在冷却或已达到并发上限。
+var errNoReadyAccount = errors.New("all available DS tokens are cooling down or at their concurrency limit")
+
+// acquireDSToken 返回用于请求 You.com 的 DS token。
+// 客户端携带 POOL_ACCESS_KEY 时按轮询顺序从账号池中选择当前可用的账号：跳过冷却中的账号，
+// 并占用一个并发名额（TOKEN_MAX_CONCURRENCY）；否则直接把客户端提供的值作为 DS token，返回的 lease 为 nil。
+// 开启 FAIR_QUEUE_ENABLED 时没有空闲账号的请求按租户公平排队，见 fair_queue.go。
+func acquireDSToken(ctx context.Context, apiKey string) (string, *tokenLease, error) {
+	accessKey := currentConfig().PoolAccessKey
+	p := getTokenPool()
+	jwt := principalFrom(ctx) != nil // 通过 JWT 认证的客户端总是使用账号池，见 oidc.go
+	if !jwt && (p == nil || accessKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(accessKey)) != 1) {
+		return apiKey, nil, nil
+	}
+	if p == nil || !p.HasAvailable(time.Now()) {
+		return "", nil, pool.ErrNoAvailableAccount
+	}
+	if currentConfig().FairQueue.Enabled {
+		return fairQueue.acquire(ctx, p, tenantFrom(ctx, apiKey))
+	}
+	if token, lease, ok := tryAcquireAccount(ctx, p); ok {
+		return token, lease, nil
+	}
+	return "", nil, errNoReadyAccount
+}
+
+// accountError 表示没能占用账号池中的账号，与上游或模型是否可用无关。
+type accountError struct {
+	err error
+}
+
+func (e *accountError) Error() string { return e.err.Error() }
+func (e *accountError) Unwrap() error { return e.err }
+
+// writeAccountError 返回占用账号失败的错误响应：排队已满时为 queue_full，否则为 no_account_available。
+func writeAccountError(w http.ResponseWriter, err error) {
+	code := apierror.CodeNoAccountAvailable
+	if errors.Is(err, errQueueFull) {
+		code = apierror.CodeQueueFull
+	}
+	dailySummary.recordIncident(code)
+	apierror.Write(w, http.StatusServiceUnavailable, code, err.Error())
+}
+
+// tryAcquireAccount 按轮询顺序占用一个未在冷却且未达到并发上限的账号。
+func tryAcquireAccount(ctx context.Context, p *pool.Pool) (string, *tokenLease, bool) {
+	now := time.Now()
+	state := getTokenState()
+	for _, account := range p.Candidates(now) {
+		id := keyID(account.Token)
+		if st, _ := state.Status(ctx, id); now.Before(st.CooldownUntil) {
+			continue
+		}
+		release, ok, _ := state.Acquire(ctx, id, currentConfig().TokenState.MaxConcurrency)
+		if !ok {
+			continue
+		}
+		return account.Token, &tokenLease{account: id, free: release}, true
+	}
+	return "", nil, false
+}
+
+// tokenLease 是一次补全占用的账号池账号。为 nil 时（未使用账号池）不做任何处理。
+type tokenLease struct {
+	account string // 账号的 key ID，不在状态存储中保存 token 本身
+	free    func() // 释放并发名额
+}
+
+// release 释放并发名额，可以多次调用。
+func (l *tokenLease) release() {
+	if l != nil && l.free != nil {
+		l.free()
+	}
+}
+
+// record 按补全结果更新账号状态：被限流时冷却，连续失败 TOKEN_FAILURE_THRESHOLD 次后冷却，成功时清零失败次数。
+// 客户端断开与订阅等级限制与账号是否健康无关，不计入。
+func (l *tokenLease) record(err error) {
+	if l == nil || errors.Is(err, errClientDisconnected) || errors.Is(err, errTierRestricted) {
+		return
+	}
+	conf := currentConfig().TokenState
+	state := getTokenState()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	cooldown := time.Duration(conf.CooldownMS) * time.Millisecond
+	var rateLimited *rateLimitError
+	switch {
+	case err == nil:
+		state.RecordSuccess(ctx, l.account)
+	case errors.As(err, &rateLimited):
+		if rateLimited.RetryAfter > 0 {
+			cooldown = rateLimited.RetryAfter
+		}
+		l.cool(ctx, state, cooldown, "rate_limited")
+	default:
+		failures, _ := state.RecordFailure(ctx, l.account)
+		if conf.FailureThreshold > 0 && failures >= conf.FailureThreshold {
+			l.cool(ctx, state, cooldown, "failures")
+			state.RecordSuccess(ctx, l.account) // 冷却结束后重新计数
+		}
+	}
+}
+
+func (l *tokenLease) cool(ctx context.Context, state pool.State, d time.Duration, reason string) {
+	if d <= 0 {
+		return
+	}
+	state.SetCooldown(ctx, l.account, time.Now().Add(d))
+	metrics.TokenCooldowns.WithLabelValues(reason).Inc()
+	dailySummary.recordIncident("cooldown_" + reason)
+	logger.L().Warn("账号进入冷却", zap.String("key_id", l.account), zap.String("reason", reason), zap.Duration("duration", d))
+}
+
+var (
+	tokenStateOnce sync.Once
+	tokenState     pool.State
+	tokenStateWarn atomic.Int64 // 上次记录 Redis 错误的时间（UnixNano），避免 Redis 故障时刷屏
+)
+
+// getTokenState 返回账号运行状态的存储：配置了 REDIS_URL 时多个实例通过 Redis 共享（出错时退回实例内存），否则只在内存中保存。
+func getTokenState() pool.State {
+	tokenStateOnce.Do(func() {
+		local := pool.NewLocalState()
+		tokenState = local
+		conf := currentConfig().TokenState
+		if conf.RedisURL == "" {
+			return
+		}
+		redisState, err := pool.NewRedisState(conf.RedisURL, conf.RedisKeyPrefix)
+		if err != nil {
+			log.Printf("REDIS_URL 无效，账号状态只保存在实例内存中: %v", err)
+			return
+		}
+		tokenState = pool.NewFallbackState(redisState, local, func(err error) {
+			metrics.TokenStateErrors.Inc()
+			now := time.Now().UnixNano()
+			if last := tokenStateWarn.Load(); now-last > int64(30*time.Second) && tokenStateWarn.CompareAndSwap(last, now) {
+				logger.L().Warn("读写 Redis 中的账号状态失败，暂时使用实例内存中的状态", zap.String("error", err.Error()))
+			}
+		})
+	})
+	return tokenState
+}
+
+// setTokenState 使用给定的账号状态存储，见 WithTokenState。
+func setTokenState(s pool.State) {
+	tokenStateOnce.Do(func() {})
+	tokenState = s
+}
+
+// poolAccountStatus 是 GET /admin/pool 返回的单个账号状态，不包含 token。
+type poolAccountStatus struct {
+	Name      string `json:"name"`
+	KeyID     string `json:"key_id"`
+	Available bool   `json:"available"`
+	Windows   int    `json:"windows"`
+	Timezone  string `json:"timezone"`
+	// 运行状态，多实例部署时是所有实例共享的状态
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"`
+	Inflight      int        `json:"inflight"`
+	Failures      int        `json:"failures"`
+}
+
+func handlePoolStatus(w http.ResponseWriter, r *http.Request) {
+	p := getTokenPool()
+	if p == nil {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeNotConfigured, "Token pool is not configured")
+		return
+	}
+	now := time.Now()
+	statuses := make([]poolAccountStatus, 0, len(p.Accounts()))
+	for _, account := range p.Accounts() {
+		status := poolAccountStatus{
+			Name:      account.Name,
+			KeyID:     keyID(account.Token),
+			Available: account.AvailableAt(now),
+			Windows:   len(account.Windows),
+			Timezone:  account.Location.String(),
+		}
+		st, _ := getTokenState().Status(r.Context(), status.KeyID)
+		if now.Before(st.CooldownUntil) {
+			status.CooldownUntil = &st.CooldownUntil
+		}
+		status.Inflight, status.Failures = st.Inflight, st.Failures
+		statuses = append(statuses, status)
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// upstreamResult 是一次非流式上游请求的汇总结果。
+type upstreamResult struct {
+	Content       string
+	Reasoning     string // 思考过程事件的内容，见 reasoning.go
+	SearchQueries []string
+	Sources       []Source // 搜索事件中的引用来源，见 citations.go
+	Truncated     bool     // 超出最大响应大小而提前结束
+	DoneReason    string   // 上游终止事件给出的 finish_reason，见 internal/upstream
+}
+
+// finishReason 返回 OpenAI 响应中的 finish_reason。
+func (res *upstreamResult) finishReason() string {
+	if res.Truncated {
+		return "length"
+	}
+	if res.DoneReason != "" {
+		return res.DoneReason
+	}
+	return "stop"
+}
+
+// providerMetadata 在没有任何元数据时返回 nil，以便在响应中省略该字段。
+func (res *upstreamResult) providerMetadata() *ProviderMetadata {
+	if len(res.SearchQueries) == 0 {
+		return nil
+	}
+	return &ProviderMetadata{SearchQueries: res.SearchQueries}
+}
+
+// searchQueryKeys 是事件数据中表示搜索查询的字段名。
+var searchQueryKeys = map[string]bool{
+	"query":         true,
+	"queries":       true,
+	"search_query":  true,
+	"searchQuery":   true,
+	"searchQueries": true,
+}
+
+// extractSearchQueries 递归查找事件 JSON 数据中的搜索查询字段。
+func extractSearchQueries(data string) []string {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return nil
+	}
+	var queries []string
+	var walk func(v interface{}, isQuery bool)
+	walk = func(v interface{}, isQuery bool) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for key, child := range val {
+				walk(child, searchQueryKeys[key])
+			}
+		case []interface{}:
+			for _, child := range val {
+				walk(child, isQuery)
+			}
+		case string:
+			if isQuery && strings.TrimSpace(val) != "" {
+				queries = append(queries, val)
+			}
+		}
+	}
+	walk(doc, false)
+	return queries
+}
+
+// appendUnique 追加尚未出现过的查询。
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		if !slices.Contains(list, item) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
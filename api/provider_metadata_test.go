@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestExtractSearchQueries(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{`{"search":{"query":"go generics"}}`, []string{"go generics"}},
+		{`{"queries":["a","b"],"other":"c"}`, []string{"a", "b"}},
+		{`{"results":[{"searchQuery":"x"},{"search_query":" "}]}`, []string{"x"}},
+		{`{"name":"not a query"}`, nil},
+		{`not json`, nil},
+	}
+	for _, tt := range tests {
+		got := extractSearchQueries(tt.data)
+		sort.Strings(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("extractSearchQueries(%s) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestSearchQueriesInResponse(t *testing.T) {
+	withMockUpstream(t, "echo")
+	resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"latest go release"}]}`))
+	if resp.ProviderMetadata == nil || !slices.Equal(resp.ProviderMetadata.SearchQueries, []string{"latest go release"}) {
+		t.Errorf("provider_metadata = %+v", resp.ProviderMetadata)
+	}
+	if got := appendUnique([]string{"a"}, "a", "b", "b"); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("appendUnique = %v", got)
+	}
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	i18n "you2api/i18n"
+)
+
+// 推理模型（如 deepseek-reasoner）在回复开头以 <think>...</think> 输出思考过程。
+// 部署级默认处理方式由 REASONING_MODE 决定，单个请求可以用 OpenRouter 风格的字段覆盖：
+// reasoning: {"exclude": true} 或 include_reasoning: false 去掉思考过程，
+// 这样同一个部署既能服务需要思考过程的 Agent，也能服务只展示最终回答的聊天界面。
+// 保留思考过程时，REASONING_DELIMITERS 可以把 <think> 标记替换为其他分隔符（如可折叠的 <details> 块），
+// 便于不同的前端把思考过程折叠显示；<details> 块的标题按客户端的 Accept-Language 本地化。
+
+// deepseek-reasoner、claude-3-7-sonnet-think 等模型的思考过程由单独的上游事件发送（见 upstream.IsThinkingEvent），
+// 这部分内容放在 DeepSeek 风格的 reasoning_content 字段（Delta 与 Message）中，不混入回复内容；
+// 处理方式为 exclude 时同样去掉。
+
+// 思考过程的处理方式。
+const (
+	reasoningInline  = "inline"  // 原样保留在回复内容中
+	reasoningExclude = "exclude" // 从回复中去掉
+)
+
+// 思考过程的开始与结束标记。
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// 思考过程分隔符的预设（REASONING_DELIMITERS）。
+const (
+	reasoningDelimitersThink   = "think"   // 保留 <think>...</think>
+	reasoningDelimitersDetails = "details" // Markdown 中可折叠的 <details> 块
+	reasoningDelimitersCustom  = "custom"  // 使用 REASONING_OPEN 与 REASONING_CLOSE
+)
+
+// detailsOpen 与 detailsClose 是 details 预设的分隔符，前后的空行让 Markdown 正常渲染块内的内容。
+const (
+	detailsOpen  = "<details>\n<summary>%s</summary>\n\n"
+	detailsClose = "\n</details>\n\n"
+)
+
+// reasoningDelimiters 返回部署配置的思考过程开始与结束分隔符，lang 是客户端的语言。
+// 自定义分隔符中的 "\n" 转义为换行符，便于在环境变量中配置。
+func reasoningDelimiters(lang string) (open, close string) {
+	conf := currentConfig()
+	switch conf.ReasoningDelimiters {
+	case reasoningDelimitersDetails:
+		return fmt.Sprintf(detailsOpen, i18n.Sprintf(lang, "Thinking")), detailsClose
+	case reasoningDelimitersCustom:
+		unescape := strings.NewReplacer(`\n`, "\n").Replace
+		return unescape(conf.ReasoningOpen), unescape(conf.ReasoningClose)
+	}
+	return thinkOpenTag, thinkCloseTag
+}
+
+// reasoningMode 返回请求的思考过程处理方式，reasoning.exclude 优先于 include_reasoning，都未指定时使用部署默认值。
+func reasoningMode(req OpenAIRequest) string {
+	switch {
+	case req.Reasoning != nil && req.Reasoning.Exclude != nil:
+		if *req.Reasoning.Exclude {
+			return reasoningExclude
+		}
+		return reasoningInline
+	case req.IncludeReasoning != nil:
+		if *req.IncludeReasoning {
+			return reasoningInline
+		}
+		return reasoningExclude
+	}
+	if currentConfig().ReasoningMode == reasoningExclude {
+		return reasoningExclude
+	}
+	return reasoningInline
+}
+
+// reasoningFilter 按处理方式转换回复中的思考过程：去掉，或替换分隔符。为 nil 时不做任何处理。
+type reasoningFilter struct {
+	exclude     bool
+	open, close string // 保留思考过程时替换 <think> 与 </think> 的分隔符
+
+	inThink    bool
+	afterThink bool   // 刚结束一段思考过程，去掉紧随其后的空行
+	pending    string // 末尾可能是被拆分的标记，等待下一个块
+}
+
+// newReasoningFilter 在不需要转换时（保留思考过程且分隔符不变）返回 nil。
+func newReasoningFilter(mode, lang string) *reasoningFilter {
+	if mode == reasoningExclude {
+		return &reasoningFilter{exclude: true}
+	}
+	open, close := reasoningDelimiters(lang)
+	if open == thinkOpenTag && close == thinkCloseTag {
+		return nil
+	}
+	return &reasoningFilter{open: open, close: close}
+}
+
+// push 处理一个内容块，返回可以立即发送的部分。
+func (f *reasoningFilter) push(chunk string) string {
+	if f == nil {
+		return chunk
+	}
+	text := f.pending + chunk
+	f.pending = ""
+	var out strings.Builder
+	for text != "" {
+		tag := thinkOpenTag
+		if f.inThink {
+			tag = thinkCloseTag
+		}
+		if i := strings.Index(text, tag); i >= 0 {
+			f.emit(&out, text[:i])
+			text = text[i+len(tag):]
+			f.inThink = !f.inThink
+			switch {
+			case f.exclude:
+				f.afterThink = !f.inThink
+			case f.inThink:
+				out.WriteString(f.open)
+			default:
+				out.WriteString(f.close)
+			}
+			continue
+		}
+		keep := partialTagSuffix(text, tag)
+		f.emit(&out, text[:len(text)-keep])
+		f.pending = text[len(text)-keep:]
+		break
+	}
+	return out.String()
+}
+
+// flush 在回复结束时输出暂存的内容。去掉思考过程时未闭合的部分直接丢弃，替换分隔符时补上结束分隔符。
+func (f *reasoningFilter) flush() string {
+	if f == nil {
+		return ""
+	}
+	var out strings.Builder
+	f.emit(&out, f.pending)
+	f.pending = ""
+	if f.inThink && !f.exclude {
+		out.WriteString(f.close)
+		f.inThink = false
+	}
+	return out.String()
+}
+
+// apply 一次性处理完整内容（非流式响应）。
+func (f *reasoningFilter) apply(content string) string {
+	if f == nil {
+		return content
+	}
+	return f.push(content) + f.flush()
+}
+
+func (f *reasoningFilter) emit(out *strings.Builder, s string) {
+	if f.inThink && f.exclude {
+		return
+	}
+	if f.afterThink {
+		s = strings.TrimLeft(s, "\r\n")
+		if s == "" {
+			return
+		}
+		f.afterThink = false
+	}
+	out.WriteString(s)
+}
+
+// partialTagSuffix 返回 text 末尾可能是 tag 前缀的最长长度。
+func partialTagSuffix(text, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// reasoningContent 返回响应中的 reasoning_content，处理方式为 exclude 时为空。
+func (rs *requestState) reasoningContent(thinking string) string {
+	if rs.ReasoningMode == reasoningExclude {
+		return ""
+	}
+	return thinking
+}
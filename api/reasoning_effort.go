@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"fmt"
+	"slices"
+)
+
+// reasoning_effort：You.com 把同一推理模型的不同思考强度作为独立模型提供（如 openai_o3_mini_high），
+// 客户端可以请求基础模型名称（o3-mini）并用 reasoning_effort（或 reasoning.effort）选择强度，
+// 代理据此选择对应的 You.com 模型。显式的强度模型名称（o3-mini-high 等）与 key 别名保持原样，不受该参数影响；
+// 请求基础模型而未指定强度时使用 medium。上游没有对应强度时使用最接近的一档（o3-mini 没有 low，使用 medium）。
+
+// reasoningEfforts 是 reasoning_effort 的取值。
+var reasoningEfforts = []string{"low", "medium", "high"}
+
+// reasoningEffortModels 是按强度区分的推理模型：基础模型名称 -> 强度 -> You.com 模型名称。
+var reasoningEffortModels = map[string]map[string]string{
+	"o3-mini": {
+		"low":    "openai_o3_mini_medium",
+		"medium": "openai_o3_mini_medium",
+		"high":   "openai_o3_mini_high",
+	},
+}
+
+// reasoningEffort 返回请求的思考强度，reasoning_effort 优先于 reasoning.effort。
+func reasoningEffort(req OpenAIRequest) (string, error) {
+	effort := req.ReasoningEffort
+	if effort == "" && req.Reasoning != nil {
+		effort = req.Reasoning.Effort
+	}
+	if effort != "" && !slices.Contains(reasoningEfforts, effort) {
+		return "", fmt.Errorf("reasoning_effort must be one of low, medium, high, got %q", effort)
+	}
+	return effort, nil
+}
+
+// reasoningEffortModel 返回基础模型在给定强度下对应的 You.com 模型，effort 为空时使用 medium；
+// model 不是按强度区分的基础模型时 ok 为 false。
+func reasoningEffortModel(model, effort string) (youModel string, ok bool) {
+	variants, ok := reasoningEffortModels[model]
+	if !ok {
+		return "", false
+	}
+	if effort == "" {
+		effort = "medium"
+	}
+	return variants[effort], true
+}
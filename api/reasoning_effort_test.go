@@ -0,0 +1,38 @@
+package handler
+
+import "testing"
+
+func TestReasoningEffortModel(t *testing.T) {
+	tests := []struct {
+		model, effort string
+		want          string
+		ok            bool
+	}{
+		{"o3-mini", "high", "openai_o3_mini_high", true},
+		{"o3-mini", "medium", "openai_o3_mini_medium", true},
+		{"o3-mini", "low", "openai_o3_mini_medium", true},
+		{"o3-mini", "", "openai_o3_mini_medium", true},
+		{"o3-mini-high", "low", "", false},
+		{"gpt-4o", "high", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := reasoningEffortModel(tt.model, tt.effort)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("reasoningEffortModel(%q, %q) = %q, %v; want %q, %v", tt.model, tt.effort, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRequestReasoningEffort(t *testing.T) {
+	req := OpenAIRequest{ReasoningEffort: "high", Reasoning: &ReasoningOptions{Effort: "low"}}
+	if effort, err := reasoningEffort(req); effort != "high" || err != nil {
+		t.Errorf("reasoning_effort = %q, %v; want high", effort, err)
+	}
+	req = OpenAIRequest{Reasoning: &ReasoningOptions{Effort: "low"}}
+	if effort, err := reasoningEffort(req); effort != "low" || err != nil {
+		t.Errorf("reasoning.effort = %q, %v; want low", effort, err)
+	}
+	if _, err := reasoningEffort(OpenAIRequest{ReasoningEffort: "extreme"}); err == nil {
+		t.Error("invalid reasoning_effort accepted")
+	}
+}
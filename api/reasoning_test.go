@@ -0,0 +1,77 @@
+package handler
+
+import "testing"
+
+func TestReasoningFilterExclude(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"no think block", []string{"Hello ", "world"}, "Hello world"},
+		{"whole block", []string{"<think>plan</think>\n\nAnswer"}, "Answer"},
+		{"split tags", []string{"<thi", "nk>pl", "an</th", "ink>", "\n", "Answer"}, "Answer"},
+		{"unclosed block", []string{"<think>still thinking"}, ""},
+		{"partial tag at end", []string{"a <thi"}, "a <thi"},
+		{"literal less-than", []string{"1 < 2", " and 3 > 2"}, "1 < 2 and 3 > 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newReasoningFilter(reasoningExclude, "en")
+			got := ""
+			for _, chunk := range tt.chunks {
+				got += f.push(chunk)
+			}
+			got += f.flush()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReasoningFilterDelimiters(t *testing.T) {
+	details := "<details>\n<summary>Thinking</summary>\n\n"
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"details block", []string{"<thi", "nk>plan</thi", "nk>\n\nAnswer"}, details + "plan" + detailsClose + "\n\nAnswer"},
+		{"unclosed block is closed", []string{"<think>plan"}, details + "plan" + detailsClose},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &reasoningFilter{open: details, close: detailsClose}
+			got := ""
+			for _, chunk := range tt.chunks {
+				got += f.push(chunk)
+			}
+			got += f.flush()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReasoningModeOverride(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		req  OpenAIRequest
+		want string
+	}{
+		{"default", OpenAIRequest{}, reasoningInline},
+		{"include_reasoning false", OpenAIRequest{IncludeReasoning: &no}, reasoningExclude},
+		{"reasoning.exclude true", OpenAIRequest{Reasoning: &ReasoningOptions{Exclude: &yes}}, reasoningExclude},
+		{"reasoning takes precedence", OpenAIRequest{Reasoning: &ReasoningOptions{Exclude: &no}, IncludeReasoning: &no}, reasoningInline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reasoningMode(tt.req); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// 客户端指定响应 ID 的请求头。
+const (
+	requestIDHeader      = "X-Request-ID"
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// validResponseID 限制客户端提供的 ID 的字符集与长度，避免注入日志或响应头。
+var validResponseID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestedResponseID 返回客户端要求使用的响应 ID，未指定时返回空字符串。
+// X-Request-ID 原样使用；Idempotency-Key 与 API key 一起哈希，不同客户端使用相同的 key 也不会冲突。
+// 两者都不合法时返回空字符串，由服务端生成 ID。
+func requestedResponseID(r *http.Request, apiKey string) string {
+	if id := r.Header.Get(requestIDHeader); validResponseID.MatchString(id) {
+		return id
+	}
+	if key := r.Header.Get(idempotencyKeyHeader); validResponseID.MatchString(key) {
+		sum := sha256.Sum256([]byte(keyID(apiKey) + ":" + key))
+		return "chatcmpl-" + hex.EncodeToString(sum[:16])
+	}
+	return ""
+}
+
+// newCompletionID 生成服务端的补全 ID。基于 UUID，同一秒内的并发请求也不会冲突。
+func newCompletionID() string {
+	return "chatcmpl-" + uuid.NewString()
+}
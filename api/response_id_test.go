@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestedResponseID(t *testing.T) {
+	req := func(header ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		return r
+	}
+
+	if got := requestedResponseID(req(), "key"); got != "" {
+		t.Errorf("no header: %q", got)
+	}
+	if got := requestedResponseID(req(requestIDHeader, "client-req-1"), "key"); got != "client-req-1" {
+		t.Errorf("X-Request-ID: %q", got)
+	}
+	for _, bad := range []string{"has space", "line\nbreak", strings.Repeat("x", 129)} {
+		if got := requestedResponseID(req(requestIDHeader, bad), "key"); got != "" {
+			t.Errorf("invalid X-Request-ID %q accepted: %q", bad, got)
+		}
+	}
+
+	// Idempotency-Key 按 API key 区分，同一客户端重复使用得到相同 ID
+	a1 := requestedResponseID(req(idempotencyKeyHeader, "retry-1"), "key-a")
+	a2 := requestedResponseID(req(idempotencyKeyHeader, "retry-1"), "key-a")
+	b := requestedResponseID(req(idempotencyKeyHeader, "retry-1"), "key-b")
+	if !strings.HasPrefix(a1, "chatcmpl-") || a1 != a2 || a1 == b {
+		t.Errorf("idempotency IDs: a1 = %q, a2 = %q, b = %q", a1, a2, b)
+	}
+	// 两者都存在时以 X-Request-ID 为准
+	if got := requestedResponseID(req(requestIDHeader, "explicit", idempotencyKeyHeader, "retry-1"), "key-a"); got != "explicit" {
+		t.Errorf("precedence: %q", got)
+	}
+}
+
+func TestClientSuppliedResponseID(t *testing.T) {
+	withMockUpstream(t, "echo")
+	rec := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, requestIDHeader, "client-chosen-id")
+	resp := decodeCompletion(t, rec)
+	if resp.ID != "client-chosen-id" || rec.Header().Get(requestIDHeader) != "client-chosen-id" {
+		t.Errorf("id = %q, header = %q", resp.ID, rec.Header().Get(requestIDHeader))
+	}
+}
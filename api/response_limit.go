@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	logger "you2api/logger"
+
+	"go.uber.org/zap"
+)
+
+// responseLimit 是单次补全允许生成的最大字节数与 token 数，0 表示不限制。
+type responseLimit struct {
+	Bytes  int `json:"bytes"`
+	Tokens int `json:"tokens"`
+}
+
+func (l responseLimit) unlimited() bool {
+	return l.Bytes <= 0 && l.Tokens <= 0
+}
+
+// tighter 返回两个限制中逐项更严格的一个。
+func (l responseLimit) tighter(other responseLimit) responseLimit {
+	pick := func(a, b int) int {
+		if a <= 0 || (b > 0 && b < a) {
+			return b
+		}
+		return a
+	}
+	return responseLimit{Bytes: pick(l.Bytes, other.Bytes), Tokens: pick(l.Tokens, other.Tokens)}
+}
+
+var (
+	keyResponseLimitsOnce sync.Once
+	keyResponseLimits     map[string]responseLimit
+)
+
+// getKeyResponseLimits 解析 MAX_RESPONSE_KEY_LIMITS：以 key ID（见 /admin/streams）为键的 JSON 对象。
+func getKeyResponseLimits() map[string]responseLimit {
+	keyResponseLimitsOnce.Do(func() {
+		keyResponseLimits = make(map[string]responseLimit)
+		raw := currentConfig().MaxResponseKeyLimits
+		if raw == "" {
+			return
+		}
+		if err := json.Unmarshal([]byte(raw), &keyResponseLimits); err != nil {
+			log.Printf("解析 MAX_RESPONSE_KEY_LIMITS 失败: %v", err)
+		}
+	})
+	return keyResponseLimits
+}
+
+// responseLimitFor 返回某个 key 的响应大小限制：全局限制与 key 级别限制中更严格的一项。
+func responseLimitFor(apiKey string) responseLimit {
+	conf := currentConfig()
+	limit := responseLimit{Bytes: conf.MaxResponseBytes, Tokens: conf.MaxResponseTokens}
+	if perKey, ok := getKeyResponseLimits()[keyID(apiKey)]; ok {
+		limit = limit.tighter(perKey)
+	}
+	return limit
+}
+
+// requestResponseLimit 返回请求中 max_tokens 与 max_completion_tokens 表示的限制，两者都设置时取较小值。
+func requestResponseLimit(req OpenAIRequest) (responseLimit, error) {
+	var limit responseLimit
+	for name, v := range map[string]*int{"max_tokens": req.MaxTokens, "max_completion_tokens": req.MaxCompletionTokens} {
+		if v == nil {
+			continue
+		}
+		if *v < 1 {
+			return limit, fmt.Errorf("%s must be at least 1", name)
+		}
+		limit = limit.tighter(responseLimit{Tokens: *v})
+	}
+	return limit, nil
+}
+
+type responseLimitKey struct{}
+
+// withResponseLimit 把响应大小限制放入请求上下文。
+func withResponseLimit(ctx context.Context, limit responseLimit) context.Context {
+	if limit.unlimited() {
+		return ctx
+	}
+	return context.WithValue(ctx, responseLimitKey{}, limit)
+}
+
+// responseBudget 记录一次补全已经生成的字节数与 token 数。nil 表示不限制。
+type responseBudget struct {
+	limit  responseLimit
+	model  string
+	bytes  int
+	tokens int
+}
+
+// newResponseBudget 根据上下文中的限制创建预算，未配置限制时返回 nil。
+func newResponseBudget(ctx context.Context, youModel string) *responseBudget {
+	limit, ok := ctx.Value(responseLimitKey{}).(responseLimit)
+	if !ok {
+		return nil
+	}
+	return &responseBudget{limit: limit, model: youModel}
+}
+
+// take 消耗预算，返回可以输出的部分；预算耗尽时 exhausted 为 true，此后应停止生成。
+// 字节限制与 token 限制都按 UTF-8 字符边界截断。
+func (b *responseBudget) take(text string) (allowed string, exhausted bool) {
+	if b == nil {
+		return text, false
+	}
+	if b.limit.Bytes > 0 && b.bytes+len(text) > b.limit.Bytes {
+		cut := b.limit.Bytes - b.bytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text, exhausted = text[:cut], true
+	}
+	tokens := countTokens(b.model, text)
+	if b.limit.Tokens > 0 && b.tokens+tokens > b.limit.Tokens {
+		text = truncateTokens(b.model, text, b.limit.Tokens-b.tokens)
+		tokens = countTokens(b.model, text)
+	}
+	b.bytes += len(text)
+	b.tokens += tokens
+	if b.limit.Tokens > 0 && b.tokens >= b.limit.Tokens {
+		exhausted = true
+	}
+	return text, exhausted
+}
+
+// truncateTokens 返回 text 中不超过 n 个 token 的最长前缀，在 UTF-8 字符边界上二分查找。
+func truncateTokens(model, text string, n int) string {
+	var ends []int // 每个字符结束的位置
+	for i := range text {
+		if i > 0 {
+			ends = append(ends, i)
+		}
+	}
+	ends = append(ends, len(text))
+	k := sort.Search(len(ends), func(i int) bool { return countTokens(model, text[:ends[i]]) > n })
+	if k == 0 {
+		return ""
+	}
+	return text[:ends[k-1]]
+}
+
+// logTruncated 记录一次因超出响应大小限制而被截断的补全。
+func (b *responseBudget) logTruncated(ctx context.Context) {
+	logger.L().Warn("补全超出最大响应大小，已截断",
+		zap.String("response_id", completionID(ctx)),
+		zap.String("model", b.model),
+		zap.Int("bytes", b.bytes),
+		zap.Int("tokens", b.tokens),
+		zap.String("limit", fmt.Sprintf("%d bytes / %d tokens", b.limit.Bytes, b.limit.Tokens)))
+}
@@ -0,0 +1,33 @@
+package handler
+
+import "testing"
+
+func TestResponseBudgetBytes(t *testing.T) {
+	b := &responseBudget{limit: responseLimit{Bytes: 8}}
+	if got, exhausted := b.take("你好"); got != "你好" || exhausted {
+		t.Fatalf("take = %q, %v", got, exhausted)
+	}
+	// 剩余 2 字节不足以容纳一个完整的中文字符
+	if got, exhausted := b.take("世界"); got != "" || !exhausted {
+		t.Fatalf("take = %q, %v, want empty and exhausted", got, exhausted)
+	}
+}
+
+func TestResponseLimitTighter(t *testing.T) {
+	global := responseLimit{Bytes: 1000}
+	got := global.tighter(responseLimit{Bytes: 2000, Tokens: 50})
+	if got != (responseLimit{Bytes: 1000, Tokens: 50}) {
+		t.Errorf("tighter = %+v", got)
+	}
+}
+
+func TestResponseBudgetTokens(t *testing.T) {
+	b := &responseBudget{limit: responseLimit{Tokens: 5}}
+	got, exhausted := b.take("one two three four five six seven eight")
+	if !exhausted || got == "" || countTokens("", got) > 5 {
+		t.Fatalf("take = %q (%d tokens), %v", got, countTokens("", got), exhausted)
+	}
+	if got, exhausted := b.take("nine"); got != "" || !exhausted {
+		t.Fatalf("take after exhaustion = %q, %v", got, exhausted)
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	apierror "you2api/apierror"
+	server "you2api/internal/server"
+)
+
+// routes 是各接口的路由表，首次处理请求时创建。
+var routes = sync.OnceValue(newRouter)
+
+// Handler 是处理所有传入 HTTP 请求的主处理函数。
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// 配置无效时拒绝所有请求，不以缺少 OIDC、账号池与管理密钥等设置的默认配置继续服务；具体原因只写入日志
+	if configError() != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeConfigError, "Service configuration is invalid")
+		return
+	}
+	routes().ServeHTTP(w, withLanguage(r))
+}
+
+// newRouter 注册各接口，规则按顺序匹配。
+func newRouter() *server.Router {
+	rt := &server.Router{}
+
+	// 管理接口与调试接口（pprof 与运行时信息），需要管理密钥
+	rt.HandlePrefix("/admin/", http.HandlerFunc(handleAdmin))
+	rt.HandlePrefix("/debug/", http.HandlerFunc(handleDebug))
+
+	// 模型列表、单个模型及其可用状态
+	rt.Handle("", "/v1/models", http.HandlerFunc(handleModelList))
+	rt.Handle("", "/api/v1/models", http.HandlerFunc(handleModelList))
+	rt.Match(func(r *http.Request) bool {
+		_, ok := modelIDFromPath(r.URL.Path)
+		return ok
+	}, http.HandlerFunc(handleModel))
+
+	// 错误码目录与文件上传
+	rt.Handle("", "/v1/error_codes", http.HandlerFunc(handleErrorCodes))
+	rt.Handle(http.MethodPost, "/v1/files", http.HandlerFunc(handleFileUpload))
+
+	// 聊天补全
+	chat := server.Chain(http.HandlerFunc(handleChatCompletions), server.CORS("GET, POST, OPTIONS"))
+	for _, path := range []string{"/v1/chat/completions", "/none/v1/chat/completions", "/such/chat/completions"} {
+		rt.Handle("", path, chat)
+	}
+
+	// 其他请求返回服务状态
+	rt.Fallback(http.HandlerFunc(handleServiceStatus))
+	return rt
+}
+
+// handleModel 处理 /v1/models/{id}：GET 返回模型详情，DELETE（需要管理密钥）隐藏模型。
+func handleModel(w http.ResponseWriter, r *http.Request) {
+	id, _ := modelIDFromPath(r.URL.Path)
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		handleModelDetail(w, r, id)
+	case http.MethodDelete:
+		handleModelDelete(w, r, id)
+	default:
+		clientError(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleServiceStatus 返回服务运行状态，用于健康检查。
+func handleServiceStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "You2Api Service Running...",
+		"message": "MoLoveSze...",
+	})
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	apierror "you2api/apierror"
+)
+
+// withAdminKeys 在测试期间配置管理密钥。
+func withAdminKeys(t *testing.T, admin, readOnly string) {
+	t.Helper()
+	prev := currentConfig()
+	conf := *prev
+	conf.AdminKey, conf.AdminReadOnlyKey = admin, readOnly
+	setConfig(&conf)
+	t.Cleanup(func() { setConfig(prev) })
+}
+
+func TestDebugRoutesRequireAdmin(t *testing.T) {
+	withAdminKeys(t, "admin-secret", "viewer-secret")
+
+	tests := []struct {
+		path, key string
+		want      int
+	}{
+		{"/debug/pprof/", "", http.StatusUnauthorized},
+		{"/debug/pprof/heap", "wrong", http.StatusUnauthorized},
+		{"/debug/runtime", "", http.StatusUnauthorized},
+		{"/debug/pprof/heap", "viewer-secret", http.StatusForbidden},
+		{"/debug/pprof/", "admin-secret", http.StatusOK},
+		{"/debug/pprof/cmdline", "admin-secret", http.StatusOK},
+		{"/debug/runtime", "viewer-secret", http.StatusOK},
+		{"/debug/goroutines", "admin-secret", http.StatusOK},
+		{"/debug/unknown", "admin-secret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		rec := httptest.NewRecorder()
+		Handler(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s (key %q) = %d, want %d", tt.path, tt.key, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestDebugRoutesHiddenWithoutAdminKey(t *testing.T) {
+	withAdminKeys(t, "", "")
+	rec := httptest.NewRecorder()
+	Handler(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 when no admin key is configured", rec.Code)
+	}
+}
+
+func TestExistingRoutesResolve(t *testing.T) {
+	withAdminKeys(t, "admin-secret", "")
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/v1/models", http.StatusOK},
+		{http.MethodGet, "/api/v1/models", http.StatusOK},
+		{http.MethodGet, "/v1/models/gpt-4o", http.StatusOK},
+		{http.MethodGet, "/v1/error_codes", http.StatusOK},
+		{http.MethodOptions, "/v1/chat/completions", http.StatusOK},
+		{http.MethodPost, "/v1/chat/completions", http.StatusUnauthorized},
+		{http.MethodGet, "/admin/streams", http.StatusUnauthorized},
+		{http.MethodGet, "/", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		Handler(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestHandlerRejectsInvalidConfig(t *testing.T) {
+	currentConfig()
+	prevCfg, prevErr := cfg, cfgErr
+	t.Cleanup(func() {
+		cfgOnce = sync.Once{}
+		setConfig(prevCfg)
+		cfgErr = prevErr
+	})
+	// OIDC_ISSUER 缺少 OIDC_AUDIENCE 时 config.Load 返回错误
+	t.Setenv("OIDC_ISSUER", "https://issuer.example.com")
+	t.Setenv("OIDC_AUDIENCE", "")
+	cfgOnce = sync.Once{}
+
+	for _, path := range []string{"/v1/chat/completions", "/v1/models", "/admin/config"} {
+		rec := httptest.NewRecorder()
+		Handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), apierror.CodeConfigError) {
+			t.Errorf("%s: status = %d, body %s, want 500 config_error", path, rec.Code, rec.Body)
+		}
+	}
+	if err := Start(context.Background()); err == nil {
+		t.Error("Start succeeded with an invalid configuration")
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 采样参数：请求中的 temperature、top_p 等参数按 SAMPLING_PARAMS 转发为 You.com 的查询参数
+// （逗号分隔的 "OpenAI 参数名[=You.com 参数名]"，默认 temperature,top_p，名称相同）。
+// You.com 没有公开它接受哪些参数，未转发的参数不会影响生成，但不会被静默丢弃：
+// 它们列在 X-Ignored-Parameters 响应头与 provider_metadata.ignored_parameters 中；
+// COMPAT_MODE=strict 时请求中出现未转发的采样参数直接返回 400。
+
+// samplingParamNames 是全部采样参数的名称。
+var samplingParamNames = []string{"temperature", "top_p", "presence_penalty", "frequency_penalty", "seed"}
+
+// samplingValues 返回请求中设置了的采样参数及其查询参数形式的值。
+func samplingValues(p SamplingParams) map[string]string {
+	values := make(map[string]string)
+	floats := map[string]*float64{
+		"temperature":       p.Temperature,
+		"top_p":             p.TopP,
+		"presence_penalty":  p.PresencePenalty,
+		"frequency_penalty": p.FrequencyPenalty,
+	}
+	for name, v := range floats {
+		if v != nil {
+			values[name] = strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+	}
+	if p.Seed != nil {
+		values["seed"] = strconv.FormatInt(*p.Seed, 10)
+	}
+	return values
+}
+
+// forwardedSamplingParams 解析 SAMPLING_PARAMS，返回 OpenAI 参数名到 You.com 查询参数名的映射。
+func forwardedSamplingParams() map[string]string {
+	forwarded := make(map[string]string)
+	for _, item := range strings.Split(currentConfig().SamplingParams, ",") {
+		name, upstream, found := strings.Cut(strings.TrimSpace(item), "=")
+		if name == "" {
+			continue
+		}
+		if !found || upstream == "" {
+			upstream = name
+		}
+		forwarded[name] = upstream
+	}
+	return forwarded
+}
+
+// isSamplingParam 判断请求参数是否是采样参数。
+func isSamplingParam(name string) bool {
+	for _, n := range samplingParamNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addSamplingQuery 把按 SAMPLING_PARAMS 转发的采样参数加入 You.com 查询参数。
+func addSamplingQuery(q url.Values, p SamplingParams) {
+	forwarded := forwardedSamplingParams()
+	for name, value := range samplingValues(p) {
+		if upstream, ok := forwarded[name]; ok {
+			q.Set(upstream, value)
+		}
+	}
+}
+
+// ignoredSamplingParams 返回请求中设置了但不会转发给上游的采样参数（按名称排序）。
+func ignoredSamplingParams(p SamplingParams) []string {
+	forwarded := forwardedSamplingParams()
+	var ignored []string
+	for name := range samplingValues(p) {
+		if _, ok := forwarded[name]; !ok {
+			ignored = append(ignored, name)
+		}
+	}
+	sort.Strings(ignored)
+	return ignored
+}
+
+// withIgnoredParams 把未转发的参数附加到响应元数据中。
+func withIgnoredParams(meta *ProviderMetadata, ignored []string) *ProviderMetadata {
+	if len(ignored) == 0 {
+		return meta
+	}
+	if meta == nil {
+		meta = &ProviderMetadata{}
+	}
+	meta.IgnoredParameters = ignored
+	return meta
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestSamplingParams(t *testing.T) {
+	temperature, topP, penalty := 0.2, 1.0, 0.5
+	seed := int64(42)
+	p := SamplingParams{Temperature: &temperature, TopP: &topP, PresencePenalty: &penalty, Seed: &seed}
+
+	q := url.Values{}
+	addSamplingQuery(q, p)
+	if got := q.Get("temperature"); got != "0.2" {
+		t.Errorf("temperature = %q, want 0.2", got)
+	}
+	if got := q.Get("top_p"); got != "1" {
+		t.Errorf("top_p = %q, want 1", got)
+	}
+	if q.Has("seed") || q.Has("presence_penalty") {
+		t.Errorf("unexpected forwarded params: %v", q)
+	}
+	if got, want := ignoredSamplingParams(p), []string{"presence_penalty", "seed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ignored() = %v, want %v", got, want)
+	}
+	if got := ignoredSamplingParams(SamplingParams{}); got != nil {
+		t.Errorf("ignored() of empty params = %v, want nil", got)
+	}
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// chatRequestSchemaJSON 是内嵌的 OpenAI 聊天请求 JSON Schema（仅包含本服务支持的字段）。
+//
+//go:embed schema/chat_completion_request.json
+var chatRequestSchemaJSON []byte
+
+// jsonSchema 是 JSON Schema 的一个子集，足以描述 OpenAI 请求体的结构。
+type jsonSchema struct {
+	Type       schemaType             `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Enum       []interface{}          `json:"enum"`
+	MinItems   *int                   `json:"minItems"`
+	MaxLength  *int                   `json:"maxLength"`
+}
+
+// schemaType 支持 "type": "string" 和 "type": ["string", "array"] 两种写法。
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*t = multiple
+	return nil
+}
+
+// chatRequestSchema 是解析后的聊天请求 Schema，在包初始化时加载。
+var chatRequestSchema = mustParseSchema(chatRequestSchemaJSON)
+
+func mustParseSchema(data []byte) *jsonSchema {
+	var s jsonSchema
+	if err := json.Unmarshal(data, &s); err != nil {
+		panic(fmt.Sprintf("解析内嵌 JSON Schema 失败: %v", err))
+	}
+	return &s
+}
+
+// validateChatRequest 校验原始请求体，返回字段级别的错误描述（如 "messages[2].content must be string"）。
+func validateChatRequest(body []byte) []string {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return []string{"request body must be valid JSON: " + err.Error()}
+	}
+	var errs []string
+	chatRequestSchema.validate("", doc, &errs)
+	return errs
+}
+
+// validate 递归校验 value 是否符合 Schema，错误追加到 errs 中。
+func (s *jsonSchema) validate(path string, value interface{}, errs *[]string) {
+	name := path
+	if name == "" {
+		name = "request body"
+	}
+
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		*errs = append(*errs, fmt.Sprintf("%s must be %s", name, strings.Join(s.Type, " or ")))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			options := make([]string, 0, len(s.Enum))
+			for _, allowed := range s.Enum {
+				options = append(options, fmt.Sprint(allowed))
+			}
+			*errs = append(*errs, fmt.Sprintf("%s must be one of: %s", name, strings.Join(options, ", ")))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if s.MaxLength != nil && utf8.RuneCountInString(v) > *s.MaxLength {
+			*errs = append(*errs, fmt.Sprintf("%s must be at most %d characters", name, *s.MaxLength))
+		}
+	case map[string]interface{}:
+		for _, field := range s.Required {
+			if _, ok := v[field]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s is required", joinSchemaPath(path, field)))
+			}
+		}
+		// 按字段名排序，保证错误信息顺序稳定
+		fields := make([]string, 0, len(s.Properties))
+		for field := range s.Properties {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if fieldValue, ok := v[field]; ok {
+				s.Properties[field].validate(joinSchemaPath(path, field), fieldValue, errs)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			*errs = append(*errs, fmt.Sprintf("%s must contain at least %d item(s)", name, *s.MinItems))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	}
+}
+
+// matchesType 判断 value 是否属于 Schema 允许的任一类型。
+func (s *jsonSchema) matchesType(value interface{}) bool {
+	for _, t := range s.Type {
+		switch t {
+		case "object":
+			if _, ok := value.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := value.(float64); ok && f == float64(int64(f)) {
+				return true
+			}
+		case "null":
+			if value == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func joinSchemaPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
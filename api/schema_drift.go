@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	upstream "you2api/internal/upstream"
+	logger "you2api/logger"
+	metrics "you2api/metrics"
+)
+
+// upstreamEventSchema 描述一种 You.com SSE 事件的预期结构。
+type upstreamEventSchema struct {
+	JSON     bool     // data 是否应为 JSON 对象
+	Required []string // 必须出现的字段
+	Fields   []string // 已知的全部字段，为空时不检查新增字段
+}
+
+// expectedEvents 是已知的 You.com SSE 事件类型。
+var expectedEvents = map[string]upstreamEventSchema{
+	"youChatToken":            {JSON: true, Required: []string{"youChatToken"}, Fields: []string{"youChatToken"}},
+	"done":                    {},
+	"thirdPartySearchResults": {JSON: true},
+	"youChatSerpResults":      {JSON: true},
+	"youChatUpdate":           {JSON: true},
+	"youChatIntent":           {JSON: true},
+	"youChatError":            {JSON: true},
+	"youChatThinkingToken":    {JSON: true},
+	"youChatSafety":           {JSON: true},
+}
+
+// maxDriftFindings 限制保留的漂移记录数，防止上游产生大量不同事件名时无限增长。
+const maxDriftFindings = 100
+
+// driftFinding 是一条检测到的上游结构变化。
+type driftFinding struct {
+	Event     string    `json:"event"`
+	Kind      string    `json:"kind"` // unknown_event / invalid_json / missing_field / new_field
+	Field     string    `json:"field,omitempty"`
+	Sample    string    `json:"sample"`
+	FirstSeen time.Time `json:"first_seen"`
+	Count     int64     `json:"count"`
+}
+
+// schemaDriftDetector 在后台抽样校验上游 SSE 事件，You.com 修改接口时尽早告警。
+type schemaDriftDetector struct {
+	once     sync.Once
+	events   chan [2]string
+	mu       sync.Mutex
+	findings map[string]*driftFinding
+}
+
+var schemaDrift = &schemaDriftDetector{
+	events:   make(chan [2]string, 256),
+	findings: make(map[string]*driftFinding),
+}
+
+// observe 按 SCHEMA_DRIFT_SAMPLE_RATE 抽样一个事件交给后台校验，不阻塞请求处理。
+func (d *schemaDriftDetector) observe(event, data string) {
+	rate := currentConfig().SchemaDriftSampleRate
+	if rate <= 0 || rand.Float64() >= rate {
+		return
+	}
+	d.once.Do(func() { go d.run() })
+	select {
+	case d.events <- [2]string{event, data}:
+	default: // 队列已满时丢弃样本
+	}
+}
+
+func (d *schemaDriftDetector) run() {
+	for ev := range d.events {
+		for _, f := range checkUpstreamEvent(ev[0], ev[1]) {
+			d.report(f)
+		}
+	}
+}
+
+// checkUpstreamEvent 校验单个事件，返回发现的结构变化。
+func checkUpstreamEvent(event, data string) []driftFinding {
+	schema, known := expectedEvents[event]
+	if !known {
+		return []driftFinding{{Event: event, Kind: "unknown_event"}}
+	}
+	if !schema.JSON {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return []driftFinding{{Event: event, Kind: "invalid_json"}}
+	}
+	var findings []driftFinding
+	for _, name := range schema.Required {
+		if _, ok := fields[name]; !ok {
+			findings = append(findings, driftFinding{Event: event, Kind: "missing_field", Field: name})
+		}
+	}
+	if len(schema.Fields) > 0 {
+		known := make(map[string]bool, len(schema.Fields))
+		for _, name := range schema.Fields {
+			known[name] = true
+		}
+		for name := range fields {
+			if !known[name] {
+				findings = append(findings, driftFinding{Event: event, Kind: "new_field", Field: name})
+			}
+		}
+	}
+	for i := range findings {
+		findings[i].Sample = upstream.Truncate(data, 200)
+	}
+	return findings
+}
+
+// report 记录一条漂移，同一类变化只在首次出现时输出日志并发送 webhook。
+func (d *schemaDriftDetector) report(f driftFinding) {
+	metrics.UpstreamSchemaDrift.WithLabelValues(f.Kind).Inc()
+
+	key := f.Event + "/" + f.Kind + "/" + f.Field
+	d.mu.Lock()
+	if existing, ok := d.findings[key]; ok {
+		existing.Count++
+		d.mu.Unlock()
+		return
+	}
+	if len(d.findings) >= maxDriftFindings {
+		d.mu.Unlock()
+		return
+	}
+	f.FirstSeen = time.Now()
+	f.Count = 1
+	d.findings[key] = &f
+	d.mu.Unlock()
+
+	message := fmt.Sprintf("上游事件结构变化: event=%s kind=%s field=%s", f.Event, f.Kind, f.Field)
+	logger.L().Warn("上游事件结构变化",
+		zap.String("event", f.Event), zap.String("kind", f.Kind), zap.String("field", f.Field), zap.String("sample", f.Sample))
+
+	webhook := currentConfig().SchemaDriftWebhookURL
+	if webhook == "" {
+		return
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"kind":    "upstream_schema_drift",
+		"finding": f,
+		"message": message,
+	})
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		logger.L().Warn("发送结构漂移 webhook 失败", zap.String("event", f.Event), zap.String("kind", f.Kind), zap.String("error", logger.ScrubError(err, webhook)))
+		return
+	}
+	resp.Body.Close()
+}
+
+// snapshot 返回所有检测到的结构变化，按首次出现时间排序。
+func (d *schemaDriftDetector) snapshot() []driftFinding {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	findings := make([]driftFinding, 0, len(d.findings))
+	for _, f := range d.findings {
+		findings = append(findings, *f)
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].FirstSeen.Before(findings[j].FirstSeen) })
+	return findings
+}
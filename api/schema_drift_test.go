@@ -0,0 +1,29 @@
+package handler
+
+import "testing"
+
+func TestCheckUpstreamEvent(t *testing.T) {
+	tests := []struct {
+		event, data string
+		wantKinds   []string
+	}{
+		{"youChatToken", `{"youChatToken":"hi"}`, nil},
+		{"done", "I'm Mr. Meeseeks. Look at me.", nil},
+		{"thirdPartySearchResults", `{"search":{"query":"x"}}`, nil},
+		{"youChatTokenV2", `{"token":"hi"}`, []string{"unknown_event"}},
+		{"youChatToken", `not json`, []string{"invalid_json"}},
+		{"youChatToken", `{"token":"hi"}`, []string{"missing_field", "new_field"}},
+	}
+	for _, tt := range tests {
+		findings := checkUpstreamEvent(tt.event, tt.data)
+		if len(findings) != len(tt.wantKinds) {
+			t.Errorf("checkUpstreamEvent(%q, %q) = %+v, want kinds %v", tt.event, tt.data, findings, tt.wantKinds)
+			continue
+		}
+		for i, f := range findings {
+			if f.Kind != tt.wantKinds[i] {
+				t.Errorf("checkUpstreamEvent(%q, %q)[%d].Kind = %q, want %q", tt.event, tt.data, i, f.Kind, tt.wantKinds[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateChatRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"minimal", `{"messages":[{"role":"user","content":"hi"}]}`, nil},
+		{"vision content", `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"text","text":"这是什么"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}]}`, nil},
+		{"null content with tool calls", `{"messages":[{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]}]}`, nil},
+		{"developer and function roles", `{"messages":[{"role":"developer","content":"be brief"},{"role":"function","name":"f","content":"42"},{"role":"user","content":"hi"}]}`, nil},
+		{"missing content with tool calls", `{"messages":[{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]}]}`, nil},
+		{"sampling and stop", `{"messages":[{"role":"user","content":"hi"}],"temperature":0.2,"seed":7,"stop":["\n"]}`, nil},
+		{"missing messages", `{"model":"gpt-4o"}`, []string{"messages is required"}},
+		{"empty messages", `{"messages":[]}`, []string{"messages must contain at least 1 item(s)"}},
+		{"wrong content type", `{"messages":[{"role":"user","content":1}]}`, []string{"messages[0].content must be string or array or null"}},
+		{"unknown role", `{"messages":[{"role":"bot","content":"hi"}]}`, []string{"messages[0].role must be one of: system, developer, user, assistant, tool, function"}},
+		{"missing role", `{"messages":[{"content":"hi"}]}`, []string{"messages[0].role is required"}},
+		{"non-integer seed", `{"messages":[{"role":"user","content":"hi"}],"seed":1.5}`, []string{"seed must be integer"}},
+		{"stream not boolean", `{"messages":[{"role":"user","content":"hi"}],"stream":"yes"}`, []string{"stream must be boolean"}},
+		{"instructions too long", `{"messages":[{"role":"user","content":"hi"}],"instructions":"` + strings.Repeat("长", 4001) + `"}`, []string{"instructions must be at most 4000 characters"}},
+		{"bad response_format", `{"messages":[{"role":"user","content":"hi"}],"response_format":{"type":"xml"}}`, []string{"response_format.type must be one of: text, json_object, json_schema"}},
+		{"body not an object", `[]`, []string{"request body must be object"}},
+	}
+	for _, tt := range tests {
+		if got := validateChatRequest([]byte(tt.body)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: validateChatRequest = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if errs := validateChatRequest([]byte(`{"messages":`)); len(errs) != 1 || !strings.HasPrefix(errs[0], "request body must be valid JSON") {
+		t.Errorf("invalid JSON: %q", errs)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	metrics "you2api/metrics"
+)
+
+// sessionHeader 是客户端标识会话的请求头，同一会话内上传的文件会自动附加到后续对话中。
+const sessionHeader = "X-Session-ID"
+
+// youSource 是 You.com 聊天请求 sources 参数中的一个已上传文件。
+type youSource struct {
+	SourceType   string `json:"source_type"`
+	Filename     string `json:"filename"`
+	UserFilename string `json:"user_filename"`
+	SizeBytes    int64  `json:"size_bytes"`
+}
+
+// sessionFile 是会话中的一个已上传文件。You.com 的文件引用只在上传它的账号下有效，
+// 因此同时记录上传账号，使用账号池时只附加到由同一账号发出的请求中。
+type sessionFile struct {
+	youSource
+	Account string `json:"account,omitempty"` // 上传账号 DS token 的 key ID
+}
+
+// sessionStore 记录每个会话已上传文件对应的 You.com source。
+// 会话按客户端隔离：X-Session-ID 由客户端指定，其他客户端使用相同的会话 ID 也读不到这些文件。
+// 会话数超过 SESSION_STORE_SIZE 时淘汰最久未使用的会话，单个会话最多保留 SESSION_MAX_FILES 个文件。
+type sessionStore struct {
+	lru *lruCache[string, []sessionFile]
+}
+
+var sessions = &sessionStore{
+	lru: newLRU[string, []sessionFile]("sessions", func() int { return currentConfig().SessionStoreSize }).
+		withSizer(func(id string, files []sessionFile) int {
+			size := len(id)
+			for _, f := range files {
+				size += f.size() + len(f.Account)
+			}
+			return size
+		}),
+}
+
+// size 估算一个 source 占用的内存。
+func (s youSource) size() int {
+	return len(s.SourceType) + len(s.Filename) + len(s.UserFilename) + 32
+}
+
+// sessionOwner 返回会话所属的客户端：JWT 认证的用户，否则是 API key 的 key ID。
+func sessionOwner(ctx context.Context, apiKey string) string {
+	if p := principalFrom(ctx); p != nil {
+		return p.key
+	}
+	return keyID(apiKey)
+}
+
+// sessionKey 是会话在 lru 中的键，由所属客户端与会话 ID 组成。
+func sessionKey(owner, sessionID string) string {
+	return owner + "\x00" + sessionID
+}
+
+// attach 将 dsToken 对应账号上传的文件添加到会话，重复的文件只保留一份，超过单会话上限时丢弃最早的文件。
+func (s *sessionStore) attach(owner, sessionID, dsToken string, src youSource) {
+	limit := currentConfig().SessionMaxFiles
+	added := sessionFile{youSource: src, Account: keyID(dsToken)}
+	s.lru.update(sessionKey(owner, sessionID), func(existing []sessionFile, _ bool) []sessionFile {
+		for _, file := range existing {
+			if file.Filename == added.Filename && file.Account == added.Account {
+				return existing
+			}
+		}
+		files := append(append([]sessionFile(nil), existing...), added)
+		if limit > 0 && len(files) > limit {
+			metrics.StoreEvictions.WithLabelValues("session_files").Add(float64(len(files) - limit))
+			files = files[len(files)-limit:]
+		}
+		return files
+	})
+}
+
+// get 返回 owner 的会话中的全部文件。
+func (s *sessionStore) get(owner, sessionID string) []sessionFile {
+	files, _ := s.lru.get(sessionKey(owner, sessionID))
+	return append([]sessionFile(nil), files...)
+}
+
+// sourcesFor 返回由 dsToken 对应账号上传的文件，其他账号上传的文件引用对该账号无效，不会附加。
+func sourcesFor(files []sessionFile, dsToken string) []youSource {
+	account := keyID(dsToken)
+	var sources []youSource
+	for _, f := range files {
+		if f.Account == account {
+			sources = append(sources, f.youSource)
+		}
+	}
+	return sources
+}
+
+// addSources 把文件作为 sources 参数附加到 You.com 请求上。
+func addSources(youReq *http.Request, sources []youSource) {
+	if len(sources) == 0 {
+		return
+	}
+	data, err := json.Marshal(sources)
+	if err != nil {
+		return
+	}
+	q := youReq.URL.Query()
+	q.Set("sources", string(data))
+	youReq.URL.RawQuery = q.Encode()
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	signing "you2api/signing"
+)
+
+var (
+	signerOnce sync.Once
+	signer     *signing.Signer
+)
+
+// getSigner 返回响应签名器；未配置 RESPONSE_SIGNING_KEY 时返回 nil。
+func getSigner() *signing.Signer {
+	signerOnce.Do(func() {
+		conf := currentConfig()
+		if conf.SigningKey == "" {
+			return
+		}
+		s, err := signing.NewSigner(conf.SigningAlg, conf.SigningKey)
+		if err != nil {
+			log.Printf("初始化响应签名失败: %v", err)
+			return
+		}
+		if pub := s.PublicKey(); pub != "" {
+			log.Printf("响应签名已启用，Ed25519 公钥: %s", pub)
+		}
+		signer = s
+	})
+	return signer
+}
+
+// signedResponseWriter 为补全响应计算签名。
+// 非流式响应先缓存完整响应体，签名后通过响应头发送；
+// 流式响应边写边计算摘要，结束时通过 HTTP trailer 发送签名。
+type signedResponseWriter struct {
+	http.ResponseWriter
+	signer *signing.Signer
+	stream bool
+
+	status int
+	buf    bytes.Buffer
+	digest hash.Hash
+}
+
+func newSignedResponseWriter(w http.ResponseWriter, s *signing.Signer, stream bool) *signedResponseWriter {
+	if stream {
+		w.Header().Set("Trailer", signing.Header)
+	}
+	return &signedResponseWriter{ResponseWriter: w, signer: s, stream: stream, status: http.StatusOK, digest: sha256.New()}
+}
+
+func (sw *signedResponseWriter) WriteHeader(status int) {
+	if sw.stream {
+		sw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	sw.status = status
+}
+
+func (sw *signedResponseWriter) Write(p []byte) (int, error) {
+	sw.digest.Write(p)
+	if sw.stream {
+		return sw.ResponseWriter.Write(p)
+	}
+	return sw.buf.Write(p)
+}
+
+func (sw *signedResponseWriter) Flush() {
+	if !sw.stream {
+		return // 非流式响应在 finish 时一次性写出
+	}
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish 计算签名并写出签名头（或 trailer），非流式响应在此时写出缓存的响应体。
+func (sw *signedResponseWriter) finish() {
+	signature := sw.signer.Sign(time.Now().Unix(), sw.digest.Sum(nil))
+	sw.Header().Set(signing.Header, signature)
+	if sw.stream {
+		return
+	}
+	sw.ResponseWriter.WriteHeader(sw.status)
+	sw.ResponseWriter.Write(sw.buf.Bytes())
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// errEmptyCompletion 表示上游正常结束但没有返回任何内容。
+var errEmptyCompletion = errors.New("upstream returned an empty completion")
+
+// errIncompleteStream 表示上游连接在 done 事件之前正常关闭，回复可能不完整。
+var errIncompleteStream = errors.New("upstream stream ended before the done event")
+
+// streamSplicer 在流式重试时拼接多次上游尝试的输出。
+//
+// 它记录已经发送给客户端的字符数；新的尝试开始后，重新生成的内容中
+// 落在已发送偏移量之前的部分会被丢弃，只发送偏移量之后的增量，
+// 从而保证客户端看到的是一段连续、不重复的内容，且 ID 与 index 保持不变。
+// 偏移量按 rune 而不是字节计算：两次尝试的内容可能不同，按字节对齐会从多字节字符中间截断。
+type streamSplicer struct {
+	emitted      strings.Builder // 已发送给客户端的全部内容
+	emittedRunes int             // emitted 中的字符数
+	attemptRunes int             // 当前尝试已从上游接收的字符数
+	attempts     int
+}
+
+// beginAttempt 开始一次新的上游尝试。
+func (s *streamSplicer) beginAttempt() {
+	s.attempts++
+	s.attemptRunes = 0
+}
+
+// resume 标记当前尝试是从断点续传的（上游接受了 Last-Event-ID）：
+// 上游只会发送断点之后的新内容，因此无需再丢弃已发送的前缀。
+func (s *streamSplicer) resume() {
+	s.attemptRunes = s.emittedRunes
+}
+
+// isResumedEvent 判断重连后收到的第一个事件 ID 是否紧接在断点之后。
+// 只有数字 ID 能可靠地比较先后；无法判断时视为重新生成，由 accept 去除重复前缀。
+func isResumedEvent(lastEventID, firstEventID string) bool {
+	last, err := strconv.ParseInt(lastEventID, 10, 64)
+	if err != nil {
+		return false
+	}
+	first, err := strconv.ParseInt(firstEventID, 10, 64)
+	return err == nil && first > last
+}
+
+// accept 处理当前尝试中的一个 token，返回需要发送给客户端的增量内容（可能为空）。
+func (s *streamSplicer) accept(token string) string {
+	runes := utf8.RuneCountInString(token)
+	skip := s.emittedRunes - s.attemptRunes
+	s.attemptRunes += runes
+	if skip >= runes {
+		return "" // 整个 token 都已发送过
+	}
+
+	delta := token
+	for ; skip > 0; skip-- {
+		_, size := utf8.DecodeRuneInString(delta)
+		delta = delta[size:]
+	}
+	s.emitted.WriteString(delta)
+	s.emittedRunes += utf8.RuneCountInString(delta)
+	return delta
+}
+
+// content 返回已发送给客户端的完整内容。
+func (s *streamSplicer) content() string {
+	return s.emitted.String()
+}
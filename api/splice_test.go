@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStreamSplicerRegeneratedAttempt(t *testing.T) {
+	s := &streamSplicer{}
+	s.beginAttempt()
+	s.accept("Hello ")
+	s.accept("wor")
+
+	// 上游重新生成：已发送的前缀被丢弃
+	s.beginAttempt()
+	var got string
+	for _, token := range []string{"Hello ", "world", "!"} {
+		got += s.accept(token)
+	}
+	if got != "ld!" || s.content() != "Hello world!" {
+		t.Errorf("delta = %q, content = %q", got, s.content())
+	}
+}
+
+func TestStreamSplicerResumedAttempt(t *testing.T) {
+	s := &streamSplicer{}
+	s.beginAttempt()
+	s.accept("Hello ")
+
+	// 上游从断点续传：新内容全部发送
+	s.beginAttempt()
+	s.resume()
+	if got := s.accept("world"); got != "world" {
+		t.Errorf("delta = %q, want %q", got, "world")
+	}
+	if s.content() != "Hello world" {
+		t.Errorf("content = %q", s.content())
+	}
+}
+
+func TestIsResumedEvent(t *testing.T) {
+	tests := []struct {
+		last, first string
+		want        bool
+	}{
+		{"5", "6", true},
+		{"5", "0", false},
+		{"", "0", false},
+		{"abc", "abd", false},
+	}
+	for _, tt := range tests {
+		if got := isResumedEvent(tt.last, tt.first); got != tt.want {
+			t.Errorf("isResumedEvent(%q, %q) = %v, want %v", tt.last, tt.first, got, tt.want)
+		}
+	}
+}
+
+func TestStreamSplicerMultiByte(t *testing.T) {
+	tests := []struct {
+		name      string
+		first     []string
+		retry     []string
+		wantDelta string
+		want      string
+	}{
+		// 重新生成的内容在字节上与已发送内容错位：按字符对齐而不是截断多字节字符
+		{"ascii then cjk", []string{"ab"}, []string{"你好", "吗"}, "吗", "ab吗"},
+		{"cjk then ascii", []string{"你"}, []string{"abc"}, "bc", "你bc"},
+		{"same cjk text", []string{"你好", "世"}, []string{"你好世界"}, "界", "你好世界"},
+		{"emoji", []string{"😀"}, []string{"😀😃", "!"}, "😃!", "😀😃!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &streamSplicer{}
+			s.beginAttempt()
+			for _, token := range tt.first {
+				s.accept(token)
+			}
+			s.beginAttempt()
+			var got string
+			for _, token := range tt.retry {
+				got += s.accept(token)
+			}
+			if got != tt.wantDelta || s.content() != tt.want {
+				t.Errorf("delta = %q, content = %q; want %q, %q", got, s.content(), tt.wantDelta, tt.want)
+			}
+			if !utf8.ValidString(s.content()) {
+				t.Errorf("content is not valid UTF-8: %q", s.content())
+			}
+		})
+	}
+}
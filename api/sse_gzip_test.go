@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"*", true},
+		{"*;q=0.1, gzip;q=0", false},
+		{"br, deflate", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipEventWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gw := &gzipEventWriter{ResponseWriter: rec}
+	io.WriteString(gw, "data: 1\n\n")
+	gw.Flush()
+
+	// 刷新后已发送的部分即可解压出完整的事件
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, _ := zr.Read(buf)
+	if got := string(buf[:n]); got != "data: 1\n\n" {
+		t.Errorf("first flush = %q", got)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q", got)
+	}
+
+	errRec := httptest.NewRecorder()
+	gw = &gzipEventWriter{ResponseWriter: errRec}
+	gw.WriteHeader(503)
+	io.WriteString(gw, `{"error":{}}`)
+	gw.finish()
+	if errRec.Header().Get("Content-Encoding") != "" || errRec.Body.String() != `{"error":{}}` {
+		t.Errorf("error response was compressed: %q", errRec.Body.String())
+	}
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	logger "you2api/logger"
+	metrics "you2api/metrics"
+	tracing "you2api/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// 补全的分阶段耗时。各阶段首尾相接，耗时之和约等于整个请求的耗时：
+//
+//	parse        从收到请求到读取、校验请求体并解析出上游模型
+//	acquire      从账号池取得账号（开启 FAIR_QUEUE_ENABLED 时包括排队等待）
+//	build        构建上游请求（包括上传内联图片）
+//	connect      上游连接（DNS、TCP 与 TLS，复用连接时接近 0）
+//	ttfb         从连接就绪到收到上游的响应头
+//	first_token  从收到响应头到收到第一个 token
+//	complete     从第一个 token 到补全结束
+//
+// 结果记录在 completion_stage_seconds 直方图与调试日志中，进行中的补全可以在 /admin/streams 查看已完成的阶段；
+// 开启 DEBUG_HEADERS 后，请求带有 X-U2API-Debug: timing 时以 Server-Timing trailer 返回。
+// 每次补全还会创建一个 chat.completion span，各阶段是它的子 span；请求带有 W3C traceparent 时，
+// chat.completion 挂在调用方的 span 下，配置 OTEL_EXPORTER_OTLP_ENDPOINT 后导出，见 tracing 包。
+// 上游重试时各阶段只记录第一次，之后的重试计入后续阶段。
+const (
+	stageParse      = "parse"
+	stageAcquire    = "acquire"
+	stageBuild      = "build"
+	stageConnect    = "connect"
+	stageTTFB       = "ttfb"
+	stageFirstToken = "first_token"
+	stageComplete   = "complete"
+)
+
+// stageOrder 是阶段的先后顺序。
+var stageOrder = []string{stageParse, stageAcquire, stageBuild, stageConnect, stageTTFB, stageFirstToken, stageComplete}
+
+// debugHeader 是请求调试信息的请求头，值为逗号分隔的调试项（目前只有 timing）。
+const debugHeader = "X-U2API-Debug"
+
+// serverTimingHeader 是返回分阶段耗时的 trailer。
+const serverTimingHeader = "Server-Timing"
+
+// stageTimer 记录一次补全各阶段的耗时，并发安全。为 nil 时不做任何处理。
+type stageTimer struct {
+	mu        sync.Mutex
+	last      time.Time
+	durations map[string]time.Duration
+
+	ctx     context.Context // 包含 chat.completion span，各阶段的 span 以它为父 span
+	span    trace.Span
+	endOnce sync.Once
+}
+
+// newStageTimer 从 start 开始计时，并在 ctx（通常带有从 traceparent 取出的调用方 span）下创建补全的 span。
+func newStageTimer(ctx context.Context, start time.Time) *stageTimer {
+	ctx, span := tracing.Tracer().Start(ctx, "chat.completion",
+		trace.WithTimestamp(start), trace.WithSpanKind(trace.SpanKindServer))
+	return &stageTimer{last: start, durations: make(map[string]time.Duration), ctx: ctx, span: span}
+}
+
+// mark 结束一个阶段，耗时从上一个阶段结束时算起。同一阶段只记录第一次。
+func (t *stageTimer) mark(stage string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, done := t.durations[stage]; done {
+		return
+	}
+	now := time.Now()
+	t.durations[stage] = now.Sub(t.last)
+	// 阶段首尾相接，span 的起止时间就是上一个阶段与本阶段结束的时间
+	_, span := tracing.Tracer().Start(t.ctx, stage, trace.WithTimestamp(t.last))
+	span.End(trace.WithTimestamp(now))
+	t.last = now
+}
+
+// end 结束补全的 span，err 不为 nil 时把 span 标记为失败。只有第一次调用生效，
+// 处理函数提前返回时由 defer 的调用结束 span。
+func (t *stageTimer) end(requestID, model string, err error) {
+	if t == nil {
+		return
+	}
+	t.endOnce.Do(func() {
+		if requestID != "" {
+			t.span.SetAttributes(attribute.String("request_id", requestID), attribute.String("model", model))
+		}
+		if err != nil {
+			t.span.RecordError(err)
+			t.span.SetStatus(codes.Error, err.Error())
+		}
+		t.span.End()
+	})
+}
+
+// snapshot 按阶段顺序返回已完成阶段的耗时（毫秒）。
+func (t *stageTimer) snapshot() map[string]float64 {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make(map[string]float64, len(t.durations))
+	for stage, d := range t.durations {
+		result[stage] = float64(d.Microseconds()) / 1000
+	}
+	return result
+}
+
+// serverTiming 返回 Server-Timing 头的值，如 "acquire;dur=0.1, parse;dur=1.5"。
+func (t *stageTimer) serverTiming() string {
+	durations := t.snapshot()
+	var parts []string
+	for _, stage := range stageOrder {
+		if ms, ok := durations[stage]; ok {
+			parts = append(parts, fmt.Sprintf("%s;dur=%.1f", stage, ms))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// observe 把各阶段耗时写入直方图与调试日志。
+func (t *stageTimer) observe(requestID, model string) {
+	durations := t.snapshot()
+	fields := []zap.Field{zap.String("request_id", requestID), zap.String("model", model)}
+	for _, stage := range stageOrder {
+		if ms, ok := durations[stage]; ok {
+			metrics.CompletionStageSeconds.WithLabelValues(stage).Observe(ms / 1000)
+			fields = append(fields, zap.Float64(stage+"_ms", ms))
+		}
+	}
+	logger.L().Debug("补全阶段耗时", fields...)
+}
+
+// withStageTimer 通过 httptrace 记录上游连接与响应头到达的时间。
+func withStageTimer(ctx context.Context, t *stageTimer) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn:              func(httptrace.GotConnInfo) { t.mark(stageConnect) },
+		GotFirstResponseByte: func() { t.mark(stageTTFB) },
+	})
+}
+
+// wantsTiming 判断是否应以 Server-Timing 返回分阶段耗时。
+func wantsTiming(r *http.Request) bool {
+	if !currentConfig().DebugHeaders {
+		return false
+	}
+	for _, item := range strings.Split(r.Header.Get(debugHeader), ",") {
+		if strings.TrimSpace(item) == "timing" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStageTimer(t *testing.T) {
+	timer := newStageTimer(context.Background(), time.Now().Add(-10*time.Millisecond))
+	timer.mark(stageParse)
+	timer.mark(stageAcquire)
+	timer.mark(stageParse) // 重复的阶段只记录第一次
+	timer.mark(stageComplete)
+
+	got := timer.serverTiming()
+	if !strings.HasPrefix(got, "parse;dur=") || !strings.Contains(got, ", acquire;dur=") {
+		t.Fatalf("serverTiming() = %q", got)
+	}
+	if parts := strings.Split(got, ", "); len(parts) != 3 || !strings.HasPrefix(parts[2], "complete;") {
+		t.Errorf("serverTiming() = %q, want parse, acquire, complete in order", got)
+	}
+	if ms := timer.snapshot()[stageParse]; ms < 10 {
+		t.Errorf("parse = %vms, want at least 10ms", ms)
+	}
+
+	var nilTimer *stageTimer
+	nilTimer.mark(stageParse)
+	if nilTimer.serverTiming() != "" {
+		t.Error("nil timer reported timings")
+	}
+}
+
+// withSpanRecorder 在测试期间把全局 TracerProvider 替换为在内存中记录 span 的实现。
+func withSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return recorder
+}
+
+func TestStageSpans(t *testing.T) {
+	withMockUpstream(t, "echo")
+	recorder := withSpanRecorder(t)
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	rec := postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`,
+		"traceparent", "00-"+traceID+"-"+parentID+"-01")
+	decodeCompletion(t, rec)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	root, ok := spans["chat.completion"]
+	if !ok {
+		t.Fatalf("no chat.completion span among %d spans", len(recorder.Ended()))
+	}
+	if root.Parent().TraceID().String() != traceID || root.Parent().SpanID().String() != parentID {
+		t.Errorf("chat.completion parent = %s/%s, want the traceparent span", root.Parent().TraceID(), root.Parent().SpanID())
+	}
+	for _, stage := range []string{stageParse, stageAcquire, stageBuild, stageComplete} {
+		span, ok := spans[stage]
+		if !ok {
+			t.Errorf("missing %s span", stage)
+			continue
+		}
+		if span.Parent().SpanID() != root.SpanContext().SpanID() || span.SpanContext().TraceID().String() != traceID {
+			t.Errorf("%s span is not a child of chat.completion", stage)
+		}
+		if span.EndTime().Before(span.StartTime()) {
+			t.Errorf("%s span ends before it starts", stage)
+		}
+	}
+	if spans[stageAcquire].StartTime() != spans[stageParse].EndTime() {
+		t.Error("stage spans are not contiguous")
+	}
+}
+
+func TestStageSpanEndedOnEarlyReturn(t *testing.T) {
+	recorder := withSpanRecorder(t)
+	if rec := postChat(t, `{"model":"gpt-4o","messages":"hi"}`); rec.Code != 400 {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Name() != "chat.completion" {
+		t.Errorf("ended spans = %d, want only chat.completion", len(ended))
+	}
+	if started := recorder.Started(); len(started) != len(ended) {
+		t.Errorf("%d spans started but %d ended", len(started), len(ended))
+	}
+}
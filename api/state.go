@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	apierror "you2api/apierror"
+	pool "you2api/pool"
+	sealed "you2api/sealed"
+)
+
+// 运行状态导出/导入，用于把一个部署迁移到新主机。
+// 导出内容包括 key 别名、全局模型别名、隐藏模型、DS token 账号池以及会话文件与对话记录，
+// 以 gzip 压缩的 JSON 经 STATE_ENCRYPTION_KEY 加密后作为归档下载。
+// 配额（MAX_RESPONSE_KEY_LIMITS）、虚拟模型等来自环境变量的配置不属于运行状态，需要随部署配置一起迁移。
+
+// stateVersion 是归档格式的版本号。
+const stateVersion = 1
+
+// maxStateArchiveBytes 是导入归档的大小上限。
+const maxStateArchiveBytes = 64 << 20
+
+// maxStateJSONBytes 是归档解压后的大小上限，防止高压缩比的归档在校验之前耗尽内存。
+const maxStateJSONBytes = 256 << 20
+
+// errStateTooLarge 表示归档解压后超过 maxStateJSONBytes。
+var errStateTooLarge = errors.New("state archive too large when decompressed")
+
+// stateArchive 是加密前的归档内容。导入时只替换归档中存在的部分，会话与对话记录合并到现有缓存中。
+type stateArchive struct {
+	Version       int                            `json:"version"`
+	CreatedAt     time.Time                      `json:"created_at"`
+	KeyAliases    map[string]map[string]string   `json:"key_aliases"`
+	ModelAliases  map[string]string              `json:"model_aliases,omitempty"`
+	HiddenModels  []string                       `json:"hidden_models"`
+	TokenPool     json.RawMessage                `json:"token_pool,omitempty"` // TOKEN_POOL_FILE 的原始内容
+	Sessions      map[string][]sessionFile       `json:"sessions,omitempty"`
+	Conversations map[string]*StoredConversation `json:"conversations,omitempty"`
+}
+
+// stateImportResult 是导入成功后返回的各部分条目数。
+type stateImportResult struct {
+	KeyAliases    int  `json:"key_aliases"`
+	ModelAliases  int  `json:"model_aliases"`
+	HiddenModels  int  `json:"hidden_models"`
+	TokenPool     bool `json:"token_pool"`
+	Sessions      int  `json:"sessions"`
+	Conversations int  `json:"conversations"`
+}
+
+// stateKey 解析 STATE_ENCRYPTION_KEY，未配置时返回 nil。
+func stateKey() ([]byte, error) {
+	raw := currentConfig().StateEncryptionKey
+	if raw == "" {
+		return nil, nil
+	}
+	return sealed.ParseKey(raw)
+}
+
+// collectState 收集当前的运行状态。
+func collectState() stateArchive {
+	return stateArchive{
+		Version:       stateVersion,
+		CreatedAt:     time.Now().UTC(),
+		KeyAliases:    getKeyAliases().snapshot(),
+		ModelAliases:  getModelAliases().snapshot(),
+		HiddenModels:  append([]string{}, getHiddenModels().list()...),
+		TokenPool:     tokenPoolSnapshot(),
+		Sessions:      sessions.lru.entries(),
+		Conversations: conversations.snapshot(),
+	}
+}
+
+// applyState 用归档内容替换对应部分的运行状态，要么全部生效，要么不修改任何部分：
+// 先校验所有部分，再依次替换别名与隐藏模型，账号池最后替换；某一部分持久化失败时把已替换的部分恢复为导入前的内容。
+func applyState(state stateArchive) (stateImportResult, error) {
+	var result stateImportResult
+	if err := validateState(state); err != nil {
+		return result, err
+	}
+
+	keyAliases, modelAliases, hidden := getKeyAliases(), getModelAliases(), getHiddenModels()
+	var rollback []func() error
+	undo := func() {
+		for i := len(rollback) - 1; i >= 0; i-- {
+			if err := rollback[i](); err != nil {
+				log.Printf("恢复导入前的运行状态失败: %v", err)
+			}
+		}
+	}
+	if state.KeyAliases != nil {
+		prev := keyAliases.snapshot()
+		if err := keyAliases.replaceAll(state.KeyAliases); err != nil {
+			undo()
+			return result, fmt.Errorf("key_aliases: %w", err)
+		}
+		rollback = append(rollback, func() error { return keyAliases.replaceAll(prev) })
+		result.KeyAliases = len(state.KeyAliases)
+	}
+	if state.ModelAliases != nil {
+		prev := modelAliases.snapshot()
+		if err := modelAliases.replaceAll(state.ModelAliases); err != nil {
+			undo()
+			return result, fmt.Errorf("model_aliases: %w", err)
+		}
+		rollback = append(rollback, func() error { return modelAliases.replaceAll(prev) })
+		result.ModelAliases = len(state.ModelAliases)
+	}
+	if state.HiddenModels != nil {
+		prev := hidden.list()
+		if err := hidden.replaceAll(state.HiddenModels); err != nil {
+			undo()
+			return result, fmt.Errorf("hidden_models: %w", err)
+		}
+		rollback = append(rollback, func() error { return hidden.replaceAll(prev) })
+		result.HiddenModels = len(state.HiddenModels)
+	}
+	if len(state.TokenPool) > 0 {
+		if err := replaceTokenPool(state.TokenPool); err != nil {
+			undo()
+			return result, fmt.Errorf("token_pool: %w", err)
+		}
+		result.TokenPool = true
+	}
+
+	for id, files := range state.Sessions {
+		sessions.lru.put(id, files)
+	}
+	result.Sessions = len(state.Sessions)
+	for id, conv := range state.Conversations {
+		conversations.put(context.Background(), id, conv)
+	}
+	result.Conversations = len(state.Conversations)
+	return result, nil
+}
+
+// validateState 在修改任何运行状态之前检查归档的每个部分。
+func validateState(state stateArchive) error {
+	if state.Version != stateVersion {
+		return fmt.Errorf("unsupported state version %d", state.Version)
+	}
+	if len(state.TokenPool) > 0 {
+		if _, err := pool.Parse(state.TokenPool); err != nil {
+			return fmt.Errorf("token_pool: %w", err)
+		}
+	}
+	for id, aliases := range state.KeyAliases {
+		if strings.TrimSpace(id) == "" {
+			return errors.New("key_aliases: empty key ID")
+		}
+		if err := validateAliases(aliases); err != nil {
+			return fmt.Errorf("key_aliases: %s: %w", id, err)
+		}
+	}
+	if err := validateAliases(state.ModelAliases); err != nil {
+		return fmt.Errorf("model_aliases: %w", err)
+	}
+	for _, model := range state.HiddenModels {
+		if strings.TrimSpace(model) == "" {
+			return errors.New("hidden_models: empty model name")
+		}
+	}
+	return nil
+}
+
+// validateAliases 检查别名与目标模型都不为空，与 /admin/models/aliases 的要求相同。
+func validateAliases(aliases map[string]string) error {
+	for alias, target := range aliases {
+		if strings.TrimSpace(alias) == "" || strings.TrimSpace(target) == "" {
+			return fmt.Errorf("alias %q -> %q must have a name and a target model", alias, target)
+		}
+	}
+	return nil
+}
+
+// sealState 把归档压缩后加密。
+func sealState(key []byte, state stateArchive) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(state); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return sealed.Seal(key, buf.Bytes())
+}
+
+// openState 解密并解压归档，解压后超过 limit 字节时返回 errStateTooLarge。
+func openState(key, data []byte, limit int64) (stateArchive, error) {
+	var state stateArchive
+	plain, err := sealed.Open(key, data)
+	if err != nil {
+		return state, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return state, err
+	}
+	defer gz.Close()
+	raw, err := io.ReadAll(io.LimitReader(gz, limit+1))
+	if err != nil {
+		return state, err
+	}
+	if int64(len(raw)) > limit {
+		return state, errStateTooLarge
+	}
+	err = json.Unmarshal(raw, &state)
+	return state, err
+}
+
+// handleStateExport 处理 GET /admin/state/export，返回加密的状态归档。
+func handleStateExport(w http.ResponseWriter, r *http.Request) {
+	key, err := stateKey()
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInvalidStateKey, "Invalid STATE_ENCRYPTION_KEY: "+err.Error())
+		return
+	}
+	if key == nil {
+		apierror.Write(w, http.StatusNotImplemented, apierror.CodeNotConfigured, "State export requires STATE_ENCRYPTION_KEY")
+		return
+	}
+	data, err := sealState(key, collectState())
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternalError, "Failed to export state: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="u2api-state-%s.bin"`, time.Now().UTC().Format("20060102T150405Z")))
+	w.Write(data)
+}
+
+// handleStateImport 处理 POST /admin/state/import，请求体为 export 接口导出的归档。
+func handleStateImport(w http.ResponseWriter, r *http.Request) {
+	key, err := stateKey()
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInvalidStateKey, "Invalid STATE_ENCRYPTION_KEY: "+err.Error())
+		return
+	}
+	if key == nil {
+		apierror.Write(w, http.StatusNotImplemented, apierror.CodeNotConfigured, "State import requires STATE_ENCRYPTION_KEY")
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxStateArchiveBytes+1))
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+		return
+	}
+	if len(data) > maxStateArchiveBytes {
+		apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, "State archive too large")
+		return
+	}
+	state, err := openState(key, data, maxStateJSONBytes)
+	if errors.Is(err, errStateTooLarge) {
+		apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, "State archive too large")
+		return
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidStateArchive, "Invalid state archive: "+err.Error())
+		return
+	}
+	result, err := applyState(state)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeImportFailed, "Failed to import state: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
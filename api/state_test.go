@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withModelAliases 在测试期间把全局模型别名设为 aliases。
+func withModelAliases(t *testing.T, aliases map[string]string) {
+	t.Helper()
+	store := getModelAliases()
+	prev := store.snapshot()
+	if err := store.replaceAll(aliases); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.replaceAll(prev) })
+}
+
+func TestApplyStateValidatesBeforeChanging(t *testing.T) {
+	withTestPool(t, "original-token")
+	withModelAliases(t, map[string]string{"keep": "gpt-4o"})
+	before := getTokenPool()
+
+	// 账号池有效，但别名无效：整个导入失败，账号池也不会被替换
+	_, err := applyState(stateArchive{
+		Version:      stateVersion,
+		TokenPool:    []byte(`[{"name": "imported", "token": "imported-token"}]`),
+		ModelAliases: map[string]string{"": "gpt-4o"},
+	})
+	if err == nil {
+		t.Fatal("import with an invalid model alias succeeded")
+	}
+	if getTokenPool() != before {
+		t.Error("token pool replaced by a failed import")
+	}
+	if got := getModelAliases().snapshot(); !reflect.DeepEqual(got, map[string]string{"keep": "gpt-4o"}) {
+		t.Errorf("model aliases = %v after a failed import", got)
+	}
+}
+
+func TestApplyStateRollsBackOnPersistFailure(t *testing.T) {
+	withTestPool(t, "original-token")
+	withModelAliases(t, map[string]string{"keep": "gpt-4o"})
+	before := getTokenPool()
+
+	// 隐藏模型写入失败（路径是目录）时，已替换的模型别名恢复为导入前的内容
+	hidden := getHiddenModels()
+	prevPath := hidden.path
+	hidden.path = t.TempDir()
+	t.Cleanup(func() { hidden.path = prevPath })
+
+	_, err := applyState(stateArchive{
+		Version:      stateVersion,
+		TokenPool:    []byte(`[{"name": "imported", "token": "imported-token"}]`),
+		ModelAliases: map[string]string{"new": "gpt-4o-mini"},
+		HiddenModels: []string{"gpt-3.5-turbo"},
+	})
+	if err == nil {
+		t.Fatal("import succeeded although hidden models could not be persisted")
+	}
+	if getTokenPool() != before {
+		t.Error("token pool replaced by a failed import")
+	}
+	if got := getModelAliases().snapshot(); !reflect.DeepEqual(got, map[string]string{"keep": "gpt-4o"}) {
+		t.Errorf("model aliases = %v, want the aliases from before the import", got)
+	}
+}
+
+func TestOpenStateLimitsDecompressedSize(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	// 高度可压缩的归档：压缩后很小，解压后超过上限
+	data, err := sealState(key, stateArchive{Version: stateVersion, HiddenModels: []string{strings.Repeat("a", 1<<20)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 16<<10 {
+		t.Fatalf("sealed archive is %d bytes, want a small compressed archive", len(data))
+	}
+	if _, err := openState(key, data, 64<<10); !errors.Is(err, errStateTooLarge) {
+		t.Errorf("openState error = %v, want errStateTooLarge", err)
+	}
+	state, err := openState(key, data, maxStateJSONBytes)
+	if err != nil || len(state.HiddenModels) != 1 {
+		t.Errorf("openState within the limit = %d hidden models, %v", len(state.HiddenModels), err)
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// 停止序列：请求的 stop 参数（字符串或最多 4 个字符串的数组）。You.com 不支持停止序列，
+// 由代理在回复中出现任一停止序列时截断回复（不包含停止序列本身），并关闭上游连接，finish_reason 为 stop。
+// 流式响应暂存可能是停止序列开头的末尾内容，确认不是停止序列后再发送。
+
+// maxStopSequences 是 stop 参数允许的最大数量，与 OpenAI 一致。
+const maxStopSequences = 4
+
+// stopSequences 是请求的停止序列，为空时不做任何处理。
+type stopSequences []string
+
+// parseStop 解析请求中的 stop 参数。
+func parseStop(raw json.RawMessage) (stopSequences, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var seqs []string
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		seqs = []string{single}
+	} else if err := json.Unmarshal(raw, &seqs); err != nil {
+		return nil, errors.New("stop must be a string or an array of strings")
+	}
+	if len(seqs) > maxStopSequences {
+		return nil, errors.New("stop must contain at most 4 sequences")
+	}
+	for _, seq := range seqs {
+		if seq == "" {
+			return nil, errors.New("stop sequences must not be empty")
+		}
+	}
+	return seqs, nil
+}
+
+// index 返回 text[from:] 中最早出现的停止序列在 text 中的位置，没有时返回 -1。
+func (s stopSequences) index(text string, from int) int {
+	found := -1
+	for _, seq := range s {
+		if i := strings.Index(text[from:], seq); i >= 0 && (found < 0 || from+i < found) {
+			found = from + i
+		}
+	}
+	return found
+}
+
+// maxLen 返回最长的停止序列的长度。
+func (s stopSequences) maxLen() int {
+	n := 0
+	for _, seq := range s {
+		n = max(n, len(seq))
+	}
+	return n
+}
+
+type stopSequencesKey struct{}
+
+// withStopSequences 把停止序列放入请求上下文。
+func withStopSequences(ctx context.Context, s stopSequences) context.Context {
+	if len(s) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, stopSequencesKey{}, s)
+}
+
+// stopSequencesFrom 返回上下文中的停止序列。
+func stopSequencesFrom(ctx context.Context) stopSequences {
+	s, _ := ctx.Value(stopSequencesKey{}).(stopSequences)
+	return s
+}
+
+// stopScanner 在逐段到达的文本中查找停止序列，from 之前的部分已确认不包含停止序列的开头。
+type stopScanner struct {
+	seqs stopSequences
+	from int
+}
+
+// scan 检查累积的文本，返回截断位置；没有出现停止序列时返回 -1。
+func (s *stopScanner) scan(text string) int {
+	if len(s.seqs) == 0 {
+		return -1
+	}
+	if i := s.seqs.index(text, min(s.from, len(text))); i >= 0 {
+		return i
+	}
+	s.from = max(0, len(text)-s.seqs.maxLen()+1)
+	return -1
+}
+
+// stopStream 在流式响应中截断停止序列。为 nil 时不做任何处理。
+type stopStream struct {
+	seqs    stopSequences
+	pending string
+	stopped bool
+}
+
+func newStopStream(seqs stopSequences) *stopStream {
+	if len(seqs) == 0 {
+		return nil
+	}
+	return &stopStream{seqs: seqs}
+}
+
+// push 处理一段回复内容，返回可以立即发送的部分；出现停止序列后 stopped 为 true，之后的内容全部丢弃。
+func (s *stopStream) push(chunk string) (string, bool) {
+	if s == nil {
+		return chunk, false
+	}
+	if s.stopped {
+		return "", true
+	}
+	text := s.pending + chunk
+	if i := s.seqs.index(text, 0); i >= 0 {
+		s.pending, s.stopped = "", true
+		return text[:i], true
+	}
+	keep := 0
+	for _, seq := range s.seqs {
+		keep = max(keep, partialTagSuffix(text, seq))
+	}
+	s.pending = text[len(text)-keep:]
+	return text[:len(text)-keep], false
+}
+
+// flush 在回复结束时返回暂存的内容。
+func (s *stopStream) flush() string {
+	if s == nil || s.stopped {
+		return ""
+	}
+	text := s.pending
+	s.pending = ""
+	return text
+}
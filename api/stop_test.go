@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStop(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    stopSequences
+		wantErr bool
+	}{
+		{``, nil, false},
+		{`null`, nil, false},
+		{`"\n\n"`, stopSequences{"\n\n"}, false},
+		{`["END","###"]`, stopSequences{"END", "###"}, false},
+		{`["a","b","c","d","e"]`, nil, true},
+		{`[""]`, nil, true},
+		{`42`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseStop([]byte(tt.raw))
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStop(%s) = %q, %v", tt.raw, got, err)
+		}
+	}
+}
+
+func TestStopStream(t *testing.T) {
+	s := newStopStream(stopSequences{"END", "###"})
+	var out string
+	for _, chunk := range []string{"hello E", "N", "no ##", "D wor", "ld#", "##tail"} {
+		text, stopped := s.push(chunk)
+		out += text
+		if stopped {
+			break
+		}
+	}
+	if want := "hello ENno ##D world"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+	if text, stopped := s.push("more"); text != "" || !stopped {
+		t.Errorf("push after stop = %q, %v", text, stopped)
+	}
+
+	s = newStopStream(stopSequences{"END"})
+	text, _ := s.push("almost EN")
+	if text+s.flush() != "almost EN" {
+		t.Errorf("flush lost held content")
+	}
+}
+
+func TestStopScanner(t *testing.T) {
+	s := &stopScanner{seqs: stopSequences{"STOP"}}
+	text := ""
+	for _, chunk := range []string{"abc ST", "O", "P def"} {
+		text += chunk
+		if i := s.scan(text); i >= 0 {
+			if text[:i] != "abc " {
+				t.Errorf("cut at %d: %q", i, text[:i])
+			}
+			return
+		}
+	}
+	t.Error("stop sequence not found")
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	translate "you2api/internal/translate"
+	logger "you2api/logger"
+)
+
+// 结构化输出：请求体中 response_format 为 json_schema 或 json_object 时，在提问后附加格式说明，
+// 并按 Schema 校验模型返回的 JSON（json_object 只要求是 JSON 对象）。流式响应逐块检查已输出的前缀是否仍是合法的 JSON，
+// 结束时在元数据中报告校验结果；非流式响应校验失败时自动发起修复请求（最多 STRUCTURED_REPAIR_ATTEMPTS 次），
+// 把不符合要求的回复与错误原因交给模型改正，修复结果同样在元数据中标注。
+// 只有输出合法时 finish_reason 才是 stop，否则为非标准的 invalid_json（超出最大响应大小时仍为 length）。
+// Schema 校验使用 schema.go 中的子集（type、required、properties、items、enum、minItems、maxLength），
+// 其他关键字会被忽略。
+
+// finishInvalidJSON 是结构化输出不合法时的 finish_reason。
+const finishInvalidJSON = "invalid_json"
+
+// structuredFinishReason 在结构化输出不合法时把 stop 替换为 invalid_json，其他停止原因保持不变。
+func structuredFinishReason(r *StructuredOutputReport, reason string) string {
+	if r != nil && !r.Valid && reason == "stop" {
+		return finishInvalidJSON
+	}
+	return reason
+}
+
+// structuredOutput 是单个请求的结构化输出设置，由 handleChatCompletions 创建后放在 requestState 中。为 nil 时不做任何处理。
+type structuredOutput struct {
+	name     string
+	raw      json.RawMessage
+	schema   *jsonSchema // json_object 模式下为 nil
+	messages []Message   // 发送给上游的消息，修复请求在其后追加上一次回复与修复说明
+
+	// 流式响应的增量校验状态
+	streamed   strings.Builder
+	divergence string // 第一次发现输出不再是合法 JSON 前缀时的错误
+}
+
+// newStructuredOutput 解析请求中的 response_format，未要求结构化输出时返回 nil。
+func newStructuredOutput(format *ResponseFormat) (*structuredOutput, error) {
+	if format == nil || format.Type == "" || format.Type == "text" {
+		return nil, nil
+	}
+	if format.Type == "json_object" {
+		return &structuredOutput{name: "json_object"}, nil
+	}
+	if format.Type != "json_schema" {
+		return nil, fmt.Errorf("unsupported response_format type %q", format.Type)
+	}
+	if format.JSONSchema == nil || len(format.JSONSchema.Schema) == 0 {
+		return nil, errors.New("response_format.json_schema.schema is required")
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal(format.JSONSchema.Schema, &schema); err != nil {
+		return nil, fmt.Errorf("invalid response_format.json_schema.schema: %w", err)
+	}
+	name := format.JSONSchema.Name
+	if name == "" {
+		name = "response"
+	}
+	return &structuredOutput{name: name, raw: format.JSONSchema.Schema, schema: &schema}, nil
+}
+
+// fork 返回相同设置、但流式校验状态独立的副本，供 n>1 的流式响应中每个 choice 单独校验。
+func (so *structuredOutput) fork() *structuredOutput {
+	if so == nil {
+		return nil
+	}
+	return &structuredOutput{name: so.name, raw: so.raw, schema: so.schema, messages: so.messages}
+}
+
+// instructions 返回附加在提问之后的格式说明。
+func (so *structuredOutput) instructions() string {
+	if so.schema == nil {
+		return "Respond only with a single valid JSON object. Do not include any explanation, Markdown or code fences."
+	}
+	return "Respond only with a single JSON value that conforms to the following JSON Schema (" + so.name + "). " +
+		"Do not include any explanation, Markdown or code fences.\n" + string(so.raw)
+}
+
+// apply 在最后一条消息后附加格式说明，返回发送给上游的消息。
+func (so *structuredOutput) apply(messages []Message) []Message {
+	if so == nil || len(messages) == 0 {
+		return messages
+	}
+	out := append([]Message{}, messages...)
+	last := &out[len(out)-1]
+	last.Content = strings.TrimRight(last.Content, "\n") + "\n\n" + so.instructions()
+	so.messages = out
+	return out
+}
+
+// validate 按 Schema 校验完整回复，返回所有错误。
+func (so *structuredOutput) validate(content string) []string {
+	text, _ := extractJSONText(content)
+	var doc interface{}
+	if err := json.Unmarshal([]byte(text), &doc); err != nil {
+		return []string{"response is not valid JSON: " + err.Error()}
+	}
+	if so.schema == nil {
+		if _, ok := doc.(map[string]interface{}); !ok {
+			return []string{"response is not a JSON object"}
+		}
+		return nil
+	}
+	var errs []string
+	so.schema.validate("", doc, &errs)
+	return errs
+}
+
+// observe 记录一段流式输出，并检查已输出的内容是否仍是合法 JSON 的前缀。
+func (so *structuredOutput) observe(delta string) {
+	if so == nil || delta == "" {
+		return
+	}
+	so.streamed.WriteString(delta)
+	if so.divergence != "" {
+		return
+	}
+	text, complete := extractJSONText(so.streamed.String())
+	if err := checkJSONPrefix(text, complete); err != nil {
+		so.divergence = fmt.Sprintf("response diverged from JSON within the first %d bytes: %s", so.streamed.Len(), err)
+	}
+}
+
+// streamReport 返回流式响应结束时的校验结果。
+func (so *structuredOutput) streamReport() *StructuredOutputReport {
+	if so == nil {
+		return nil
+	}
+	report := &StructuredOutputReport{Schema: so.name}
+	if so.divergence != "" {
+		report.Errors = []string{so.divergence}
+		return report
+	}
+	report.Errors = so.validate(so.streamed.String())
+	report.Valid = len(report.Errors) == 0
+	return report
+}
+
+// ensure 校验非流式回复，不符合要求时发起修复请求，每次修复都基于上一次的回复与错误。
+// 全部修复失败时返回原回复与首次的校验错误。
+func (so *structuredOutput) ensure(youReq *http.Request, content string) (string, *StructuredOutputReport) {
+	report := &StructuredOutputReport{Schema: so.name}
+	errs := so.validate(content)
+	if len(errs) == 0 {
+		report.Valid = true
+		return content, report
+	}
+	report.Errors = errs
+
+	last, lastErrs := content, errs
+	for attempt := 1; attempt <= currentConfig().StructuredRepairAttempts; attempt++ {
+		report.RepairAttempts = attempt
+		repaired, err := fetchCompletion(so.repairRequest(youReq, last, lastErrs))
+		if err != nil {
+			logger.L().Warn("结构化输出修复请求失败", zap.String("error", logger.ScrubError(err)))
+			break
+		}
+		fixed := repairEncoding(repaired.Content)
+		fixedErrs := so.validate(fixed)
+		if len(fixedErrs) == 0 {
+			return fixed, &StructuredOutputReport{Schema: so.name, Valid: true, Repaired: true, RepairAttempts: attempt}
+		}
+		logger.L().Info("结构化输出修复后仍不符合要求", zap.Int("attempt", attempt), zap.Strings("errors", fixedErrs))
+		last, lastErrs = fixed, fixedErrs
+	}
+	return content, report
+}
+
+// withStructuredOutput 把结构化输出的校验结果附加到响应元数据中。
+func withStructuredOutput(meta *ProviderMetadata, report *StructuredOutputReport) *ProviderMetadata {
+	if report == nil {
+		return meta
+	}
+	if meta == nil {
+		meta = &ProviderMetadata{}
+	}
+	meta.StructuredOutput = report
+	return meta
+}
+
+// repairRequest 基于原上游请求构建修复请求：保留 Cookie、附件与自定义指令，只替换聊天内容。
+func (so *structuredOutput) repairRequest(youReq *http.Request, content string, errs []string) *http.Request {
+	messages := append(append([]Message{}, so.messages...),
+		Message{Role: "assistant", Content: content},
+		Message{Role: "user", Content: "Your previous reply does not conform to the required JSON format:\n- " +
+			strings.Join(errs, "\n- ") + "\n\n" + so.instructions()},
+	)
+	req := youReq.Clone(youReq.Context())
+	q := req.URL.Query()
+	_, chat := splitSystemMessages(messages) // system 消息已在原请求的自定义指令中
+	translate.SetChatQuery(q, chat)
+	req.URL.RawQuery = q.Encode()
+	return req
+}
+
+// extractJSONText 去掉回复开头与结尾的 Markdown 代码围栏（模型经常忽略"不要使用代码围栏"的要求）。
+// complete 为 false 表示开始围栏所在的行尚未输出完整。
+func extractJSONText(content string) (text string, complete bool) {
+	text = strings.TrimSpace(content)
+	if strings.HasPrefix("```", text) && text != "" {
+		return "", false // 开始围栏的反引号尚未输出完整
+	}
+	if !strings.HasPrefix(text, "```") {
+		return text, true
+	}
+	newline := strings.IndexByte(text, '\n')
+	if newline < 0 {
+		return "", false
+	}
+	text = text[newline+1:]
+	if end := strings.Index(text, "```"); end >= 0 {
+		text = text[:end]
+	}
+	// 流式输出中结束围栏可能只输出了一部分
+	return strings.TrimRight(strings.TrimSpace(text), "`"), true
+}
+
+// checkJSONPrefix 检查 text 是否可能是某个合法 JSON 值的前缀，只有出现语法错误时才返回错误。
+func checkJSONPrefix(text string, complete bool) error {
+	if !complete || text == "" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(text)))
+	for {
+		_, err := dec.Token()
+		if err == nil {
+			continue
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStructuredOutputStreaming(t *testing.T) {
+	format := &ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchemaFormat{
+		Name:   "point",
+		Schema: []byte(`{"type":"object","required":["x","y"],"properties":{"x":{"type":"integer"},"y":{"type":"integer"}}}`),
+	}}
+	tests := []struct {
+		chunks []string
+		valid  bool
+		errors string
+	}{
+		{[]string{`{"x": 1,`, ` "y": 2}`}, true, ""},
+		{[]string{"`", "``json\n{\"x\"", ": 1, \"y\": 2}\n`", "``"}, true, ""},
+		{[]string{`{"x": 1}`}, false, "y is required"},
+		{[]string{`{"x": "1", "y": 2}`}, false, "x must be integer"},
+		{[]string{`Sure! `, `{"x": 1, "y": 2}`}, false, "diverged from JSON"},
+	}
+	for _, tt := range tests {
+		so, err := newStructuredOutput(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, chunk := range tt.chunks {
+			so.observe(chunk)
+		}
+		report := so.streamReport()
+		if report.Valid != tt.valid || !strings.Contains(strings.Join(report.Errors, "; "), tt.errors) {
+			t.Errorf("chunks %q: report = %+v, want valid=%v errors containing %q", tt.chunks, report, tt.valid, tt.errors)
+		}
+	}
+}
+
+func TestJSONObjectMode(t *testing.T) {
+	so, err := newStructuredOutput(&ResponseFormat{Type: "json_object"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		content string
+		finish  string
+	}{
+		{`{"ok": true}`, "stop"},
+		{"```json\n{\"ok\": true}\n```", "stop"},
+		{`[1, 2]`, finishInvalidJSON},
+		{`Sure, here it is`, finishInvalidJSON},
+	}
+	for _, tt := range tests {
+		errs := so.validate(tt.content)
+		report := &StructuredOutputReport{Valid: len(errs) == 0, Errors: errs}
+		if got := structuredFinishReason(report, "stop"); got != tt.finish {
+			t.Errorf("%q: finish_reason = %q, want %q (errors %v)", tt.content, got, tt.finish, errs)
+		}
+	}
+	if got := structuredFinishReason(&StructuredOutputReport{}, "length"); got != "length" {
+		t.Errorf("truncated invalid output: finish_reason = %q, want length", got)
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	apierror "you2api/apierror"
+	upstream "you2api/internal/upstream"
+	logger "you2api/logger"
+)
+
+// DS token 导入：运维人员从浏览器开发者工具中复制整段 Cookie 请求头或 HAR 片段，
+// 由服务端提取其中的 DS cookie、向 You.com 验证后加入账号池，免去手工查找 token 的步骤。
+// 本服务请求上游时只需要 DS（见 upstream.Cookies），其他 cookie（如 DSR）会被忽略。
+
+// maxTokenImportBytes 是导入请求体的大小上限，HAR 文件可能包含较大的响应内容。
+const maxTokenImportBytes = 16 << 20
+
+// tokenImportRequest 是 POST /admin/tokens/import 的请求体，cookie 与 har 二选一。
+type tokenImportRequest struct {
+	Name     string          `json:"name"`
+	Cookie   string          `json:"cookie"` // 整段 Cookie 请求头，可以带 "Cookie:" 前缀
+	HAR      json.RawMessage `json:"har"`    // HAR 文件或其中的 entry、request 片段
+	Windows  []string        `json:"windows"`
+	Timezone string          `json:"timezone"`
+	// SkipValidation 跳过向 You.com 验证 token，适合导入时无法访问上游的环境
+	SkipValidation bool `json:"skip_validation"`
+}
+
+// tokenImportResult 是导入成功后返回的账号信息，不包含 token。
+type tokenImportResult struct {
+	Name      string `json:"name"`
+	KeyID     string `json:"key_id"`
+	Validated bool   `json:"validated"`
+	Accounts  int    `json:"accounts"` // 导入后账号池中的账号数
+}
+
+// errDuplicateToken 表示账号池中已有相同的 DS token。
+var errDuplicateToken = errors.New("DS token is already in the pool")
+
+// extractDSToken 从 Cookie 请求头或 HAR 片段中提取 DS cookie 的值。
+func extractDSToken(req tokenImportRequest) (string, error) {
+	switch {
+	case req.Cookie != "" && len(req.HAR) > 0:
+		return "", errors.New("cookie and har cannot both be set")
+	case req.Cookie != "":
+		if token := dsFromCookieHeader(req.Cookie); token != "" {
+			return token, nil
+		}
+		return "", errors.New("no DS cookie found in cookie header")
+	case len(req.HAR) > 0:
+		var doc interface{}
+		if err := json.Unmarshal(req.HAR, &doc); err != nil {
+			return "", fmt.Errorf("invalid har: %w", err)
+		}
+		if token := dsFromHAR(doc); token != "" {
+			return token, nil
+		}
+		return "", errors.New("no DS cookie found in har")
+	default:
+		return "", errors.New("cookie or har is required")
+	}
+}
+
+// dsFromCookieHeader 解析 "name=value; name2=value2" 格式的 Cookie 请求头。
+func dsFromCookieHeader(header string) string {
+	header = strings.TrimSpace(header)
+	if name, value, ok := strings.Cut(header, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "cookie") {
+		header = value
+	}
+	for _, part := range strings.Split(header, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && name == "DS" && value != "" {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// dsFromHAR 在 HAR 结构中递归查找 DS cookie：既查找 cookies 数组中的 {"name": "DS"}，
+// 也查找 headers 数组中的 Cookie 请求头。HAR 中通常有多个请求，返回找到的第一个。
+func dsFromHAR(v interface{}) string {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		name, _ := val["name"].(string)
+		value, _ := val["value"].(string)
+		switch {
+		case name == "DS" && value != "":
+			return value
+		case strings.EqualFold(name, "cookie") && value != "":
+			if token := dsFromCookieHeader(value); token != "" {
+				return token
+			}
+		}
+		for _, child := range val {
+			if token := dsFromHAR(child); token != "" {
+				return token
+			}
+		}
+	case []interface{}:
+		for _, child := range val {
+			if token := dsFromHAR(child); token != "" {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// validateDSToken 用 token 请求一次 You.com 的 get_nonce 接口，只有已登录的会话才能拿到 nonce。
+func validateDSToken(ctx context.Context, dsToken string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", upstream.NonceURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Cookie", upstream.CookieHeader(dsToken))
+	resp, err := upstreamClient(0).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("You.com rejected the DS token (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// appendPoolAccount 把账号追加到账号池文件内容中并替换当前账号池，返回导入后的账号数。
+func appendPoolAccount(req tokenImportRequest, dsToken string) (int, error) {
+	var count int
+	err := updateTokenPool(func(data []byte) ([]byte, error) {
+		var entries []map[string]interface{}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return nil, err
+			}
+		}
+		for _, entry := range entries {
+			if entry["token"] == dsToken {
+				return nil, errDuplicateToken
+			}
+		}
+		account := map[string]interface{}{"name": req.Name, "token": dsToken}
+		if len(req.Windows) > 0 {
+			account["windows"] = req.Windows
+		}
+		if req.Timezone != "" {
+			account["timezone"] = req.Timezone
+		}
+		entries = append(entries, account)
+		count = len(entries)
+		return json.MarshalIndent(entries, "", "  ")
+	})
+	return count, err
+}
+
+// handleTokenImport 处理 POST /admin/tokens/import，从 Cookie 请求头或 HAR 片段中导入 DS token。
+func handleTokenImport(w http.ResponseWriter, r *http.Request) {
+	var req tokenImportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTokenImportBytes)).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: "+err.Error())
+		return
+	}
+	dsToken, err := extractDSToken(req)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Name == "" {
+		req.Name = "imported-" + keyID(dsToken)
+	}
+
+	if !req.SkipValidation {
+		if err := validateDSToken(r.Context(), dsToken); err != nil {
+			apierror.Write(w, http.StatusUnprocessableEntity, apierror.CodeInvalidDSToken, "DS token validation failed: "+logger.ScrubError(err, dsToken))
+			return
+		}
+	}
+
+	accounts, err := appendPoolAccount(req, dsToken)
+	switch {
+	case errors.Is(err, errDuplicateToken):
+		apierror.Write(w, http.StatusConflict, apierror.CodeDuplicateToken, err.Error())
+		return
+	case err != nil:
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeImportFailed, "Failed to import token: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, tokenImportResult{
+		Name:      req.Name,
+		KeyID:     keyID(dsToken),
+		Validated: !req.SkipValidation,
+		Accounts:  accounts,
+	})
+}
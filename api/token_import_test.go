@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	upstream "you2api/internal/upstream"
+)
+
+func TestExtractDSToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     tokenImportRequest
+		want    string
+		wantErr bool
+	}{
+		{"cookie header", tokenImportRequest{Cookie: "uuid=1; DS=abc.def; DSR=xyz"}, "abc.def", false},
+		{"cookie header with prefix", tokenImportRequest{Cookie: `Cookie: a=b; DS="quoted"`}, "quoted", false},
+		{"cookie without DS", tokenImportRequest{Cookie: "DSR=xyz; a=b"}, "", true},
+		{"har cookies array", tokenImportRequest{HAR: json.RawMessage(`{"log":{"entries":[{"request":{"cookies":[{"name":"a","value":"b"},{"name":"DS","value":"from-cookies"}]}}]}}`)}, "from-cookies", false},
+		{"har cookie header", tokenImportRequest{HAR: json.RawMessage(`{"request":{"headers":[{"name":"cookie","value":"x=1; DS=from-header"}]}}`)}, "from-header", false},
+		{"har without DS", tokenImportRequest{HAR: json.RawMessage(`{"request":{"headers":[]}}`)}, "", true},
+		{"invalid har", tokenImportRequest{HAR: json.RawMessage(`"not an object"`)}, "", true},
+		{"both set", tokenImportRequest{Cookie: "DS=a", HAR: json.RawMessage(`{}`)}, "", true},
+		{"neither set", tokenImportRequest{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractDSToken(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("extractDSToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// withEmptyTokenPool 在测试期间使用空账号池，并把导入的账号池写入临时文件。
+func withEmptyTokenPool(t *testing.T) string {
+	t.Helper()
+	getTokenPool()
+	tokenPoolMu.Lock()
+	prevPool, prevData := tokenPool, tokenPoolData
+	tokenPool, tokenPoolData = nil, nil
+	tokenPoolMu.Unlock()
+
+	path := filepath.Join(t.TempDir(), "pool.json")
+	prev := currentConfig()
+	conf := *prev
+	conf.TokenPoolFile = path
+	setConfig(&conf)
+	t.Cleanup(func() {
+		setConfig(prev)
+		tokenPoolMu.Lock()
+		tokenPool, tokenPoolData = prevPool, prevData
+		tokenPoolMu.Unlock()
+	})
+	return path
+}
+
+func postTokenImport(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/tokens/import", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rec := httptest.NewRecorder()
+	Handler(rec, req)
+	return rec
+}
+
+func TestHandleTokenImport(t *testing.T) {
+	withAdminKeys(t, "admin-secret", "")
+	withMockUpstream(t, "echo")
+	path := withEmptyTokenPool(t)
+
+	rec := postTokenImport(t, `{"name":"alice","cookie":"Cookie: uuid=1; DS=imported-token; DSR=x"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body %s", rec.Code, rec.Body)
+	}
+	var result tokenImportResult
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if result.Name != "alice" || result.KeyID != keyID("imported-token") || !result.Validated || result.Accounts != 1 {
+		t.Errorf("result = %+v", result)
+	}
+	if strings.Contains(rec.Body.String(), "imported-token") {
+		t.Error("response must not contain the DS token")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `"imported-token"`) {
+		t.Errorf("pool file = %s (%v), want the imported account", data, err)
+	}
+
+	if rec := postTokenImport(t, `{"cookie":"DS=imported-token"}`); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate import status = %d, want 409", rec.Code)
+	}
+	if rec := postTokenImport(t, `{"cookie":"a=b"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("import without DS status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleTokenImportValidation(t *testing.T) {
+	withAdminKeys(t, "admin-secret", "")
+	withEmptyTokenPool(t)
+	prev := upstreamClient
+	upstreamClient = func(timeout time.Duration) upstream.Doer {
+		return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			resp := mockResponse(req, "text/plain", "")
+			resp.StatusCode = http.StatusUnauthorized
+			return resp, nil
+		})}
+	}
+	t.Cleanup(func() { upstreamClient = prev })
+
+	rec := postTokenImport(t, `{"cookie":"DS=expired-token"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422; body %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "expired-token") {
+		t.Error("error must not contain the DS token")
+	}
+
+	// 跳过验证时不请求上游
+	if rec := postTokenImport(t, `{"cookie":"DS=expired-token","skip_validation":true}`); rec.Code != http.StatusCreated {
+		t.Errorf("skip_validation status = %d, want 201; body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHandleTokenImportConcurrent(t *testing.T) {
+	withAdminKeys(t, "admin-secret", "")
+	withEmptyTokenPool(t)
+
+	// 同时导入的账号都要保留，不会互相覆盖
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := postTokenImport(t, fmt.Sprintf(`{"cookie":"DS=token-%d","skip_validation":true}`, i))
+			if rec.Code != http.StatusCreated {
+				t.Errorf("status = %d, want 201; body %s", rec.Code, rec.Body)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(getTokenPool().Accounts()); got != n {
+		t.Errorf("pool has %d accounts, want %d", got, n)
+	}
+}
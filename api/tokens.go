@@ -0,0 +1,27 @@
+package handler
+
+import tokenizer "you2api/tokenizer"
+
+// countTokens 使用模型家族对应的分词器计算文本的 token 数。
+func countTokens(model, text string) int {
+	return tokenizer.Default().Count(model, text)
+}
+
+// countMessagesTokens 计算消息列表的 token 数，每条消息额外计入少量格式开销。
+func countMessagesTokens(model string, messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += countTokens(model, msg.Content) + 4
+	}
+	return total
+}
+
+// usage 估算一次补全的用量，n>1 时各 choice 的回复都计入 completion_tokens。
+func (rs *requestState) usage(completions ...string) *Usage {
+	u := &Usage{PromptTokens: rs.PromptTokens}
+	for _, content := range completions {
+		u.CompletionTokens += countTokens(rs.UpstreamModel, content)
+	}
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	return u
+}
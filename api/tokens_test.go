@@ -0,0 +1,24 @@
+package handler
+
+import "testing"
+
+func TestNonStreamingUsage(t *testing.T) {
+	withMockUpstream(t, "echo")
+	resp := decodeCompletion(t, postChat(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"count these tokens please"}]}`))
+	if resp.Usage == nil {
+		t.Fatal("usage missing from non-streaming response")
+	}
+
+	youModel := mapModelName("gpt-4o")
+	wantPrompt := countMessagesTokens(youModel, []Message{{Role: "user", Content: "count these tokens please"}})
+	wantCompletion := countTokens(youModel, resp.Choices[0].Message.Content)
+	if resp.Usage.PromptTokens != wantPrompt || resp.Usage.CompletionTokens != wantCompletion {
+		t.Errorf("usage = %+v, want prompt %d completion %d", *resp.Usage, wantPrompt, wantCompletion)
+	}
+	if resp.Usage.TotalTokens != wantPrompt+wantCompletion {
+		t.Errorf("total_tokens = %d, want %d", resp.Usage.TotalTokens, wantPrompt+wantCompletion)
+	}
+	if wantCompletion == 0 {
+		t.Error("completion tokens should be counted for a non-empty reply")
+	}
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	translate "you2api/internal/translate"
+)
+
+// 工具调用模拟：You.com 没有原生的函数调用，请求中带有 tools 时，把工具定义与调用格式作为系统提示词发送
+// （与 system 消息一样作为自定义指令，见 instructions.go），要求模型以 [tool_calls] 标记加 JSON 代码块的形式
+// 发起调用，这与 tool_history.go 写入聊天历史的格式相同，模型在多轮调用中能看到一致的示例。
+// 回复中识别到对已声明工具的调用时，转换为 choice 中的 tool_calls，finish_reason 为 tool_calls。
+// 流式响应在出现 [tool_calls] 标记（或回复以 JSON 开头）后暂存剩余内容，结束时再决定作为调用还是普通内容发送。
+// tool_choice 支持 auto、none、required 与指定函数；parallel_tool_calls 为 false 时只保留第一个调用。
+
+// anyTool 表示 tool_choice 为 required：必须调用工具，但不限定哪一个。
+const anyTool = "*"
+
+// toolEmulation 是单个请求的工具调用设置，由 handleChatCompletions 创建后放在 requestState 中。为 nil 时不做任何处理。
+type toolEmulation struct {
+	tools    []Tool
+	required string // 必须调用的函数名，anyTool 表示任意一个，为空时由模型决定
+	single   bool   // parallel_tool_calls 为 false
+}
+
+// newToolEmulation 解析请求中的 tools 与 tool_choice，没有工具或 tool_choice 为 none 时返回 nil。
+func newToolEmulation(req OpenAIRequest) (*toolEmulation, error) {
+	if len(req.Tools) == 0 {
+		return nil, nil
+	}
+	te := &toolEmulation{tools: req.Tools, single: req.ParallelToolCalls != nil && !*req.ParallelToolCalls}
+	for _, tool := range req.Tools {
+		if tool.Type != "function" {
+			return nil, fmt.Errorf("unsupported tool type %q", tool.Type)
+		}
+		if tool.Function.Name == "" {
+			return nil, errors.New("tools[].function.name is required")
+		}
+	}
+	if len(req.ToolChoice) == 0 {
+		return te, nil
+	}
+	var mode string
+	if err := json.Unmarshal(req.ToolChoice, &mode); err == nil {
+		switch mode {
+		case "auto":
+		case "none":
+			return nil, nil
+		case "required":
+			te.required = anyTool
+		default:
+			return nil, fmt.Errorf("unsupported tool_choice %q", mode)
+		}
+		return te, nil
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(req.ToolChoice, &named); err != nil || named.Function.Name == "" {
+		return nil, errors.New("tool_choice must be auto, none, required or a function")
+	}
+	if !te.declared(named.Function.Name) {
+		return nil, fmt.Errorf("tool_choice refers to unknown function %q", named.Function.Name)
+	}
+	te.required = named.Function.Name
+	return te, nil
+}
+
+func (te *toolEmulation) declared(name string) bool {
+	for _, tool := range te.tools {
+		if tool.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// instructions 返回描述工具与调用格式的系统提示词。
+func (te *toolEmulation) instructions() string {
+	var b strings.Builder
+	b.WriteString("You can call the following tools (functions), described as JSON:\n")
+	functions := make([]ToolFunction, len(te.tools))
+	for i, tool := range te.tools {
+		functions[i] = tool.Function
+	}
+	b.WriteString(translate.JSONBlock(functions))
+	b.WriteString("\n\nTo call tools, reply with the line " + translate.ToolCallsMarker + " followed by a JSON code block containing an array of calls, " +
+		`each of the form {"name": "<function name>", "arguments": {...}}, and nothing after it. ` +
+		"The arguments must conform to the function's parameters schema. " +
+		"Tool results will be sent back to you in " + translate.ToolResultMarker + " blocks. ")
+	switch {
+	case te.required == anyTool:
+		b.WriteString("You must call at least one tool in your reply.")
+	case te.required != "":
+		b.WriteString("You must call the function " + te.required + " in your reply.")
+	default:
+		b.WriteString("If no tool is needed, answer normally without the " + translate.ToolCallsMarker + " line.")
+	}
+	if te.single {
+		b.WriteString(" Call at most one tool per reply.")
+	}
+	return b.String()
+}
+
+// apply 在消息开头附加工具说明（作为 system 消息），返回发送给上游的消息。
+func (te *toolEmulation) apply(messages []Message) []Message {
+	if te == nil {
+		return messages
+	}
+	return append([]Message{{Role: "system", Content: te.instructions()}}, messages...)
+}
+
+// split 识别回复中的工具调用，返回调用之前的文本与调用列表。没有识别到对已声明工具的调用时 calls 为空。
+func (te *toolEmulation) split(content string) (text string, calls []ToolCall) {
+	if te == nil {
+		return content, nil
+	}
+	text, block := content, content
+	if i := strings.Index(content, translate.ToolCallsMarker); i >= 0 {
+		text, block = content[:i], content[i+len(translate.ToolCallsMarker):]
+	} else {
+		text = "" // 省略了标记时只接受整个回复都是调用的情况
+	}
+	calls = te.parseCalls(block)
+	if len(calls) == 0 {
+		return content, nil
+	}
+	return strings.TrimSpace(text), calls
+}
+
+// parseCalls 解析 JSON（可以在代码块中）形式的调用：单个调用或调用数组，
+// 每个调用是 {"name", "arguments"} 或 OpenAI 格式的 {"function": {"name", "arguments"}}。
+func (te *toolEmulation) parseCalls(block string) []ToolCall {
+	text, _ := extractJSONText(block)
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "[") {
+		text = "[" + text + "]"
+	}
+	type rawCall struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+		Function  *struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"function"`
+	}
+	var raw []rawCall
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil
+	}
+	var calls []ToolCall
+	for _, rc := range raw {
+		if rc.Function != nil {
+			rc.Name, rc.Arguments = rc.Function.Name, rc.Function.Arguments
+		}
+		if !te.declared(rc.Name) {
+			return nil // 调用了未声明的工具，按普通回复处理
+		}
+		calls = append(calls, ToolCall{
+			ID:       newToolCallID(),
+			Type:     "function",
+			Function: ToolCallFunction{Name: rc.Name, Arguments: toolArguments(rc.Arguments)},
+		})
+	}
+	if te.single && len(calls) > 1 {
+		calls = calls[:1]
+	}
+	return calls
+}
+
+// toolArguments 把调用参数转换为 OpenAI 格式的 JSON 字符串；模型输出的参数已是字符串时原样使用。
+func toolArguments(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "{}"
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func newToolCallID() string {
+	return "call_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:24]
+}
+
+// toolCallDeltas 把工具调用转换为流式响应中的格式。
+func toolCallDeltas(calls []ToolCall) []ToolCallDelta {
+	deltas := make([]ToolCallDelta, len(calls))
+	for i, call := range calls {
+		deltas[i] = ToolCallDelta{Index: i, ID: call.ID, Type: call.Type, Function: call.Function}
+	}
+	return deltas
+}
+
+// toolCallStream 在流式响应中暂存可能是工具调用的内容。为 nil 时不做任何处理。
+type toolCallStream struct {
+	te      *toolEmulation
+	started bool // 已输出过非空白内容
+	holding bool
+	pending strings.Builder
+}
+
+func newToolCallStream(te *toolEmulation) *toolCallStream {
+	if te == nil {
+		return nil
+	}
+	return &toolCallStream{te: te}
+}
+
+// push 处理一段回复内容，返回可以立即发送的部分。
+func (s *toolCallStream) push(chunk string) string {
+	if s == nil {
+		return chunk
+	}
+	s.pending.WriteString(chunk)
+	if s.holding {
+		return ""
+	}
+	text := s.pending.String()
+	if !s.started {
+		trimmed := strings.TrimLeft(text, " \t\r\n")
+		if trimmed == "" || strings.HasPrefix("```json", trimmed) {
+			return "" // 还无法判断回复是否以 JSON 开头
+		}
+		s.started = true
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "```json") {
+			s.holding = true
+			return ""
+		}
+	}
+	if i := strings.Index(text, translate.ToolCallsMarker); i >= 0 {
+		s.holding = true
+		s.pending.Reset()
+		s.pending.WriteString(text[i:])
+		return text[:i]
+	}
+	keep := partialTagSuffix(text, translate.ToolCallsMarker)
+	s.pending.Reset()
+	s.pending.WriteString(text[len(text)-keep:])
+	return text[:len(text)-keep]
+}
+
+// flush 在回复结束时调用，返回剩余的普通内容与识别到的工具调用。
+func (s *toolCallStream) flush() (string, []ToolCall) {
+	if s == nil {
+		return "", nil
+	}
+	text := s.pending.String()
+	s.pending.Reset()
+	if rest, calls := s.te.split(text); len(calls) > 0 {
+		return rest, calls
+	}
+	return text, nil
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testTools(t *testing.T, choice string) *toolEmulation {
+	t.Helper()
+	req := OpenAIRequest{Tools: []Tool{{Type: "function", Function: ToolFunction{Name: "get_weather"}}}}
+	if choice != "" {
+		req.ToolChoice = json.RawMessage(choice)
+	}
+	te, err := newToolEmulation(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return te
+}
+
+func TestToolEmulationSplit(t *testing.T) {
+	te := testTools(t, "")
+	tests := []struct {
+		content   string
+		text      string
+		arguments string // 为空表示没有识别到调用
+	}{
+		{"Checking.\n[tool_calls]\n```json\n[{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}]\n```", "Checking.", `{"city": "Paris"}`},
+		{`{"name": "get_weather", "arguments": "{\"city\":\"Oslo\"}"}`, "", `{"city":"Oslo"}`}, // 省略标记、参数为字符串
+		{"[tool_calls]\n[{\"function\": {\"name\": \"get_weather\"}}]", "", "{}"},              // OpenAI 格式
+		{"[tool_calls]\n[{\"name\": \"delete_files\", \"arguments\": {}}]", "", ""},            // 未声明的工具
+		{"Use `{\"name\": \"get_weather\"}` to query.", "", ""},
+	}
+	for _, tt := range tests {
+		text, calls := te.split(tt.content)
+		if tt.arguments == "" {
+			if len(calls) != 0 || text != tt.content {
+				t.Errorf("split(%q) = %q, %+v, want no calls", tt.content, text, calls)
+			}
+			continue
+		}
+		if len(calls) != 1 || text != tt.text || calls[0].Function.Arguments != tt.arguments || calls[0].Function.Name != "get_weather" {
+			t.Errorf("split(%q) = %q, %+v", tt.content, text, calls)
+		}
+	}
+}
+
+func TestToolChoice(t *testing.T) {
+	if te := testTools(t, `"none"`); te != nil {
+		t.Errorf("tool_choice none: got %+v", te)
+	}
+	if te := testTools(t, `{"type": "function", "function": {"name": "get_weather"}}`); te.required != "get_weather" {
+		t.Errorf("named tool_choice: required = %q", te.required)
+	}
+	req := OpenAIRequest{
+		Tools:      []Tool{{Type: "function", Function: ToolFunction{Name: "get_weather"}}},
+		ToolChoice: json.RawMessage(`{"type": "function", "function": {"name": "other"}}`),
+	}
+	if _, err := newToolEmulation(req); err == nil {
+		t.Error("tool_choice with an undeclared function: want error")
+	}
+}
+
+func TestToolCallStream(t *testing.T) {
+	te := testTools(t, "")
+	tests := []struct {
+		chunks []string
+		sent   string
+		calls  int
+	}{
+		{[]string{"Sure", ". [tool", "_calls]\n```json\n[{\"name\": \"get_weather\"}]\n```"}, "Sure. ", 1},
+		{[]string{"```js", "on\n{\"name\": \"get_weather\"}\n```"}, "", 1},
+		{[]string{"```python\n", "print(1)\n```"}, "```python\nprint(1)\n```", 0},
+		{[]string{"[1, 2", ", 3]"}, "[1, 2, 3]", 0}, // 以 JSON 开头但不是调用，结束时作为普通内容发送
+	}
+	for _, tt := range tests {
+		s := newToolCallStream(te)
+		sent := ""
+		for _, chunk := range tt.chunks {
+			sent += s.push(chunk)
+		}
+		rest, calls := s.flush()
+		sent += rest
+		if sent != tt.sent || len(calls) != tt.calls {
+			t.Errorf("%q: sent %q, %d calls", tt.chunks, sent, len(calls))
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierror "you2api/apierror"
+)
+
+// 流式响应的断线重连（SSE_RETRY_MS、STREAM_TRANSCRIPT_SIZE、STREAM_RESUME_GRACE_MS）：
+//   - 第一个事件带有 retry 字段，建议客户端断开后等待的重连间隔
+//   - 每个事件带有 id 字段，格式为 "<响应 ID>:<序号>"，序号从 1 开始
+//   - 已发送的事件按响应 ID 保存在内存中（事件记录），客户端断开后携带 Last-Event-ID 请求头重新请求
+//     （EventSource 会自动这样做，请求体被忽略），服务端补发该事件之后的全部事件；补全尚未结束时继续推送新事件
+//   - 客户端断开后上游请求继续 STREAM_RESUME_GRACE_MS，期间没有客户端重连才取消，不再消耗账号额度
+//   - 事件记录不存在（已淘汰、属于其他 key）时返回 404；补全已结束且没有更多事件时返回 204，
+//     按 SSE 规范这两种响应都会让 EventSource 停止重连
+// 只在单个实例的内存中保存，多实例部署时重连请求需要路由到同一个实例。
+
+// transcripts 按响应 ID 保存流式补全已发送的事件，超过 STREAM_TRANSCRIPT_SIZE 时淘汰最久未使用的记录。
+var transcripts = newLRU[string, *streamTranscript]("transcripts", func() int { return currentConfig().StreamResume.TranscriptSize }).
+	withSizer(func(id string, t *streamTranscript) int { return len(id) + t.bytes() })
+
+// streamTranscript 是一个流式补全已发送的事件（含 id 与 retry 字段的完整 SSE 帧）。
+type streamTranscript struct {
+	keyID string
+
+	mu        sync.Mutex
+	frames    [][]byte
+	size      int
+	done      bool
+	changed   chan struct{} // 追加事件或结束时关闭并替换
+	listeners int           // 正在接收事件的客户端连接数，包括原始请求
+	cancel    func()        // 取消上游请求，原始请求的客户端断开后设置
+	timer     *time.Timer
+}
+
+func newStreamTranscript(keyID string) *streamTranscript {
+	return &streamTranscript{keyID: keyID, changed: make(chan struct{}), listeners: 1}
+}
+
+func (t *streamTranscript) bytes() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.size
+}
+
+// append 保存一个事件并唤醒等待新事件的重连请求。
+func (t *streamTranscript) append(frame []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.frames = append(t.frames, frame)
+	t.size += len(frame)
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// finish 在补全结束时调用，此后不会再有新事件。
+func (t *streamTranscript) finish() {
+	t.mu.Lock()
+	t.done = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	close(t.changed)
+	t.changed = make(chan struct{})
+	t.mu.Unlock()
+}
+
+// since 返回序号 seq 之后的事件、补全是否已结束，以及下一次变化时关闭的 channel。
+func (t *streamTranscript) since(seq int) (frames [][]byte, done bool, changed <-chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if seq < len(t.frames) {
+		frames = t.frames[seq:len(t.frames):len(t.frames)]
+	}
+	return frames, t.done, t.changed
+}
+
+// hold 是原始请求的客户端断开时的处理函数（见 holdDisconnect）：没有其他客户端在接收事件时，
+// 等待 STREAM_RESUME_GRACE_MS 后取消上游请求，期间有客户端重连则继续生成。
+func (t *streamTranscript) hold(cancel func()) bool {
+	grace := time.Duration(currentConfig().StreamResume.GraceMS) * time.Millisecond
+	if grace <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	t.cancel = cancel
+	t.mu.Unlock()
+	t.leave()
+	return true
+}
+
+// attach 登记一个重连的客户端，停止等待中的取消。
+func (t *streamTranscript) attach() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners++
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// leave 在客户端连接结束时调用，最后一个客户端断开且补全未结束时开始等待取消。
+func (t *streamTranscript) leave() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners--
+	if t.listeners > 0 || t.done || t.cancel == nil {
+		return
+	}
+	grace := time.Duration(currentConfig().StreamResume.GraceMS) * time.Millisecond
+	t.timer = time.AfterFunc(grace, func() {
+		t.mu.Lock()
+		idle := t.listeners == 0
+		t.mu.Unlock()
+		if idle {
+			t.cancel()
+		}
+	})
+}
+
+// eventID 返回客户端可见的事件 ID。
+func eventID(responseID string, seq int) string {
+	return responseID + ":" + strconv.Itoa(seq)
+}
+
+// parseEventID 解析 Last-Event-ID，格式不符时返回 false。
+func parseEventID(id string) (responseID string, seq int, ok bool) {
+	i := strings.LastIndex(id, ":")
+	if i <= 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.Atoi(id[i+1:])
+	if err != nil || seq < 0 {
+		return "", 0, false
+	}
+	return id[:i], seq, true
+}
+
+// transcriptWriter 为流式响应的每个事件加上 id 字段（第一个事件还有 retry 字段），并保存到事件记录中。
+// 流式响应的每次 Write 都是一个完整事件；t 为 nil 时（未开启事件记录）只添加 retry 字段。
+type transcriptWriter struct {
+	http.ResponseWriter
+	t          *streamTranscript
+	responseID string
+	seq        int
+}
+
+// newTranscriptWriter 为流式补全创建事件记录并包装 ResponseWriter。返回的函数必须在补全结束时调用。
+func newTranscriptWriter(ctx context.Context, w http.ResponseWriter, responseID, keyID string) (http.ResponseWriter, func()) {
+	conf := currentConfig().StreamResume
+	if conf.TranscriptSize <= 0 {
+		if conf.RetryMS <= 0 {
+			return w, func() {}
+		}
+		return &transcriptWriter{ResponseWriter: w, responseID: responseID}, func() {}
+	}
+	t := newStreamTranscript(keyID)
+	transcripts.put(responseID, t)
+	holdDisconnect(ctx, t.hold)
+	return &transcriptWriter{ResponseWriter: w, t: t, responseID: responseID}, func() {
+		t.finish()
+		transcripts.put(responseID, t) // 按最终大小重新计算字节预算
+	}
+}
+
+func (tw *transcriptWriter) Write(p []byte) (int, error) {
+	if !bytes.HasPrefix(p, []byte("data: ")) {
+		return tw.ResponseWriter.Write(p)
+	}
+	tw.seq++
+	var frame bytes.Buffer
+	if retry := currentConfig().StreamResume.RetryMS; tw.seq == 1 && retry > 0 {
+		fmt.Fprintf(&frame, "retry: %d\n", retry)
+	}
+	if tw.t != nil {
+		fmt.Fprintf(&frame, "id: %s\n", eventID(tw.responseID, tw.seq))
+	}
+	frame.Write(p)
+	if tw.t != nil {
+		tw.t.append(frame.Bytes())
+	}
+	if _, err := tw.ResponseWriter.Write(frame.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (tw *transcriptWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// resumeStream 处理携带 Last-Event-ID 的重连请求，返回 false 表示不是重连请求（或未开启事件记录），按新请求处理。
+func resumeStream(w http.ResponseWriter, r *http.Request, apiKey string) bool {
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" || currentConfig().StreamResume.TranscriptSize <= 0 {
+		return false
+	}
+	responseID, seq, ok := parseEventID(lastEventID)
+	if !ok {
+		return false
+	}
+	t, ok := transcripts.get(responseID)
+	if !ok || t.keyID != keyID(apiKey) {
+		clientError(w, r, http.StatusNotFound, apierror.CodeStreamNotFound, "Stream not found or expired: %s", responseID)
+		return true
+	}
+
+	t.attach()
+	defer t.leave()
+	frames, done, changed := t.since(seq)
+	if len(frames) == 0 && done {
+		w.WriteHeader(http.StatusNoContent) // 补全已结束，没有更多事件
+		return true
+	}
+	w, finish := newGzipEventWriter(w, r)
+	defer finish()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set(requestIDHeader, responseID)
+	w.WriteHeader(http.StatusOK)
+	for {
+		for _, frame := range frames {
+			if _, err := w.Write(frame); err != nil {
+				return true
+			}
+		}
+		w.(http.Flusher).Flush()
+		seq += len(frames)
+		if done {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return true
+		}
+		frames, done, changed = t.since(seq)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseEventID(t *testing.T) {
+	tests := []struct {
+		id         string
+		responseID string
+		seq        int
+		ok         bool
+	}{
+		{"chatcmpl-abc:12", "chatcmpl-abc", 12, true},
+		{"job:1:3", "job:1", 3, true},
+		{"chatcmpl-abc", "", 0, false},
+		{":3", "", 0, false},
+		{"chatcmpl-abc:-1", "", 0, false},
+		{"12345", "", 0, false}, // 上游 You.com 的事件 ID
+	}
+	for _, tt := range tests {
+		responseID, seq, ok := parseEventID(tt.id)
+		if responseID != tt.responseID || seq != tt.seq || ok != tt.ok {
+			t.Errorf("parseEventID(%q) = %q, %d, %v", tt.id, responseID, seq, ok)
+		}
+	}
+}
+
+func TestResumeStreamReplaysAfterLastEventID(t *testing.T) {
+	tr := newStreamTranscript(keyID("key"))
+	for _, frame := range []string{"id: r1:1\ndata: a\n\n", "id: r1:2\ndata: b\n\n", "id: r1:3\ndata: [DONE]\n\n"} {
+		tr.append([]byte(frame))
+	}
+	tr.finish()
+	transcripts.put("r1", tr)
+
+	tests := []struct {
+		key, lastEventID string
+		status           int
+		body             string
+	}{
+		{"key", "r1:1", http.StatusOK, "id: r1:2\ndata: b\n\nid: r1:3\ndata: [DONE]\n\n"},
+		{"key", "r1:3", http.StatusNoContent, ""},
+		{"other", "r1:1", http.StatusNotFound, ""}, // 其他 key 的事件记录视为不存在
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		r.Header.Set("Last-Event-ID", tt.lastEventID)
+		w := httptest.NewRecorder()
+		if !resumeStream(w, r, tt.key) {
+			t.Fatalf("%s: not handled as resume", tt.lastEventID)
+		}
+		if w.Code != tt.status || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s/%s: status %d, body %q", tt.key, tt.lastEventID, w.Code, w.Body.String())
+		}
+	}
+}
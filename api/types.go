@@ -0,0 +1,34 @@
+package handler
+
+import api "you2api/pkg/api"
+
+// OpenAI 兼容接口的请求与响应结构定义在 pkg/api 中，与上游客户端（internal/upstream）和
+// 请求转换（internal/translate）共用；这里的别名让 handler 内部继续使用原来的名称。
+type (
+	OpenAIRequest          = api.OpenAIRequest
+	SamplingParams         = api.SamplingParams
+	ReasoningOptions       = api.ReasoningOptions
+	ResponseFormat         = api.ResponseFormat
+	JSONSchemaFormat       = api.JSONSchemaFormat
+	Tool                   = api.Tool
+	ToolFunction           = api.ToolFunction
+	ToolCall               = api.ToolCall
+	ToolCallFunction       = api.ToolCallFunction
+	ToolCallDelta          = api.ToolCallDelta
+	Message                = api.Message
+	OpenAIResponse         = api.OpenAIResponse
+	OpenAIChoice           = api.OpenAIChoice
+	OpenAIStreamResponse   = api.OpenAIStreamResponse
+	Choice                 = api.Choice
+	Delta                  = api.Delta
+	Annotation             = api.Annotation
+	URLCitation            = api.URLCitation
+	Usage                  = api.Usage
+	ChoiceError            = api.ChoiceError
+	ProviderMetadata       = api.ProviderMetadata
+	AutoModelDecision      = api.AutoModelDecision
+	StructuredOutputReport = api.StructuredOutputReport
+	OutputRetry            = api.OutputRetry
+	ModelResponse          = api.ModelResponse
+	ModelDetail            = api.ModelDetail
+)
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	apierror "you2api/apierror"
+)
+
+func TestUpstreamErrorCode(t *testing.T) {
+	tests := []struct {
+		status int
+		header string
+		want   string
+	}{
+		{http.StatusUnauthorized, "", apierror.CodeTokenExpired},
+		{http.StatusForbidden, "challenge", apierror.CodeUpstreamBlocked},
+		{http.StatusForbidden, "", apierror.CodeTierRestricted},
+		{http.StatusTooManyRequests, "", apierror.CodeUpstreamRateLimited},
+		{http.StatusBadGateway, "", apierror.CodeUpstreamError},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Cf-Mitigated", tt.header)
+		}
+		if _, code := upstreamErrorCode(checkUpstreamStatus(resp)); code != tt.want {
+			t.Errorf("status %d (Cf-Mitigated %q): code = %q, want %q", tt.status, tt.header, code, tt.want)
+		}
+	}
+	if status, code := upstreamErrorCode(fmt.Errorf("stream: %w", errFirstTokenTimeout)); status != http.StatusGatewayTimeout || code != apierror.CodeStreamStalled {
+		t.Errorf("first token timeout: %d %q", status, code)
+	}
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// virtualModel 是运营者在配置中定义的“虚拟模型”：基于某个上游模型，
+// 附加固定的系统提示词与输出清理规则，对客户端表现为一个独立的模型。
+type virtualModel struct {
+	Name         string   `json:"name"`
+	BaseModel    string   `json:"base_model"`            // OpenAI 模型名称或 You.com 模型名称
+	SystemPrompt string   `json:"system_prompt"`         // 追加在对话开头的系统提示词
+	Temperature  *float64 `json:"temperature,omitempty"` // 请求未指定 temperature 时使用，按 SAMPLING_PARAMS 转发
+	Sanitizers   []string `json:"sanitizers,omitempty"`
+}
+
+var (
+	citationMarkers = regexp.MustCompile(`\[\[?\d+\]\]?`)
+	excessNewlines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// sanitizers 是虚拟模型可以引用的输出清理规则。
+// 流式响应中规则作用于缓冲后的片段，匹配内容只能由 sanitizerHoldback 中的字符组成，
+// 否则可能被拆分到两个片段中而漏掉。
+var sanitizers = map[string]func(string) string{
+	"strip_citations":   func(s string) string { return citationMarkers.ReplaceAllString(s, "") },
+	"collapse_newlines": func(s string) string { return excessNewlines.ReplaceAllString(s, "\n\n") },
+}
+
+// sanitizerHoldback 是清理规则可能匹配的字符。流式响应中以这些字符结尾的部分暂不发送，
+// 等到出现其他字符时再整体清理，避免 "[1" 与 "2]"、"\n\n" 与 "\n" 分属两个块时规则失效。
+const sanitizerHoldback = "[]0123456789\n"
+
+var (
+	virtualModelsOnce sync.Once
+	virtualModels     map[string]*virtualModel
+)
+
+// getVirtualModels 返回配置中的虚拟模型，来源为 VIRTUAL_MODELS（JSON 字符串）或 VIRTUAL_MODELS_FILE（JSON 文件）。
+func getVirtualModels() map[string]*virtualModel {
+	virtualModelsOnce.Do(func() {
+		virtualModels = make(map[string]*virtualModel)
+		conf := currentConfig()
+
+		data := []byte(conf.VirtualModels)
+		if conf.VirtualModelsFile != "" {
+			fileData, err := os.ReadFile(conf.VirtualModelsFile)
+			if err != nil {
+				log.Printf("读取虚拟模型文件失败: %v", err)
+				return
+			}
+			data = fileData
+		}
+		if len(data) == 0 {
+			return
+		}
+
+		var defs []*virtualModel
+		if err := json.Unmarshal(data, &defs); err != nil {
+			log.Printf("解析虚拟模型配置失败: %v", err)
+			return
+		}
+		for _, vm := range defs {
+			if vm.Name == "" || vm.BaseModel == "" {
+				log.Printf("忽略缺少 name 或 base_model 的虚拟模型: %+v", vm)
+				continue
+			}
+			for _, name := range vm.Sanitizers {
+				if _, ok := sanitizers[name]; !ok {
+					log.Printf("虚拟模型 %s 引用了未知的清理规则 %q，已忽略", vm.Name, name)
+				}
+			}
+			virtualModels[vm.Name] = vm
+		}
+	})
+	return virtualModels
+}
+
+// upstreamModel 返回虚拟模型对应的 You.com 模型名称。
+func (vm *virtualModel) upstreamModel() string {
+	if mapped, ok := getModelMap()[vm.BaseModel]; ok {
+		return mapped
+	}
+	return vm.BaseModel
+}
+
+// apply 在请求的消息列表开头插入虚拟模型的系统提示词，并在请求未指定时使用虚拟模型的 temperature。
+func (vm *virtualModel) apply(openAIReq OpenAIRequest) OpenAIRequest {
+	if openAIReq.Temperature == nil && vm.Temperature != nil {
+		temperature := *vm.Temperature
+		openAIReq.Temperature = &temperature
+	}
+	if vm.SystemPrompt == "" {
+		return openAIReq
+	}
+	messages := make([]Message, 0, len(openAIReq.Messages)+1)
+	messages = append(messages, Message{Role: "system", Content: vm.SystemPrompt})
+	openAIReq.Messages = append(messages, openAIReq.Messages...)
+	return openAIReq
+}
+
+// sanitize 依次应用虚拟模型配置的清理规则；vm 为 nil 时原样返回。
+// 流式响应使用 newSanitizeStream，避免匹配内容被拆分到两个块中。
+func (vm *virtualModel) sanitize(content string) string {
+	if vm == nil {
+		return content
+	}
+	for _, name := range vm.Sanitizers {
+		if fn, ok := sanitizers[name]; ok {
+			content = fn(content)
+		}
+	}
+	return content
+}
+
+// sanitizeStream 在流式响应中应用虚拟模型的清理规则。
+// 以 sanitizerHoldback 字符结尾的部分会暂存到下一个块，保证每次清理的片段边界不会落在匹配内容中间。
+type sanitizeStream struct {
+	vm      *virtualModel
+	pending string
+}
+
+// newSanitizeStream 返回虚拟模型的流式清理器；没有配置清理规则时返回 nil，push 原样返回内容。
+func newSanitizeStream(vm *virtualModel) *sanitizeStream {
+	if vm == nil || len(vm.Sanitizers) == 0 {
+		return nil
+	}
+	return &sanitizeStream{vm: vm}
+}
+
+// push 处理一个内容块，返回可以立即发送的已清理部分。
+func (s *sanitizeStream) push(chunk string) string {
+	if s == nil {
+		return chunk
+	}
+	s.pending += chunk
+	cut := strings.LastIndexFunc(s.pending, func(r rune) bool {
+		return !strings.ContainsRune(sanitizerHoldback, r)
+	})
+	if cut < 0 {
+		return ""
+	}
+	_, size := utf8.DecodeRuneInString(s.pending[cut:])
+	cut += size
+	ready := s.pending[:cut]
+	s.pending = s.pending[cut:]
+	return s.vm.sanitize(ready)
+}
+
+// flush 在流结束时清理并返回暂存的内容。
+func (s *sanitizeStream) flush() string {
+	if s == nil {
+		return ""
+	}
+	rest := s.pending
+	s.pending = ""
+	return s.vm.sanitize(rest)
+}
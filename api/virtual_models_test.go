@@ -0,0 +1,69 @@
+package handler
+
+import "testing"
+
+func TestSanitizeStreamSplitMatches(t *testing.T) {
+	vm := &virtualModel{Name: "clean", Sanitizers: []string{"strip_citations", "collapse_newlines"}}
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"citation split", []string{"see [", "1", "] here"}, "see  here"},
+		{"double bracket split", []string{"a [[1", "2]", "] b"}, "a  b"},
+		{"citation at end", []string{"done [3"}, "done [3"},
+		{"citation at end closed", []string{"done [3", "]"}, "done "},
+		{"newlines split", []string{"a\n", "\n", "\n\nb"}, "a\n\nb"},
+		{"plain digits", []string{"year 20", "24 ok"}, "year 2024 ok"},
+		{"multibyte", []string{"引用[", "2]。"}, "引用。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSanitizeStream(vm)
+			var got string
+			for _, chunk := range tt.chunks {
+				got += s.push(chunk)
+			}
+			got += s.flush()
+			if got != tt.want {
+				t.Errorf("streamed = %q, want %q", got, tt.want)
+			}
+			// 流式结果应与一次性清理完整内容一致
+			var full string
+			for _, chunk := range tt.chunks {
+				full += chunk
+			}
+			if whole := vm.sanitize(full); whole != got {
+				t.Errorf("streamed %q differs from whole-content %q", got, whole)
+			}
+		})
+	}
+}
+
+func TestSanitizeStreamWithoutSanitizers(t *testing.T) {
+	if s := newSanitizeStream(&virtualModel{Name: "plain"}); s.push("[1]") != "[1]" || s.flush() != "" {
+		t.Error("virtual model without sanitizers should pass content through")
+	}
+	if s := newSanitizeStream(nil); s.push("x") != "x" {
+		t.Error("nil virtual model should pass content through")
+	}
+}
+
+func TestVirtualModelApplyTemperature(t *testing.T) {
+	vmTemp, reqTemp := 0.2, 0.9
+	vm := &virtualModel{Name: "cold", SystemPrompt: "be brief", Temperature: &vmTemp}
+
+	got := vm.apply(OpenAIRequest{Messages: []Message{{Role: "user", Content: "hi"}}})
+	if got.Temperature == nil || *got.Temperature != vmTemp {
+		t.Errorf("Temperature = %v, want %v", got.Temperature, vmTemp)
+	}
+	if len(got.Messages) != 2 || got.Messages[0].Content != "be brief" {
+		t.Errorf("system prompt not prepended: %+v", got.Messages)
+	}
+
+	req := OpenAIRequest{}
+	req.Temperature = &reqTemp
+	if got := vm.apply(req); *got.Temperature != reqTemp {
+		t.Errorf("request temperature overridden: %v", *got.Temperature)
+	}
+}
@@ -0,0 +1,58 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Response 是 OpenAI 兼容的错误响应体，OpenAI SDK 会从中解析错误信息：
+//
+//	{"error": {"message": "...", "type": "invalid_request_error", "param": null, "code": "invalid_request_body"}}
+type Response struct {
+	Error Detail `json:"error"`
+}
+
+// Detail 是错误的具体内容。
+type Detail struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    string  `json:"code"`
+}
+
+// Type 按 HTTP 状态码返回 OpenAI 的错误类型。
+func Type(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusNotFound:
+		return "not_found_error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= http.StatusInternalServerError:
+		return "server_error"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+// Write 以 OpenAI 的错误格式返回错误，code 是机器可读的错误码（如 invalid_request_body）。
+func Write(w http.ResponseWriter, status int, code, message string) {
+	WriteParam(w, status, code, "", message)
+}
+
+// WriteParam 与 Write 相同，param 是导致错误的请求参数，为空时返回 null。
+func WriteParam(w http.ResponseWriter, status int, code, param, message string) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	detail := Detail{Message: message, Type: Type(status), Code: code}
+	if param != "" {
+		detail.Param = &param
+	}
+	json.NewEncoder(w).Encode(Response{Error: detail})
+}
@@ -0,0 +1,28 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Write(rec, http.StatusUnauthorized, "invalid_api_key", "Missing or invalid authorization header")
+
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status = %d, content type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var body map[string]map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	got := body["error"]
+	if got["type"] != "authentication_error" || got["code"] != "invalid_api_key" || got["message"] == "" {
+		t.Errorf("error = %v", got)
+	}
+	if v, ok := got["param"]; !ok || v != nil {
+		t.Errorf("param = %v, want null", v)
+	}
+}
@@ -0,0 +1,126 @@
+package apierror
+
+import "net/http"
+
+// 错误码目录：错误响应体中的 code 字段取自下面的常量，客户端可以据此分支处理。
+// 稳定性约定：已发布的错误码不会改名、删除或改变含义；新的错误情形使用新的错误码，
+// 不复用旧的。HTTP 状态码与 message 的措辞（以及 Accept-Language 对应的翻译）不属于约定的一部分。
+const (
+	// 请求
+	CodeInvalidRequestBody  = "invalid_request_body"
+	CodeUnsupportedParam    = "unsupported_parameter"
+	CodeRequestTooLarge     = "request_too_large"
+	CodeFileTooLarge        = "file_too_large"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeDuplicateRequestID  = "duplicate_request_id"
+	CodeConflict            = "conflict"
+	CodeNotFound            = "not_found"
+	CodeModelNotFound       = "model_not_found"
+	CodeResponseNotFound    = "response_not_found"
+	CodeStreamNotFound      = "stream_not_found"
+	CodeAsyncNotConfigured  = "async_not_configured"
+	CodeJobQueueUnavailable = "job_queue_unavailable"
+	CodeQueueFull           = "queue_full"
+	CodePluginRejected      = "plugin_rejected"
+
+	// 认证与权限
+	CodeInvalidAPIKey     = "invalid_api_key"
+	CodeInvalidAdminKey   = "invalid_admin_key"
+	CodeInsufficientRole  = "insufficient_role"
+	CodeInvalidToken      = "invalid_token"
+	CodeInsufficientScope = "insufficient_scope"
+	CodeModelNotAllowed   = "model_not_allowed"
+	CodeRateLimited       = "rate_limited"
+
+	// 账号池与上游
+	CodeNoAccountAvailable   = "no_account_available"
+	CodeInvalidDSToken       = "invalid_ds_token"
+	CodeDuplicateToken       = "duplicate_token"
+	CodeTokenExpired         = "token_expired"
+	CodeTierRestricted       = "tier_restricted"
+	CodeUpstreamBlocked      = "upstream_blocked"
+	CodeUpstreamRateLimited  = "upstream_rate_limited"
+	CodeUpstreamError        = "upstream_error"
+	CodeUpstreamUploadFailed = "upstream_upload_failed"
+	CodeEmptyCompletion      = "empty_completion"
+	CodeStreamStalled        = "stream_stalled"
+	CodeStreamInterrupted    = "stream_interrupted"
+	CodeResponseRejected     = "response_rejected"
+
+	// 管理与运维
+	CodeNotConfigured       = "not_configured"
+	CodeAuditDisabled       = "audit_disabled"
+	CodeImportFailed        = "import_failed"
+	CodePersistFailed       = "persist_failed"
+	CodeInvalidStateKey     = "invalid_state_key"
+	CodeInvalidStateArchive = "invalid_state_archive"
+	CodeInternalError       = "internal_error"
+	CodeConfigError         = "config_error"
+)
+
+// CodeInfo 描述目录中的一个错误码，Status 是通常伴随它的 HTTP 状态码。
+type CodeInfo struct {
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// Catalog 是全部错误码，按上面的分组排列。
+var Catalog = []CodeInfo{
+	{CodeInvalidRequestBody, http.StatusBadRequest, "请求体无法解析或未通过校验"},
+	{CodeUnsupportedParam, http.StatusBadRequest, "COMPAT_MODE=strict 时请求包含无法处理的参数"},
+	{CodeRequestTooLarge, http.StatusRequestEntityTooLarge, "请求体超过大小上限"},
+	{CodeFileTooLarge, http.StatusRequestEntityTooLarge, "上传的文件超过大小上限"},
+	{CodeMethodNotAllowed, http.StatusMethodNotAllowed, "接口不支持该 HTTP 方法"},
+	{CodeDuplicateRequestID, http.StatusConflict, "相同 X-Request-ID 的补全正在进行"},
+	{CodeConflict, http.StatusConflict, "资源状态不允许该操作"},
+	{CodeNotFound, http.StatusNotFound, "资源不存在"},
+	{CodeModelNotFound, http.StatusNotFound, "模型不存在"},
+	{CodeResponseNotFound, http.StatusNotFound, "previous_response_id 引用的响应不存在或已过期"},
+	{CodeStreamNotFound, http.StatusNotFound, "Last-Event-ID 引用的流式响应不存在或已过期"},
+	{CodeAsyncNotConfigured, http.StatusNotImplemented, "异步补全所需的配置缺失"},
+	{CodeJobQueueUnavailable, http.StatusServiceUnavailable, "任务队列无法打开"},
+	{CodeQueueFull, http.StatusServiceUnavailable, "等待账号名额的请求已达到上限"},
+	{CodePluginRejected, http.StatusBadRequest, "请求改写插件拒绝了请求"},
+
+	{CodeInvalidAPIKey, http.StatusUnauthorized, "缺少或无效的 API key"},
+	{CodeInvalidAdminKey, http.StatusUnauthorized, "缺少或无效的管理 key"},
+	{CodeInsufficientRole, http.StatusForbidden, "管理 key 的角色无权执行该操作"},
+	{CodeInvalidToken, http.StatusUnauthorized, "客户端 JWT 的签名、签发方、受众或有效期校验失败"},
+	{CodeInsufficientScope, http.StatusForbidden, "客户端 JWT 不带任何已配置的 scope"},
+	{CodeModelNotAllowed, http.StatusForbidden, "客户端 JWT 的 scope 不允许使用该模型"},
+	{CodeRateLimited, http.StatusTooManyRequests, "客户端超过了 scope 配置的每分钟请求数"},
+
+	{CodeNoAccountAvailable, http.StatusServiceUnavailable, "所有账号都在冷却或已达到并发上限"},
+	{CodeInvalidDSToken, http.StatusUnprocessableEntity, "DS token 格式无效"},
+	{CodeDuplicateToken, http.StatusConflict, "DS token 已在账号池中"},
+	{CodeTokenExpired, http.StatusBadGateway, "上游拒绝了账号的 DS token（401），token 可能已过期"},
+	{CodeTierRestricted, http.StatusForbidden, "账号的订阅等级无法使用该模型"},
+	{CodeUpstreamBlocked, http.StatusBadGateway, "上游的防护（如 Cloudflare 质询）拦截了请求"},
+	{CodeUpstreamRateLimited, http.StatusTooManyRequests, "上游对账号限流"},
+	{CodeUpstreamError, http.StatusBadGateway, "其他上游错误"},
+	{CodeUpstreamUploadFailed, http.StatusBadGateway, "文件上传到上游失败"},
+	{CodeEmptyCompletion, http.StatusBadGateway, "上游正常结束但没有返回任何内容"},
+	{CodeStreamStalled, http.StatusGatewayTimeout, "上游在首 token 超时时间内没有返回内容"},
+	{CodeStreamInterrupted, http.StatusBadGateway, "上游连接在生成结束前断开"},
+	{CodeResponseRejected, http.StatusBadGateway, "请求改写插件拒绝了上游的回复"},
+
+	{CodeNotConfigured, http.StatusNotFound, "功能所需的配置缺失"},
+	{CodeAuditDisabled, http.StatusNotFound, "审计日志未开启"},
+	{CodeImportFailed, http.StatusBadRequest, "导入的数据无效"},
+	{CodePersistFailed, http.StatusInternalServerError, "写入持久化文件失败"},
+	{CodeInvalidStateKey, http.StatusInternalServerError, "STATE_ENCRYPTION_KEY 配置无效"},
+	{CodeInvalidStateArchive, http.StatusBadRequest, "状态归档无法解密或解析"},
+	{CodeInternalError, http.StatusInternalServerError, "服务内部错误"},
+	{CodeConfigError, http.StatusInternalServerError, "服务配置无效，所有请求都被拒绝"},
+}
+
+// Lookup 返回错误码在目录中的描述。
+func Lookup(code string) (CodeInfo, bool) {
+	for _, info := range Catalog {
+		if info.Code == code {
+			return info, true
+		}
+	}
+	return CodeInfo{}, false
+}
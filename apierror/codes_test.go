@@ -0,0 +1,40 @@
+package apierror
+
+import (
+	"regexp"
+	"testing"
+)
+
+// releasedCodes 是已发布的错误码。按稳定性约定只能在末尾追加，不能删除或修改。
+var releasedCodes = []string{
+	"invalid_request_body", "unsupported_parameter", "request_too_large", "file_too_large",
+	"method_not_allowed", "duplicate_request_id", "conflict", "not_found", "model_not_found",
+	"response_not_found", "stream_not_found", "async_not_configured", "job_queue_unavailable",
+	"queue_full", "invalid_api_key", "invalid_admin_key", "insufficient_role",
+	"no_account_available", "invalid_ds_token", "duplicate_token", "token_expired",
+	"tier_restricted", "upstream_blocked", "upstream_rate_limited", "upstream_error",
+	"upstream_upload_failed", "empty_completion", "stream_stalled", "stream_interrupted",
+	"not_configured", "audit_disabled", "import_failed", "persist_failed",
+	"invalid_state_key", "invalid_state_archive", "internal_error",
+	"invalid_token", "insufficient_scope", "model_not_allowed", "rate_limited",
+	"plugin_rejected", "response_rejected", "config_error",
+}
+
+func TestCatalog(t *testing.T) {
+	codePattern := regexp.MustCompile(`^[a-z]+(_[a-z]+)*$`)
+	seen := make(map[string]bool)
+	for _, info := range Catalog {
+		if !codePattern.MatchString(info.Code) || info.Status < 400 || info.Description == "" {
+			t.Errorf("invalid catalog entry %+v", info)
+		}
+		if seen[info.Code] {
+			t.Errorf("duplicate code %q", info.Code)
+		}
+		seen[info.Code] = true
+	}
+	for _, code := range releasedCodes {
+		if _, ok := Lookup(code); !ok {
+			t.Errorf("released code %q is missing from the catalog", code)
+		}
+	}
+}
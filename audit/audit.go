@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message 是审计日志中记录的一条聊天消息。
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Entry 是一次聊天补全请求的审计记录，不包含 DS token 等凭据。
+type Entry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Model      string    `json:"model"`
+	Stream     bool      `json:"stream"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	Messages   []Message `json:"messages"`
+	Response   string    `json:"response"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// Store 在内存中保存最近的审计记录，并可选地追加写入 JSONL 文件。
+type Store struct {
+	mu      sync.RWMutex
+	entries []*Entry
+	next    int
+	byID    map[string]*Entry
+	file    *os.File
+}
+
+// NewStore 创建最多保存 size 条记录的审计存储，path 非空时同时写入文件。
+func NewStore(size int, path string) (*Store, error) {
+	if size <= 0 {
+		size = 1
+	}
+	s := &Store{
+		entries: make([]*Entry, size),
+		byID:    make(map[string]*Entry, size),
+	}
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		s.file = file
+	}
+	return s, nil
+}
+
+// Close 关闭审计文件，之后的记录只保存在内存中。
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// Add 记录一条审计条目，超出容量时覆盖最旧的记录。
+func (s *Store) Add(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old := s.entries[s.next]; old != nil {
+		delete(s.byID, old.ID)
+	}
+	s.entries[s.next] = entry
+	s.byID[entry.ID] = entry
+	s.next = (s.next + 1) % len(s.entries)
+
+	if s.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Get 按 ID 查找审计记录。
+func (s *Store) Get(id string) (*Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.byID[id]
+	return entry, ok
+}
+
+// Recent 按时间倒序返回内存中的审计记录。
+func (s *Store) Recent() []*Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Entry, 0, len(s.byID))
+	for i := 1; i <= len(s.entries); i++ {
+		idx := (s.next - i + len(s.entries)) % len(s.entries)
+		if s.entries[idx] != nil {
+			result = append(result, s.entries[idx])
+		}
+	}
+	return result
+}
+
+// FindInFile 在 JSONL 审计文件中查找指定 ID 的记录，供命令行重放工具使用。
+func FindInFile(path, id string) (*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var found *Entry
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // 跳过损坏的行
+		}
+		if entry.ID == id {
+			found = &entry // 同一 ID 以最后一次记录为准
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, os.ErrNotExist
+	}
+	return found, nil
+}
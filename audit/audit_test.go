@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreRingBuffer(t *testing.T) {
+	s, err := NewStore(3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := s.Add(&Entry{ID: fmt.Sprintf("req-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	for _, e := range s.Recent() {
+		ids = append(ids, e.ID)
+	}
+	if got, want := strings.Join(ids, ","), "req-5,req-4,req-3"; got != want {
+		t.Errorf("Recent() = %s, want %s", got, want)
+	}
+	if _, ok := s.Get("req-2"); ok {
+		t.Error("overwritten entry req-2 should no longer be found")
+	}
+	if _, ok := s.Get("req-3"); !ok {
+		t.Error("entry req-3 should still be found")
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	s, err := NewStore(1, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(&Entry{ID: "a", Response: "first"})
+	s.Add(&Entry{ID: "b", Response: "other"})
+	s.Add(&Entry{ID: "a", Response: "second"})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 内存中已被覆盖的记录仍可从文件中找到，同一 ID 以最后一次为准
+	entry, err := FindInFile(path, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Response != "second" {
+		t.Errorf("Response = %q, want second", entry.Response)
+	}
+	if _, err := FindInFile(path, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("FindInFile(missing) error = %v, want ErrNotExist", err)
+	}
+
+	// 关闭文件后仍可在内存中记录
+	if err := s.Add(&Entry{ID: "c"}); err != nil {
+		t.Errorf("Add after Close: %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"identical", "x\ny", "x\ny", "  x\n  y\n"},
+		{"changed line", "x\ny\nz", "x\nY\nz", "  x\n- y\n+ Y\n  z\n"},
+		{"inserted line", "x\nz", "x\ny\nz", "  x\n+ y\n  z\n"},
+		{"removed line", "x\ny\nz", "x\nz", "  x\n- y\n  z\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b); got != tt.want {
+				t.Errorf("Diff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLargeInput(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	// 5000x5000 超过 maxDiffCells，应退化为整体删除加新增而不是分配完整的 LCS 表
+	got := Diff("head\n"+a.String()+"tail", "head\n"+b.String()+"tail")
+	if !strings.HasPrefix(got, "  head\n- a0\n") || !strings.Contains(got, "- a4999\n+ b0\n") || !strings.HasSuffix(got, "+ b4999\n  tail\n") {
+		t.Errorf("unexpected diff boundaries: %q ... %q", got[:20], got[len(got)-30:])
+	}
+}
@@ -0,0 +1,85 @@
+package audit
+
+import "strings"
+
+// maxDiffCells 限制 LCS 表的大小（行数乘积），约 32MB。超出时中间部分不再逐行对齐，
+// 直接整体输出为删除加新增，避免超长回复让重放接口占用大量内存。
+const maxDiffCells = 4 << 20
+
+// Diff 对两段文本做按行的最长公共子序列比较，返回类似 unified diff 的结果：
+// 以 "  " 开头的行两者相同，"- " 仅存在于 original，"+ " 仅存在于 replayed。
+func Diff(original, replayed string) string {
+	a := strings.Split(original, "\n")
+	b := strings.Split(replayed, "\n")
+
+	// 先去掉相同的首尾行，通常只剩很小的差异区间
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out strings.Builder
+	for _, line := range a[:prefix] {
+		out.WriteString("  " + line + "\n")
+	}
+	diffLines(&out, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, line := range a[len(a)-suffix:] {
+		out.WriteString("  " + line + "\n")
+	}
+	return out.String()
+}
+
+func diffLines(out *strings.Builder, a, b []string) {
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, line := range a {
+			out.WriteString("- " + line + "\n")
+		}
+		for _, line := range b {
+			out.WriteString("+ " + line + "\n")
+		}
+		return
+	}
+
+	// lcs[i][j] 表示 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out.WriteString("- " + a[i] + "\n")
+	}
+	for ; j < len(b); j++ {
+		out.WriteString("+ " + b[j] + "\n")
+	}
+}
@@ -0,0 +1,193 @@
+package canary
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	apierror "you2api/apierror"
+	logger "you2api/logger"
+
+	"go.uber.org/zap"
+)
+
+// RouteHeader 标记请求最终由哪个实例处理，便于对比金丝雀与主实例的输出。
+const RouteHeader = "X-U2API-Route"
+
+// Router 按权重把一部分流量转发到金丝雀实例，其余流量交给本地处理器。
+// 金丝雀不健康或转发失败时自动回退到本地处理器。
+type Router struct {
+	primary   http.Handler
+	target    *url.URL
+	weight    float64
+	healthURL string
+	interval  time.Duration
+	client    *http.Client
+	proxy     *httputil.ReverseProxy
+	healthy   atomic.Bool
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// fallbackKey 用于在请求上下文中携带回退函数。
+type fallbackKey struct{}
+
+// NewRouter 创建金丝雀路由器，weight 为转发到金丝雀实例的流量比例（0~1）。
+func NewRouter(primary http.Handler, targetURL string, weight float64, healthPath string, healthIntervalMS, timeoutMS int) (*Router, error) {
+	target, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, err
+	}
+	healthURL, err := target.Parse(healthPath)
+	if err != nil {
+		return nil, err
+	}
+
+	router := &Router{
+		primary:   primary,
+		target:    target,
+		weight:    weight,
+		healthURL: healthURL.String(),
+		interval:  time.Duration(healthIntervalMS) * time.Millisecond,
+		client: &http.Client{
+			Timeout: time.Duration(timeoutMS) * time.Millisecond,
+		},
+	}
+	// 启动前乐观地认为金丝雀可用，首次健康检查会修正该状态
+	router.healthy.Store(true)
+
+	router.proxy = &httputil.ReverseProxy{
+		Director:       router.director,
+		FlushInterval:  -1, // SSE 需要立即刷新
+		ErrorHandler:   router.errorHandler,
+		ModifyResponse: router.modifyResponse,
+	}
+	return router, nil
+}
+
+func (rt *Router) director(req *http.Request) {
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	req.Host = rt.target.Host
+}
+
+// errCanaryServerError 表示金丝雀返回了 5xx 响应，与连接失败一样回退到本地处理器。
+var errCanaryServerError = errors.New("canary returned server error")
+
+// modifyResponse 把金丝雀的 5xx 响应视为转发失败。此时响应尚未写给客户端，可以安全回退。
+func (rt *Router) modifyResponse(resp *http.Response) error {
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: %s", errCanaryServerError, resp.Status)
+	}
+	return nil
+}
+
+// errorHandler 在转发失败时将金丝雀标记为不健康，并用缓存的请求体回退到本地处理器。
+// 客户端主动断开导致的 context.Canceled 不是金丝雀的问题，直接忽略。
+func (rt *Router) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	logger.L().Warn("金丝雀转发失败，回退到主实例", zap.String("error", logger.ScrubError(err)))
+	rt.healthy.Store(false)
+
+	fallback, ok := r.Context().Value(fallbackKey{}).(func(http.ResponseWriter))
+	if !ok {
+		apierror.Write(w, http.StatusBadGateway, apierror.CodeUpstreamError, "Canary upstream unavailable")
+		return
+	}
+	fallback(w)
+}
+
+// Healthy 报告金丝雀实例最近一次健康检查的结果。
+func (rt *Router) Healthy() bool {
+	return rt.healthy.Load()
+}
+
+// Start 启动后台健康检查循环，ctx 结束或调用 Stop 时退出。
+func (rt *Router) Start(ctx context.Context) {
+	ctx, rt.cancel = context.WithCancel(ctx)
+	rt.done = make(chan struct{})
+	go func() {
+		defer close(rt.done)
+		rt.checkHealth(ctx)
+		ticker := time.NewTicker(rt.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				rt.checkHealth(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop 结束健康检查循环，并等待进行中的检查返回。
+func (rt *Router) Stop() {
+	if rt.cancel == nil {
+		return
+	}
+	rt.cancel()
+	<-rt.done
+}
+
+func (rt *Router) checkHealth(ctx context.Context) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rt.healthURL, nil)
+	if err != nil {
+		return
+	}
+	resp, err := rt.client.Do(req)
+	if ctx.Err() != nil {
+		// 正在停止，本次结果不可信
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return
+	}
+	healthy := err == nil && resp.StatusCode < http.StatusInternalServerError
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if healthy != rt.healthy.Swap(healthy) {
+		logger.L().Info("金丝雀实例健康状态变更", zap.String("host", rt.target.Host), zap.Bool("healthy", healthy))
+	}
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !rt.healthy.Load() || rand.Float64() >= rt.weight {
+		w.Header().Set(RouteHeader, "primary")
+		rt.primary.ServeHTTP(w, r)
+		return
+	}
+
+	// 缓存请求体，以便转发失败时可以交给本地处理器重放
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+		return
+	}
+	r.Body.Close()
+
+	fallback := func(w http.ResponseWriter) {
+		local := r.Clone(r.Context())
+		local.Body = io.NopCloser(bytes.NewReader(body))
+		w.Header().Set(RouteHeader, "primary")
+		rt.primary.ServeHTTP(w, local)
+	}
+
+	forwarded := r.WithContext(context.WithValue(r.Context(), fallbackKey{}, fallback))
+	forwarded.Body = io.NopCloser(bytes.NewReader(body))
+	forwarded.ContentLength = int64(len(body))
+	w.Header().Set(RouteHeader, "canary")
+	rt.proxy.ServeHTTP(w, forwarded)
+}
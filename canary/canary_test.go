@@ -0,0 +1,159 @@
+package canary
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// echoPrimary 是本地处理器，回显请求体，用于确认回退时请求体被完整重放。
+var echoPrimary = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Write([]byte("primary:" + string(body)))
+})
+
+func newTestRouter(t *testing.T, canaryURL string) *Router {
+	t.Helper()
+	router, err := NewRouter(echoPrimary, canaryURL, 1, "/health", 10, 1000)
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	return router
+}
+
+func TestServeHTTPFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		wantRoute   string
+		wantBody    string
+		wantHealthy bool
+	}{
+		{"canary ok", http.StatusOK, "canary", "canary", true},
+		{"canary 4xx is passed through", http.StatusNotFound, "canary", "canary", true},
+		{"canary 5xx falls back", http.StatusBadGateway, "primary", "primary:hello", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte("canary"))
+			}))
+			defer upstream.Close()
+
+			router := newTestRouter(t, upstream.URL)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("hello")))
+
+			if got := rec.Header().Get(RouteHeader); got != tt.wantRoute {
+				t.Errorf("route = %q, want %q", got, tt.wantRoute)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if got := router.Healthy(); got != tt.wantHealthy {
+				t.Errorf("Healthy() = %v, want %v", got, tt.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestServeHTTPFallbackOnConnectionError(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	router := newTestRouter(t, upstream.URL)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+
+	if got := rec.Body.String(); got != "primary:hello" {
+		t.Errorf("body = %q, want fallback to primary", got)
+	}
+	if router.Healthy() {
+		t.Error("canary should be marked unhealthy after connection error")
+	}
+}
+
+func TestServeHTTPClientCanceled(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	router := newTestRouter(t, upstream.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	time.AfterFunc(50*time.Millisecond, cancel)
+	router.ServeHTTP(rec, req)
+
+	if !router.Healthy() {
+		t.Error("client cancellation must not mark canary unhealthy")
+	}
+	if strings.HasPrefix(rec.Body.String(), "primary") {
+		t.Error("client cancellation must not fall back to primary")
+	}
+}
+
+func TestHealthTransitions(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+
+	router := newTestRouter(t, upstream.URL)
+	router.Start(context.Background())
+	defer router.Stop()
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for router.Healthy() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Healthy() did not become %v", want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor(true)
+	healthy.Store(false)
+	waitFor(false)
+
+	// 不健康时所有流量交给本地处理器
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hi")))
+	if got := rec.Header().Get(RouteHeader); got != "primary" {
+		t.Errorf("route while unhealthy = %q, want primary", got)
+	}
+
+	healthy.Store(true)
+	waitFor(true)
+}
+
+func TestHealthLoopEndsWithContext(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	router := newTestRouter(t, upstream.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	router.Start(ctx)
+	cancel()
+	select {
+	case <-router.done:
+	case <-time.After(time.Second):
+		t.Fatal("health loop still running after context was canceled")
+	}
+	router.Stop() // ctx 已结束时 Stop 也应立即返回
+}
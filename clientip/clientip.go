@@ -0,0 +1,108 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolver 从请求中提取真实客户端 IP。
+// 只有当直接连接方属于可信代理时，才会采信 X-Forwarded-For / X-Real-IP，
+// 防止客户端伪造这些头部绕过基于 IP 的限流与访问控制。
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver 根据逗号分隔的 IP 或 CIDR 列表（如 "10.0.0.0/8,127.0.0.1"）创建解析器。
+// 列表为空时不信任任何代理，始终使用 TCP 连接的对端地址。
+func NewResolver(trustedProxies string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, item := range strings.Split(trustedProxies, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("无效的可信代理网段 %q: %w", item, err)
+			}
+			r.trusted = append(r.trusted, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的可信代理地址 %q: %w", item, err)
+		}
+		addr = addr.Unmap()
+		r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return r, nil
+}
+
+// ClientIP 返回请求的真实客户端 IP。
+// X-Forwarded-For 从右向左解析，跳过可信代理，第一个不可信的地址即为客户端；
+// 若整条链都是可信代理，则取最左侧的地址。
+func (r *Resolver) ClientIP(req *http.Request) string {
+	peer := remoteIP(req.RemoteAddr)
+	if !r.isTrusted(peer) {
+		return peer
+	}
+
+	if xff := req.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		var hops []string
+		for _, value := range xff {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(hops[i])
+			if err != nil {
+				// 链中出现无法解析的地址，说明该位置及其左侧都不可信
+				break
+			}
+			ip := addr.Unmap().String()
+			if i == 0 || !r.isTrusted(ip) {
+				return ip
+			}
+		}
+		return peer
+	}
+
+	if realIP := strings.TrimSpace(req.Header.Get("X-Real-IP")); realIP != "" {
+		if addr, err := netip.ParseAddr(realIP); err == nil {
+			return addr.Unmap().String()
+		}
+	}
+	return peer
+}
+
+func (r *Resolver) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP 去掉 RemoteAddr 中的端口部分。
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
@@ -0,0 +1,51 @@
+package clientip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewResolver("10.0.0.0/8, 127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer spoofing xff", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "198.51.100.9"}, "198.51.100.9"},
+		{"client prepends fake hop", "127.0.0.1:80", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.9, 10.0.0.5"}, "198.51.100.9"},
+		{"all hops trusted", "127.0.0.1:80", map[string]string{"X-Forwarded-For": "10.0.0.9, 10.0.0.5"}, "10.0.0.9"},
+		{"garbage hop", "127.0.0.1:80", map[string]string{"X-Forwarded-For": "nonsense"}, "127.0.0.1"},
+		{"x-real-ip", "10.1.2.3:80", map[string]string{"X-Real-IP": "198.51.100.9"}, "198.51.100.9"},
+		{"x-real-ip from untrusted peer", "203.0.113.7:5000", map[string]string{"X-Real-IP": "198.51.100.9"}, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := resolver.ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewResolverRejectsInvalidEntries(t *testing.T) {
+	if _, err := NewResolver("10.0.0.0/33"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+	if _, err := NewResolver("not-an-ip"); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
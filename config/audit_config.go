@@ -0,0 +1,8 @@
+package config
+
+// AuditConfig 控制聊天补全请求的审计日志，用于重放与排查问题。
+type AuditConfig struct {
+	EnableAudit bool   `json:"enable_audit"`
+	AuditSize   int    `json:"audit_size"`
+	AuditFile   string `json:"audit_file"`
+}
@@ -0,0 +1,15 @@
+package config
+
+// AutoModelConfig 控制虚拟模型 auto 的选择规则。模型名称均为 OpenAI 风格名称（如 gpt-4o-mini），
+// 列表型配置以逗号分隔。
+type AutoModelConfig struct {
+	Enabled          bool   `json:"enabled"`
+	DefaultModel     string `json:"default_model"`
+	CodeModel        string `json:"code_model"`
+	ReasoningModel   string `json:"reasoning_model"`
+	LongModel        string `json:"long_model"`
+	LongPromptTokens int    `json:"long_prompt_tokens"`
+	ReasoningMarkers string `json:"reasoning_markers"`
+	// Fallbacks 是所选模型最近调用失败或受订阅等级限制时依次尝试的候选模型
+	Fallbacks string `json:"fallbacks"`
+}
@@ -0,0 +1,15 @@
+package config
+
+// CanaryConfig 控制将部分流量转发到另一个 u2api 实例（如金丝雀版本）。
+type CanaryConfig struct {
+	CanaryURL        string  `json:"canary_url"`
+	CanaryWeight     float64 `json:"canary_weight"`
+	HealthPath       string  `json:"health_path"`
+	HealthIntervalMS int     `json:"health_interval_ms"`
+	CanaryTimeoutMS  int     `json:"canary_timeout_ms"`
+}
+
+// Enabled 报告是否配置了金丝雀目标且分配了流量。
+func (c CanaryConfig) Enabled() bool {
+	return c.CanaryURL != "" && c.CanaryWeight > 0
+}
@@ -6,23 +6,341 @@ import (
 )
 
 type Config struct {
-    Port     int         `json:"port"`
-    LogLevel string      `json:"log_level"`
-    Proxy    ProxyConfig `json:"proxy"`
-    // 其他配置项...
+	Port     int          `json:"port"`
+	LogLevel string       `json:"log_level"`
+	Proxy    ProxyConfig  `json:"proxy"`
+	Canary   CanaryConfig `json:"canary"`
+	AdminKey string       `json:"-"`
+	Audit    AuditConfig  `json:"audit"`
+	// Corpus 控制回归测试语料的采集，见 corpus 包
+	Corpus CorpusConfig `json:"corpus"`
+	// AdminReadOnlyKey 是只读管理密钥，只能查看指标与状态；ADMIN_KEY 为操作员密钥。两者都可以用逗号分隔多个密钥
+	AdminReadOnlyKey string `json:"-"`
+	// KeyAliasesFile 持久化按 API key 划分的模型别名，为空时仅保存在内存中
+	KeyAliasesFile string `json:"key_aliases_file"`
+	// UpstreamRetries 是流式请求在上游失败或返回空内容时的最大重试次数
+	UpstreamRetries int `json:"upstream_retries"`
+	// DefaultModel 是未知模型名称回退到的模型（OpenAI 或 You.com 名称）；
+	// RejectUnknownModels 开启后不回退，直接返回 404 model_not_found
+	DefaultModel        string `json:"default_model"`
+	RejectUnknownModels bool   `json:"reject_unknown_models"`
+	// ModelMap（JSON 对象）与 ModelMapFile（JSON 或简单 YAML 文件）覆盖内置的模型映射，
+	// 文件每隔 ModelMapReloadMS 检查一次变化并重新加载，0 表示不检查
+	ModelMap         string `json:"model_map"`
+	ModelMapFile     string `json:"model_map_file"`
+	ModelMapReloadMS int    `json:"model_map_reload_ms"`
+	// ModelAliasesFile 持久化通过 /admin/models/aliases 添加的全局模型别名，为空时仅保存在内存中
+	ModelAliasesFile string `json:"model_aliases_file"`
+	// BadOutputRetry 开启后非流式回复疑似损坏（空、只有空白、包含 BadOutputPatterns 中的固定失败提示、代码围栏未闭合）时
+	// 换账号重试一次，BadOutputRetryModel 非空时重试改用该模型
+	BadOutputRetry      bool   `json:"bad_output_retry"`
+	BadOutputPatterns   string `json:"bad_output_patterns"`
+	BadOutputRetryModel string `json:"bad_output_retry_model"`
+	// Plugins 是逗号分隔的 WebAssembly 请求改写插件（.wasm 文件），按顺序执行，见 plugins 包；
+	// PluginTimeoutMS 是插件单次调用的最长时间
+	Plugins         string `json:"plugins"`
+	PluginTimeoutMS int    `json:"plugin_timeout_ms"`
+	// VirtualModels 与 VirtualModelsFile 以 JSON 数组定义虚拟模型，文件优先
+	VirtualModels     string `json:"virtual_models"`
+	VirtualModelsFile string `json:"virtual_models_file"`
+	// MockMode 开启后不连接 You.com，按 MockStyle（echo/lorem/model/update/error/safety）返回合成回复，用于离线开发
+	MockMode  bool   `json:"mock_mode"`
+	MockStyle string `json:"mock_style"`
+	// UpstreamParams 是发送给 You.com 的固定查询参数（默认集合见 upstream_params_config.go），可通过 UPSTREAM_PARAMS 覆盖
+	UpstreamParams []UpstreamParam `json:"upstream_params"`
+	// MockEventDelayMS 是模拟上游每个 SSE 事件之间的间隔，用于观察流式输出与客户端断开
+	MockEventDelayMS int `json:"mock_event_delay_ms"`
+	// SigningAlg（hmac-sha256/ed25519）与 SigningKey 用于对响应签名，密钥为空时不签名
+	SigningAlg string `json:"signing_alg"`
+	SigningKey string `json:"-"`
+	// NormalizeWhitespace 开启后去掉回复开头的空白，并把三个及以上的连续换行压缩为两个
+	NormalizeWhitespace bool `json:"normalize_whitespace"`
+	// 首 token 超时 = 该模型最近 p95 首 token 延迟 × FirstTokenTimeoutFactor，并限制在上下限之间
+	FirstTokenTimeoutMinMS  int     `json:"first_token_timeout_min_ms"`
+	FirstTokenTimeoutMaxMS  int     `json:"first_token_timeout_max_ms"`
+	FirstTokenTimeoutFactor float64 `json:"first_token_timeout_factor"`
+	// CompatMode 为 strict 时拒绝无法处理的请求参数，为 lenient 时静默忽略
+	CompatMode string `json:"compat_mode"`
+	// DebugHeaders 开启后，请求带有 X-U2API-Debug: timing 时以 Server-Timing trailer 返回分阶段耗时
+	DebugHeaders bool `json:"debug_headers"`
+	// FingerprintMode 为 fixed 时使用固定的浏览器指纹，为 sampled 时按账号抽样，见 api/fingerprint.go
+	FingerprintMode string `json:"fingerprint_mode"`
+	// SamplingParams 是逗号分隔的 "OpenAI 参数名[=You.com 参数名]"，列出转发给上游的采样参数
+	SamplingParams string `json:"sampling_params"`
+	// 输出 token 数不超过 NearEmptyTokens 视为近乎为空，连续 AnomalyStreak 次时告警
+	NearEmptyTokens   int    `json:"near_empty_tokens"`
+	AnomalyStreak     int    `json:"anomaly_streak"`
+	AnomalyWebhookURL string `json:"anomaly_webhook_url"`
+	// DailySummaryAt 是每天发送用量汇总的本地时间（HH:MM），为空时不发送
+	DailySummaryAt string `json:"daily_summary_at"`
+	// DailySummaryWebhookURL 非空时每日汇总同时 POST 到该地址
+	DailySummaryWebhookURL string `json:"daily_summary_webhook_url"`
+	// TrustedProxies 是逗号分隔的可信反向代理 IP/CIDR，仅信任来自它们的 X-Forwarded-For / X-Real-IP
+	TrustedProxies string `json:"trusted_proxies"`
+	// FanoutPolicy 决定 n>1 时部分 choice 失败的处理方式：best_effort 返回成功的部分，all_or_nothing 整体失败
+	FanoutPolicy string `json:"fanout_policy"`
+	// MaxChoices 是单个请求允许的最大 n
+	MaxChoices int `json:"max_choices"`
+	// ReportContextBudget 开启后在响应头中报告模型上下文窗口与估算的剩余 token 数
+	ReportContextBudget bool `json:"report_context_budget"`
+	// FeatureFlags 是逗号分隔的功能开关，前缀 "-" 表示关闭，如 "citations,-batching"
+	FeatureFlags string `json:"feature_flags"`
+	// SchemaDriftSampleRate 是抽样校验上游 SSE 事件结构的比例（0~1），0 表示关闭
+	SchemaDriftSampleRate float64 `json:"schema_drift_sample_rate"`
+	SchemaDriftWebhookURL string  `json:"schema_drift_webhook_url"`
+	// ConversationStoreSize 是为 previous_response_id 保留的对话数量，0 表示不保存；
+	// ConversationMaxMessages 是单个对话保留的最大消息数
+	ConversationStoreSize   int `json:"conversation_store_size"`
+	ConversationMaxMessages int `json:"conversation_max_messages"`
+	// CacheByteBudgets 以 JSON 对象按名称设置内存缓存的字节预算（conversations、sessions、uploaded_images），
+	// 如 {"conversations": 33554432}；未设置的缓存只受条目数限制
+	CacheByteBudgets string `json:"cache_byte_budgets"`
+	// SessionStoreSize 是保留的会话数量，SessionMaxFiles 是单个会话保留的最大文件数
+	SessionStoreSize int `json:"session_store_size"`
+	SessionMaxFiles  int `json:"session_max_files"`
+	// ShutdownTimeoutMS 是每个子系统启动或停止的最长时间，HTTP 服务器在此期间等待进行中的请求结束
+	ShutdownTimeoutMS int `json:"shutdown_timeout_ms"`
+	// HiddenModelsFile 持久化通过 DELETE /v1/models/{id} 隐藏的模型，为空时仅保存在内存中
+	HiddenModelsFile string `json:"hidden_models_file"`
+	// FixMojibake 开启后修复上游把 UTF-8 误按 Latin-1/Windows-1252 解码产生的乱码
+	FixMojibake bool `json:"fix_mojibake"`
+	// TagCodeFences 开启后为回复中未标注语言的代码块猜测并补上语言标注
+	TagCodeFences bool `json:"tag_code_fences"`
+	// SystemAsInstructions 开启后 system 消息作为 You.com 的自定义指令发送，而不是放在聊天历史中
+	SystemAsInstructions bool `json:"system_as_instructions"`
+	// ScrubAccountData 开启后从客户端可见的内容中去掉上游事件里的账号信息（邮箱、用户名等）
+	ScrubAccountData bool `json:"scrub_account_data"`
+	// ReasoningMode 是推理模型思考过程（<think> 块）的默认处理方式：inline 原样保留在回复中，exclude 去掉。
+	// 请求可以通过 reasoning.exclude 或 include_reasoning 覆盖
+	ReasoningMode string `json:"reasoning_mode"`
+	// ReasoningDelimiters 是保留思考过程时使用的分隔符：think 保留 <think> 标记，details 使用可折叠的 <details> 块，
+	// custom 使用 ReasoningOpen 与 ReasoningClose（其中的 \n 表示换行）
+	ReasoningDelimiters string `json:"reasoning_delimiters"`
+	ReasoningOpen       string `json:"reasoning_open"`
+	ReasoningClose      string `json:"reasoning_close"`
+	// StructuredRepairAttempts 是非流式结构化输出（response_format）校验失败时最多发起的修复请求次数，0 表示不修复
+	StructuredRepairAttempts int `json:"structured_repair_attempts"`
+	// CallbackAllowedHosts 是逗号分隔的主机名，异步补全的 callback_url 可以指向这些主机的内网或本机地址；
+	// 其他主机解析到回环、私有、链路本地或未指定地址时拒绝
+	CallbackAllowedHosts string `json:"callback_allowed_hosts"`
+	// JobQueueFile 是后台任务队列的 SQLite 数据库文件，为空时任务只保存在内存中
+	JobQueueFile string `json:"job_queue_file"`
+	// JobWorkers 是并发执行后台任务的 worker 数量
+	JobWorkers int `json:"job_workers"`
+	// JobVisibilityTimeoutMS 是任务被取出后的租约时长，超时未完成（例如进程崩溃）时重新执行
+	JobVisibilityTimeoutMS int `json:"job_visibility_timeout_ms"`
+	// JobMaxAttempts 是任务进入死信状态前的最大执行次数
+	JobMaxAttempts int `json:"job_max_attempts"`
+	// JobRetryBackoffMS 是任务第一次失败后的重试等待时间，之后每次翻倍
+	JobRetryBackoffMS int `json:"job_retry_backoff_ms"`
+	// JobDoneRetentionMS 是已完成任务及其结果的保留时长，0 表示一直保留
+	JobDoneRetentionMS int `json:"job_done_retention_ms"`
+	// JobDeadRetentionMS 是死信任务的保留时长，0 表示一直保留
+	JobDeadRetentionMS int `json:"job_dead_retention_ms"`
+	// TokenPoolFile 定义 DS token 账号池及各账号的可用时间段，客户端使用 PoolAccessKey 认证时从池中选择账号
+	TokenPoolFile string `json:"token_pool_file"`
+	PoolAccessKey string `json:"-"`
+	// StateEncryptionKey 是导出/导入运行状态归档使用的 AES-256 密钥（base64 编码的 32 字节），为空时禁用该功能
+	StateEncryptionKey string `json:"-"`
+	// CoalesceRequests 开启后同时到达的相同非流式请求只请求一次上游，结果共享给所有等待者
+	CoalesceRequests bool `json:"coalesce_requests"`
+	// MaxResponseBytes 与 MaxResponseTokens 限制单次补全的输出大小（0 为不限制），超出时以 finish_reason "length" 结束；
+	// MaxResponseKeyLimits 以 JSON 对象按 key ID 设置更严格的限制，如 {"<key_id>": {"bytes": 20000, "tokens": 4000}}
+	MaxResponseBytes     int    `json:"max_response_bytes"`
+	MaxResponseTokens    int    `json:"max_response_tokens"`
+	MaxResponseKeyLimits string `json:"max_response_key_limits"`
+	// SSEMaxEventBytes 是上游单个 SSE 事件的最大字节数，超出的事件（如过大的搜索结果）会被跳过而不中断回复
+	SSEMaxEventBytes int `json:"sse_max_event_bytes"`
+	// SSEGzip 开启后，流式响应在客户端的 Accept-Encoding 包含 gzip 时压缩发送，每个事件之后刷新
+	SSEGzip bool `json:"sse_gzip"`
+	// AutoModel 控制虚拟模型 auto 按请求内容选择上游模型的规则
+	AutoModel AutoModelConfig `json:"auto_model"`
+	// ModelDiscovery 控制从 You.com 动态发现模型列表
+	ModelDiscovery ModelDiscoveryConfig `json:"model_discovery"`
+	// StreamResume 控制流式响应的 SSE retry 字段与 Last-Event-ID 断线重连
+	StreamResume StreamResumeConfig `json:"stream_resume"`
+	// TokenState 控制账号池中账号的冷却与并发上限，以及通过 Redis 在多个实例间共享
+	TokenState TokenStateConfig `json:"token_state"`
+	// FairQueue 控制没有空闲账号时按租户公平排队
+	FairQueue FairQueueConfig `json:"fair_queue"`
+	// OIDC 控制以 OIDC 签发的 JWT 认证客户端
+	OIDC OIDCConfig `json:"oidc"`
+	// Tracing 控制以 OpenTelemetry 导出补全各阶段的 span
+	Tracing TracingConfig `json:"tracing"`
+	// 其他配置项...
 }
 
 func Load() (*Config, error) {
-    config := &Config{
-        Port:     8080,
-        LogLevel: "info",
-        Proxy: ProxyConfig{
-            EnableProxy:     getEnvBool("ENABLE_PROXY", false),
-            ProxyURL:       getEnv("PROXY_URL", ""),
-            ProxyTimeoutMS: getEnvInt("PROXY_TIMEOUT_MS", 5000),
-        },
-    }
-    return config, nil
+	config := &Config{
+		Port:     8080,
+		LogLevel: getEnv("LOG_LEVEL", "info"),
+		Proxy: ProxyConfig{
+			EnableProxy:    getEnvBool("ENABLE_PROXY", false),
+			ProxyURL:       getEnv("PROXY_URL", ""),
+			ProxyTimeoutMS: getEnvInt("PROXY_TIMEOUT_MS", 5000),
+		},
+		Canary: CanaryConfig{
+			CanaryURL:        getEnv("CANARY_URL", ""),
+			CanaryWeight:     getEnvFloat("CANARY_WEIGHT", 0),
+			HealthPath:       getEnv("CANARY_HEALTH_PATH", "/"),
+			HealthIntervalMS: getEnvInt("CANARY_HEALTH_INTERVAL_MS", 10000),
+			CanaryTimeoutMS:  getEnvInt("CANARY_TIMEOUT_MS", 3000),
+		},
+		AdminKey:         getEnv("ADMIN_KEY", ""),
+		AdminReadOnlyKey: getEnv("ADMIN_READONLY_KEY", ""),
+		Audit: AuditConfig{
+			EnableAudit: getEnvBool("ENABLE_AUDIT", false),
+			AuditSize:   getEnvInt("AUDIT_SIZE", 200),
+			AuditFile:   getEnv("AUDIT_FILE", ""),
+		},
+		Corpus: CorpusConfig{
+			Dir:        getEnv("CORPUS_DIR", ""),
+			SampleRate: getEnvFloat("CORPUS_SAMPLE_RATE", 0.01),
+		},
+		KeyAliasesFile:           getEnv("KEY_ALIASES_FILE", ""),
+		UpstreamRetries:          getEnvInt("UPSTREAM_RETRIES", 1),
+		DefaultModel:             getEnv("DEFAULT_MODEL", "deepseek-chat"),
+		RejectUnknownModels:      getEnvBool("REJECT_UNKNOWN_MODELS", false),
+		ModelMap:                 getEnv("MODEL_MAP", ""),
+		ModelMapFile:             getEnv("MODEL_MAP_FILE", ""),
+		ModelMapReloadMS:         getEnvInt("MODEL_MAP_RELOAD_MS", 5000),
+		ModelAliasesFile:         getEnv("MODEL_ALIASES_FILE", ""),
+		BadOutputRetry:           getEnvBool("BAD_OUTPUT_RETRY", false),
+		BadOutputPatterns:        getEnv("BAD_OUTPUT_PATTERNS", "I'm unable to search right now,I am unable to search right now,Something went wrong. Please try again,An error occurred while generating"),
+		BadOutputRetryModel:      getEnv("BAD_OUTPUT_RETRY_MODEL", ""),
+		Plugins:                  getEnv("PLUGINS", ""),
+		PluginTimeoutMS:          getEnvInt("PLUGIN_TIMEOUT_MS", 1000),
+		VirtualModels:            getEnv("VIRTUAL_MODELS", ""),
+		VirtualModelsFile:        getEnv("VIRTUAL_MODELS_FILE", ""),
+		MockMode:                 getEnvBool("MOCK_MODE", false),
+		MockStyle:                getEnv("MOCK_STYLE", "echo"),
+		MockEventDelayMS:         getEnvInt("MOCK_EVENT_DELAY_MS", 0),
+		SigningAlg:               getEnv("RESPONSE_SIGNING_ALG", "hmac-sha256"),
+		SigningKey:               getEnv("RESPONSE_SIGNING_KEY", ""),
+		NormalizeWhitespace:      getEnvBool("NORMALIZE_WHITESPACE", false),
+		FirstTokenTimeoutMinMS:   getEnvInt("FIRST_TOKEN_TIMEOUT_MIN_MS", 10000),
+		FirstTokenTimeoutMaxMS:   getEnvInt("FIRST_TOKEN_TIMEOUT_MAX_MS", 120000),
+		FirstTokenTimeoutFactor:  getEnvFloat("FIRST_TOKEN_TIMEOUT_FACTOR", 2),
+		CompatMode:               getEnv("COMPAT_MODE", "lenient"),
+		SamplingParams:           getEnv("SAMPLING_PARAMS", "temperature,top_p"),
+		FingerprintMode:          getEnv("FINGERPRINT_MODE", "fixed"),
+		DebugHeaders:             getEnvBool("DEBUG_HEADERS", false),
+		NearEmptyTokens:          getEnvInt("NEAR_EMPTY_TOKENS", 2),
+		AnomalyStreak:            getEnvInt("ANOMALY_STREAK", 5),
+		AnomalyWebhookURL:        getEnv("ANOMALY_WEBHOOK_URL", ""),
+		DailySummaryAt:           getEnv("DAILY_SUMMARY_AT", ""),
+		DailySummaryWebhookURL:   getEnv("DAILY_SUMMARY_WEBHOOK_URL", ""),
+		TrustedProxies:           getEnv("TRUSTED_PROXIES", ""),
+		FanoutPolicy:             getEnv("FANOUT_POLICY", "best_effort"),
+		MaxChoices:               getEnvInt("MAX_CHOICES", 8),
+		ReportContextBudget:      getEnvBool("REPORT_CONTEXT_BUDGET", false),
+		FeatureFlags:             getEnv("FEATURE_FLAGS", ""),
+		SchemaDriftSampleRate:    getEnvFloat("SCHEMA_DRIFT_SAMPLE_RATE", 0.05),
+		SchemaDriftWebhookURL:    getEnv("SCHEMA_DRIFT_WEBHOOK_URL", ""),
+		ConversationStoreSize:    getEnvInt("CONVERSATION_STORE_SIZE", 1000),
+		ConversationMaxMessages:  getEnvInt("CONVERSATION_MAX_MESSAGES", 200),
+		CacheByteBudgets:         getEnv("CACHE_BYTE_BUDGETS", ""),
+		SessionStoreSize:         getEnvInt("SESSION_STORE_SIZE", 1000),
+		SessionMaxFiles:          getEnvInt("SESSION_MAX_FILES", 20),
+		ShutdownTimeoutMS:        getEnvInt("SHUTDOWN_TIMEOUT_MS", 15000),
+		HiddenModelsFile:         getEnv("HIDDEN_MODELS_FILE", ""),
+		FixMojibake:              getEnvBool("FIX_MOJIBAKE", true),
+		TagCodeFences:            getEnvBool("TAG_CODE_FENCES", false),
+		ScrubAccountData:         getEnvBool("SCRUB_ACCOUNT_DATA", true),
+		SystemAsInstructions:     getEnvBool("SYSTEM_AS_INSTRUCTIONS", true),
+		ReasoningMode:            getEnv("REASONING_MODE", "inline"),
+		StructuredRepairAttempts: getEnvInt("STRUCTURED_REPAIR_ATTEMPTS", 1),
+		ReasoningDelimiters:      getEnv("REASONING_DELIMITERS", "think"),
+		ReasoningOpen:            getEnv("REASONING_OPEN", ""),
+		ReasoningClose:           getEnv("REASONING_CLOSE", ""),
+		CallbackAllowedHosts:     getEnv("CALLBACK_ALLOWED_HOSTS", ""),
+		JobQueueFile:             getEnv("JOB_QUEUE_FILE", ""),
+		JobWorkers:               getEnvInt("JOB_WORKERS", 4),
+		JobVisibilityTimeoutMS:   getEnvInt("JOB_VISIBILITY_TIMEOUT_MS", 300000),
+		JobMaxAttempts:           getEnvInt("JOB_MAX_ATTEMPTS", 5),
+		JobRetryBackoffMS:        getEnvInt("JOB_RETRY_BACKOFF_MS", 5000),
+		JobDoneRetentionMS:       getEnvInt("JOB_DONE_RETENTION_MS", 86400000),
+		JobDeadRetentionMS:       getEnvInt("JOB_DEAD_RETENTION_MS", 604800000),
+		TokenPoolFile:            getEnv("TOKEN_POOL_FILE", ""),
+		PoolAccessKey:            getEnv("POOL_ACCESS_KEY", ""),
+		StateEncryptionKey:       getEnv("STATE_ENCRYPTION_KEY", ""),
+		CoalesceRequests:         getEnvBool("COALESCE_REQUESTS", true),
+		MaxResponseBytes:         getEnvInt("MAX_RESPONSE_BYTES", 0),
+		SSEMaxEventBytes:         getEnvInt("SSE_MAX_EVENT_BYTES", 4<<20),
+		SSEGzip:                  getEnvBool("SSE_GZIP", false),
+		MaxResponseTokens:        getEnvInt("MAX_RESPONSE_TOKENS", 0),
+		MaxResponseKeyLimits:     getEnv("MAX_RESPONSE_KEY_LIMITS", ""),
+		AutoModel: AutoModelConfig{
+			Enabled:          getEnvBool("AUTO_MODEL_ENABLED", true),
+			DefaultModel:     getEnv("AUTO_MODEL_DEFAULT", "gpt-4o-mini"),
+			CodeModel:        getEnv("AUTO_MODEL_CODE", "qwen-2.5-coder-32b"),
+			ReasoningModel:   getEnv("AUTO_MODEL_REASONING", "deepseek-reasoner"),
+			LongModel:        getEnv("AUTO_MODEL_LONG", "claude-3.5-sonnet"),
+			LongPromptTokens: getEnvInt("AUTO_MODEL_LONG_PROMPT_TOKENS", 6000),
+			ReasoningMarkers: getEnv("AUTO_MODEL_REASONING_MARKERS", "step by step,prove,proof,derive,think carefully,reason through,逐步,证明,推导,推理"),
+			Fallbacks:        getEnv("AUTO_MODEL_FALLBACKS", "gpt-4o,deepseek-chat"),
+		},
+		ModelDiscovery: ModelDiscoveryConfig{
+			Enabled:   getEnvBool("MODEL_DISCOVERY_ENABLED", false),
+			URL:       getEnv("MODEL_DISCOVERY_URL", "https://you.com/api/get_ai_models"),
+			TTLMS:     getEnvInt("MODEL_DISCOVERY_TTL_MS", 600000),
+			TimeoutMS: getEnvInt("MODEL_DISCOVERY_TIMEOUT_MS", 10000),
+			Prune:     getEnvBool("MODEL_DISCOVERY_PRUNE", false),
+		},
+		StreamResume: StreamResumeConfig{
+			RetryMS:        getEnvInt("SSE_RETRY_MS", 3000),
+			TranscriptSize: getEnvInt("STREAM_TRANSCRIPT_SIZE", 200),
+			GraceMS:        getEnvInt("STREAM_RESUME_GRACE_MS", 10000),
+		},
+		TokenState: TokenStateConfig{
+			RedisURL:         getEnv("REDIS_URL", ""),
+			RedisKeyPrefix:   getEnv("REDIS_KEY_PREFIX", "you2api"),
+			CooldownMS:       getEnvInt("TOKEN_COOLDOWN_MS", 60000),
+			FailureThreshold: getEnvInt("TOKEN_FAILURE_THRESHOLD", 3),
+			MaxConcurrency:   getEnvInt("TOKEN_MAX_CONCURRENCY", 0),
+		},
+		FairQueue: FairQueueConfig{
+			Enabled:           getEnvBool("FAIR_QUEUE_ENABLED", false),
+			MaxWaiting:        getEnvInt("FAIR_QUEUE_MAX_WAITING", 100),
+			TimeoutMS:         getEnvInt("FAIR_QUEUE_TIMEOUT_MS", 30000),
+			TenantWeights:     getEnv("TENANT_WEIGHTS", ""),
+			TrustedTenantKeys: getEnv("TRUSTED_TENANT_KEYS", ""),
+		},
+		OIDC: OIDCConfig{
+			Issuer:        getEnv("OIDC_ISSUER", ""),
+			Audience:      getEnv("OIDC_AUDIENCE", ""),
+			JWKSURL:       getEnv("OIDC_JWKS_URL", ""),
+			ScopePolicies: getEnv("OIDC_SCOPE_POLICIES", ""),
+		},
+		Tracing: TracingConfig{
+			Endpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+			ServiceName: getEnv("OTEL_SERVICE_NAME", "you2api"),
+			SampleRatio: getEnvFloat("OTEL_TRACES_SAMPLE_RATIO", 1),
+		},
+	}
+
+	params, err := parseUpstreamParams(getEnv("UPSTREAM_PARAMS", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.UpstreamParams = params
+	if err := config.Normalize(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Normalize 补全不是由 Load 构造的配置（如嵌入时自行构造的配置）中 Load 会派生的字段，并做与 Load 相同的检查。
+// 未设置 UpstreamParams 时使用默认的固定查询参数。
+func (c *Config) Normalize() error {
+	if c.UpstreamParams == nil {
+		params, err := parseUpstreamParams("")
+		if err != nil {
+			return err
+		}
+		c.UpstreamParams = params
+	}
+	return c.OIDC.Validate()
 }
 
 func getEnv(key, defaultValue string) string {
@@ -46,4 +364,13 @@ func getEnvInt(key string, defaultValue int) int {
         }
     }
     return defaultValue
-} 
\ No newline at end of file
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,10 @@
+package config
+
+// CorpusConfig 控制回归测试语料的采集：按比例抽样保存真实的请求、上游事件流与响应（脱敏后），
+// 之后可以用新构建重放这些语料并比较输出。默认关闭，需要显式设置 Dir。
+type CorpusConfig struct {
+	// Dir 是语料文件的保存目录，为空时不采集
+	Dir string `json:"dir"`
+	// SampleRate 是抽样保存的请求比例（0~1）
+	SampleRate float64 `json:"sample_rate"`
+}
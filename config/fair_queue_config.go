@@ -0,0 +1,16 @@
+package config
+
+// FairQueueConfig 控制账号池的公平排队：没有空闲账号时请求按租户加权公平排队等待，
+// 而不是立即返回 503，一个租户的突发请求不会让其他租户一直拿不到账号。
+type FairQueueConfig struct {
+	Enabled bool `json:"enabled"`
+	// MaxWaiting 是所有租户合计的排队请求数上限，超出时返回 503 queue_full
+	MaxWaiting int `json:"max_waiting"`
+	// TimeoutMS 是单个请求的最长等待时间，超时后返回 503 no_account_available
+	TimeoutMS int `json:"timeout_ms"`
+	// TenantWeights 是 JSON 对象 {"租户": 权重}，未列出的租户权重为 1；权重为 2 的租户获得两倍的账号份额
+	TenantWeights string `json:"tenant_weights"`
+	// TrustedTenantKeys 是逗号分隔的 API key，只有使用这些 key 的请求才能通过 X-Tenant-ID 请求头指定租户
+	// （如在前面完成用户认证的内部网关）；其他请求的租户取自凭据本身，客户端不能冒充权重更高的租户
+	TrustedTenantKeys string `json:"-"`
+}
@@ -0,0 +1,14 @@
+package config
+
+// ModelDiscoveryConfig 控制从 You.com 动态发现模型列表。开启后 /v1/models 使用后台刷新的快照补充上游新增的模型，
+// 快照过期后仍先返回旧快照，同时在后台刷新（stale-while-revalidate），请求不会等待上游；服务运行期间每个 TTL 周期也会主动刷新一次。
+type ModelDiscoveryConfig struct {
+	Enabled bool   `json:"enabled"`
+	URL     string `json:"url"`
+	// TTLMS 是快照的有效期，过期后的第一个请求触发后台刷新
+	TTLMS int `json:"ttl_ms"`
+	// TimeoutMS 是单次刷新请求的超时时间
+	TimeoutMS int `json:"timeout_ms"`
+	// Prune 开启后，内置映射中上游已不再提供的模型不出现在 /v1/models 中，也不算作已知模型
+	Prune bool `json:"prune"`
+}
@@ -0,0 +1,26 @@
+package config
+
+import "errors"
+
+// OIDCConfig 控制客户端 JWT 认证：配置了 Issuer 后，客户端可以使用该签发方签发的 JWT 代替 API key，
+// 校验通过的请求从账号池中选择账号，并按 token 的 scope 限制可用的模型与配额。
+type OIDCConfig struct {
+	// Issuer 是签发方的地址（与 token 的 iss 一致），为空时不启用 JWT 认证
+	Issuer string `json:"issuer"`
+	// Audience 是 token 的 aud 必须包含的值，设置了 Issuer 时必填：否则同一签发方为其他应用签发的 token 也会被接受
+	Audience string `json:"audience"`
+	// JWKSURL 是签名公钥的地址，为空时通过 <Issuer>/.well-known/openid-configuration 发现
+	JWKSURL string `json:"jwks_url"`
+	// ScopePolicies 以 JSON 对象按 scope 设置允许的模型与配额，如
+	// {"chat:basic": {"models": ["gpt-4o-mini"], "requests_per_minute": 20, "max_response_tokens": 2000}}；
+	// 为空时所有校验通过的 token 不受限制，非空时不带任何已配置 scope 的 token 会被拒绝
+	ScopePolicies string `json:"scope_policies"`
+}
+
+// Validate 检查配置是否完整。
+func (c OIDCConfig) Validate() error {
+	if c.Issuer != "" && c.Audience == "" {
+		return errors.New("OIDC_ISSUER is set but OIDC_AUDIENCE is empty: tokens issued for other applications would be accepted")
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package config
+
+import "testing"
+
+func TestLoadRequiresOIDCAudience(t *testing.T) {
+	t.Setenv("OIDC_ISSUER", "https://sso.example")
+	t.Setenv("OIDC_AUDIENCE", "")
+	if _, err := Load(); err == nil {
+		t.Error("Load succeeded with OIDC_ISSUER but no OIDC_AUDIENCE")
+	}
+
+	t.Setenv("OIDC_AUDIENCE", "u2api")
+	if _, err := Load(); err != nil {
+		t.Errorf("Load: %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+package config
+
+// StreamResumeConfig 控制面向客户端的 SSE 断线重连：每个流式事件带有 id，
+// 客户端（EventSource 等）断开后携带 Last-Event-ID 重新请求时，从保存的事件记录中补发之后的事件。
+type StreamResumeConfig struct {
+	// RetryMS 是通过 SSE retry 字段建议客户端等待的重连间隔（毫秒），0 表示不发送
+	RetryMS int `json:"retry_ms"`
+	// TranscriptSize 是保存事件记录的流式补全数量，0 表示不保存，此时不支持断线重连
+	TranscriptSize int `json:"transcript_size"`
+	// GraceMS 是客户端断开后继续生成、等待其重连的时间（毫秒），0 表示断开后立即取消上游请求
+	GraceMS int `json:"grace_ms"`
+}
@@ -0,0 +1,16 @@
+package config
+
+// TokenStateConfig 控制账号池中账号的运行状态：被限流或连续失败后冷却一段时间，以及单个账号的并发上限。
+// 配置了 RedisURL 时多个实例通过 Redis 共享这些状态，Redis 不可用时退回到实例内存中的状态。
+type TokenStateConfig struct {
+	// RedisURL 形如 redis://:password@host:6379/0（TLS 使用 rediss://），为空时只在实例内存中保存状态
+	RedisURL string `json:"-"`
+	// RedisKeyPrefix 是写入 Redis 的键的前缀，多个部署共用一个 Redis 时用于区分
+	RedisKeyPrefix string `json:"redis_key_prefix"`
+	// CooldownMS 是账号被上游限流（429）或连续失败后暂停使用的时间，上游返回 Retry-After 时以其为准
+	CooldownMS int `json:"cooldown_ms"`
+	// FailureThreshold 是触发冷却的连续失败次数，0 表示只在被限流时冷却
+	FailureThreshold int `json:"failure_threshold"`
+	// MaxConcurrency 是单个账号同时进行的补全数量上限（所有实例合计），0 表示不限制
+	MaxConcurrency int `json:"max_concurrency"`
+}
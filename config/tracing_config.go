@@ -0,0 +1,11 @@
+package config
+
+// TracingConfig 控制以 OpenTelemetry 导出补全各阶段的 span。
+type TracingConfig struct {
+	// Endpoint 是 OTLP/HTTP 的导出地址（如 http://otel-collector:4318），为空时不导出 span
+	Endpoint string `json:"endpoint"`
+	// ServiceName 是 span 中的 service.name
+	ServiceName string `json:"service_name"`
+	// SampleRatio 是没有上游 traceparent 时的采样比例；请求带有 traceparent 时沿用调用方的采样决定
+	SampleRatio float64 `json:"sample_ratio"`
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UpstreamParam 是发送给 You.com streamingSearch 的一个固定查询参数。
+// 问题、聊天历史、模型等随请求变化的参数不在此列。
+type UpstreamParam struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description"`
+}
+
+// defaultUpstreamParams 是固定查询参数的默认集合，与网页版 You.com 发送的参数保持一致。
+// 实验性的 UX 开关可能改变上游行为，也会让代理的请求特征与浏览器不同，可以通过 UPSTREAM_PARAMS 关闭。
+var defaultUpstreamParams = []UpstreamParam{
+	{"page", "1", true, "搜索结果页码"},
+	{"count", "10", true, "每页搜索结果数量"},
+	{"safeSearch", "Moderate", true, "安全搜索级别"},
+	{"mkt", "zh-HK", true, "搜索地区"},
+	{"domain", "youchat", true, "请求来源产品"},
+	{"selectedChatMode", "custom", true, "聊天模式，custom 才能指定模型"},
+	{"enable_worklow_generation_ux", "true", true, "实验性的工作流生成界面（参数名的拼写与上游一致）"},
+	{"use_personalization_extraction", "true", true, "从对话中提取个性化信息"},
+	{"enable_agent_clarification_questions", "true", true, "允许模型先提出澄清问题"},
+	{"use_nested_youchat_updates", "true", true, "以 youChatUpdate 事件推送嵌套的更新"},
+}
+
+// parseUpstreamParams 在默认参数集合上应用 UPSTREAM_PARAMS 中逗号分隔的覆盖项：
+//
+//	-name       不发送该参数
+//	+name       发送默认关闭的参数
+//	name=value  修改参数值（不存在时新增）
+func parseUpstreamParams(spec string) ([]UpstreamParam, error) {
+	params := append([]UpstreamParam(nil), defaultUpstreamParams...)
+	index := func(name string) int {
+		for i, p := range params {
+			if p.Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			continue
+		case strings.HasPrefix(item, "-"), strings.HasPrefix(item, "+"):
+			i := index(item[1:])
+			if i < 0 {
+				return nil, fmt.Errorf("UPSTREAM_PARAMS: unknown parameter %q", item[1:])
+			}
+			params[i].Enabled = item[0] == '+'
+		default:
+			name, value, ok := strings.Cut(item, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("UPSTREAM_PARAMS: invalid item %q, want name=value, -name or +name", item)
+			}
+			if i := index(name); i >= 0 {
+				params[i].Value, params[i].Enabled = value, true
+			} else {
+				params = append(params, UpstreamParam{Name: name, Value: value, Enabled: true, Description: "UPSTREAM_PARAMS 新增"})
+			}
+		}
+	}
+	return params, nil
+}
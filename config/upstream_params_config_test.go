@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestParseUpstreamParams(t *testing.T) {
+	params, err := parseUpstreamParams("-use_personalization_extraction, mkt=en-US, extra=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]UpstreamParam)
+	for _, p := range params {
+		got[p.Name] = p
+	}
+	if got["use_personalization_extraction"].Enabled {
+		t.Error("disabled parameter still enabled")
+	}
+	if p := got["mkt"]; p.Value != "en-US" || !p.Enabled {
+		t.Errorf("mkt = %+v", p)
+	}
+	if p := got["extra"]; p.Value != "1" || !p.Enabled {
+		t.Errorf("extra = %+v", p)
+	}
+	if p := got["page"]; p.Value != "1" || !p.Enabled {
+		t.Errorf("untouched default changed: %+v", p)
+	}
+
+	for _, bad := range []string{"-nope", "novalue", "=x"} {
+		if _, err := parseUpstreamParams(bad); err == nil {
+			t.Errorf("parseUpstreamParams(%q) succeeded", bad)
+		}
+	}
+}
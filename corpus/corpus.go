@@ -0,0 +1,77 @@
+// Package corpus 读写回归测试语料。
+//
+// 每个语料文件记录一次真实补全：客户端的请求体、上游返回的原始 SSE 事件流，以及当时客户端收到的响应。
+// 重放时把请求交给新构建处理、上游返回记录的事件流，再与记录的响应比较，
+// 这样请求转换与响应转换中的细微回归可以在发布前发现。语料在保存前已脱敏，不包含凭据。
+package corpus
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Fixture 是一条语料。
+type Fixture struct {
+	// Name 是语料的文件名，不保存在文件中
+	Name     string    `json:"-"`
+	Captured time.Time `json:"captured"`
+	// Headers 是影响响应内容的请求头（如 Accept、Accept-Language）
+	Headers  map[string]string `json:"headers,omitempty"`
+	Request  json.RawMessage   `json:"request"`
+	Upstream string            `json:"upstream"`
+	Response string            `json:"response"`
+}
+
+// Save 把语料写入 dir，文件名由采集时间与 id 组成，返回文件路径。
+func Save(dir, id string, f *Fixture) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", f.Captured.UTC().Format("20060102T150405"), id))
+	return path, os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// Load 读取 dir 中的全部语料（*.json），按文件名排序。
+func Load(dir string) ([]*Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	fixtures := make([]*Fixture, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		f.Name = filepath.Base(path)
+		fixtures = append(fixtures, &f)
+	}
+	return fixtures, nil
+}
+
+var (
+	completionIDPattern = regexp.MustCompile(`"id":"chatcmpl-[^"]*"`)
+	createdPattern      = regexp.MustCompile(`"created":\d+`)
+	eventIDPattern      = regexp.MustCompile(`(?m)^id: chatcmpl-[^:\n]*:`)
+)
+
+// Normalize 去掉响应中每次请求都不同的部分（补全 ID、SSE 事件 ID 中的补全 ID 与创建时间），使记录的响应与重放结果可以直接比较。
+func Normalize(response string) string {
+	response = completionIDPattern.ReplaceAllString(response, `"id":"chatcmpl-*"`)
+	response = eventIDPattern.ReplaceAllString(response, "id: chatcmpl-*:")
+	return createdPattern.ReplaceAllString(response, `"created":0`)
+}
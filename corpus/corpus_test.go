@@ -0,0 +1,87 @@
+package corpus
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "corpus")
+	captured := time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	f := &Fixture{
+		Captured: captured,
+		Headers:  map[string]string{"Accept": "text/plain"},
+		Request:  json.RawMessage(`{"model":"gpt-4o"}`),
+		Upstream: "event: done\ndata: x\n\n",
+		Response: "hello",
+	}
+	path, err := Save(dir, "req1", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "20240501T043000-req1.json"); path != want {
+		t.Errorf("path = %q, want %q (UTC capture time)", path, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("fixture file mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+	// 较早采集的语料排在前面
+	if _, err := Save(dir, "req0", &Fixture{Captured: captured.Add(-time.Hour), Request: json.RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 2 || fixtures[0].Name != "20240501T033000-req0.json" {
+		t.Fatalf("fixtures = %+v, want 2 sorted by name", fixtures)
+	}
+	got := fixtures[1]
+	var request bytes.Buffer
+	json.Compact(&request, got.Request)
+	if got.Name != "20240501T043000-req1.json" || request.String() != `{"model":"gpt-4o"}` ||
+		got.Upstream != f.Upstream || got.Response != f.Response || got.Headers["Accept"] != "text/plain" || !got.Captured.Equal(captured) {
+		t.Errorf("loaded fixture = %+v, want %+v", got, f)
+	}
+}
+
+func TestLoadInvalidFixture(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600)
+	if _, err := Load(dir); err == nil {
+		t.Error("Load accepted an invalid fixture")
+	}
+	if fixtures, err := Load(filepath.Join(dir, "missing")); err != nil || len(fixtures) != 0 {
+		t.Errorf("Load(missing dir) = %v, %v, want no fixtures", fixtures, err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			"completion id and created",
+			`{"id":"chatcmpl-1b2c","object":"chat.completion","created":1714560000}`,
+			`{"id":"chatcmpl-*","object":"chat.completion","created":0}`,
+		},
+		{
+			"sse event ids",
+			"id: chatcmpl-abc:1\ndata: {\"id\":\"chatcmpl-abc\",\"created\":12}\n\nid: chatcmpl-abc:2\ndata: [DONE]\n\n",
+			"id: chatcmpl-*:1\ndata: {\"id\":\"chatcmpl-*\",\"created\":0}\n\nid: chatcmpl-*:2\ndata: [DONE]\n\n",
+		},
+		{"content untouched", "the id: chatcmpl-x is mentioned inline", "the id: chatcmpl-x is mentioned inline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
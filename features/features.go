@@ -0,0 +1,117 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Flag 是一个可独立开关的实验性功能。
+type Flag string
+
+const (
+	Citations            Flag = "citations"             // 在回复中附带引用来源
+	ReasoningPassthrough Flag = "reasoning_passthrough" // 透传推理模型的思考过程
+	SessionStickiness    Flag = "session_stickiness"    // 按 X-Session-ID 自动附加会话中上传的文件
+	Batching             Flag = "batching"              // 支持 n>1 的并行生成
+)
+
+// ErrUnknownFlag 表示功能开关未在注册表中登记。
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// defaults 是每个功能开关的默认值。已经稳定的行为默认开启，新实验默认关闭（暗发布）。
+var defaults = map[Flag]bool{
+	Citations:            false,
+	ReasoningPassthrough: false,
+	SessionStickiness:    true,
+	Batching:             true,
+}
+
+// State 描述功能开关的当前状态。
+type State struct {
+	Enabled bool `json:"enabled"`
+	Default bool `json:"default"`
+	// Overridden 表示该值来自管理接口的运行时修改，而非部署配置
+	Overridden bool `json:"overridden"`
+}
+
+// Registry 保存部署级别的功能开关：先取默认值，再应用部署配置，最后应用运行时覆盖。
+type Registry struct {
+	mu        sync.RWMutex
+	base      map[Flag]bool
+	overrides map[Flag]bool
+}
+
+// NewRegistry 根据部署配置创建注册表。spec 是逗号分隔的开关列表，
+// 前缀 "-" 表示关闭，如 "citations,-batching"。
+func NewRegistry(spec string) (*Registry, error) {
+	r := &Registry{
+		base:      make(map[Flag]bool, len(defaults)),
+		overrides: make(map[Flag]bool),
+	}
+	for flag, enabled := range defaults {
+		r.base[flag] = enabled
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		enabled := !strings.HasPrefix(item, "-")
+		flag := Flag(strings.TrimPrefix(item, "-"))
+		if _, known := defaults[flag]; !known {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFlag, flag)
+		}
+		r.base[flag] = enabled
+	}
+	return r, nil
+}
+
+// Enabled 报告功能是否开启；未登记的功能总是关闭。
+func (r *Registry) Enabled(flag Flag) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if enabled, ok := r.overrides[flag]; ok {
+		return enabled
+	}
+	return r.base[flag]
+}
+
+// Set 在运行时覆盖功能开关，重启后失效。
+func (r *Registry) Set(flag Flag, enabled bool) error {
+	if _, known := defaults[flag]; !known {
+		return fmt.Errorf("%w: %s", ErrUnknownFlag, flag)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overrides[flag] = enabled
+	return nil
+}
+
+// Reset 移除运行时覆盖，恢复部署配置中的值。
+func (r *Registry) Reset(flag Flag) error {
+	if _, known := defaults[flag]; !known {
+		return fmt.Errorf("%w: %s", ErrUnknownFlag, flag)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.overrides, flag)
+	return nil
+}
+
+// Snapshot 返回所有功能开关的当前状态。
+func (r *Registry) Snapshot() map[Flag]State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	states := make(map[Flag]State, len(r.base))
+	for flag, enabled := range r.base {
+		state := State{Enabled: enabled, Default: defaults[flag]}
+		if override, ok := r.overrides[flag]; ok {
+			state.Enabled = override
+			state.Overridden = true
+		}
+		states[flag] = state
+	}
+	return states
+}
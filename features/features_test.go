@@ -0,0 +1,42 @@
+package features
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistry("citations, -batching")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Enabled(Citations) || r.Enabled(Batching) || !r.Enabled(SessionStickiness) {
+		t.Fatalf("unexpected flags from spec: %+v", r.Snapshot())
+	}
+
+	if err := r.Set(Batching, true); err != nil {
+		t.Fatal(err)
+	}
+	if state := r.Snapshot()[Batching]; !state.Enabled || !state.Overridden {
+		t.Errorf("override not applied: %+v", state)
+	}
+	if err := r.Reset(Batching); err != nil {
+		t.Fatal(err)
+	}
+	if r.Enabled(Batching) {
+		t.Error("reset should restore the deployment value")
+	}
+}
+
+func TestUnknownFlags(t *testing.T) {
+	if _, err := NewRegistry("warp_drive"); !errors.Is(err, ErrUnknownFlag) {
+		t.Errorf("NewRegistry error = %v, want ErrUnknownFlag", err)
+	}
+	r, _ := NewRegistry("")
+	if err := r.Set("warp_drive", true); !errors.Is(err, ErrUnknownFlag) {
+		t.Errorf("Set error = %v, want ErrUnknownFlag", err)
+	}
+	if r.Enabled("warp_drive") {
+		t.Error("unknown flags must be disabled")
+	}
+}
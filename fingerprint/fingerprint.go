@@ -0,0 +1,162 @@
+// Package fingerprint 提供一组内部一致的浏览器指纹：User-Agent、客户端提示（sec-ch-ua*）与语言区域相互匹配，
+// 避免出现 macOS 的 User-Agent 搭配 Windows 客户端提示、或香港市场搭配德语等矛盾组合。
+package fingerprint
+
+import (
+	"hash/fnv"
+	"net/http"
+	"strings"
+)
+
+// Profile 是一种浏览器与操作系统的组合。Safari 与 Firefox 不发送客户端提示，ClientHints 为 nil。
+type Profile struct {
+	Name        string
+	Weight      int // 抽样权重，大致对应桌面浏览器的市场份额
+	UserAgent   string
+	ClientHints map[string]string
+}
+
+// Profiles 是可供抽样的指纹，第一项与网页版 Windows Edge 一致，也是固定模式下使用的指纹。
+var Profiles = []Profile{
+	{
+		Name:      "windows-edge",
+		Weight:    20,
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0",
+		ClientHints: map[string]string{
+			"sec-ch-ua":                  `"Not(A:Brand";v="99", "Microsoft Edge";v="133", "Chromium";v="133"`,
+			"sec-ch-ua-platform":         "Windows",
+			"sec-ch-ua-platform-version": "19.0.0",
+			"sec-ch-ua-arch":             "x86",
+			"sec-ch-ua-bitness":          "64",
+			"sec-ch-ua-model":            "",
+			"sec-ch-ua-mobile":           "?0",
+			"sec-ch-ua-full-version":     "133.0.3065.39",
+		},
+	},
+	{
+		Name:      "windows-chrome",
+		Weight:    45,
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+		ClientHints: map[string]string{
+			"sec-ch-ua":                  `"Not(A:Brand";v="99", "Google Chrome";v="133", "Chromium";v="133"`,
+			"sec-ch-ua-platform":         "Windows",
+			"sec-ch-ua-platform-version": "15.0.0",
+			"sec-ch-ua-arch":             "x86",
+			"sec-ch-ua-bitness":          "64",
+			"sec-ch-ua-model":            "",
+			"sec-ch-ua-mobile":           "?0",
+			"sec-ch-ua-full-version":     "133.0.6943.127",
+		},
+	},
+	{
+		Name:      "macos-chrome",
+		Weight:    20,
+		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+		ClientHints: map[string]string{
+			"sec-ch-ua":                  `"Not(A:Brand";v="99", "Google Chrome";v="133", "Chromium";v="133"`,
+			"sec-ch-ua-platform":         "macOS",
+			"sec-ch-ua-platform-version": "15.3.1",
+			"sec-ch-ua-arch":             "arm",
+			"sec-ch-ua-bitness":          "64",
+			"sec-ch-ua-model":            "",
+			"sec-ch-ua-mobile":           "?0",
+			"sec-ch-ua-full-version":     "133.0.6943.127",
+		},
+	},
+	{
+		Name:      "macos-safari",
+		Weight:    10,
+		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.3 Safari/605.1.15",
+	},
+	{
+		Name:      "windows-firefox",
+		Weight:    5,
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:135.0) Gecko/20100101 Firefox/135.0",
+	},
+}
+
+// Pick 按权重选择指纹。相同的 seed 总是得到相同的指纹，同一个账号因此始终像同一个浏览器。
+func Pick(seed string) Profile {
+	total := 0
+	for _, p := range Profiles {
+		total += p.Weight
+	}
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	n := int(h.Sum64() % uint64(total))
+	for _, p := range Profiles {
+		if n < p.Weight {
+			return p
+		}
+		n -= p.Weight
+	}
+	return Profiles[0]
+}
+
+// Apply 把指纹写入请求头：先删除已有的客户端提示，再写入 User-Agent 与本指纹的客户端提示。
+// market 不为空时附带匹配的 Accept-Language。客户端提示的头名称保持小写，与浏览器一致。
+func (p Profile) Apply(h http.Header, market string) {
+	for name := range h {
+		if strings.HasPrefix(strings.ToLower(name), "sec-ch-ua") {
+			delete(h, name)
+		}
+	}
+	for name, value := range p.ClientHints {
+		h[name] = []string{value}
+	}
+	h.Set("User-Agent", p.UserAgent)
+	if lang := AcceptLanguage(market); lang != "" {
+		h.Set("Accept-Language", lang)
+	}
+}
+
+// AcceptLanguage 返回 market（如 zh-HK）对应的 Accept-Language，market 为空时返回空字符串。
+func AcceptLanguage(market string) string {
+	if market == "" {
+		return ""
+	}
+	lang, _, found := strings.Cut(market, "-")
+	if !found || lang == "en" {
+		return market + "," + lang + ";q=0.9"
+	}
+	return market + "," + lang + ";q=0.9,en;q=0.8"
+}
+
+// timezoneMarkets 是时区到 You.com 搜索地区（mkt）的对应关系，未列出的 America/ 时区视为 en-US。
+var timezoneMarkets = map[string]string{
+	"Asia/Hong_Kong":      "zh-HK",
+	"Asia/Macau":          "zh-HK",
+	"Asia/Shanghai":       "zh-CN",
+	"Asia/Chongqing":      "zh-CN",
+	"Asia/Taipei":         "zh-TW",
+	"Asia/Tokyo":          "ja-JP",
+	"Asia/Seoul":          "ko-KR",
+	"Asia/Singapore":      "en-SG",
+	"Asia/Kolkata":        "en-IN",
+	"Europe/London":       "en-GB",
+	"Europe/Dublin":       "en-IE",
+	"Europe/Berlin":       "de-DE",
+	"Europe/Vienna":       "de-AT",
+	"Europe/Zurich":       "de-CH",
+	"Europe/Paris":        "fr-FR",
+	"Europe/Madrid":       "es-ES",
+	"Europe/Rome":         "it-IT",
+	"Europe/Amsterdam":    "nl-NL",
+	"Australia/Sydney":    "en-AU",
+	"Australia/Melbourne": "en-AU",
+	"America/Toronto":     "en-CA",
+	"America/Vancouver":   "en-CA",
+	"America/Mexico_City": "es-MX",
+	"America/Sao_Paulo":   "pt-BR",
+}
+
+// MarketForTimezone 返回 IANA 时区对应的搜索地区，无法判断时返回空字符串。
+func MarketForTimezone(tz string) string {
+	if market, ok := timezoneMarkets[tz]; ok {
+		return market
+	}
+	if strings.HasPrefix(tz, "America/") {
+		return "en-US"
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package fingerprint
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestPick(t *testing.T) {
+	if Pick("account-1").Name != Pick("account-1").Name {
+		t.Fatal("Pick is not deterministic")
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[Pick(strconv.Itoa(i)).Name]++
+	}
+	for _, p := range Profiles {
+		// 权重为 w 的指纹大约被选中 w% 次
+		if got := counts[p.Name]; got < p.Weight*100/2 || got > p.Weight*100*2 {
+			t.Errorf("%s picked %d times out of 10000, weight %d", p.Name, got, p.Weight)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	h := http.Header{}
+	Profiles[0].Apply(h, "")
+	if h["sec-ch-ua-platform"][0] != "Windows" || h.Get("Accept-Language") != "" {
+		t.Fatalf("headers = %v", h)
+	}
+
+	var safari Profile
+	for _, p := range Profiles {
+		if p.Name == "macos-safari" {
+			safari = p
+		}
+	}
+	safari.Apply(h, "en-GB")
+	if _, ok := h["sec-ch-ua-platform"]; ok {
+		t.Errorf("safari kept client hints: %v", h)
+	}
+	if got := h.Get("Accept-Language"); got != "en-GB,en;q=0.9" {
+		t.Errorf("Accept-Language = %q", got)
+	}
+}
+
+func TestMarketForTimezone(t *testing.T) {
+	tests := map[string]string{
+		"Asia/Hong_Kong":  "zh-HK",
+		"America/Chicago": "en-US",
+		"Europe/Berlin":   "de-DE",
+		"UTC":             "",
+	}
+	for tz, want := range tests {
+		if got := MarketForTimezone(tz); got != want {
+			t.Errorf("MarketForTimezone(%q) = %q, want %q", tz, got, want)
+		}
+	}
+	if got := AcceptLanguage("zh-HK"); got != "zh-HK,zh;q=0.9,en;q=0.8" {
+		t.Errorf("AcceptLanguage(zh-HK) = %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 面向客户端的错误信息目录。消息以英文格式字符串作为 ID，英文是默认语言，无需登记；
+// 其它语言通过 Register 登记译文，缺少译文的消息回退为英文原文。
+
+// Fallback 是客户端未指定或指定的语言都不受支持时使用的语言。
+const Fallback = "en"
+
+var (
+	mu       sync.RWMutex
+	catalogs = map[string]map[string]string{Fallback: {}}
+)
+
+// Register 登记某种语言的译文，键为英文格式字符串。可以多次调用以补充译文。
+func Register(lang string, messages map[string]string) {
+	lang = strings.ToLower(lang)
+	mu.Lock()
+	defer mu.Unlock()
+	catalog, ok := catalogs[lang]
+	if !ok {
+		catalog = make(map[string]string, len(messages))
+		catalogs[lang] = catalog
+	}
+	for id, text := range messages {
+		catalog[id] = text
+	}
+}
+
+// Match 按 Accept-Language 头中的权重选择最合适的已登记语言。
+// zh-CN 等带地区的标签在没有精确匹配时回退到主语言 zh。
+func Match(acceptLanguage string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, t := range tags {
+		if t.tag == "*" {
+			return Fallback
+		}
+		if _, ok := catalogs[t.tag]; ok {
+			return t.tag
+		}
+		if primary, _, ok := strings.Cut(t.tag, "-"); ok {
+			if _, ok := catalogs[primary]; ok {
+				return primary
+			}
+		}
+	}
+	return Fallback
+}
+
+// Sprintf 用指定语言格式化消息，没有译文时使用英文格式字符串。
+// 译文可以使用 %[n]d 这样的显式参数序号调整参数顺序。
+func Sprintf(lang, format string, args ...interface{}) string {
+	mu.RLock()
+	translated, ok := catalogs[lang][format]
+	mu.RUnlock()
+	if ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
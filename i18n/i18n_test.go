@@ -0,0 +1,40 @@
+package i18n
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	Register("zh", map[string]string{"Invalid request body": "请求体无效"})
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh"},
+		{"en-US,en;q=0.9,zh;q=0.8", "en"},
+		{"fr-FR, zh;q=0.5", "zh"},
+		{"zh;q=0.2, en;q=0.7", "en"},
+		{"zh;q=0, fr", "en"},
+		{"*", "en"},
+		{"ZH-tw", "zh"},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.header); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	Register("zh", map[string]string{"%d of %d choices failed": "%[2]d 个候选中有 %[1]d 个失败"})
+
+	if got := Sprintf("zh", "%d of %d choices failed", 1, 3); got != "3 个候选中有 1 个失败" {
+		t.Errorf("translated = %q", got)
+	}
+	if got := Sprintf("zh", "Untranslated: %s", "x"); got != "Untranslated: x" {
+		t.Errorf("fallback = %q", got)
+	}
+	if got := Sprintf("en", "100% literal"); got != "100% literal" {
+		t.Errorf("no args = %q", got)
+	}
+}
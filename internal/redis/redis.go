@@ -0,0 +1,241 @@
+// Package redis 是一个最小的 Redis 客户端，只实现多实例协调需要的部分：
+// RESP2 协议的请求与应答、AUTH 与 SELECT，以及一个简单的空闲连接池。不支持 Pub/Sub、管道与集群。
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nil 表示键不存在（RESP 的空值应答）。
+var Nil = errors.New("redis: nil")
+
+// Error 是 Redis 返回的错误应答，如 "WRONGTYPE ..."，连接本身仍可继续使用。
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// defaultTimeout 是上下文没有截止时间时单条命令的超时时间。
+const defaultTimeout = 2 * time.Second
+
+// maxIdleConns 是连接池保留的空闲连接数。
+const maxIdleConns = 8
+
+// Client 是并发安全的 Redis 客户端。
+type Client struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      bool
+
+	idle chan *conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// New 解析 redis://[user:password@]host[:port][/db] 形式的地址（rediss:// 使用 TLS），不会立即建立连接。
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("redis: unsupported scheme %q", u.Scheme)
+	}
+	c := &Client{addr: u.Host, tls: u.Scheme == "rediss", idle: make(chan *conn, maxIdleConns)}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Do 执行一条命令并返回应答：string、int64、[]interface{}，键不存在时返回 Nil 错误。
+func (c *Client) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.do(ctx, args...)
+	var redisErr Error
+	if err == nil || errors.Is(err, Nil) || errors.As(err, &redisErr) {
+		c.put(cn)
+	} else {
+		cn.Close() // 网络错误后连接的状态未知，不再复用
+	}
+	return reply, err
+}
+
+// Close 关闭所有空闲连接。
+func (c *Client) Close() {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+	var nc net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: defaultTimeout}
+	if c.tls {
+		nc, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.password != "" {
+		auth := []interface{}{"AUTH", c.password}
+		if c.username != "" {
+			auth = []interface{}{"AUTH", c.username, c.password}
+		}
+		if _, err := cn.do(ctx, auth...); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do(ctx, "SELECT", c.db); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+func (cn *conn) do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	cn.SetDeadline(deadline)
+	if _, err := cn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r)
+}
+
+// encodeCommand 把命令编码为 RESP 的 bulk string 数组。
+func encodeCommand(args []interface{}) []byte {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		var s string
+		switch v := arg.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case int:
+			s = strconv.Itoa(v)
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		default:
+			s = fmt.Sprint(v)
+		}
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(s)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, s...)
+		buf = append(buf, "\r\n"...)
+	}
+	return buf
+}
+
+// readReply 读取一个 RESP2 应答。
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed reply %q", line)
+		}
+		if n < 0 {
+			return nil, Nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed reply %q", line)
+		}
+		if n < 0 {
+			return nil, Nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readReply(r)
+			var redisErr Error
+			switch {
+			case errors.Is(err, Nil):
+				continue // 数组中的空值（如 MGET 中不存在的键）保留为 nil
+			case errors.As(err, &redisErr):
+				items[i] = redisErr // 继续读取剩余元素，保持连接可用
+				continue
+			case err != nil:
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
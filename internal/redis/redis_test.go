@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want interface{}
+		err  error
+	}{
+		{"+OK\r\n", "OK", nil},
+		{":42\r\n", int64(42), nil},
+		{"$5\r\nhello\r\n", "hello", nil},
+		{"$-1\r\n", nil, Nil},
+		{"*3\r\n$1\r\na\r\n$-1\r\n:7\r\n", []interface{}{"a", nil, int64(7)}, nil},
+		{"-ERR unknown command\r\n", nil, Error("ERR unknown command")},
+	}
+	for _, tt := range tests {
+		got, err := readReply(bufio.NewReader(strings.NewReader(tt.raw)))
+		if !reflect.DeepEqual(got, tt.want) || !errors.Is(err, tt.err) {
+			t.Errorf("readReply(%q) = %#v, %v", tt.raw, got, err)
+		}
+	}
+}
+
+// TestClientAuthAndSelect 用一个只会按顺序应答的假服务端检查连接建立时发送的命令。
+func TestClientAuthAndSelect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 3)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for _, reply := range []string{"+OK\r\n", "+OK\r\n", "$3\r\nbar\r\n"} {
+			cmd, err := readReply(r)
+			if err != nil {
+				return
+			}
+			received <- strings.Join(toStrings(cmd), " ")
+			conn.Write([]byte(reply))
+		}
+	}()
+
+	c, err := New("redis://:secret@" + ln.Addr().String() + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	got, err := c.Do(context.Background(), "GET", "foo")
+	if err != nil || got != "bar" {
+		t.Fatalf("GET foo = %v, %v", got, err)
+	}
+	for _, want := range []string{"AUTH secret", "SELECT 2", "GET foo"} {
+		if cmd := <-received; cmd != want {
+			t.Errorf("command = %q, want %q", cmd, want)
+		}
+	}
+}
+
+func toStrings(v interface{}) []string {
+	items, _ := v.([]interface{})
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i], _ = item.(string)
+	}
+	return out
+}
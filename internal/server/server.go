@@ -0,0 +1,86 @@
+// Package server 是 HTTP 接口的路由与中间件层。
+//
+// Router 只负责按方法与路径把请求分发给各接口，不知道接口的具体内容；
+// 认证、限流等与业务相关的检查仍在 handler 中完成。
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// route 是一条路由规则。
+type route struct {
+	match   func(r *http.Request) bool
+	handler http.Handler
+}
+
+// Router 按注册顺序依次匹配路由规则，第一条匹配的规则处理请求；都不匹配时交给 Fallback，未设置时返回 404。
+// 注册应在开始处理请求之前完成。
+type Router struct {
+	routes   []route
+	fallback http.Handler
+}
+
+// Handle 注册方法与路径都相同的请求，method 为空时匹配任意方法。
+func (rt *Router) Handle(method, path string, h http.Handler) {
+	rt.Match(func(r *http.Request) bool {
+		return r.URL.Path == path && (method == "" || r.Method == method)
+	}, h)
+}
+
+// HandlePrefix 注册路径以 prefix 开头的请求。
+func (rt *Router) HandlePrefix(prefix string, h http.Handler) {
+	rt.Match(func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, prefix) }, h)
+}
+
+// Match 注册 match 返回 true 的请求，用于路径中带参数的接口。
+func (rt *Router) Match(match func(r *http.Request) bool, h http.Handler) {
+	rt.routes = append(rt.routes, route{match: match, handler: h})
+}
+
+// Fallback 设置没有任何规则匹配时的处理器。
+func (rt *Router) Fallback(h http.Handler) {
+	rt.fallback = h
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, route := range rt.routes {
+		if route.match(r) {
+			route.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	if rt.fallback != nil {
+		rt.fallback.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// Middleware 包装一个处理器。
+type Middleware func(http.Handler) http.Handler
+
+// Chain 依次用中间件包装处理器，第一个中间件在最外层。
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// CORS 允许任意来源以 methods 中的方法跨域访问，并直接响应 OPTIONS 预检请求。
+func CORS(methods string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", "*")
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
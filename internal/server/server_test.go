@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	named := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(name)) })
+	}
+	rt := &Router{}
+	rt.HandlePrefix("/admin/", named("admin"))
+	rt.Handle(http.MethodPost, "/v1/files", named("upload"))
+	rt.Handle("", "/v1/chat/completions", Chain(named("chat"), CORS("GET, POST, OPTIONS")))
+	rt.Fallback(named("status"))
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/admin/pool", "admin"},
+		{http.MethodPost, "/v1/files", "upload"},
+		{http.MethodGet, "/v1/files", "status"},
+		{http.MethodPost, "/v1/chat/completions", "chat"},
+		{http.MethodOptions, "/v1/chat/completions", ""},
+		{http.MethodGet, "/", "status"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package sse 按 HTML 规范（Server-Sent Events）解析事件流。
+//
+// 与逐行匹配 "event: " / "data: " 前缀的做法不同，Reader 支持：
+//   - CRLF、LF、CR 三种换行符
+//   - 冒号后没有空格的字段（"data:xxx"）
+//   - 以冒号开头的注释行
+//   - 多行 data，按规范以 "\n" 拼接
+//   - 在事件之间保持的 id（Last-Event-ID）
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultEvent 是没有 event 字段时的事件类型。
+const DefaultEvent = "message"
+
+// DefaultMaxEventBytes 是单个事件（所有行）的默认最大字节数。
+const DefaultMaxEventBytes = 4 << 20
+
+// ErrEventTooLarge 表示事件超过了最大字节数。超大的事件会被整个跳过，之后可以继续调用 Next 读取后续事件。
+var ErrEventTooLarge = errors.New("sse: event exceeds maximum size")
+
+// Event 是一个完整的事件。
+type Event struct {
+	// ID 是截至该事件的最后一个事件 ID，事件本身没有 id 字段时沿用之前的值
+	ID string
+	// Event 是事件类型，没有 event 字段时为 DefaultEvent
+	Event string
+	// Data 是所有 data 行以 "\n" 拼接的内容
+	Data string
+	// Retry 是 retry 字段指定的重连间隔（毫秒），没有时为 0
+	Retry int
+}
+
+// Reader 从事件流中逐个读取事件。
+type Reader struct {
+	br            *bufio.Reader
+	maxEventBytes int
+	lastID        string
+
+	line       []byte
+	pendingCR  bool // 上一行以 CR 结束，下一行开头的 LF 属于同一个换行符
+	eventBytes int  // 当前事件已读取的字节数
+	tooLarge   bool // 当前事件超过最大字节数，跳过直到事件结束
+}
+
+// NewReader 创建读取 r 的 Reader，单个事件的最大字节数为 DefaultMaxEventBytes。
+func NewReader(r io.Reader) *Reader {
+	return NewReaderSize(r, DefaultMaxEventBytes)
+}
+
+// NewReaderSize 创建读取 r 的 Reader，maxEventBytes 是单个事件的最大字节数，不大于 0 时使用 DefaultMaxEventBytes。
+func NewReaderSize(r io.Reader, maxEventBytes int) *Reader {
+	if maxEventBytes <= 0 {
+		maxEventBytes = DefaultMaxEventBytes
+	}
+	return &Reader{br: bufio.NewReaderSize(r, 64*1024), maxEventBytes: maxEventBytes}
+}
+
+// Next 返回下一个事件。事件流结束时返回 io.EOF；结尾没有空行的不完整事件按规范丢弃。
+// 事件超过最大字节数时返回 ErrEventTooLarge。
+func (r *Reader) Next() (Event, error) {
+	var (
+		eventType string
+		data      strings.Builder
+		hasData   bool
+		retry     int
+	)
+	for {
+		line, n, err := r.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+		if n == 0 {
+			// 空行分派事件；没有 data 的事件只更新 id 与 retry
+			r.eventBytes = 0
+			if r.tooLarge {
+				r.tooLarge = false
+				return Event{}, ErrEventTooLarge
+			}
+			if !hasData {
+				eventType, retry = "", 0
+				continue
+			}
+			if eventType == "" {
+				eventType = DefaultEvent
+			}
+			return Event{ID: r.lastID, Event: eventType, Data: data.String(), Retry: retry}, nil
+		}
+		if r.tooLarge || line[0] == ':' {
+			continue // 正在跳过超大事件，或注释
+		}
+		field, value, _ := strings.Cut(string(line), ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				retry = ms
+			}
+		}
+	}
+}
+
+// LastEventID 返回最近读取到的事件 ID。
+func (r *Reader) LastEventID() string {
+	return r.lastID
+}
+
+// readLine 读取一行（支持 CRLF、LF 与 CR 换行符），返回不含换行符的内容与该行的实际长度。
+// 事件超过最大字节数后不再保存行内容，只返回长度。流结束时最后一行可以没有换行符。
+func (r *Reader) readLine() (line []byte, n int, err error) {
+	if r.pendingCR {
+		// CR 之后的 LF 延迟到读取下一行时才跳过，避免在分派事件前等待下一个字节
+		r.pendingCR = false
+		if b, err := r.br.ReadByte(); err == nil && b != '\n' {
+			r.br.UnreadByte()
+		}
+	}
+	r.line = r.line[:0]
+	for {
+		if r.br.Buffered() == 0 {
+			if _, err := r.br.Peek(1); err != nil {
+				if err == io.EOF && n > 0 {
+					return r.line, n, nil
+				}
+				return nil, 0, err
+			}
+		}
+		chunk, _ := r.br.Peek(r.br.Buffered())
+		end := bytes.IndexAny(chunk, "\r\n")
+		if end < 0 {
+			r.append(chunk)
+			n += len(chunk)
+			r.br.Discard(len(chunk))
+			continue
+		}
+		r.pendingCR = chunk[end] == '\r'
+		r.append(chunk[:end])
+		n += end
+		r.br.Discard(end + 1)
+		return r.line, n, nil
+	}
+}
+
+// append 把行内容计入当前事件，超过最大字节数后丢弃内容并标记整个事件需要跳过。
+func (r *Reader) append(chunk []byte) {
+	r.eventBytes += len(chunk)
+	if r.eventBytes > r.maxEventBytes {
+		r.tooLarge = true
+		r.line = r.line[:0]
+		return
+	}
+	if !r.tooLarge {
+		r.line = append(r.line, chunk...)
+	}
+}
@@ -0,0 +1,95 @@
+package sse
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []Event
+	}{
+		{
+			name:   "you.com style",
+			stream: "id: 1\nevent: youChatToken\ndata: {\"youChatToken\":\"hi\"}\n\nevent: done\ndata: bye\n\n",
+			want: []Event{
+				{ID: "1", Event: "youChatToken", Data: `{"youChatToken":"hi"}`},
+				{ID: "1", Event: "done", Data: "bye"},
+			},
+		},
+		{
+			name:   "crlf and cr line endings",
+			stream: "event: a\r\ndata: 1\r\n\r\nevent: b\rdata: 2\r\r",
+			want:   []Event{{Event: "a", Data: "1"}, {Event: "b", Data: "2"}},
+		},
+		{
+			name:   "no space after colon and comments",
+			stream: ": keep-alive\nevent:a\ndata:x\n\n",
+			want:   []Event{{Event: "a", Data: "x"}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: line 1\ndata:\ndata:  indented\n\n",
+			want:   []Event{{Event: DefaultEvent, Data: "line 1\n\n indented"}},
+		},
+		{
+			name:   "event without data is not dispatched",
+			stream: "event: ping\nretry: 100\n\ndata: x\nretry: 3000\n\n",
+			want:   []Event{{Event: DefaultEvent, Data: "x", Retry: 3000}},
+		},
+		{
+			name:   "incomplete trailing event is discarded",
+			stream: "data: complete\n\ndata: partial",
+			want:   []Event{{Event: DefaultEvent, Data: "complete"}},
+		},
+	}
+	for _, tt := range tests {
+		r := NewReader(strings.NewReader(tt.stream))
+		var got []Event
+		for {
+			ev, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			got = append(got, ev)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReaderMaxEventBytes(t *testing.T) {
+	long := strings.Repeat("x", 200*1024) // 超过 bufio 的缓冲区大小
+	stream := "data: " + long + "\n\n" +
+		"event: serp\ndata: " + strings.Repeat("y", 600) + "\ndata: more\n\n" +
+		"event: done\ndata: ok\n\n"
+	r := NewReaderSize(strings.NewReader(stream), 256*1024)
+
+	ev, err := r.Next()
+	if err != nil || ev.Data != long {
+		t.Fatalf("long event: err %v, got %d bytes", err, len(ev.Data))
+	}
+
+	r = NewReaderSize(strings.NewReader(stream), 512)
+	if _, err := r.Next(); err != ErrEventTooLarge {
+		t.Fatalf("first event: got %v, want ErrEventTooLarge", err)
+	}
+	if _, err := r.Next(); err != ErrEventTooLarge {
+		t.Fatalf("second event: got %v, want ErrEventTooLarge", err)
+	}
+	ev, err = r.Next()
+	if err != nil || ev.Event != "done" || ev.Data != "ok" {
+		t.Fatalf("event after oversized ones: got %+v, %v", ev, err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+}
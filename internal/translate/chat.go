@@ -0,0 +1,154 @@
+// Package translate 把 OpenAI 格式的请求转换为 You.com 网页端的查询参数：模型名称映射、聊天历史与自定义指令。
+//
+// 转换只依赖 pkg/api 中的请求结构，不读取服务配置；是否启用某项转换（如 SYSTEM_AS_INSTRUCTIONS）由调用方决定。
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	api "you2api/pkg/api"
+)
+
+// CustomInstructionsParam 是 You.com 网页端自定义指令的查询参数名。
+const CustomInstructionsParam = "customInstructions"
+
+// 工具调用历史：Agent 在多轮对话中会发送 assistant 消息里的 tool_calls 与 role 为 tool 的调用结果。
+// You.com 的聊天历史只有问答文本，这些内容如果直接丢弃，模型就不知道之前调用过哪些工具、得到了什么结果。
+// 这里把它们序列化为带标记的 JSON 代码块写入历史：调用放在 assistant 的回答中，结果作为下一轮的提问。
+
+// 工具调用历史在聊天记录中的标记。
+const (
+	ToolCallsMarker  = "[tool_calls]"
+	ToolResultMarker = "[tool_result]"
+)
+
+// SetChatQuery 把消息转换为 You.com 的提问与聊天历史查询参数。
+func SetChatQuery(q url.Values, messages []api.Message) {
+	// 快速路径：最常见的单条用户消息请求不需要构建历史，直接拼接与 setChatHistory 结果相同的 JSON，
+	// 耗时与内存分配约为完整构建的三分之一（见 BenchmarkSetChatQuery）
+	if len(messages) == 1 && messages[0].Role == "user" && len(messages[0].ToolCalls) == 0 {
+		question, _ := json.Marshal(messages[0].Content)
+		q.Set("q", messages[0].Content)
+		q.Set("pastChatLength", "0")
+		q.Set("chat", `[{"answer":"","question":`+string(question)+`}]`)
+		return
+	}
+	setChatHistory(q, messages)
+}
+
+// setChatHistory 构建完整的 You.com 聊天历史，SetChatQuery 快速路径之外的请求使用。
+func setChatHistory(q url.Values, messages []api.Message) {
+	// 构建 You.com 聊天历史
+	var chatHistory []map[string]interface{}
+	toolNames := toolCallNames(messages)
+	for _, msg := range messages {
+		content := historyContent(msg, toolNames) // 工具调用与结果转换为带标记的 JSON 块
+		chatMsg := map[string]interface{}{
+			"question": content,
+			"answer":   "",
+		}
+		// 如果是 assistant 的消息, 则交换 question 和 answer
+		if msg.Role == "assistant" {
+			chatMsg["question"] = ""
+			chatMsg["answer"] = content
+		}
+		chatHistory = append(chatHistory, chatMsg)
+	}
+
+	chatHistoryJSON, _ := json.Marshal(chatHistory) // 将聊天历史序列化为 JSON
+
+	q.Set("q", historyContent(messages[len(messages)-1], toolNames)) // 主要查询参数 (最后一条消息)
+	q.Set("pastChatLength", fmt.Sprintf("%d", len(chatHistory)-1))   // 过去的聊天记录长度
+	q.Set("chat", string(chatHistoryJSON))                           // 聊天历史 (JSON 格式)
+}
+
+// IsSystemRole 判断消息是否是系统指令：OpenAI 新模型使用的 developer 角色等同于 system。
+func IsSystemRole(role string) bool {
+	return role == "system" || role == "developer"
+}
+
+// SplitSystemMessages 把 system（含 developer）消息从聊天历史中取出，返回 system 消息的内容与其余消息。
+// 只有 system 消息时保留在聊天历史中，否则没有可以提问的内容。
+func SplitSystemMessages(messages []api.Message) (system []string, chat []api.Message) {
+	chat = make([]api.Message, 0, len(messages))
+	for _, msg := range messages {
+		if !IsSystemRole(msg.Role) {
+			chat = append(chat, msg)
+			continue
+		}
+		if content := strings.TrimSpace(msg.Content); content != "" {
+			system = append(system, content)
+		}
+	}
+	if len(chat) == 0 {
+		return nil, messages
+	}
+	return system, chat
+}
+
+// JoinInstructions 把请求的自定义指令与 system 消息合并为发送给 You.com 的自定义指令。
+func JoinInstructions(instructions string, system []string) string {
+	if instructions != "" {
+		system = append([]string{instructions}, system...)
+	}
+	return strings.Join(system, "\n\n")
+}
+
+// toolCallNames 返回消息历史中工具调用 ID 到函数名的映射，用于补全 tool 消息中缺少的 name。
+func toolCallNames(messages []api.Message) map[string]string {
+	names := make(map[string]string)
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			names[call.ID] = call.Function.Name
+		}
+	}
+	return names
+}
+
+// historyContent 返回消息写入 You.com 聊天历史的文本。普通消息原样返回。
+func historyContent(m api.Message, toolNames map[string]string) string {
+	switch {
+	case m.Role == "tool":
+		name := m.Name
+		if name == "" {
+			name = toolNames[m.ToolCallID]
+		}
+		return ToolResultMarker + "\n" + JSONBlock(map[string]interface{}{
+			"tool_call_id": m.ToolCallID,
+			"name":         name,
+			"content":      jsonOrString(m.Content),
+		})
+	case len(m.ToolCalls) > 0:
+		calls := make([]map[string]interface{}, 0, len(m.ToolCalls))
+		for _, call := range m.ToolCalls {
+			calls = append(calls, map[string]interface{}{
+				"id":        call.ID,
+				"name":      call.Function.Name,
+				"arguments": jsonOrString(call.Function.Arguments),
+			})
+		}
+		block := ToolCallsMarker + "\n" + JSONBlock(calls)
+		if strings.TrimSpace(m.Content) == "" {
+			return block
+		}
+		return m.Content + "\n\n" + block
+	}
+	return m.Content
+}
+
+// JSONBlock 把值格式化为 Markdown 的 JSON 代码块。
+func JSONBlock(v interface{}) string {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return "```json\n" + string(data) + "\n```"
+}
+
+// jsonOrString 在 s 是合法 JSON 时返回解析后的值，使其在代码块中以结构化形式出现，否则返回原字符串。
+func jsonOrString(s string) interface{} {
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package translate
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	api "you2api/pkg/api"
+)
+
+func TestSetChatQuery(t *testing.T) {
+	messages := []api.Message{
+		{Role: "user", Content: "天气如何？"},
+		{Role: "assistant", ToolCalls: []api.ToolCall{{ID: "call_1", Type: "function", Function: api.ToolCallFunction{Name: "weather", Arguments: `{"city":"北京"}`}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: `{"temp":21}`},
+	}
+	q := url.Values{}
+	SetChatQuery(q, messages)
+	if got := q.Get("pastChatLength"); got != "2" {
+		t.Errorf("pastChatLength = %q, want 2", got)
+	}
+	if got := q.Get("q"); !strings.HasPrefix(got, ToolResultMarker) || !strings.Contains(got, `"name": "weather"`) {
+		t.Errorf("q = %q, want a tool result block naming the called function", got)
+	}
+	if chat := q.Get("chat"); !strings.Contains(chat, ToolCallsMarker) {
+		t.Errorf("chat history missing tool calls: %s", chat)
+	}
+}
+
+func TestToolHistoryBlocks(t *testing.T) {
+	call := api.ToolCall{ID: "call_1", Type: "function", Function: api.ToolCallFunction{Name: "weather", Arguments: `{"city":"北京"}`}}
+	names := map[string]string{"call_1": "weather"}
+	tests := []struct {
+		name string
+		msg  api.Message
+		want string
+	}{
+		{
+			name: "plain message unchanged",
+			msg:  api.Message{Role: "user", Content: "hi"},
+			want: "hi",
+		},
+		{
+			name: "tool calls only",
+			msg:  api.Message{Role: "assistant", ToolCalls: []api.ToolCall{call}},
+			want: ToolCallsMarker + "\n```json\n[\n  {\n    \"arguments\": {\n      \"city\": \"北京\"\n    },\n    \"id\": \"call_1\",\n    \"name\": \"weather\"\n  }\n]\n```",
+		},
+		{
+			name: "text before tool calls",
+			msg:  api.Message{Role: "assistant", Content: "Let me check.", ToolCalls: []api.ToolCall{call}},
+			want: "Let me check.\n\n" + ToolCallsMarker + "\n```json\n[\n  {\n    \"arguments\": {\n      \"city\": \"北京\"\n    },\n    \"id\": \"call_1\",\n    \"name\": \"weather\"\n  }\n]\n```",
+		},
+		{
+			name: "result name looked up from the call",
+			msg:  api.Message{Role: "tool", ToolCallID: "call_1", Content: `{"temp":21}`},
+			want: ToolResultMarker + "\n```json\n{\n  \"content\": {\n    \"temp\": 21\n  },\n  \"name\": \"weather\",\n  \"tool_call_id\": \"call_1\"\n}\n```",
+		},
+		{
+			name: "non-JSON result kept as string",
+			msg:  api.Message{Role: "tool", ToolCallID: "call_2", Name: "search", Content: "no results"},
+			want: ToolResultMarker + "\n```json\n{\n  \"content\": \"no results\",\n  \"name\": \"search\",\n  \"tool_call_id\": \"call_2\"\n}\n```",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := historyContent(tt.msg, names); got != tt.want {
+				t.Errorf("historyContent() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolCallsStoredAsAnswer(t *testing.T) {
+	q := url.Values{}
+	SetChatQuery(q, []api.Message{
+		{Role: "user", Content: "天气如何？"},
+		{Role: "assistant", ToolCalls: []api.ToolCall{{ID: "call_1", Function: api.ToolCallFunction{Name: "weather", Arguments: "{}"}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "晴"},
+	})
+	var history []map[string]string
+	if err := json.Unmarshal([]byte(q.Get("chat")), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("history has %d turns, want 3", len(history))
+	}
+	if history[1]["question"] != "" || !strings.HasPrefix(history[1]["answer"], ToolCallsMarker) {
+		t.Errorf("tool calls turn = %v, want calls in the answer", history[1])
+	}
+	if !strings.HasPrefix(history[2]["question"], ToolResultMarker) {
+		t.Errorf("tool result turn = %v, want result as the question", history[2])
+	}
+}
+
+func TestSingleMessageFastPath(t *testing.T) {
+	// 快速路径的结果必须与完整构建历史的结果一致
+	for _, content := range []string{"hi", `带 "引号" 与 <html> & 换行` + "\n", ""} {
+		messages := []api.Message{{Role: "user", Content: content}}
+		q, want := url.Values{}, url.Values{}
+		SetChatQuery(q, messages)
+		setChatHistory(want, messages)
+		if !reflect.DeepEqual(q, want) {
+			t.Errorf("fast path = %v, want %v", q, want)
+		}
+	}
+
+	// 单条非用户消息仍走完整路径
+	q := url.Values{}
+	SetChatQuery(q, []api.Message{{Role: "assistant", Content: "hello"}})
+	if got := q.Get("chat"); got != `[{"answer":"hello","question":""}]` {
+		t.Errorf("assistant-only chat = %s", got)
+	}
+}
+
+func TestSplitSystemMessages(t *testing.T) {
+	system, chat := SplitSystemMessages([]api.Message{
+		{Role: "system", Content: " Rule one. "},
+		{Role: "user", Content: "hi"},
+		{Role: "system", Content: "  "},
+		{Role: "developer", Content: "Rule two."},
+	})
+	if len(system) != 2 || system[0] != "Rule one." || system[1] != "Rule two." {
+		t.Errorf("system = %q, want trimmed non-empty system messages", system)
+	}
+	if len(chat) != 1 || chat[0].Content != "hi" {
+		t.Errorf("chat = %+v, want only the user message", chat)
+	}
+
+	// 只有 system 消息时保留在历史中，否则没有可以提问的内容
+	only := []api.Message{{Role: "system", Content: "Rule."}}
+	if system, chat := SplitSystemMessages(only); system != nil || len(chat) != 1 {
+		t.Errorf("system-only: system = %q, chat = %+v", system, chat)
+	}
+
+	if got := JoinInstructions("Be brief.", []string{"Rule one.", "Rule two."}); got != "Be brief.\n\nRule one.\n\nRule two." {
+		t.Errorf("JoinInstructions() = %q", got)
+	}
+	if got := JoinInstructions("", nil); got != "" {
+		t.Errorf("JoinInstructions() = %q, want empty", got)
+	}
+}
+
+// BenchmarkSetChatQuery 比较单条用户消息走快速路径与完整构建历史的开销。
+func BenchmarkSetChatQuery(b *testing.B) {
+	messages := []api.Message{{Role: "user", Content: "Write a haiku about the sea, and explain the imagery you chose."}}
+	b.Run("fast_path", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			SetChatQuery(url.Values{}, messages)
+		}
+	})
+	b.Run("full_history", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			setChatHistory(url.Values{}, messages)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package translate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// BuiltinModels 是内置的 OpenAI 模型名称到 You.com 模型名称的映射。服务端在其上叠加 MODEL_MAP、MODEL_MAP_FILE 与运行时别名，
+// 调用方不能修改它。
+var BuiltinModels = map[string]string{
+	"deepseek-reasoner":       "deepseek_r1",
+	"deepseek-chat":           "deepseek_v3",
+	"o3-mini-high":            "openai_o3_mini_high",
+	"o3-mini-medium":          "openai_o3_mini_medium",
+	"o1":                      "openai_o1",
+	"o1-mini":                 "openai_o1_mini",
+	"o1-preview":              "openai_o1_preview",
+	"gpt-4o":                  "gpt_4o",
+	"gpt-4o-mini":             "gpt_4o_mini",
+	"gpt-4-turbo":             "gpt_4_turbo",
+	"gpt-3.5-turbo":           "gpt_3.5",
+	"claude-3-opus":           "claude_3_opus",
+	"claude-3-sonnet":         "claude_3_sonnet",
+	"claude-3.5-sonnet":       "claude_3_5_sonnet",
+	"claude-3.5-haiku":        "claude_3_5_haiku",
+	"gemini-1.5-pro":          "gemini_1_5_pro",
+	"gemini-1.5-flash":        "gemini_1_5_flash",
+	"llama-3.2-90b":           "llama3_2_90b",
+	"llama-3.1-405b":          "llama3_1_405b",
+	"mistral-large-2":         "mistral_large_2",
+	"qwen-2.5-72b":            "qwen2p5_72b",
+	"qwen-2.5-coder-32b":      "qwen2p5_coder_32b",
+	"command-r-plus":          "command_r_plus",
+	"claude-3-7-sonnet":       "claude_3_7_sonnet",
+	"claude-3-7-sonnet-think": "claude_3_7_sonnet_thinking",
+}
+
+// MergeModelMaps 依次合并多层映射，后面的层覆盖前面的层，值为空字符串的条目被删除。
+func MergeModelMaps(layers ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, layer := range layers {
+		for openAIModel, youModel := range layer {
+			if youModel == "" {
+				delete(merged, openAIModel)
+			} else {
+				merged[openAIModel] = youModel
+			}
+		}
+	}
+	return merged
+}
+
+// EqualModelMaps 判断两个映射是否完全相同。
+func EqualModelMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseModelMapFile 按扩展名解析模型映射文件。YAML 只支持一层 "key: value" 映射、# 注释与带引号的值。
+func ParseModelMapFile(path string, data []byte) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		var m map[string]string
+		err := json.Unmarshal(data, &m)
+		return m, err
+	}
+	m := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line)
+		}
+		key, value = unquoteYAML(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if i := strings.Index(value, " #"); i >= 0 && !strings.HasPrefix(value, `"`) && !strings.HasPrefix(value, "'") {
+			value = strings.TrimSpace(value[:i])
+		}
+		m[key] = unquoteYAML(value)
+	}
+	return m, scanner.Err()
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
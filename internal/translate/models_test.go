@@ -0,0 +1,32 @@
+package translate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeModelMaps(t *testing.T) {
+	builtin := map[string]string{"gpt-4o": "gpt_4o", "o1": "openai_o1"}
+	file := map[string]string{"gpt-4o": "gpt_4o_2024", "o1": "", "grok-2": "grok_2"}
+	got := MergeModelMaps(builtin, nil, file)
+	want := map[string]string{"gpt-4o": "gpt_4o_2024", "grok-2": "grok_2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeModelMaps = %v, want %v", got, want)
+	}
+}
+
+func TestParseModelMapFile(t *testing.T) {
+	yaml := "# OpenAI -> You.com\n---\ngpt-4o: gpt_4o_2024  # 新版本\n\"o1\": ''\n'grok-2': \"grok_2\"\n"
+	got, err := ParseModelMapFile("models.yaml", []byte(yaml))
+	want := map[string]string{"gpt-4o": "gpt_4o_2024", "o1": "", "grok-2": "grok_2"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("yaml = %v, %v, want %v", got, err, want)
+	}
+	if _, err := ParseModelMapFile("models.yml", []byte("gpt-4o\n")); err == nil {
+		t.Error("yaml line without a colon accepted")
+	}
+	got, err = ParseModelMapFile("models.json", []byte(`{"gpt-4o":"gpt_4o"}`))
+	if err != nil || got["gpt-4o"] != "gpt_4o" {
+		t.Errorf("json = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,178 @@
+package upstream
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenEvent 是 youChatToken 事件的数据，即回复中的一段增量内容。
+type TokenEvent struct {
+	YouChatToken string `json:"youChatToken"`
+}
+
+// UpdateEvent 是 youChatUpdate 事件的数据，Text 为累积的完整回答。
+// 部分模型不发送增量 token，而是反复发送到目前为止的完整回答，并且可能修改已经发送过的中间部分。
+type UpdateEvent struct {
+	Text string `json:"text"`
+}
+
+// 上游事件的 data 可能分成多行：按 SSE 规范，token 中的换行符会让一个 JSON 值分布在多个 data 行上，
+// sse.Reader 以 "\n" 拼接后，JSON 字符串中出现未转义的换行符，标准的 JSON 解析会失败并丢弃整个 token，
+// 代码块因此缺行。DecodeEventData 在解析失败时把字符串内的原始控制字符转义后重试。
+
+// DecodeEventData 解析事件的 JSON 数据，兼容字符串中未转义的换行符与制表符。
+func DecodeEventData(data string, v interface{}) error {
+	err := json.Unmarshal([]byte(data), v)
+	if err == nil || !strings.ContainsAny(data, "\n\r\t") {
+		return err
+	}
+	if retryErr := json.Unmarshal([]byte(escapeRawControlChars(data)), v); retryErr != nil {
+		return err
+	}
+	return nil
+}
+
+// escapeRawControlChars 转义 JSON 字符串字面量中的原始换行符、回车符与制表符，字符串之外的空白保持不变。
+func escapeRawControlChars(data string) string {
+	var b strings.Builder
+	b.Grow(len(data) + 8)
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString && c == '\n':
+			b.WriteString(`\n`)
+			continue
+		case inString && c == '\r':
+			b.WriteString(`\r`)
+			continue
+		case inString && c == '\t':
+			b.WriteString(`\t`)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// IsThinkingEvent 判断事件是否是思考过程 token（如 youChatThinkingToken）。
+// 与搜索事件一样，上游的事件名称并不固定，因此按名称模糊匹配。
+func IsThinkingEvent(event string) bool {
+	lower := strings.ToLower(event)
+	return strings.Contains(lower, "thinking") || strings.Contains(lower, "reasoning")
+}
+
+// thinkingTokenKeys 是思考过程事件数据中表示 token 的字段名，按优先级排列。
+var thinkingTokenKeys = []string{"youChatThinkingToken", "youChatReasoningToken", "thinkingToken", "reasoningToken", "token", "text"}
+
+// ParseThinkingToken 从思考过程事件中提取 token，无法识别时返回空字符串。
+func ParseThinkingToken(data string) string {
+	var fields map[string]interface{}
+	if err := DecodeEventData(data, &fields); err != nil {
+		return ""
+	}
+	for _, key := range thinkingTokenKeys {
+		if token, ok := fields[key].(string); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// IsSearchEvent 判断事件是否可能携带搜索查询与来源。
+// You.com 的搜索相关事件名称并不固定（如 thirdPartySearchResults、youChatSerpResults），因此按名称模糊匹配。
+func IsSearchEvent(event string) bool {
+	lower := strings.ToLower(event)
+	return strings.Contains(lower, "search") || strings.Contains(lower, "serp") || strings.Contains(lower, "quer")
+}
+
+// You.com 在生成失败时发送 youChatError 事件（部分情况下事件名为 error），随后关闭连接而不发送 done。
+// 这类事件转换为 EventError，由调用方决定以错误状态码还是 OpenAI 格式的错误块报告给客户端。
+
+// maxErrorLen 是错误信息中保留的上游原始内容长度。
+const maxErrorLen = 200
+
+// EventError 是上游通过错误事件报告的失败。
+type EventError struct {
+	Message string
+}
+
+func (e *EventError) Error() string {
+	return "upstream reported an error: " + e.Message
+}
+
+// IsErrorEvent 判断事件是否表示上游生成失败。
+func IsErrorEvent(event string) bool {
+	return event == "youChatError" || event == "error"
+}
+
+// ParseError 从错误事件数据中提取错误信息。数据不是 JSON 或没有已知字段时使用原始内容。
+func ParseError(data string) error {
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(data), &fields) == nil {
+		for _, key := range []string{"message", "error", "detail", "youChatError", "text"} {
+			if msg, ok := fields[key].(string); ok && strings.TrimSpace(msg) != "" {
+				return &EventError{Message: Truncate(msg, maxErrorLen)}
+			}
+		}
+	}
+	msg := strings.TrimSpace(data)
+	if msg == "" {
+		msg = "unknown error"
+	}
+	return &EventError{Message: Truncate(msg, maxErrorLen)}
+}
+
+// 终止事件：只有 done 或安全策略拦截事件表示上游正常结束，连接在此之前关闭（EOF）可能是网络故障，
+// 调用方不应以 finish_reason stop 结束。done 的 data 通常是一句固定文本，
+// 部分模型会以 JSON 带上结束原因，其中表示失败的原因按上游错误处理。
+
+// doneReasonKeys 是 done 事件数据中表示结束原因的字段名。
+var doneReasonKeys = []string{"finish_reason", "reason", "status", "stop_reason"}
+
+// IsSafetyEvent 判断事件是否表示回复被上游的安全策略拦截（如 youChatSafety）。
+func IsSafetyEvent(event string) bool {
+	lower := strings.ToLower(event)
+	return strings.Contains(lower, "safety") || strings.Contains(lower, "moderation")
+}
+
+// ParseDone 返回 done 事件对应的 finish_reason；结束原因表示生成失败时返回 EventError。
+func ParseDone(data string) (string, error) {
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(data), &fields) != nil {
+		return "stop", nil
+	}
+	for _, key := range doneReasonKeys {
+		reason, ok := fields[key].(string)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(reason) {
+		case "error", "failed", "failure", "aborted":
+			return "", ParseError(data)
+		case "safety", "content_filter", "moderated", "blocked":
+			return "content_filter", nil
+		case "length", "max_tokens":
+			return "length", nil
+		}
+	}
+	return "stop", nil
+}
+
+// Truncate 把字符串截断到最多 n 字节，且不会截断在多字节字符中间。
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
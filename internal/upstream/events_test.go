@@ -0,0 +1,132 @@
+package upstream
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	sse "you2api/internal/sse"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"error":"Rate limit exceeded"}`, "Rate limit exceeded"},
+		{`{"message":"","detail":"model unavailable"}`, "model unavailable"},
+		{`Internal Server Error`, "Internal Server Error"},
+		{``, "unknown error"},
+	}
+	for _, tt := range tests {
+		err, ok := ParseError(tt.data).(*EventError)
+		if !ok || err.Message != tt.want {
+			t.Errorf("ParseError(%q) = %v, want message %q", tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestParseDone(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{"I'm Mr. Meeseeks. Look at me.", "stop", false},
+		{`{"status":"complete"}`, "stop", false},
+		{`{"finish_reason":"max_tokens"}`, "length", false},
+		{`{"reason":"safety"}`, "content_filter", false},
+		{`{"status":"error","message":"model crashed"}`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDone(tt.data)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseDone(%q) = %q, %v; want %q, error %v", tt.data, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDecodeEventData(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{`{"youChatToken":"a\nb"}`, "a\nb", false},
+		{"{\"youChatToken\":\"a\nb\"}", "a\nb", false},
+		{"{\"youChatToken\":\"x\\\"\n\ty\"}", "x\"\n\ty", false},
+		{"{\n\"youChatToken\":\n\"z\"\n}", "z", false},
+		{`{"youChatToken":`, "", true},
+	}
+	for _, tt := range tests {
+		var token TokenEvent
+		err := DecodeEventData(tt.data, &token)
+		if (err != nil) != tt.wantErr || token.YouChatToken != tt.want {
+			t.Errorf("DecodeEventData(%q) = %q, %v", tt.data, token.YouChatToken, err)
+		}
+	}
+}
+
+// testdata/code_stream.sse 是生成代码时抓取的上游事件流，token 中的换行符使 JSON 分布在多个 data 行上。
+func TestClassifyCapturedCodeStream(t *testing.T) {
+	f, err := os.Open("testdata/code_stream.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var content strings.Builder
+	reader := sse.NewReader(f)
+	for {
+		raw, err := reader.Next()
+		if err != nil {
+			break
+		}
+		ev := Classify(raw)
+		if ev.Kind == KindInvalid {
+			t.Fatalf("token dropped:\n%s", raw.Data)
+		}
+		if ev.Kind == KindToken {
+			content.WriteString(ev.Text)
+		}
+	}
+	want := "Here is an example:\n\n```go\nfunc main() {\n\tf, _ := os.Open(\"in.txt\")\n\ts := bufio.NewScanner(f)\n\tfor s.Scan() {\n\t\tfmt.Println(s.Text())\n\t}\n}\n```"
+	if got := content.String(); got != want {
+		t.Errorf("content =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseThinkingToken(t *testing.T) {
+	tests := []struct {
+		event, data string
+		want        string
+	}{
+		{"youChatThinkingToken", `{"youChatThinkingToken":"step 1"}`, "step 1"},
+		{"youChatReasoningToken", `{"token":"step 2"}`, "step 2"},
+		{"youChatThinkingToken", `not json`, ""},
+	}
+	for _, tt := range tests {
+		if !IsThinkingEvent(tt.event) {
+			t.Errorf("%s: not recognized as a thinking event", tt.event)
+		}
+		if got := ParseThinkingToken(tt.data); got != tt.want {
+			t.Errorf("%s %s: got %q, want %q", tt.event, tt.data, got, tt.want)
+		}
+	}
+	if IsThinkingEvent("youChatToken") {
+		t.Error("youChatToken recognized as a thinking event")
+	}
+}
+
+func TestIsSearchEvent(t *testing.T) {
+	for _, event := range []string{"thirdPartySearchResults", "youChatSerpResults", "searchQueries", "QueryRewrite"} {
+		if !IsSearchEvent(event) {
+			t.Errorf("IsSearchEvent(%q) = false", event)
+		}
+	}
+	for _, event := range []string{"youChatToken", "done", "youChatUpdate"} {
+		if IsSearchEvent(event) {
+			t.Errorf("IsSearchEvent(%q) = true", event)
+		}
+	}
+}
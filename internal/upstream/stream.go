@@ -0,0 +1,152 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	sse "you2api/internal/sse"
+)
+
+// Kind 是分类后的上游事件类别。
+type Kind int
+
+const (
+	KindOther    Kind = iota // 其他事件，只用于结构漂移检测与学习需要清理的账号信息
+	KindInvalid              // 数据无法解析的 youChatToken 或 youChatUpdate，应跳过
+	KindToken                // youChatToken：Text 为增量内容
+	KindUpdate               // youChatUpdate：Text 为累积的完整回答
+	KindThinking             // 思考过程：Text 为 token，无法识别时为空
+	KindSearch               // 搜索相关事件，查询与来源由调用方从 Data 中提取
+	KindDone                 // done：FinishReason 为结束原因
+	KindSafety               // 回复被上游的安全策略拦截
+	KindError                // 上游报告生成失败（错误事件或表示失败的 done）：Err 为 *EventError
+)
+
+// Event 是分类后的上游事件。
+type Event struct {
+	Kind Kind
+	Name string // 原始的事件名称
+	ID   string // 事件 ID，重连时通过 Last-Event-ID 请求断点续传
+	Data string // 原始的事件数据
+	// Text 是 token、累积快照或思考过程的内容
+	Text string
+	// FinishReason 是 done 与安全策略事件对应的 finish_reason
+	FinishReason string
+	Err          error
+}
+
+// Classify 按事件名称分类并解析事件数据。
+func Classify(ev sse.Event) Event {
+	e := Event{Name: ev.Event, ID: ev.ID, Data: ev.Data}
+	switch {
+	case ev.Event == "youChatToken":
+		var token TokenEvent
+		if err := DecodeEventData(ev.Data, &token); err != nil {
+			e.Kind = KindInvalid
+			return e
+		}
+		e.Kind, e.Text = KindToken, token.YouChatToken
+	case ev.Event == "youChatUpdate":
+		var update UpdateEvent
+		if err := DecodeEventData(ev.Data, &update); err != nil || update.Text == "" {
+			e.Kind = KindInvalid
+			return e
+		}
+		e.Kind, e.Text = KindUpdate, update.Text
+	case IsThinkingEvent(ev.Event):
+		e.Kind, e.Text = KindThinking, ParseThinkingToken(ev.Data)
+	case IsSearchEvent(ev.Event):
+		e.Kind = KindSearch
+	case ev.Event == "done":
+		e.Kind = KindDone
+		if e.FinishReason, e.Err = ParseDone(ev.Data); e.Err != nil {
+			e.Kind = KindError
+		}
+	case IsSafetyEvent(ev.Event):
+		e.Kind, e.FinishReason = KindSafety, "content_filter"
+	case IsErrorEvent(ev.Event):
+		e.Kind, e.Err = KindError, ParseError(ev.Data)
+	}
+	return e
+}
+
+// Stream 是一次 streamingSearch 请求返回的事件流。
+type Stream interface {
+	// Next 返回下一个事件，连接正常关闭时返回 io.EOF。收到 done 之前的 io.EOF 说明回复可能不完整。
+	Next() (Event, error)
+	// Close 关闭连接，读完之前关闭会中止上游的生成。
+	Close() error
+}
+
+// Client 发送 streamingSearch 请求并返回事件流。handler 只通过该接口访问补全接口，测试可以替换为其他实现。
+type Client interface {
+	Stream(req *http.Request) (Stream, error)
+}
+
+// HTTPClient 通过 Doer 发送请求，按 SSE 规范读取响应。
+type HTTPClient struct {
+	Doer Doer
+	// CheckStatus 把非 200 响应转换为错误（账号失效、限流等由调用方区分），为 nil 时拒绝所有非 200 响应
+	CheckStatus func(resp *http.Response) error
+	// MaxEventBytes 是单个事件的大小上限，超过的事件被跳过，见 sse.NewReaderSize
+	MaxEventBytes int
+	// OnSkipped 在跳过超过大小上限的事件时调用，可以为 nil
+	OnSkipped func(ctx context.Context)
+}
+
+// Stream 发送请求，响应状态不是 200 时关闭连接并返回 CheckStatus 的错误。
+func (c *HTTPClient) Stream(req *http.Request) (Stream, error) {
+	resp, err := c.Doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	check := c.CheckStatus
+	if check == nil {
+		check = func(resp *http.Response) error {
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("upstream returned status %d", resp.StatusCode)
+			}
+			return nil
+		}
+	}
+	if err := check(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &httpStream{
+		ctx:       req.Context(),
+		body:      resp.Body,
+		reader:    sse.NewReaderSize(resp.Body, c.MaxEventBytes),
+		onSkipped: c.OnSkipped,
+	}, nil
+}
+
+type httpStream struct {
+	ctx       context.Context
+	body      io.ReadCloser
+	reader    *sse.Reader
+	onSkipped func(ctx context.Context)
+}
+
+func (s *httpStream) Next() (Event, error) {
+	for {
+		ev, err := s.reader.Next()
+		if errors.Is(err, sse.ErrEventTooLarge) {
+			if s.onSkipped != nil {
+				s.onSkipped(s.ctx)
+			}
+			continue
+		}
+		if err != nil {
+			return Event{}, err
+		}
+		return Classify(ev), nil
+	}
+}
+
+func (s *httpStream) Close() error {
+	return s.body.Close()
+}
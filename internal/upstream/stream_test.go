@@ -0,0 +1,114 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sse "you2api/internal/sse"
+)
+
+func TestHTTPClientStream(t *testing.T) {
+	body := "event: youChatToken\ndata: {\"youChatToken\":\"Hi\"}\n\n" +
+		"event: youChatToken\ndata: {\"youChatToken\":\"" + strings.Repeat("x", 100) + "\"}\n\n" + // 超过大小上限
+		"event: youChatToken\ndata: {broken\n\n" +
+		"event: thirdPartySearchResults\ndata: {\"query\":\"q\"}\n\n" +
+		"id: 7\nevent: youChatUpdate\ndata: {\"text\":\"Hi there\"}\n\n" +
+		"event: done\ndata: {\"finish_reason\":\"length\"}\n\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	skipped := 0
+	client := &HTTPClient{Doer: server.Client(), MaxEventBytes: 64, OnSkipped: func(context.Context) { skipped++ }}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	stream, err := client.Stream(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var got []Event
+	for {
+		ev, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ev)
+	}
+	want := []Event{
+		{Kind: KindToken, Name: "youChatToken", Text: "Hi"},
+		{Kind: KindInvalid, Name: "youChatToken"},
+		{Kind: KindSearch, Name: "thirdPartySearchResults"},
+		{Kind: KindUpdate, Name: "youChatUpdate", ID: "7", Text: "Hi there"},
+		{Kind: KindDone, Name: "done", ID: "7", FinishReason: "length"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i, ev := range got {
+		w := want[i]
+		if ev.Kind != w.Kind || ev.Name != w.Name || ev.ID != w.ID || ev.Text != w.Text || ev.FinishReason != w.FinishReason {
+			t.Errorf("event %d = %+v, want %+v", i, ev, w)
+		}
+	}
+	if skipped != 1 {
+		t.Errorf("OnSkipped called %d times, want 1", skipped)
+	}
+}
+
+func TestHTTPClientStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	errLimited := errors.New("limited")
+	tests := []struct {
+		name  string
+		check func(*http.Response) error
+		want  error
+	}{
+		{"default", nil, nil},
+		{"custom", func(resp *http.Response) error { return errLimited }, errLimited},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		stream, err := (&HTTPClient{Doer: server.Client(), CheckStatus: tt.check}).Stream(req)
+		if stream != nil || err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: Stream = %v, %v", tt.name, stream, err)
+		}
+	}
+}
+
+func TestClassifyErrors(t *testing.T) {
+	tests := []struct {
+		event, data string
+		kind        Kind
+	}{
+		{"youChatError", `{"error":"boom"}`, KindError},
+		{"done", `{"status":"error","message":"model crashed"}`, KindError},
+		{"youChatSafety", `{}`, KindSafety},
+		{"youChatUpdate", `{"text":""}`, KindInvalid},
+		{"appMetadata", `{}`, KindOther},
+	}
+	for _, tt := range tests {
+		ev := Classify(sse.Event{Event: tt.event, Data: tt.data})
+		if ev.Kind != tt.kind {
+			t.Errorf("%s %s: kind = %d, want %d", tt.event, tt.data, ev.Kind, tt.kind)
+		}
+		var eventErr *EventError
+		if (tt.kind == KindError) != errors.As(ev.Err, &eventErr) {
+			t.Errorf("%s %s: err = %v", tt.event, tt.data, ev.Err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package upstream 是 You.com 网页端接口的客户端层：构建携带 DS token 的请求，
+// 通过 Client 发送补全请求，并把流式响应解析为分类后的事件（Stream）。
+//
+// 这里不依赖服务配置，也不关心 OpenAI 的请求格式：查询参数由 translate 层生成，
+// 浏览器指纹、账号池与重试等策略由 handler 在调用前后处理，回复内容的处理（停止序列、脱敏、
+// 拼接重试等）由 handler 在读取事件时完成。请求通过 Doer 发送，
+// handler 中的 Doer 带有日志、语料采样与 MOCK_MODE 的 Transport。
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// You.com 网页端的接口地址。
+const (
+	StreamingSearchURL = "https://you.com/api/streamingSearch"
+	NonceURL           = "https://you.com/api/get_nonce"
+	UploadURL          = "https://you.com/api/upload"
+)
+
+// Doer 发送上游请求，*http.Client 实现了该接口。
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// NewStreamingRequest 根据查询参数构建 streamingSearch 请求。User-Agent 等浏览器指纹请求头由调用方补充。
+func NewStreamingRequest(ctx context.Context, query url.Values, dsToken string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", StreamingSearchURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header = http.Header{
+		"Cache-Control":  {"no-cache"},
+		"Accept":         {"text/event-stream"}, // 重要：接受 SSE 流
+		"Sec-Fetch-Site": {"same-origin"},
+		"Sec-Fetch-Mode": {"cors"},
+		"Sec-Fetch-Dest": {"empty"},
+		"Host":           {"you.com"},
+	}
+	req.Header.Add("Cookie", CookieHeader(dsToken))
+	return req, nil
+}
+
+// CookieHeader 返回携带 DS token 的 Cookie 请求头。
+func CookieHeader(dsToken string) string {
+	var cookieStrings []string
+	for name, value := range Cookies(dsToken) {
+		cookieStrings = append(cookieStrings, fmt.Sprintf("%s=%s", name, value))
+	}
+	return strings.Join(cookieStrings, ";")
+}
+
+// Cookies 根据提供的 DS token 生成所需的 Cookie。
+func Cookies(dsToken string) map[string]string {
+	return map[string]string{
+		"guest_has_seen_legal_disclaimer": "true",
+		"youchat_personalization":         "true",
+		"DS":                              dsToken, // 关键的 DS token
+		"you_subscription":                "youpro_standard_year", // 示例订阅信息
+		"youpro_subscription":             "true",
+		"ai_model":                        "deepseek_r1", // 示例 AI 模型
+		"youchat_smart_learn":             "true",
+	}
+}
@@ -0,0 +1,310 @@
+package jobqueue
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite" // 注册 sqlite 驱动
+)
+
+// State 是任务的状态。
+type State string
+
+const (
+	Pending State = "pending" // 等待执行（包括等待重试）
+	Running State = "running" // 已被取出，可见性超时前不会再次分发
+	Done    State = "done"    // 执行成功
+	Dead    State = "dead"    // 重试次数用尽，进入死信状态
+)
+
+// ErrNotFound 表示任务不存在。
+var ErrNotFound = errors.New("jobqueue: job not found")
+
+// Job 是队列中的一个任务。Payload 由调用方定义，队列只负责保存。
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	State       State           `json:"state"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	VisibleAt   time.Time       `json:"visible_at"` // 早于该时间不会被取出
+	LastError   string          `json:"last_error,omitempty"`
+	// Result 是执行成功后的结果，已完成的任务在保留期内仍可查询
+	Result    json.RawMessage `json:"result,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Options 是队列的行为参数。
+type Options struct {
+	// VisibilityTimeout 是任务被取出后的租约时长，超时未确认（例如进程崩溃）时任务会重新分发
+	VisibilityTimeout time.Duration
+	// MaxAttempts 是进入死信状态前的最大执行次数
+	MaxAttempts int
+	// RetryBackoff 是第一次失败后的重试等待时间，之后每次翻倍
+	RetryBackoff time.Duration
+	// DoneRetention 是已完成任务的保留时长，0 表示一直保留
+	DoneRetention time.Duration
+	// DeadRetention 是死信任务的保留时长，0 表示一直保留
+	DeadRetention time.Duration
+}
+
+// Queue 是保存在 SQLite 中的持久化任务队列。每次状态变化都在事务中写入数据库，
+// 因此进程重启后未完成的任务会继续执行，已完成与死信任务按保留时长由 Purge 清理。
+type Queue struct {
+	opts Options
+	db   *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id           TEXT PRIMARY KEY,
+	kind         TEXT NOT NULL,
+	payload      BLOB,
+	state        TEXT NOT NULL,
+	attempts     INTEGER NOT NULL,
+	max_attempts INTEGER NOT NULL,
+	visible_at   INTEGER NOT NULL,
+	last_error   TEXT NOT NULL DEFAULT '',
+	result       BLOB,
+	created_at   INTEGER NOT NULL,
+	updated_at   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS jobs_ready ON jobs (state, visible_at);
+`
+
+// jobColumns 是读取任务时的列顺序，与 scanJob 一致。
+const jobColumns = `id, kind, payload, state, attempts, max_attempts, visible_at, last_error, result, created_at, updated_at`
+
+// Open 打开或创建 path 处的 SQLite 数据库，path 为空时只保存在内存中。
+func Open(path string, opts Options) (*Queue, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	dsn := ":memory:"
+	if path != "" {
+		// payload 中可能包含客户端凭据，数据库文件只允许本用户读写（WAL 文件沿用数据库文件的权限）
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+		dsn = "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)&_pragma=busy_timeout(5000)"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写入者；单个连接也保证内存数据库在连接之间不会丢失
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("jobqueue: init %s: %w", path, err)
+	}
+	return &Queue{opts: opts, db: db}, nil
+}
+
+// Close 关闭数据库，之后的操作都会返回错误。
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// Enqueue 添加一个任务，ID 已存在时返回错误。
+func (q *Queue) Enqueue(id, kind string, payload []byte, now time.Time) (*Job, error) {
+	job := &Job{
+		ID:          id,
+		Kind:        kind,
+		Payload:     payload,
+		State:       Pending,
+		MaxAttempts: q.opts.MaxAttempts,
+		VisibleAt:   now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	res, err := q.db.Exec(`INSERT INTO jobs (`+jobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		job.ID, job.Kind, []byte(job.Payload), job.State, job.Attempts, job.MaxAttempts,
+		job.VisibleAt.UnixNano(), job.LastError, nil, job.CreatedAt.UnixNano(), job.UpdatedAt.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("jobqueue: job %q already exists", id)
+	}
+	return job, nil
+}
+
+// Dequeue 取出一个到期的任务（按可见时间先后），并在可见性超时内将其标记为执行中。
+// 执行中但租约已过期的任务同样会被取出。没有可执行的任务（或读取数据库失败）时返回 false。
+func (q *Queue) Dequeue(now time.Time) (*Job, bool) {
+	row := q.db.QueryRow(`UPDATE jobs SET state = ?, attempts = attempts + 1, visible_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs WHERE state IN (?, ?) AND visible_at <= ?
+			ORDER BY visible_at, created_at LIMIT 1
+		)
+		RETURNING `+jobColumns,
+		Running, now.Add(q.opts.VisibilityTimeout).UnixNano(), now.UnixNano(),
+		Pending, Running, now.UnixNano())
+	job, err := scanJob(row)
+	if err != nil {
+		return nil, false
+	}
+	return job, true
+}
+
+// Checkpoint 更新执行中任务的 Payload，重试时可以跳过已经完成的步骤。
+func (q *Queue) Checkpoint(id string, payload []byte, now time.Time) error {
+	return q.update(id, now, func(job *Job) error {
+		job.Payload = payload
+		return nil
+	})
+}
+
+// Complete 把任务标记为成功并保存结果。已完成的任务不会再被取出，保留 DoneRetention 后由 Purge 删除。
+func (q *Queue) Complete(id string, result []byte, now time.Time) error {
+	return q.update(id, now, func(job *Job) error {
+		job.State = Done
+		job.LastError = ""
+		job.Result = result
+		return nil
+	})
+}
+
+// Fail 记录一次失败：尚有重试次数时按指数退避重新排队，否则进入死信状态。
+func (q *Queue) Fail(id string, cause error, now time.Time) error {
+	return q.update(id, now, func(job *Job) error {
+		job.LastError = cause.Error()
+		if job.Attempts >= job.MaxAttempts {
+			job.State = Dead
+			return nil
+		}
+		job.State = Pending
+		job.VisibleAt = now.Add(q.opts.RetryBackoff << (job.Attempts - 1))
+		return nil
+	})
+}
+
+// Release 把执行中的任务立即放回队列且不计入执行次数，用于进程退出时中断的任务。
+func (q *Queue) Release(id string, now time.Time) error {
+	return q.update(id, now, func(job *Job) error {
+		if job.State != Running {
+			return nil
+		}
+		job.State = Pending
+		job.Attempts--
+		job.VisibleAt = now
+		return nil
+	})
+}
+
+// Retry 把死信任务重新放回队列，并重置执行次数。
+func (q *Queue) Retry(id string, now time.Time) error {
+	return q.update(id, now, func(job *Job) error {
+		if job.State != Dead {
+			return fmt.Errorf("jobqueue: job %q is %s, only dead jobs can be retried", id, job.State)
+		}
+		job.State = Pending
+		job.Attempts = 0
+		job.VisibleAt = now
+		return nil
+	})
+}
+
+// Purge 删除超过保留时长的已完成任务与死信任务，返回删除的任务数。
+func (q *Queue) Purge(now time.Time) (int, error) {
+	var total int64
+	for state, retention := range map[State]time.Duration{Done: q.opts.DoneRetention, Dead: q.opts.DeadRetention} {
+		if retention <= 0 {
+			continue
+		}
+		res, err := q.db.Exec(`DELETE FROM jobs WHERE state = ? AND updated_at < ?`, state, now.Add(-retention).UnixNano())
+		if err != nil {
+			return int(total), err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+	}
+	return int(total), nil
+}
+
+// update 在事务中读取任务、调用 fn 修改后写回。fn 返回错误时不做修改。
+func (q *Queue) update(id string, now time.Time, fn func(job *Job) error) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	job, err := scanJob(tx.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := fn(job); err != nil {
+		return err
+	}
+	job.UpdatedAt = now
+	_, err = tx.Exec(`UPDATE jobs SET payload = ?, state = ?, attempts = ?, visible_at = ?, last_error = ?, result = ?, updated_at = ? WHERE id = ?`,
+		[]byte(job.Payload), job.State, job.Attempts, job.VisibleAt.UnixNano(), job.LastError, []byte(job.Result), job.UpdatedAt.UnixNano(), id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Get 按 ID 查找任务。
+func (q *Queue) Get(id string) (*Job, bool) {
+	job, err := scanJob(q.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err != nil {
+		return nil, false
+	}
+	return job, true
+}
+
+// List 按创建时间返回所有任务。
+func (q *Queue) List() []*Job {
+	rows, err := q.db.Query(`SELECT ` + jobColumns + ` FROM jobs ORDER BY created_at`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return jobs
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// scanJob 按 jobColumns 的顺序读取一行。
+func scanJob(row interface{ Scan(dest ...any) error }) (*Job, error) {
+	var (
+		job                             Job
+		payload, result                 []byte
+		visibleAt, createdAt, updatedAt int64
+	)
+	err := row.Scan(&job.ID, &job.Kind, &payload, &job.State, &job.Attempts, &job.MaxAttempts,
+		&visibleAt, &job.LastError, &result, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 {
+		job.Payload = payload
+	}
+	if len(result) > 0 {
+		job.Result = result
+	}
+	job.VisibleAt = time.Unix(0, visibleAt)
+	job.CreatedAt = time.Unix(0, createdAt)
+	job.UpdatedAt = time.Unix(0, updatedAt)
+	return &job, nil
+}
@@ -0,0 +1,187 @@
+package jobqueue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var opts = Options{VisibilityTimeout: time.Minute, MaxAttempts: 2, RetryBackoff: time.Second}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	q, _ := Open("", opts)
+	now := time.Unix(1000, 0)
+	q.Enqueue("a", "test", []byte(`{}`), now)
+
+	job, ok := q.Dequeue(now)
+	if !ok || job.Attempts != 1 {
+		t.Fatalf("Dequeue = %+v, %v", job, ok)
+	}
+	if _, ok := q.Dequeue(now); ok {
+		t.Fatal("running job dequeued again before visibility timeout")
+	}
+
+	q.Fail("a", errors.New("boom"), now)
+	if _, ok := q.Dequeue(now); ok {
+		t.Fatal("failed job dequeued before retry backoff")
+	}
+	if _, ok := q.Dequeue(now.Add(time.Second)); !ok {
+		t.Fatal("failed job not retried after backoff")
+	}
+
+	q.Fail("a", errors.New("boom again"), now)
+	job, _ = q.Get("a")
+	if job.State != Dead || job.LastError != "boom again" {
+		t.Fatalf("job = %+v, want dead", job)
+	}
+
+	if err := q.Retry("a", now); err != nil {
+		t.Fatal(err)
+	}
+	if job, ok := q.Dequeue(now); !ok || job.Attempts != 1 {
+		t.Fatalf("retried job = %+v, %v", job, ok)
+	}
+}
+
+func TestSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	now := time.Unix(1000, 0)
+
+	q, err := Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("done", "test", []byte(`1`), now)
+	q.Enqueue("running", "test", []byte(`2`), now.Add(time.Second))
+	q.Dequeue(now)
+	q.Complete("done", []byte(`"result"`), now)
+	q.Dequeue(now.Add(time.Second))
+	q.Checkpoint("running", []byte(`3`), now)
+	q.Close()
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("database file mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+
+	// 进程崩溃后重新打开：执行中的任务在租约过期后重新分发，已完成的任务保留结果且不再分发
+	q, err = Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if job, ok := q.Get("done"); !ok || job.State != Done || string(job.Result) != `"result"` {
+		t.Errorf("completed job after restart = %+v, %v, want done with its result", job, ok)
+	}
+	if _, ok := q.Dequeue(now.Add(time.Second)); ok {
+		t.Error("running job redelivered before visibility timeout")
+	}
+	job, ok := q.Dequeue(now.Add(2 * time.Minute))
+	if !ok || job.ID != "running" || string(job.Payload) != "3" || job.Attempts != 2 {
+		t.Fatalf("redelivered job = %+v, %v", job, ok)
+	}
+	if job, ok := q.Dequeue(now.Add(time.Hour)); ok && job.ID == "done" {
+		t.Error("completed job dequeued again")
+	}
+}
+
+func TestEnqueueDuplicate(t *testing.T) {
+	q, _ := Open("", opts)
+	now := time.Unix(1000, 0)
+	if _, err := q.Enqueue("a", "test", nil, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue("a", "test", nil, now); err == nil {
+		t.Error("duplicate ID accepted")
+	}
+	if err := q.Complete("missing", nil, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Complete(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	q, _ := Open("", Options{MaxAttempts: 1, DoneRetention: time.Hour, DeadRetention: 24 * time.Hour})
+	now := time.Unix(100000, 0)
+	for _, id := range []string{"done", "dead", "pending"} {
+		q.Enqueue(id, "test", nil, now)
+	}
+	q.Dequeue(now)
+	q.Dequeue(now)
+	q.Complete("done", []byte(`1`), now)
+	q.Fail("dead", errors.New("boom"), now)
+
+	// 保留期内的任务不会被清理，结果仍可查询
+	if n, err := q.Purge(now.Add(30 * time.Minute)); err != nil || n != 0 {
+		t.Fatalf("Purge within retention = %d, %v", n, err)
+	}
+	if job, ok := q.Get("done"); !ok || string(job.Result) != "1" {
+		t.Errorf("done job = %+v, %v, want result kept", job, ok)
+	}
+
+	if n, _ := q.Purge(now.Add(2 * time.Hour)); n != 1 {
+		t.Errorf("Purge after done retention removed %d jobs, want 1", n)
+	}
+	if _, ok := q.Get("done"); ok {
+		t.Error("done job kept after retention")
+	}
+	if _, ok := q.Get("dead"); !ok {
+		t.Error("dead job removed before its retention")
+	}
+
+	if n, _ := q.Purge(now.Add(48 * time.Hour)); n != 1 {
+		t.Errorf("Purge after dead retention removed %d jobs, want 1", n)
+	}
+	if jobs := q.List(); len(jobs) != 1 || jobs[0].ID != "pending" {
+		t.Errorf("remaining jobs = %+v, want only the pending job", jobs)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	q, _ := Open("", opts)
+	now := time.Unix(1000, 0)
+	q.Enqueue("a", "test", nil, now)
+	q.Dequeue(now)
+	q.Release("a", now)
+	if job, ok := q.Dequeue(now); !ok || job.Attempts != 1 {
+		t.Fatalf("released job = %+v, %v", job, ok)
+	}
+}
+
+func TestConcurrentDequeue(t *testing.T) {
+	q, _ := Open("", opts)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 50; i++ {
+		q.Enqueue(fmt.Sprint(i), "test", nil, now)
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, ok := q.Dequeue(now)
+				if !ok {
+					return
+				}
+				mu.Lock()
+				seen[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 50 {
+		t.Errorf("dequeued %d distinct jobs, want 50", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("job %s dequeued %d times", id, n)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Hook 是一个子系统的启动与停止回调，两者都可以为 nil。
+// Start 不应阻塞：需要长期运行的工作应在后台 goroutine 中进行，并在 Stop 中结束。
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Manager 按注册顺序启动子系统，按相反顺序停止，每个回调都有独立的超时。
+type Manager struct {
+	timeout time.Duration
+	hooks   []Hook
+	started int // 已成功启动的 hook 数量
+}
+
+// NewManager 创建生命周期管理器，timeout 是单个回调的最长执行时间。
+func NewManager(timeout time.Duration) *Manager {
+	return &Manager{timeout: timeout}
+}
+
+// Register 注册一个子系统，必须在 Start 之前调用。
+func (m *Manager) Register(h Hook) {
+	m.hooks = append(m.hooks, h)
+}
+
+// Start 依次启动所有子系统。某个子系统启动失败时，已启动的子系统会被停止，并返回该错误。
+func (m *Manager) Start(ctx context.Context) error {
+	for _, h := range m.hooks[m.started:] {
+		if h.Start != nil {
+			if err := m.call(ctx, h.Start); err != nil {
+				err = fmt.Errorf("启动 %s 失败: %w", h.Name, err)
+				if stopErr := m.Stop(ctx); stopErr != nil {
+					err = errors.Join(err, stopErr)
+				}
+				return err
+			}
+		}
+		m.started++
+		log.Printf("子系统 %s 已启动", h.Name)
+	}
+	return nil
+}
+
+// Stop 按启动的相反顺序停止子系统。单个子系统停止失败或超时不影响其他子系统，所有错误合并返回。
+func (m *Manager) Stop(ctx context.Context) error {
+	var errs []error
+	for ; m.started > 0; m.started-- {
+		h := m.hooks[m.started-1]
+		if h.Stop == nil {
+			continue
+		}
+		if err := m.call(ctx, h.Stop); err != nil {
+			errs = append(errs, fmt.Errorf("停止 %s 失败: %w", h.Name, err))
+			continue
+		}
+		log.Printf("子系统 %s 已停止", h.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// call 在超时限制内执行回调；回调未及时返回时不再等待，直接返回超时错误。
+func (m *Manager) call(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStartStopOrder(t *testing.T) {
+	var calls []string
+	hook := func(name string) Hook {
+		return Hook{
+			Name:  name,
+			Start: func(context.Context) error { calls = append(calls, "start "+name); return nil },
+			Stop:  func(context.Context) error { calls = append(calls, "stop "+name); return nil },
+		}
+	}
+	m := NewManager(time.Second)
+	m.Register(hook("a"))
+	m.Register(hook("b"))
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start a", "start b", "stop b", "stop a"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestStartFailureStopsStartedHooks(t *testing.T) {
+	stopped := false
+	m := NewManager(time.Second)
+	m.Register(Hook{Name: "ok", Stop: func(context.Context) error { stopped = true; return nil }})
+	m.Register(Hook{Name: "broken", Start: func(context.Context) error { return errors.New("boom") }})
+
+	if err := m.Start(context.Background()); err == nil {
+		t.Fatal("expected start error")
+	}
+	if !stopped {
+		t.Error("hooks started before the failure should be stopped")
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	m := NewManager(10 * time.Millisecond)
+	m.Register(Hook{Name: "slow", Stop: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+	m.Start(context.Background())
+
+	start := time.Now()
+	if err := m.Stop(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() error = %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Stop should not wait for a hook past its timeout")
+	}
+}
@@ -5,29 +5,44 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-var log *zap.Logger
+var log = zap.NewNop()
 
 func Init(level string) error {
 	config := zap.NewProductionConfig()
 	config.Level.SetLevel(getLogLevel(level))
-	
+
 	logger, err := config.Build()
 	if err != nil {
 		return err
 	}
-	
+
 	log = logger
 	return nil
 }
 
+// L 返回全局 logger；未调用 Init 时返回不输出任何内容的 logger。
+func L() *zap.Logger {
+	return log
+}
+
+// Replace 替换全局 logger 并返回恢复函数，供测试捕获日志输出。
+func Replace(l *zap.Logger) func() {
+	prev := log
+	log = l
+	return func() { log = prev }
+}
+
 func getLogLevel(level string) zapcore.Level {
 	switch level {
 	case "debug":
 		return zapcore.DebugLevel
 	case "info":
 		return zapcore.InfoLevel
-	// ... 其他级别
+	case "warn":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
 	default:
 		return zapcore.InfoLevel
 	}
-} 
\ No newline at end of file
+}
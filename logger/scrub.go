@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted 替换日志中被脱敏的值。
+const Redacted = "<redacted>"
+
+// sensitiveHeaders 中的请求头整体脱敏（Cookie 单独按名称处理）。
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// safeCookies 是已知不含凭据、可以原样记录的 Cookie；其余 Cookie 的值一律脱敏。
+var safeCookies = map[string]bool{
+	"guest_has_seen_legal_disclaimer": true,
+	"youchat_personalization":         true,
+	"you_subscription":                true,
+	"youpro_subscription":             true,
+	"ai_model":                        true,
+	"youchat_smart_learn":             true,
+}
+
+// sensitiveParams 中的查询参数值会被脱敏。
+var sensitiveParams = []string{"token", "key", "secret", "password", "auth", "session", "ds"}
+
+// ScrubHeaders 返回适合写入日志的请求头副本：凭据类请求头被脱敏，Cookie 仅保留名称与安全值。
+func ScrubHeaders(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+	for name, values := range header {
+		canonical := http.CanonicalHeaderKey(name)
+		switch {
+		case canonical == "Cookie":
+			result[canonical] = ScrubCookies(strings.Join(values, "; "))
+		case sensitiveHeaders[canonical]:
+			result[canonical] = Redacted
+		default:
+			result[canonical] = strings.Join(values, ", ")
+		}
+	}
+	return result
+}
+
+// ScrubCookies 对 Cookie 字符串中除安全列表外的所有值进行脱敏。
+func ScrubCookies(cookie string) string {
+	parts := strings.Split(cookie, ";")
+	for i, part := range parts {
+		name, _, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			parts[i] = strings.TrimSpace(part)
+			continue
+		}
+		value := Redacted
+		if safeCookies[name] {
+			value = strings.TrimSpace(part)[len(name)+1:]
+		}
+		parts[i] = name + "=" + value
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ScrubURL 返回脱敏后的 URL 字符串，名称疑似凭据的查询参数值会被替换。
+func ScrubURL(u *url.URL) string {
+	scrubbed := *u
+	scrubbed.User = nil
+	query := scrubbed.Query()
+	for name := range query {
+		if isSensitiveParam(name) {
+			query[name] = []string{Redacted}
+		}
+	}
+	scrubbed.RawQuery = query.Encode()
+	return scrubbed.String()
+}
+
+func isSensitiveParam(name string) bool {
+	lower := strings.ToLower(name)
+	for _, s := range sensitiveParams {
+		// 短名称（如 ds）只做完整匹配，避免误伤 "updates" 之类的参数
+		if len(s) <= 2 {
+			if lower == s || strings.HasPrefix(lower, s+"_") || strings.HasSuffix(lower, "_"+s) {
+				return true
+			}
+			continue
+		}
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	urlPattern    = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	cookiePattern = regexp.MustCompile(`(?i)\b(cookie:\s*)[^\r\n"]+`)
+	dsPattern     = regexp.MustCompile(`\b(DSR?=)[^;\s"]+`)
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer\s+)[^\s"',;]+`)
+)
+
+// ScrubText 清理将要返回给客户端或写入 info 级别日志的文本：
+// secrets 中的值（如 DS token）、Cookie、Bearer 凭据被脱敏，URL 只保留到路径，完整查询字符串不会出现。
+func ScrubText(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if len(secret) >= 4 { // 过短的值替换后反而会破坏正常文本
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+	}
+	s = urlPattern.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return Redacted
+		}
+		return u.Scheme + "://" + u.Host + u.Path
+	})
+	s = cookiePattern.ReplaceAllString(s, "${1}"+Redacted)
+	s = dsPattern.ReplaceAllString(s, "${1}"+Redacted)
+	s = bearerPattern.ReplaceAllString(s, "${1}"+Redacted)
+	return s
+}
+
+// ScrubError 返回脱敏后的错误描述，err 为 nil 时返回空字符串。
+func ScrubError(err error, secrets ...string) string {
+	if err == nil {
+		return ""
+	}
+	return ScrubText(err.Error(), secrets...)
+}
@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testToken = "eyJhbGciOiJIUzI1NiJ9.secret-ds-token"
+
+func TestScrubErrorRedactsUpstreamURL(t *testing.T) {
+	err := &url.Error{
+		Op:  "Get",
+		URL: "https://you.com/api/streamingSearch?q=my+private+question&chat=%5B%5D&selectedAiModel=gpt_4o",
+		Err: errors.New("context deadline exceeded"),
+	}
+	got := ScrubError(err)
+	if strings.Contains(got, "private") || strings.Contains(got, "?") {
+		t.Errorf("query string leaked: %q", got)
+	}
+	if !strings.Contains(got, "https://you.com/api/streamingSearch") || !strings.Contains(got, "context deadline exceeded") {
+		t.Errorf("useful context was lost: %q", got)
+	}
+}
+
+func TestScrubErrorRedactsCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"cookie header", errors.New("request failed, Cookie: youchat_smart_learn=true; DS=" + testToken)},
+		{"ds cookie", errors.New("bad cookie DS=" + testToken + "; ai_model=gpt_4o")},
+		{"bearer", errors.New("Authorization: Bearer " + testToken)},
+		{"userinfo in url", errors.New("dial https://user:" + testToken + "@you.com/api failed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 仅依赖模式匹配，不传入 secrets
+			if got := ScrubError(tt.err); strings.Contains(got, "secret-ds-token") {
+				t.Errorf("ScrubError() = %q, token leaked", got)
+			}
+		})
+	}
+}
+
+func TestScrubErrorRedactsExplicitSecrets(t *testing.T) {
+	got := ScrubError(fmt.Errorf("upstream rejected token %s", testToken), testToken)
+	if strings.Contains(got, testToken) || !strings.Contains(got, Redacted) {
+		t.Errorf("ScrubError() = %q", got)
+	}
+	if ScrubError(nil) != "" {
+		t.Error("ScrubError(nil) should be empty")
+	}
+}
+
+func TestScrubHeadersAndURL(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+testToken)
+	header.Set("Cookie", "ai_model=gpt_4o; DS="+testToken)
+	scrubbed := ScrubHeaders(header)
+	if strings.Contains(scrubbed["Authorization"]+scrubbed["Cookie"], "secret-ds-token") {
+		t.Errorf("ScrubHeaders leaked token: %v", scrubbed)
+	}
+	if !strings.Contains(scrubbed["Cookie"], "ai_model=gpt_4o") {
+		t.Errorf("safe cookie should be kept: %v", scrubbed["Cookie"])
+	}
+
+	u, _ := url.Parse("https://you.com/api?ds_token=" + testToken + "&q=hello")
+	if got := ScrubURL(u); strings.Contains(got, "secret-ds-token") {
+		t.Errorf("ScrubURL() = %q", got)
+	}
+}
+
+func TestScrubHeadersCredentialHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Api-Key", testToken)
+	header.Set("Proxy-Authorization", "Basic "+testToken)
+	header.Set("Set-Cookie", "DS="+testToken+"; Path=/")
+	header.Add("Cookie", "DSR="+testToken)
+	header.Add("Cookie", "you_subscription=free")
+	header.Set("Accept", "text/event-stream")
+
+	scrubbed := ScrubHeaders(header)
+	for name, value := range scrubbed {
+		if strings.Contains(value, "secret-ds-token") {
+			t.Errorf("%s leaked token: %q", name, value)
+		}
+	}
+	if scrubbed["Accept"] != "text/event-stream" {
+		t.Errorf("non-sensitive header changed: %q", scrubbed["Accept"])
+	}
+	if !strings.Contains(scrubbed["Cookie"], "DSR="+Redacted) || !strings.Contains(scrubbed["Cookie"], "you_subscription=free") {
+		t.Errorf("Cookie = %q", scrubbed["Cookie"])
+	}
+}
+
+func TestScrubTextBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"json headers", `{"headers":{"Cookie":"DS=` + testToken + `; ai_model=gpt_4o"}}`},
+		{"json authorization", `{"Authorization":"Bearer ` + testToken + `"}`},
+		{"raw request dump", "GET /api HTTP/1.1\r\nCookie: DS=" + testToken + "\r\nAccept: */*\r\n"},
+		{"form body", "ds=1&DS=" + testToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScrubText(tt.body); strings.Contains(got, "secret-ds-token") {
+				t.Errorf("ScrubText() = %q, token leaked", got)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package metrics
 
 import (
+	"sync/atomic"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -12,8 +15,145 @@ var (
 		},
 		[]string{"method", "endpoint", "status"},
 	)
+
+	OutputTokens = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "completion_output_tokens",
+			Help:    "每次补全输出的 token 数分布",
+			Buckets: []float64{0, 1, 5, 10, 50, 100, 250, 500, 1000, 2000, 4000, 8000},
+		},
+		[]string{"model"},
+	)
+
+	OutputAnomalies = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "completion_output_anomalies_total",
+			Help: "检测到的输出异常次数（如连续出现近乎为空的补全）",
+		},
+		[]string{"model", "kind"},
+	)
+
+	UpstreamSchemaDrift = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "upstream_schema_drift_total",
+			Help: "抽样校验中发现的上游 SSE 事件结构变化次数",
+		},
+		[]string{"kind"},
+	)
+
+	StoreEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "store_evictions_total",
+			Help: "内存存储（会话、对话历史等）因容量上限淘汰的条目数",
+		},
+		[]string{"store"},
+	)
+
+	CacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "内存缓存的查找次数，按是否命中区分",
+		},
+		[]string{"store", "result"},
+	)
+
+	CacheEntries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_entries",
+			Help: "内存缓存当前的条目数",
+		},
+		[]string{"store"},
+	)
+
+	CacheBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_bytes",
+			Help: "内存缓存当前条目的估算字节数",
+		},
+		[]string{"store"},
+	)
+
+	ClientDisconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "client_disconnects_total",
+			Help: "补全完成前客户端断开连接、上游请求被取消的次数",
+		},
+		[]string{"stream"},
+	)
+
+	ModelSnapshotRefreshes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "model_snapshot_refreshes_total",
+			Help: "后台刷新上游模型列表快照的次数",
+		},
+		[]string{"result"},
+	)
+
+	TokenCooldowns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "token_cooldowns_total",
+			Help: "账号池中的账号因限流或连续失败进入冷却的次数",
+		},
+		[]string{"reason"},
+	)
+
+	TokenStateErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "token_state_errors_total",
+			Help: "读写 Redis 中的账号状态失败、改用实例内存状态的次数",
+		},
+	)
+
+	CompletionStageSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "completion_stage_seconds",
+			Help:    "补全各阶段（acquire、parse、connect、ttfb、first_token、complete）的耗时分布",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		},
+		[]string{"stage"},
+	)
+
+	FairQueueWaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "fair_queue_wait_seconds",
+			Help:    "各租户的请求等待账号池名额的时间分布，用于确认排队是否公平；未在 TENANT_WEIGHTS 中列出的租户合并为 other",
+			Buckets: []float64{0, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"tenant"},
+	)
+
+	FairQueueWaiting = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "fair_queue_waiting",
+			Help: "正在排队等待账号池名额的请求数",
+		},
+	)
+
+	ModelSnapshotAge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "model_snapshot_age_seconds",
+			Help: "当前上游模型列表快照距上次成功刷新的秒数，尚未刷新成功时为 -1",
+		},
+		func() float64 {
+			refreshed := modelSnapshotRefreshed.Load()
+			if refreshed == 0 {
+				return -1
+			}
+			return time.Since(time.Unix(0, refreshed)).Seconds()
+		},
+	)
 )
 
+// modelSnapshotRefreshed 是模型列表快照最近一次刷新成功的时间（UnixNano）。
+var modelSnapshotRefreshed atomic.Int64
+
+// SetModelSnapshotRefreshed 记录模型列表快照刷新成功的时间，供 ModelSnapshotAge 计算快照年龄。
+func SetModelSnapshotRefreshed(t time.Time) {
+	modelSnapshotRefreshed.Store(t.UnixNano())
+}
+
 func Init() {
-	prometheus.MustRegister(RequestCounter)
-} 
\ No newline at end of file
+	prometheus.MustRegister(RequestCounter, OutputTokens, OutputAnomalies, UpstreamSchemaDrift, StoreEvictions, ClientDisconnects,
+		ModelSnapshotRefreshes, ModelSnapshotAge, CacheLookups, CacheEntries, CacheBytes, TokenCooldowns, TokenStateErrors,
+		CompletionStageSeconds, FairQueueWaitSeconds, FairQueueWaiting)
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestModelSnapshotAge(t *testing.T) {
+	modelSnapshotRefreshed.Store(0)
+	if got := testutil.ToFloat64(ModelSnapshotAge); got != -1 {
+		t.Errorf("age before first refresh = %v, want -1", got)
+	}
+	SetModelSnapshotRefreshed(time.Now().Add(-time.Minute))
+	if got := testutil.ToFloat64(ModelSnapshotAge); got < 59 || got > 120 {
+		t.Errorf("age = %v, want about 60s", got)
+	}
+}
+
+func TestCollectorsLint(t *testing.T) {
+	// 所有指标都能注册到同一个注册表，且名称与帮助文本符合 Prometheus 规范
+	reg := prometheus.NewPedanticRegistry()
+	collectors := []prometheus.Collector{RequestCounter, OutputTokens, OutputAnomalies, UpstreamSchemaDrift, StoreEvictions, ClientDisconnects,
+		ModelSnapshotRefreshes, ModelSnapshotAge, CacheLookups, CacheEntries, CacheBytes, TokenCooldowns, TokenStateErrors,
+		CompletionStageSeconds, FairQueueWaitSeconds, FairQueueWaiting}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			t.Errorf("register: %v", err)
+		}
+	}
+	OutputAnomalies.WithLabelValues("m", "near_empty_streak").Inc()
+	problems, err := testutil.GatherAndLint(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("lint %s: %s", p.Metric, p.Text)
+	}
+}
@@ -0,0 +1,423 @@
+// Package oidc 校验 OIDC 身份提供方签发的 JWT（access token 或 ID token）。
+//
+// 签名公钥从提供方的 JWKS 获取并缓存：未指定 JWKS 地址时通过 <issuer>/.well-known/openid-configuration 发现；
+// 遇到未知的 kid 时重新拉取（两次拉取至少间隔 RefetchInterval），以支持提供方轮换密钥。
+// 拉取在锁外进行，并发的拉取合并为一次：提供方响应缓慢时，使用已缓存公钥的校验不会被阻塞。
+// 支持 RS256/384/512、PS256/384/512、ES256/384/512 与 EdDSA（Ed25519），不接受 none 与 HMAC 算法。
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Leeway 是校验 exp 与 nbf 时允许的时钟偏差。
+const Leeway = time.Minute
+
+// CacheTTL 是 JWKS 的缓存时间。
+const CacheTTL = time.Hour
+
+// RefetchInterval 是因未知 kid 重新拉取 JWKS 的最小间隔，避免伪造的 kid 导致频繁请求提供方。
+const RefetchInterval = time.Minute
+
+// ErrInvalidToken 表示 token 的格式、签名或声明无效，具体原因包装在错误信息中。
+var ErrInvalidToken = errors.New("oidc: invalid token")
+
+// Claims 是校验通过的 token 中与授权相关的声明。
+type Claims struct {
+	Issuer  string
+	Subject string
+	// Scopes 来自 scope（空格分隔的字符串）或 scp（字符串数组）声明
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// Verifier 校验某个签发方的 token，并发安全。
+type Verifier struct {
+	issuer   string
+	audience string
+	jwksURL  string
+	client   *http.Client
+	now      func() time.Time
+
+	fetch singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewVerifier 创建校验器。audience 为空时不校验 aud（任何客户端的 token 都会被接受，调用方应当要求配置受众）；
+// jwksURL 为空时通过签发方的发现文档获取。
+func NewVerifier(issuer, audience, jwksURL string) *Verifier {
+	return &Verifier{
+		issuer:   strings.TrimRight(issuer, "/"),
+		audience: audience,
+		jwksURL:  jwksURL,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// Recognizes 判断 token 是否声称由该签发方签发（不校验签名），用于与其他凭据区分：
+// You.com 的 DS token 本身也是 JWT，只看格式无法区分。
+func (v *Verifier) Recognizes(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	var payload struct {
+		Iss string `json:"iss"`
+	}
+	return decodeSegment(parts[1], &payload) == nil && strings.TrimRight(payload.Iss, "/") == v.issuer
+}
+
+// Verify 校验 token 的签名、签发方、受众与有效期，返回其中的声明。
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", ErrInvalidToken)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var payload struct {
+		Iss   string          `json:"iss"`
+		Sub   string          `json:"sub"`
+		Aud   json.RawMessage `json:"aud"`
+		Exp   *json.Number    `json:"exp"`
+		Nbf   *json.Number    `json:"nbf"`
+		Scope string          `json:"scope"`
+		Scp   []string        `json:"scp"`
+	}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+	if strings.TrimRight(payload.Iss, "/") != v.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, payload.Iss)
+	}
+	if v.audience != "" && !audienceContains(payload.Aud, v.audience) {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidToken)
+	}
+	now := v.now()
+	if payload.Exp == nil {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	exp := numericDate(*payload.Exp)
+	if now.After(exp.Add(Leeway)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if payload.Nbf != nil && now.Add(Leeway).Before(numericDate(*payload.Nbf)) {
+		return nil, fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+
+	claims := &Claims{Issuer: payload.Iss, Subject: payload.Sub, ExpiresAt: exp, Scopes: payload.Scp}
+	if payload.Scope != "" {
+		claims.Scopes = strings.Fields(payload.Scope)
+	}
+	return claims, nil
+}
+
+// key 返回 kid 对应的公钥，缓存过期或找不到时重新拉取 JWKS。
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	now := v.now()
+	key, ok := v.lookup(kid)
+	fetchedAt := v.fetchedAt
+	v.mu.Unlock()
+
+	stale := now.Sub(fetchedAt) > CacheTTL
+	if ok && !stale {
+		return key, nil
+	}
+	if !stale && now.Sub(fetchedAt) < RefetchInterval {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, kid)
+	}
+	_, err, _ := v.fetch.Do("jwks", func() (interface{}, error) {
+		// 拉取结果由所有等待的请求共享，不随发起拉取的请求取消而失败（client 自带超时）
+		keys, err := v.fetchKeys(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		v.mu.Lock()
+		v.keys, v.fetchedAt = keys, v.now()
+		v.mu.Unlock()
+		return nil, nil
+	})
+	if err != nil {
+		if ok {
+			return key, nil // 提供方暂时不可用时继续使用缓存的公钥
+		}
+		return nil, err
+	}
+	v.mu.Lock()
+	key, ok = v.lookup(kid)
+	v.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, kid)
+}
+
+// lookup 按 kid 查找公钥；token 没有 kid 且 JWKS 中只有一个公钥时使用该公钥。调用方需持有 mu。
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// fetchKeys 拉取并解析 JWKS，忽略无法识别的密钥。
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := v.jwksURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, v.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("oidc: discovery: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("oidc: discovery document has no jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("oidc: jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (v *Verifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonWebKey 是 JWKS 中的单个公钥。
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verifySignature 按 alg 校验签名，alg 必须与公钥类型一致。
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	if alg == "EdDSA" {
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, signed, signature) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	digest := digestOf(hash, signed)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		if alg[0] == 'R' {
+			return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		}
+		return rsa.VerifyPSS(pub, hash, digest, signature, nil)
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid ECDSA signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+func digestOf(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	return dec.Decode(out)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
+
+// audienceContains 判断 aud（字符串或字符串数组）是否包含 audience。
+func audienceContains(raw json.RawMessage, audience string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == audience
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, aud := range many {
+			if aud == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// numericDate 把 NumericDate（可以带小数的秒数）转换为时间。
+func numericDate(n json.Number) time.Time {
+	f, _ := n.Float64()
+	return time.Unix(0, int64(f*float64(time.Second)))
+}
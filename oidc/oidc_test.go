@@ -0,0 +1,186 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func b64(data []byte) string { return base64.RawURLEncoding.EncodeToString(data) }
+
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func TestVerifier(t *testing.T) {
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/jwks"})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+				{"kty": "OKP", "crv": "Ed25519", "kid": "ed", "x": b64(edPub)},
+				{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Unix(1_700_000_000, 0)
+	v := NewVerifier(server.URL+"/", "u2api", "")
+	v.now = func() time.Time { return now }
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"iss": server.URL, "sub": "alice", "aud": []string{"u2api", "other"}, "exp": now.Add(time.Hour).Unix(), "scope": "chat:basic chat:pro"}
+		for k, val := range overrides {
+			c[k] = val
+		}
+		return c
+	}
+
+	got, err := v.Verify(context.Background(), sign(t, "EdDSA", "ed", edKey, claims(nil)))
+	if err != nil {
+		t.Fatalf("valid EdDSA token: %v", err)
+	}
+	if got.Subject != "alice" || !reflect.DeepEqual(got.Scopes, []string{"chat:basic", "chat:pro"}) {
+		t.Errorf("claims = %+v", got)
+	}
+	if _, err := v.Verify(context.Background(), sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"scope": nil, "scp": []string{"x"}}))); err != nil {
+		t.Errorf("valid RS256 token: %v", err)
+	}
+
+	invalid := map[string]string{
+		"wrong issuer":   sign(t, "EdDSA", "ed", edKey, claims(map[string]interface{}{"iss": "https://evil.example"})),
+		"wrong audience": sign(t, "EdDSA", "ed", edKey, claims(map[string]interface{}{"aud": "someone-else"})),
+		"expired":        sign(t, "EdDSA", "ed", edKey, claims(map[string]interface{}{"exp": now.Add(-2 * Leeway).Unix()})),
+		"missing exp":    sign(t, "EdDSA", "ed", edKey, claims(map[string]interface{}{"exp": nil})),
+		"not yet valid":  sign(t, "EdDSA", "ed", edKey, claims(map[string]interface{}{"nbf": now.Add(2 * Leeway).Unix()})),
+		"alg mismatch":   sign(t, "RS256", "ed", rsaKey, claims(nil)),
+		"unknown kid":    sign(t, "EdDSA", "rotated", edKey, claims(nil)),
+		"alg none":       b64([]byte(`{"alg":"none","kid":"ed"}`)) + "." + b64([]byte(`{"iss":"x"}`)) + ".",
+	}
+	parts := strings.Split(sign(t, "EdDSA", "ed", edKey, claims(nil)), ".")
+	payload, _ := json.Marshal(claims(map[string]interface{}{"sub": "mallory"}))
+	invalid["tampered payload"] = parts[0] + "." + b64(payload) + "." + parts[2]
+	for name, token := range invalid {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestRecognizes(t *testing.T) {
+	v := NewVerifier("https://sso.example/", "", "")
+	token := func(payload string) string {
+		return b64([]byte(`{"alg":"RS256"}`)) + "." + b64([]byte(payload)) + ".c2ln"
+	}
+	tests := map[string]bool{
+		token(`{"iss":"https://sso.example"}`):  true,
+		token(`{"iss":"https://sso.example/"}`): true,
+		token(`{"iss":"https://you.com"}`):      false,
+		token(`{}`):                             false,
+		"ds-token-value":                        false,
+	}
+	for tok, want := range tests {
+		if got := v.Recognizes(tok); got != want {
+			t.Errorf("Recognizes(%q) = %v, want %v", tok, got, want)
+		}
+	}
+}
+
+func TestKeyFetchOutsideLock(t *testing.T) {
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release // 第一次之后的拉取一直等到测试放行
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{"kty": "OKP", "crv": "Ed25519", "kid": "ed", "x": b64(edPub)},
+		}})
+	}))
+	defer server.Close()
+
+	now := time.Unix(1_700_000_000, 0)
+	v := NewVerifier(server.URL, "u2api", server.URL+"/jwks")
+	v.now = func() time.Time { return now }
+	token := func(kid string) string {
+		return sign(t, "EdDSA", kid, edKey, map[string]interface{}{"iss": server.URL, "sub": "alice", "aud": "u2api", "exp": now.Add(time.Hour).Unix()})
+	}
+	known, rotated := token("ed"), token("rotated")
+	if _, err := v.Verify(context.Background(), known); err != nil {
+		t.Fatalf("prime cache: %v", err)
+	}
+
+	now = now.Add(2 * RefetchInterval)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v.Verify(context.Background(), rotated)
+		}()
+	}
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 拉取进行中时，已缓存的公钥仍可直接使用
+	done := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(context.Background(), known)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("cached key during fetch: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("verification with a cached key blocked on the JWKS fetch")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want concurrent refetches coalesced into 1", got-1)
+	}
+}
@@ -0,0 +1,243 @@
+// Package api 定义 OpenAI 兼容接口的请求与响应结构，供服务端各层以及以代码方式调用本服务的程序共用。
+//
+// 这里只包含数据结构与 JSON 编解码，不依赖配置或任何运行状态；
+// 字段的处理方式（如工具调用的模拟、结构化输出的校验）由服务端实现，相关说明见各字段的注释。
+package api
+
+import "encoding/json"
+
+// OpenAIRequest 定义了 OpenAI API 请求体的结构。
+type OpenAIRequest struct {
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	Model    string    `json:"model"`
+	DryRun   bool      `json:"dry_run"` // 只返回将要发送给 You.com 的参数，不实际调用
+	N        int       `json:"n"`       // 生成的候选回复数量，大于 1 时并行请求上游
+	// PreviousResponseID 引用之前某次响应的 X-Request-ID，在其完整对话之后继续
+	PreviousResponseID string `json:"previous_response_id"`
+	// Async 为 true 时立即返回补全 ID，生成结束后把结果 POST 到 CallbackURL
+	Async       bool   `json:"async"`
+	CallbackURL string `json:"callback_url"`
+	// Instructions（别名 Persona）作为 You.com 的自定义指令发送，与聊天历史分开
+	Instructions string `json:"instructions"`
+	Persona      string `json:"persona"`
+	// ResponseFormat 要求按 JSON Schema 输出
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// Reasoning 与 IncludeReasoning 覆盖思考过程的默认处理方式
+	Reasoning        *ReasoningOptions `json:"reasoning,omitempty"`
+	IncludeReasoning *bool             `json:"include_reasoning,omitempty"`
+	// ReasoningEffort 为基础推理模型（如 o3-mini）选择思考强度
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	// Tools、ToolChoice 与 ParallelToolCalls 通过提示词模拟函数调用
+	Tools             []Tool          `json:"tools,omitempty"`
+	ToolChoice        json.RawMessage `json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool           `json:"parallel_tool_calls,omitempty"`
+	// MaxTokens 与 MaxCompletionTokens 限制回复的 token 数，与服务端的响应大小限制取更严格的一项
+	MaxTokens           *int `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int `json:"max_completion_tokens,omitempty"`
+	// Stop 是停止序列（字符串或数组），由代理截断回复
+	Stop json.RawMessage `json:"stop,omitempty"`
+	// SamplingParams 中按 SAMPLING_PARAMS 配置的参数转发给上游，其余记录为已忽略
+	SamplingParams
+}
+
+// SamplingParams 是 OpenAI 请求中的采样参数，嵌入在 OpenAIRequest 中。
+type SamplingParams struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+}
+
+// ReasoningOptions 是请求中的 reasoning 字段。
+type ReasoningOptions struct {
+	Exclude *bool `json:"exclude,omitempty"`
+	// Effort 与 reasoning_effort 相同
+	Effort string `json:"effort,omitempty"`
+}
+
+// ResponseFormat 是 OpenAI 请求中的 response_format 字段。
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat 是 response_format 为 json_schema 时的 Schema 定义。
+type JSONSchemaFormat struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	Strict      bool            `json:"strict,omitempty"`
+}
+
+// Tool 是请求 tools 字段中的一个工具，目前只支持 function。
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 是工具的函数定义，Parameters 是参数的 JSON Schema。
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall 是 assistant 消息中的一次工具调用。
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 是工具调用的函数名与 JSON 编码的参数。
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCallDelta 是流式响应中的一次工具调用，Index 标识调用在 tool_calls 中的位置。
+type ToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// OpenAIResponse 定义了 OpenAI API 非流式响应的结构。
+type OpenAIResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"`
+	// Citations 是引用来源的 URL 列表（Perplexity 风格），回复中的 [n] 对应第 n 个
+	Citations []string `json:"citations,omitempty"`
+	// ProviderMetadata 是非 OpenAI 标准的扩展字段，如 You.com 实际执行的搜索查询
+	ProviderMetadata *ProviderMetadata `json:"provider_metadata,omitempty"`
+	// ChoiceErrors 是非 OpenAI 标准的扩展字段，列出 n>1 时失败的 choice
+	ChoiceErrors []ChoiceError `json:"choice_errors,omitempty"`
+}
+
+// OpenAIChoice 定义了 OpenAI 非流式响应中 choices 数组的单个元素的结构。
+type OpenAIChoice struct {
+	Message      Message `json:"message"`
+	Index        int     `json:"index"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// OpenAIStreamResponse 定义了 OpenAI API 流式响应的结构。
+type OpenAIStreamResponse struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	// Citations 是引用来源的 URL 列表，在最后一个内容块之后发送
+	Citations []string `json:"citations,omitempty"`
+	// ProviderMetadata 在收到搜索事件时以单独的块发送
+	ProviderMetadata *ProviderMetadata `json:"provider_metadata,omitempty"`
+	// ChoiceErrors 是非 OpenAI 标准的扩展字段，n>1 时列出失败的 choice
+	ChoiceErrors []ChoiceError `json:"choice_errors,omitempty"`
+}
+
+// Choice 定义了 OpenAI 流式响应中 choices 数组的单个元素的结构。
+type Choice struct {
+	Delta        Delta  `json:"delta"`
+	Index        int    `json:"index"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// Delta 定义了流式响应中表示增量内容的结构。
+type Delta struct {
+	Role    string `json:"role,omitempty"` // 只在每个流的第一个块中出现
+	Content string `json:"content,omitempty"`
+	// ReasoningContent 是推理模型的思考过程
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// Annotations 是回复中的引用标注
+	Annotations []Annotation `json:"annotations,omitempty"`
+	// ToolCalls 是模型发起的工具调用
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// Annotation 是 OpenAI 消息中的注解，目前只有 url_citation 一种。
+type Annotation struct {
+	Type        string       `json:"type"`
+	URLCitation *URLCitation `json:"url_citation,omitempty"`
+}
+
+// URLCitation 是对网页的引用，StartIndex 与 EndIndex 是引用标注在回复内容中的字符位置。
+type URLCitation struct {
+	URL        string `json:"url"`
+	Title      string `json:"title,omitempty"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
+// Usage 是 OpenAI 响应中的 token 用量。You.com 不返回用量，由服务端按分词器估算。
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChoiceError 描述 n>1 请求中单个 choice 的失败原因。
+type ChoiceError struct {
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+}
+
+// ProviderMetadata 是响应中的扩展字段，描述 You.com 在生成回答时的额外行为。
+type ProviderMetadata struct {
+	SearchQueries []string `json:"search_queries,omitempty"`
+	// AutoModel 是虚拟模型 auto 的选择结果
+	AutoModel *AutoModelDecision `json:"auto_model,omitempty"`
+	// StructuredOutput 是 response_format 结构化输出的校验结果
+	StructuredOutput *StructuredOutputReport `json:"structured_output,omitempty"`
+	// IgnoredParameters 是请求中设置了但没有转发给上游的采样参数
+	IgnoredParameters []string `json:"ignored_parameters,omitempty"`
+	// OutputRetry 说明回复因疑似损坏而重试
+	OutputRetry *OutputRetry `json:"output_retry,omitempty"`
+}
+
+// AutoModelDecision 记录 auto 模型的选择结果，通过 provider_metadata.auto_model 返回给客户端。
+type AutoModelDecision struct {
+	Model        string `json:"model"`
+	Reason       string `json:"reason"`
+	PromptTokens int    `json:"prompt_tokens"`
+	// FallbackFrom 是按规则选中、但因最近调用失败而被替换的模型
+	FallbackFrom string `json:"fallback_from,omitempty"`
+}
+
+// StructuredOutputReport 是结构化输出的校验结果，出现在响应元数据中。
+type StructuredOutputReport struct {
+	Schema   string   `json:"schema"`
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors,omitempty"`
+	Repaired bool     `json:"repaired,omitempty"` // 首次回复不符合 Schema，返回的是修复后的回复
+	// RepairAttempts 是发起的修复请求次数
+	RepairAttempts int `json:"repair_attempts,omitempty"`
+}
+
+// OutputRetry 是响应中的坏输出重试信息。
+type OutputRetry struct {
+	Reason    string `json:"reason"`
+	Model     string `json:"model"`     // 重试使用的模型
+	Recovered bool   `json:"recovered"` // 重试是否得到正常回复
+}
+
+// ModelResponse 定义了 /v1/models 响应的结构。
+type ModelResponse struct {
+	Object string        `json:"object"`
+	Data   []ModelDetail `json:"data"`
+}
+
+// ModelDetail 定义了模型列表中单个模型的详细信息。
+type ModelDetail struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOpenAIRequestSamplingParams(t *testing.T) {
+	var req OpenAIRequest
+	data := `{"model":"gpt-4o","stream":true,"temperature":0.2,"seed":7,"messages":[{"role":"user","content":"hi"}]}`
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Temperature == nil || *req.Temperature != 0.2 || req.Seed == nil || *req.Seed != 7 || req.TopP != nil {
+		t.Errorf("sampling params = %+v", req.SamplingParams)
+	}
+	if !req.Stream || req.Model != "gpt-4o" || len(req.Messages) != 1 {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+func TestStreamResponseJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk OpenAIStreamResponse
+		want  string
+	}{
+		{
+			"content delta",
+			OpenAIStreamResponse{ID: "chatcmpl-1", Object: "chat.completion.chunk", Created: 1, Model: "gpt-4o", Choices: []Choice{{Delta: Delta{Content: "hi"}}}},
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"delta":{"content":"hi"},"index":0,"finish_reason":""}]}`,
+		},
+		{
+			// 元数据块不带 choices，但 choices 字段仍然是数组
+			"metadata",
+			OpenAIStreamResponse{ID: "chatcmpl-1", Choices: []Choice{}, ProviderMetadata: &ProviderMetadata{SearchQueries: []string{"q"}}},
+			`{"id":"chatcmpl-1","object":"","created":0,"model":"","choices":[],"provider_metadata":{"search_queries":["q"]}}`,
+		},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(data); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResponseUsageRoundTrip(t *testing.T) {
+	resp := OpenAIResponse{
+		ID:      "chatcmpl-1",
+		Choices: []OpenAIChoice{{Message: Message{Role: "assistant", Content: "hi"}, FinishReason: "stop"}},
+		Usage:   &Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4},
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got OpenAIResponse
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Usage == nil || *got.Usage != *resp.Usage || got.Choices[0].Message.Content != "hi" || got.Choices[0].FinishReason != "stop" {
+		t.Errorf("round trip = %+v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Message 定义了 OpenAI 聊天消息的结构。
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ReasoningContent 是推理模型的思考过程，只出现在响应中
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// Annotations 是回复中的引用标注，只出现在响应中
+	Annotations []Annotation `json:"annotations,omitempty"`
+	// 工具调用历史：assistant 消息发起的调用与 tool 消息返回的结果
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+
+	// ImageURLs 是视觉请求中 content 数组里的图片 URL，由服务端上传后作为文件引用发送，不参与编码
+	ImageURLs []string `json:"-"`
+}
+
+// contentPart 是 OpenAI 视觉请求中 content 数组的单个元素。
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// UnmarshalJSON 同时支持字符串 content 与视觉请求的 content 数组：
+// 文本部分按顺序拼接为 Content，图片部分的 URL 保存在 ImageURLs 中。
+// 没有 content 或 content 为 null（例如只包含工具调用的 assistant 消息）时 Content 为空。
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role       string          `json:"role"`
+		Content    json.RawMessage `json:"content"`
+		ToolCalls  []ToolCall      `json:"tool_calls"`
+		ToolCallID string          `json:"tool_call_id"`
+		Name       string          `json:"name"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m.Role = raw.Role
+	m.ToolCalls, m.ToolCallID, m.Name = raw.ToolCalls, raw.ToolCallID, raw.Name
+	if len(raw.Content) == 0 || string(raw.Content) == "null" {
+		m.Content = ""
+		return nil
+	}
+	if raw.Content[0] != '[' {
+		return json.Unmarshal(raw.Content, &m.Content)
+	}
+
+	var parts []contentPart
+	if err := json.Unmarshal(raw.Content, &parts); err != nil {
+		return err
+	}
+	var texts []string
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+		case "image_url":
+			if part.ImageURL != nil {
+				m.ImageURLs = append(m.ImageURLs, part.ImageURL.URL)
+			}
+		}
+	}
+	m.Content = strings.Join(texts, "\n")
+	return nil
+}
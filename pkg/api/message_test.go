@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMessageUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Message
+	}{
+		{"string content", `{"role":"user","content":"hi"}`, Message{Role: "user", Content: "hi"}},
+		{"null content", `{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]}`,
+			Message{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "f", Arguments: "{}"}}}}},
+		{"missing content", `{"role":"assistant","tool_calls":[]}`, Message{Role: "assistant", ToolCalls: []ToolCall{}}},
+		{"content parts", `{"role":"user","content":[{"type":"text","text":"look"},{"type":"image_url","image_url":{"url":"https://example.com/a.png"}},{"type":"text","text":"here"}]}`,
+			Message{Role: "user", Content: "look\nhere", ImageURLs: []string{"https://example.com/a.png"}}},
+		{"tool result", `{"role":"tool","content":"42","tool_call_id":"call_1","name":"f"}`, Message{Role: "tool", Content: "42", ToolCallID: "call_1", Name: "f"}},
+	}
+	for _, tt := range tests {
+		var got Message
+		if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	var m Message
+	if err := json.Unmarshal([]byte(`{"role":"user","content":{"text":"x"}}`), &m); err == nil {
+		t.Error("object content accepted")
+	}
+}
+
+func TestMessageMarshalOmitsImageURLs(t *testing.T) {
+	data, err := json.Marshal(Message{Role: "user", Content: "hi", ImageURLs: []string{"https://example.com/a.png"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != `{"role":"user","content":"hi"}` {
+		t.Errorf("Marshal = %s", got)
+	}
+}
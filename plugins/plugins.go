@@ -0,0 +1,68 @@
+// Package plugins 定义请求改写插件的挂载点与宿主接口。
+//
+// 插件在两个挂载点运行：pre_request 在请求转发给 You.com 之前，可以改写模型与消息（如补充提示词）；
+// post_response 在非流式回复返回给客户端之前，可以改写回复内容（如按组织规则过滤）。
+// 插件只能看到并返回下面的 Request / Response 结构，看不到 DS token 等凭据。
+//
+// 插件有两种形式：不能重新编译本服务的运维人员在部署时通过 PLUGINS 加载 WebAssembly 模块（.wasm 文件），
+// 宿主接口与沙箱限制见 wasm.go；嵌入本服务时也可以用 Go 代码实现 Hook，通过 handler.WithPlugins 注册。
+//
+// 流式响应逐块发送给客户端，不经过 post_response。需要检查全部回复内容的插件可以在 pre_request 中
+// 根据 Request.Stream 拒绝流式请求。
+package plugins
+
+import (
+	"context"
+	"fmt"
+)
+
+// Message 是插件看到的一条对话消息。
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request 是 pre_request 挂载点的输入与输出。
+type Request struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	// Stream 表示客户端请求流式响应，流式响应不经过 post_response。只读，插件的修改会被忽略
+	Stream bool `json:"stream"`
+}
+
+// Response 是 post_response 挂载点的输入与输出。
+type Response struct {
+	Model   string `json:"model"`
+	Content string `json:"content"`
+}
+
+// Hook 是一个插件。返回错误时请求失败，错误信息返回给客户端：
+// pre_request 的拒绝以 400 plugin_rejected 返回，post_response 的拒绝以 502 response_rejected 返回。
+type Hook interface {
+	Name() string
+	PreRequest(ctx context.Context, req *Request) error
+	PostResponse(ctx context.Context, resp *Response) error
+}
+
+// Chain 按顺序执行多个插件，前一个插件的输出是下一个插件的输入。
+type Chain []Hook
+
+// PreRequest 依次执行各插件的 pre_request。
+func (c Chain) PreRequest(ctx context.Context, req *Request) error {
+	for _, h := range c {
+		if err := h.PreRequest(ctx, req); err != nil {
+			return fmt.Errorf("plugin %s: %w", h.Name(), err)
+		}
+	}
+	return nil
+}
+
+// PostResponse 依次执行各插件的 post_response。
+func (c Chain) PostResponse(ctx context.Context, resp *Response) error {
+	for _, h := range c {
+		if err := h.PostResponse(ctx, resp); err != nil {
+			return fmt.Errorf("plugin %s: %w", h.Name(), err)
+		}
+	}
+	return nil
+}
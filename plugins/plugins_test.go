@@ -0,0 +1,40 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type suffixHook struct{ suffix string }
+
+func (h suffixHook) Name() string { return "suffix" + h.suffix }
+
+func (h suffixHook) PreRequest(_ context.Context, req *Request) error {
+	req.Messages = append(req.Messages, Message{Role: "system", Content: h.suffix})
+	return nil
+}
+
+func (h suffixHook) PostResponse(_ context.Context, resp *Response) error {
+	if strings.Contains(resp.Content, "secret") {
+		return errors.New("blocked")
+	}
+	resp.Content += h.suffix
+	return nil
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain{suffixHook{"a"}, suffixHook{"b"}}
+	req := &Request{Model: "gpt-4o"}
+	if err := chain.PreRequest(context.Background(), req); err != nil || len(req.Messages) != 2 || req.Messages[1].Content != "b" {
+		t.Errorf("PreRequest = %+v, %v", req, err)
+	}
+	resp := &Response{Content: "hi "}
+	if err := chain.PostResponse(context.Background(), resp); err != nil || resp.Content != "hi ab" {
+		t.Errorf("PostResponse = %q, %v", resp.Content, err)
+	}
+	if err := chain.PostResponse(context.Background(), &Response{Content: "secret"}); err == nil || !strings.Contains(err.Error(), "plugin suffixa") {
+		t.Errorf("rejection error = %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+// Package plugintest 生成用于测试的最小 WebAssembly 插件模块，不需要 WebAssembly 工具链。
+package plugintest
+
+// 值类型与段 ID，见 WebAssembly 二进制格式规范。
+const (
+	i32 = 0x7f
+	i64 = 0x7e
+
+	sectionType     = 1
+	sectionImport   = 2
+	sectionFunction = 3
+	sectionMemory   = 5
+	sectionExport   = 7
+	sectionCode     = 10
+	sectionData     = 11
+)
+
+// 输出 JSON 在插件内存中的位置，alloc 总是返回 inputOffset。
+const (
+	preOffset   = 0
+	postOffset  = 16 << 10
+	inputOffset = 32 << 10
+)
+
+// Module 返回一个插件模块：pre_request 与 post_response 分别返回固定的 JSON 输出，为空字符串时返回 0（不做修改）。
+// pre_request 在返回之前调用 u2api.log 记录自己的输出。
+func Module(pre, post string) []byte {
+	return build(
+		result(preOffset, pre),
+		result(postOffset, post),
+		[]segment{{preOffset, pre}, {postOffset, post}},
+		true,
+	)
+}
+
+// Loop 返回一个 pre_request 永不返回的插件模块，用于测试超时。
+func Loop() []byte {
+	loop := []byte{0x03, 0x40, 0x0c, 0x00, 0x0b} // loop (br 0) end
+	return build(append(loop, result(0, "")...), result(0, ""), nil, false)
+}
+
+// Importing 返回一个导入了宿主接口之外函数的模块。
+func Importing(module, name string) []byte {
+	var b []byte
+	b = append(b, 0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00)
+	b = appendSection(b, sectionType, vec(1, funcType([]byte{i32}, []byte{i32})))
+	b = appendSection(b, sectionImport, vec(1, append(append(str(module), str(name)...), 0x00, 0x00)))
+	return b
+}
+
+type segment struct {
+	offset int64
+	data   string
+}
+
+// result 返回挂载点函数体中的返回值：输出的地址与长度，没有输出时为 0。
+func result(offset int64, out string) []byte {
+	if out == "" {
+		return []byte{0x42, 0x00} // i64.const 0
+	}
+	return append([]byte{0x42}, sleb(offset<<32|int64(len(out)))...)
+}
+
+// build 组装模块。函数 0 是导入的 u2api.log，之后依次是 alloc、pre_request 与 post_response。
+func build(pre, post []byte, data []segment, logPre bool) []byte {
+	var b []byte
+	b = append(b, 0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00)
+	b = appendSection(b, sectionType, vec(3,
+		funcType([]byte{i32, i32}, nil),
+		funcType([]byte{i32}, []byte{i32}),
+		funcType([]byte{i32, i32}, []byte{i64}),
+	))
+	b = appendSection(b, sectionImport, vec(1, append(append(str("u2api"), str("log")...), 0x00, 0x00)))
+	b = appendSection(b, sectionFunction, vec(3, []byte{1}, []byte{2}, []byte{2}))
+	b = appendSection(b, sectionMemory, vec(1, []byte{0x00, 0x01})) // 最少 1 页
+	b = appendSection(b, sectionExport, vec(4,
+		append(str("memory"), 0x02, 0x00),
+		append(str("alloc"), 0x00, 0x01),
+		append(str("pre_request"), 0x00, 0x02),
+		append(str("post_response"), 0x00, 0x03),
+	))
+	alloc := append(append([]byte{0x41}, sleb(inputOffset)...), 0x0b) // i32.const inputOffset
+	if logPre && len(data) > 0 && data[0].data != "" {
+		call := append([]byte{0x41}, sleb(data[0].offset)...)
+		call = append(call, 0x41)
+		call = append(call, sleb(int64(len(data[0].data)))...)
+		call = append(call, 0x10, 0x00) // call u2api.log
+		pre = append(call, pre...)
+	}
+	b = appendSection(b, sectionCode, vec(3, body(alloc), body(append(pre, 0x0b)), body(append(post, 0x0b))))
+	var segments [][]byte
+	for _, s := range data {
+		if s.data == "" {
+			continue
+		}
+		seg := append([]byte{0x00, 0x41}, sleb(s.offset)...)
+		seg = append(seg, 0x0b)
+		seg = append(seg, uleb(uint64(len(s.data)))...)
+		segments = append(segments, append(seg, s.data...))
+	}
+	if len(segments) > 0 {
+		b = appendSection(b, sectionData, vec(len(segments), segments...))
+	}
+	return b
+}
+
+func funcType(params, results []byte) []byte {
+	t := append([]byte{0x60}, uleb(uint64(len(params)))...)
+	t = append(t, params...)
+	t = append(t, uleb(uint64(len(results)))...)
+	return append(t, results...)
+}
+
+// body 返回没有局部变量的函数体。
+func body(code []byte) []byte {
+	code = append([]byte{0x00}, code...)
+	return append(uleb(uint64(len(code))), code...)
+}
+
+func vec(n int, items ...[]byte) []byte {
+	v := uleb(uint64(n))
+	for _, item := range items {
+		v = append(v, item...)
+	}
+	return v
+}
+
+func str(s string) []byte {
+	return append(uleb(uint64(len(s))), s...)
+}
+
+func appendSection(b []byte, id byte, content []byte) []byte {
+	b = append(b, id)
+	b = append(b, uleb(uint64(len(content)))...)
+	return append(b, content...)
+}
+
+func uleb(v uint64) []byte {
+	var out []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		out = append(out, c)
+		if v == 0 {
+			return out
+		}
+	}
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(out, c)
+		}
+		out = append(out, c|0x80)
+	}
+}
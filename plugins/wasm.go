@@ -0,0 +1,228 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// WebAssembly 插件的宿主接口（ABI）：
+//
+// 插件模块导出线性内存 memory 与 alloc(size i32) -> i32，并至少导出 pre_request 与 post_response 之一，
+// 签名都是 (ptr i32, len i32) -> i64。宿主用 alloc 在插件内存中申请空间，写入 JSON 编码的 Request 或 Response，
+// 再以其地址与长度调用挂载点函数。返回值的高 32 位是输出 JSON 的地址，低 32 位是长度，返回 0 表示不做修改。
+// 输出 JSON 与输入结构相同，只需包含要修改的字段；包含非空的 "error" 字段时拒绝请求或回复。
+//
+// 插件只能导入 u2api.log(ptr i32, len i32)，把一段文本写入服务日志；没有 WASI，也就没有文件、网络、时钟或环境变量。
+// 每次调用都使用新的模块实例，请求之间不共享状态；调用超过超时时间时中断，内存不超过 MemoryLimitPages。
+
+// MemoryLimitPages 是插件线性内存的上限（64 KiB 一页，共 16 MiB）。
+const MemoryLimitPages = 256
+
+// hostModule 是插件可以导入的宿主模块名。
+const hostModule = "u2api"
+
+// Runtime 编译并执行 WebAssembly 插件。
+type Runtime struct {
+	rt      wazero.Runtime
+	timeout time.Duration
+}
+
+// NewRuntime 创建插件运行时，timeout 是单次挂载点调用的最长时间。
+func NewRuntime(ctx context.Context, timeout time.Duration) (*Runtime, error) {
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(MemoryLimitPages).
+		WithCloseOnContextDone(true))
+	_, err := rt.NewHostModuleBuilder(hostModule).
+		NewFunctionBuilder().WithFunc(hostLog).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, err
+	}
+	return &Runtime{rt: rt, timeout: timeout}, nil
+}
+
+// Close 释放运行时与所有已编译的插件。
+func (r *Runtime) Close(ctx context.Context) error {
+	return r.rt.Close(ctx)
+}
+
+// Load 编译 .wasm 文件中的插件，插件名称是不含扩展名的文件名。
+func (r *Runtime) Load(ctx context.Context, path string) (Hook, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Compile(ctx, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), code)
+}
+
+// Compile 编译插件并检查它只导入宿主接口、导出所需的函数。
+func (r *Runtime) Compile(ctx context.Context, name string, code []byte) (Hook, error) {
+	module, err := r.rt.CompileModule(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+	if err := checkModule(module); err != nil {
+		module.Close(ctx)
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+	exports := module.ExportedFunctions()
+	_, pre := exports["pre_request"]
+	_, post := exports["post_response"]
+	return &wasmHook{name: name, runtime: r, module: module, pre: pre, post: post}, nil
+}
+
+// checkModule 检查模块的导入与导出是否符合宿主接口。
+func checkModule(module wazero.CompiledModule) error {
+	for _, fn := range module.ImportedFunctions() {
+		mod, name, _ := fn.Import()
+		if mod != hostModule || name != "log" {
+			return fmt.Errorf("imports %s.%s, only %s.log is available", mod, name, hostModule)
+		}
+	}
+	if len(module.ImportedMemories()) > 0 {
+		return errors.New("must not import memory")
+	}
+	if _, ok := module.ExportedMemories()["memory"]; !ok {
+		return errors.New("must export memory")
+	}
+	exports := module.ExportedFunctions()
+	if !hasSignature(exports["alloc"], []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}) {
+		return errors.New("must export alloc(i32) -> i32")
+	}
+	hooks := 0
+	for _, hook := range []string{"pre_request", "post_response"} {
+		fn, ok := exports[hook]
+		if !ok {
+			continue
+		}
+		if !hasSignature(fn, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}) {
+			return fmt.Errorf("%s must have the signature (i32, i32) -> i64", hook)
+		}
+		hooks++
+	}
+	if hooks == 0 {
+		return errors.New("must export pre_request or post_response")
+	}
+	return nil
+}
+
+// hasSignature 判断函数的参数与返回值类型。
+func hasSignature(fn api.FunctionDefinition, params, results []api.ValueType) bool {
+	if fn == nil {
+		return false
+	}
+	return string(fn.ParamTypes()) == string(params) && string(fn.ResultTypes()) == string(results)
+}
+
+type pluginNameKey struct{}
+
+// hostLog 实现 u2api.log，把插件内存中的文本写入日志。
+func hostLog(ctx context.Context, m api.Module, ptr, length uint32) {
+	text, ok := m.Memory().Read(ptr, length)
+	if !ok {
+		return
+	}
+	name, _ := ctx.Value(pluginNameKey{}).(string)
+	log.Printf("插件 %s: %s", name, text)
+}
+
+// wasmHook 是一个 WebAssembly 插件，没有导出的挂载点不做任何处理。
+type wasmHook struct {
+	name      string
+	runtime   *Runtime
+	module    wazero.CompiledModule
+	pre, post bool
+}
+
+func (h *wasmHook) Name() string { return h.name }
+
+// PreRequest 调用插件的 pre_request。Request.Stream 只读，插件的修改会被忽略。
+func (h *wasmHook) PreRequest(ctx context.Context, req *Request) error {
+	if !h.pre {
+		return nil
+	}
+	out := struct {
+		*Request
+		Error string `json:"error"`
+	}{Request: req}
+	stream := req.Stream
+	err := h.call(ctx, "pre_request", req, &out)
+	req.Stream = stream
+	if err != nil {
+		return err
+	}
+	if out.Error != "" {
+		return errors.New(out.Error)
+	}
+	return nil
+}
+
+// PostResponse 调用插件的 post_response。
+func (h *wasmHook) PostResponse(ctx context.Context, resp *Response) error {
+	if !h.post {
+		return nil
+	}
+	out := struct {
+		*Response
+		Error string `json:"error"`
+	}{Response: resp}
+	if err := h.call(ctx, "post_response", resp, &out); err != nil {
+		return err
+	}
+	if out.Error != "" {
+		return errors.New(out.Error)
+	}
+	return nil
+}
+
+// call 在新的模块实例中调用挂载点函数，把输出 JSON 解码到 out 中；插件返回 0 时 out 保持不变。
+func (h *wasmHook) call(ctx context.Context, fn string, in, out any) error {
+	input, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, pluginNameKey{}, h.name), h.runtime.timeout)
+	defer cancel()
+
+	mod, err := h.runtime.rt.InstantiateModule(ctx, h.module, wazero.NewModuleConfig().
+		WithName("").WithStartFunctions("_initialize"))
+	if err != nil {
+		return fmt.Errorf("instantiate: %w", err)
+	}
+	defer mod.Close(context.Background())
+
+	res, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(input)))
+	if err != nil {
+		return fmt.Errorf("alloc: %w", err)
+	}
+	ptr := uint32(res[0])
+	if !mod.Memory().Write(ptr, input) {
+		return errors.New("alloc returned memory out of range")
+	}
+	res, err = mod.ExportedFunction(fn).Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	if res[0] == 0 {
+		return nil
+	}
+	output, ok := mod.Memory().Read(uint32(res[0]>>32), uint32(res[0]))
+	if !ok {
+		return fmt.Errorf("%s returned memory out of range", fn)
+	}
+	if err := json.Unmarshal(output, out); err != nil {
+		return fmt.Errorf("%s returned invalid JSON: %w", fn, err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package plugins
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	plugintest "you2api/plugins/plugintest"
+)
+
+func newTestRuntime(t *testing.T, timeout time.Duration) *Runtime {
+	t.Helper()
+	rt, err := NewRuntime(context.Background(), timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rt.Close(context.Background()) })
+	return rt
+}
+
+func TestWASMHook(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRuntime(t, time.Second)
+	path := filepath.Join(t.TempDir(), "rewrite.wasm")
+	module := plugintest.Module(
+		`{"model":"gpt-4o-mini","stream":false,"messages":[{"role":"system","content":"be brief"}]}`,
+		`{"content":"rewritten"}`,
+	)
+	if err := os.WriteFile(path, module, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := rt.Load(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.Name() != "rewrite" {
+		t.Errorf("Name = %q", hook.Name())
+	}
+
+	// 插件不能修改只读的 Stream
+	req := &Request{Model: "gpt-4o", Messages: []Message{{Role: "user", Content: "hi"}}, Stream: true}
+	if err := hook.PreRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Model != "gpt-4o-mini" || len(req.Messages) != 1 || req.Messages[0].Content != "be brief" || !req.Stream {
+		t.Errorf("PreRequest = %+v", req)
+	}
+	resp := &Response{Model: "gpt-4o", Content: "original"}
+	if err := hook.PostResponse(ctx, resp); err != nil || resp.Content != "rewritten" || resp.Model != "gpt-4o" {
+		t.Errorf("PostResponse = %+v, %v", resp, err)
+	}
+}
+
+func TestWASMHookUnchangedAndRejected(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRuntime(t, time.Second)
+	hook, err := rt.Compile(ctx, "filter", plugintest.Module("", `{"error":"contains secrets"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &Request{Model: "gpt-4o", Messages: []Message{{Role: "user", Content: "hi"}}}
+	if err := hook.PreRequest(ctx, req); err != nil || req.Model != "gpt-4o" || len(req.Messages) != 1 {
+		t.Errorf("PreRequest = %+v, %v, want unchanged", req, err)
+	}
+	if err := hook.PostResponse(ctx, &Response{Content: "secret"}); err == nil || err.Error() != "contains secrets" {
+		t.Errorf("PostResponse error = %v", err)
+	}
+}
+
+func TestWASMHookTimeout(t *testing.T) {
+	ctx := context.Background()
+	rt := newTestRuntime(t, 50*time.Millisecond)
+	hook, err := rt.Compile(ctx, "loop", plugintest.Loop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := hook.PreRequest(ctx, &Request{}); err == nil {
+		t.Error("endless plugin returned without error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("plugin ran for %v", elapsed)
+	}
+}
+
+func TestWASMHookRejectsHostImports(t *testing.T) {
+	rt := newTestRuntime(t, time.Second)
+	for _, code := range [][]byte{
+		plugintest.Importing("wasi_snapshot_preview1", "fd_write"),
+		plugintest.Importing("u2api", "http_get"),
+		[]byte("not wasm"),
+	} {
+		if _, err := rt.Compile(context.Background(), "bad", code); err == nil {
+			t.Errorf("Compile(%q) accepted", code[:8])
+		} else if !strings.Contains(err.Error(), "plugin bad") {
+			t.Errorf("error = %v, want the plugin name", err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package pool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+	_ "time/tzdata" // 容器镜像中可能没有时区数据库
+)
+
+// ErrNoAvailableAccount 表示当前时间没有任何账号可用。
+var ErrNoAvailableAccount = errors.New("no DS token is available at this time")
+
+// Window 是一天中允许使用账号的时间段，End 早于 Start 时表示跨越午夜（如 22:00-07:00）。
+type Window struct {
+	Start time.Duration // 距当天零点的偏移
+	End   time.Duration
+}
+
+// ParseWindow 解析 "HH:MM-HH:MM" 格式的时间段。
+func ParseWindow(s string) (Window, error) {
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: want HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(startStr)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	end, err := parseClock(endStr)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	return Window{Start: start, End: end}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains 判断一天中的某个时刻是否落在时间段内。
+func (w Window) Contains(clock time.Duration) bool {
+	if w.Start <= w.End {
+		return clock >= w.Start && clock < w.End
+	}
+	return clock >= w.Start || clock < w.End
+}
+
+// Account 是池中的一个 You.com 账号。
+type Account struct {
+	Name     string
+	Token    string
+	Windows  []Window // 为空表示任何时间都可用
+	Location *time.Location
+	Market   string // 账号所在的搜索地区（如 en-US），为空时按 Location 推断，见 fingerprint.MarketForTimezone
+	Tier     string // 账号的订阅等级（如 free、pro），不同等级可用的模型不同，为空表示未知
+}
+
+// AvailableAt 判断账号在给定时间是否允许使用。
+func (a *Account) AvailableAt(now time.Time) bool {
+	if len(a.Windows) == 0 {
+		return true
+	}
+	local := now.In(a.Location)
+	clock := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	for _, w := range a.Windows {
+		if w.Contains(clock) {
+			return true
+		}
+	}
+	return false
+}
+
+// accountFile 是账号池文件中单个账号的格式。
+type accountFile struct {
+	Name     string   `json:"name"`
+	Token    string   `json:"token"`
+	Windows  []string `json:"windows"`
+	Timezone string   `json:"timezone"`
+	Market   string   `json:"market"`
+	Tier     string   `json:"tier"`
+}
+
+// Pool 在多个 DS token 之间轮询，只选择当前处于可用时间段内的账号。
+type Pool struct {
+	accounts []*Account
+	next     atomic.Uint64
+}
+
+// New 用给定的账号创建账号池，供以代码方式配置账号池时使用。Location 为 nil 的账号按 UTC 计算可用时间段。
+func New(accounts ...*Account) *Pool {
+	p := &Pool{}
+	for _, account := range accounts {
+		if account.Location == nil {
+			account.Location = time.UTC
+		}
+		p.accounts = append(p.accounts, account)
+	}
+	return p
+}
+
+// Load 从 JSON 文件加载账号池，文件格式为
+// [{"name": "personal", "token": "...", "windows": ["22:00-07:00"], "timezone": "Asia/Shanghai", "market": "zh-CN", "tier": "pro"}]。
+func Load(path string) (*Pool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse 解析账号池文件的内容，格式见 Load。
+func Parse(data []byte) (*Pool, error) {
+	var entries []accountFile
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	p := &Pool{}
+	for i, entry := range entries {
+		if entry.Token == "" {
+			return nil, fmt.Errorf("account %d has no token", i)
+		}
+		account := &Account{Name: entry.Name, Token: entry.Token, Location: time.UTC, Market: entry.Market, Tier: entry.Tier}
+		if account.Name == "" {
+			account.Name = fmt.Sprintf("account-%d", i)
+		}
+		if entry.Timezone != "" {
+			location, err := time.LoadLocation(entry.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %w", account.Name, err)
+			}
+			account.Location = location
+		}
+		for _, spec := range entry.Windows {
+			window, err := ParseWindow(spec)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %w", account.Name, err)
+			}
+			account.Windows = append(account.Windows, window)
+		}
+		p.accounts = append(p.accounts, account)
+	}
+	return p, nil
+}
+
+// Pick 按轮询顺序返回当前可用的下一个账号。
+func (p *Pool) Pick(now time.Time) (*Account, error) {
+	candidates := p.Candidates(now)
+	if len(candidates) == 0 {
+		return nil, ErrNoAvailableAccount
+	}
+	return candidates[0], nil
+}
+
+// Candidates 按轮询顺序返回当前处于可用时间段内的全部账号，调用方可以再按运行状态（见 State）跳过其中的账号。
+func (p *Pool) Candidates(now time.Time) []*Account {
+	n := uint64(len(p.accounts))
+	start := p.next.Add(1) - 1
+	var candidates []*Account
+	for i := uint64(0); i < n; i++ {
+		account := p.accounts[(start+i)%n]
+		if account.AvailableAt(now) {
+			candidates = append(candidates, account)
+		}
+	}
+	return candidates
+}
+
+// HasAvailable 报告当前是否有处于可用时间段内的账号，不推进轮询位置。
+func (p *Pool) HasAvailable(now time.Time) bool {
+	for _, account := range p.accounts {
+		if account.AvailableAt(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Accounts 返回池中的全部账号。
+func (p *Pool) Accounts() []*Account {
+	return p.accounts
+}
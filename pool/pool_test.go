@@ -0,0 +1,66 @@
+package pool
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWindowContains(t *testing.T) {
+	night, err := ParseWindow("22:00-07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, _ := ParseWindow("09:00-18:00")
+
+	tests := []struct {
+		window Window
+		clock  string
+		want   bool
+	}{
+		{night, "23:30", true},
+		{night, "03:00", true},
+		{night, "07:00", false},
+		{night, "12:00", false},
+		{day, "09:00", true},
+		{day, "17:59", true},
+		{day, "18:00", false},
+	}
+	for _, tt := range tests {
+		clock, _ := parseClock(tt.clock)
+		if got := tt.window.Contains(clock); got != tt.want {
+			t.Errorf("%+v.Contains(%s) = %v, want %v", tt.window, tt.clock, got, tt.want)
+		}
+	}
+}
+
+func TestPickRespectsWindows(t *testing.T) {
+	night, _ := ParseWindow("22:00-07:00")
+	p := &Pool{accounts: []*Account{
+		{Name: "personal", Token: "a", Windows: []Window{night}, Location: time.UTC},
+		{Name: "shared", Token: "b", Location: time.UTC},
+	}}
+
+	noon := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		account, err := p.Pick(noon)
+		if err != nil || account.Name != "shared" {
+			t.Fatalf("Pick at noon = %v, %v; want shared", account, err)
+		}
+	}
+
+	midnight := time.Date(2025, 1, 1, 0, 30, 0, 0, time.UTC)
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		account, _ := p.Pick(midnight)
+		seen[account.Name] = true
+	}
+	if !seen["personal"] || !seen["shared"] {
+		t.Errorf("Pick at night should rotate through both accounts, got %v", seen)
+	}
+
+	p.accounts = p.accounts[:1]
+	if _, err := p.Pick(noon); !errors.Is(err, ErrNoAvailableAccount) {
+		t.Errorf("Pick outside all windows error = %v, want ErrNoAvailableAccount", err)
+	}
+}
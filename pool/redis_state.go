@@ -0,0 +1,121 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	redis "you2api/internal/redis"
+)
+
+// inflightTTL 是并发计数的过期时间，每次占用时刷新。实例在释放名额前崩溃时，计数最多在这段时间后恢复。
+const inflightTTL = 10 * time.Minute
+
+// failuresTTL 是连续失败计数的过期时间，长时间没有新的失败时计数自动清零。
+const failuresTTL = time.Hour
+
+// acquireScript 在并发数未达到上限时加一，原子地完成检查与占用。
+const acquireScript = `local n = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if tonumber(ARGV[1]) > 0 and n > tonumber(ARGV[1]) then
+  redis.call('DECR', KEYS[1])
+  return 0
+end
+return 1`
+
+// releaseScript 释放一个名额，计数归零时删除键。
+const releaseScript = `local n = redis.call('DECR', KEYS[1])
+if n <= 0 then redis.call('DEL', KEYS[1]) end
+return n`
+
+// RedisState 在 Redis 中保存账号状态，键为 <prefix>:token:<account>:{cooldown,inflight,failures}。
+type RedisState struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisState 使用 redis://... 地址创建状态，不会立即连接。
+func NewRedisState(rawURL, prefix string) (*RedisState, error) {
+	client, err := redis.New(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisState{client: client, prefix: prefix}, nil
+}
+
+func (s *RedisState) key(account, field string) string {
+	return s.prefix + ":token:" + account + ":" + field
+}
+
+func (s *RedisState) Status(ctx context.Context, account string) (AccountState, error) {
+	reply, err := s.client.Do(ctx, "MGET", s.key(account, "cooldown"), s.key(account, "inflight"), s.key(account, "failures"))
+	if err != nil {
+		return AccountState{}, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 3 {
+		return AccountState{}, errors.New("redis: unexpected MGET reply")
+	}
+	var st AccountState
+	if ms := replyInt(values[0]); ms > 0 {
+		st.CooldownUntil = time.UnixMilli(int64(ms))
+	}
+	st.Inflight = replyInt(values[1])
+	st.Failures = replyInt(values[2])
+	return st, nil
+}
+
+func (s *RedisState) SetCooldown(ctx context.Context, account string, until time.Time) error {
+	ttl := time.Until(until).Milliseconds()
+	if ttl <= 0 {
+		return nil
+	}
+	// GT 只会延长冷却，但需要 Redis 7；这里直接覆盖，多个实例同时设置时冷却时间相近
+	_, err := s.client.Do(ctx, "SET", s.key(account, "cooldown"), until.UnixMilli(), "PX", ttl)
+	return err
+}
+
+func (s *RedisState) Acquire(ctx context.Context, account string, limit int) (func(), bool, error) {
+	key := s.key(account, "inflight")
+	reply, err := s.client.Do(ctx, "EVAL", acquireScript, 1, key, limit, inflightTTL.Milliseconds())
+	if err != nil {
+		return nil, false, err
+	}
+	if n, _ := reply.(int64); n != 1 {
+		return nil, false, nil
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// 请求的上下文可能已取消，释放使用独立的上下文
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			s.client.Do(ctx, "EVAL", releaseScript, 1, key)
+		})
+	}, true, nil
+}
+
+func (s *RedisState) RecordFailure(ctx context.Context, account string) (int, error) {
+	key := s.key(account, "failures")
+	reply, err := s.client.Do(ctx, "INCR", key)
+	if err != nil {
+		return 0, err
+	}
+	s.client.Do(ctx, "PEXPIRE", key, failuresTTL.Milliseconds())
+	n, _ := reply.(int64)
+	return int(n), nil
+}
+
+func (s *RedisState) RecordSuccess(ctx context.Context, account string) error {
+	_, err := s.client.Do(ctx, "DEL", s.key(account, "failures"))
+	return err
+}
+
+// replyInt 把 GET 的应答（字符串或不存在）转换为整数。
+func replyInt(v interface{}) int {
+	s, _ := v.(string)
+	n, _ := strconv.Atoi(s)
+	return n
+}
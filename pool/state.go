@@ -0,0 +1,161 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AccountState 是账号的运行状态。
+type AccountState struct {
+	CooldownUntil time.Time // 在此之前不使用该账号（被限流或连续失败）
+	Inflight      int       // 正在进行的补全数量
+	Failures      int       // 连续失败次数，成功后清零
+}
+
+// State 保存账号的运行状态，account 是账号的标识（不应是 token 本身）。
+// 单实例部署使用 LocalState；多实例部署通过 RedisState 共享，使一个实例发现的限流对其他实例立即生效。
+type State interface {
+	// Status 返回账号当前的状态。
+	Status(ctx context.Context, account string) (AccountState, error)
+	// SetCooldown 让账号在 until 之前不被选择。
+	SetCooldown(ctx context.Context, account string, until time.Time) error
+	// Acquire 在账号的并发数低于 limit（<= 0 表示不限制）时占用一个名额，ok 为 true 时必须调用 release。
+	Acquire(ctx context.Context, account string, limit int) (release func(), ok bool, err error)
+	// RecordFailure 记录一次失败，返回连续失败次数。
+	RecordFailure(ctx context.Context, account string) (int, error)
+	// RecordSuccess 清零连续失败次数。
+	RecordSuccess(ctx context.Context, account string) error
+}
+
+// LocalState 在实例内存中保存账号状态。
+type LocalState struct {
+	mu       sync.Mutex
+	accounts map[string]*AccountState
+}
+
+// NewLocalState 创建空的内存状态。
+func NewLocalState() *LocalState {
+	return &LocalState{accounts: make(map[string]*AccountState)}
+}
+
+func (s *LocalState) account(account string) *AccountState {
+	st, ok := s.accounts[account]
+	if !ok {
+		st = &AccountState{}
+		s.accounts[account] = st
+	}
+	return st
+}
+
+func (s *LocalState) Status(_ context.Context, account string) (AccountState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.account(account), nil
+}
+
+func (s *LocalState) SetCooldown(_ context.Context, account string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.account(account)
+	if until.After(st.CooldownUntil) {
+		st.CooldownUntil = until
+	}
+	return nil
+}
+
+func (s *LocalState) Acquire(_ context.Context, account string, limit int) (func(), bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.account(account)
+	if limit > 0 && st.Inflight >= limit {
+		return nil, false, nil
+	}
+	st.Inflight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			st.Inflight--
+			s.mu.Unlock()
+		})
+	}, true, nil
+}
+
+func (s *LocalState) RecordFailure(_ context.Context, account string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.account(account)
+	st.Failures++
+	return st.Failures, nil
+}
+
+func (s *LocalState) RecordSuccess(_ context.Context, account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account(account).Failures = 0
+	return nil
+}
+
+// FallbackState 优先使用 primary（Redis），出错时调用 onError 并改用 local，使 Redis 故障不影响请求。
+// 冷却同时写入两者，Redis 恢复前本实例仍能记住已发现的限流；并发名额由实际占用它的一方释放。
+type FallbackState struct {
+	primary State
+	local   State
+	onError func(error)
+}
+
+// NewFallbackState 创建带本地回退的状态，onError 可以为 nil。
+func NewFallbackState(primary, local State, onError func(error)) *FallbackState {
+	if onError == nil {
+		onError = func(error) {}
+	}
+	return &FallbackState{primary: primary, local: local, onError: onError}
+}
+
+func (s *FallbackState) Status(ctx context.Context, account string) (AccountState, error) {
+	st, err := s.primary.Status(ctx, account)
+	if err != nil {
+		s.onError(err)
+		return s.local.Status(ctx, account)
+	}
+	// 本地记录的冷却可能是 Redis 故障期间发现的
+	if local, _ := s.local.Status(ctx, account); local.CooldownUntil.After(st.CooldownUntil) {
+		st.CooldownUntil = local.CooldownUntil
+	}
+	return st, nil
+}
+
+func (s *FallbackState) SetCooldown(ctx context.Context, account string, until time.Time) error {
+	s.local.SetCooldown(ctx, account, until)
+	if err := s.primary.SetCooldown(ctx, account, until); err != nil {
+		s.onError(err)
+	}
+	return nil
+}
+
+func (s *FallbackState) Acquire(ctx context.Context, account string, limit int) (func(), bool, error) {
+	release, ok, err := s.primary.Acquire(ctx, account, limit)
+	if err != nil {
+		s.onError(err)
+		return s.local.Acquire(ctx, account, limit)
+	}
+	return release, ok, nil
+}
+
+func (s *FallbackState) RecordFailure(ctx context.Context, account string) (int, error) {
+	n, err := s.primary.RecordFailure(ctx, account)
+	if err != nil {
+		s.onError(err)
+		return s.local.RecordFailure(ctx, account)
+	}
+	return n, nil
+}
+
+func (s *FallbackState) RecordSuccess(ctx context.Context, account string) error {
+	s.local.RecordSuccess(ctx, account)
+	if err := s.primary.RecordSuccess(ctx, account); err != nil {
+		s.onError(err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocalStateAcquireLimit(t *testing.T) {
+	s := NewLocalState()
+	ctx := context.Background()
+	release, ok, _ := s.Acquire(ctx, "a", 1)
+	if !ok {
+		t.Fatal("first acquire should succeed")
+	}
+	if _, ok, _ := s.Acquire(ctx, "a", 1); ok {
+		t.Error("second acquire should hit the limit")
+	}
+	release()
+	release() // 重复释放不影响计数
+	if st, _ := s.Status(ctx, "a"); st.Inflight != 0 {
+		t.Errorf("inflight = %d after release", st.Inflight)
+	}
+}
+
+// TestFallbackStateWithoutRedis 检查 Redis 不可用时退回实例内存中的状态。
+func TestFallbackStateWithoutRedis(t *testing.T) {
+	redisState, err := NewRedisState("redis://127.0.0.1:1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := 0
+	s := NewFallbackState(redisState, NewLocalState(), func(error) { errs++ })
+	ctx := context.Background()
+
+	until := time.Now().Add(time.Minute)
+	s.SetCooldown(ctx, "a", until)
+	st, err := s.Status(ctx, "a")
+	if err != nil || !st.CooldownUntil.Equal(until) {
+		t.Errorf("status = %+v, %v; want cooldown until %v", st, err, until)
+	}
+	if n, _ := s.RecordFailure(ctx, "a"); n != 1 {
+		t.Errorf("failures = %d, want 1", n)
+	}
+	if errs == 0 {
+		t.Error("onError was not called")
+	}
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyForwardsToTarget(t *testing.T) {
+	var gotPath, gotHost string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotHost = r.URL.Path, r.Host
+		w.Header().Set("X-Target", "yes")
+		io.WriteString(w, "from target")
+	}))
+	defer target.Close()
+
+	p, err := NewProxy(target.URL, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/proxy/", http.StripPrefix("/proxy", p))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://client.example/proxy/v1/models", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "from target" || rec.Header().Get("X-Target") != "yes" {
+		t.Fatalf("response = %d %q, want the target's response", rec.Code, rec.Body)
+	}
+	if gotPath != "/v1/models" {
+		t.Errorf("target path = %q, want the prefix stripped", gotPath)
+	}
+	if want := target.Listener.Addr().String(); gotHost != want {
+		t.Errorf("target Host = %q, want %q", gotHost, want)
+	}
+}
+
+func TestProxyTargetUnavailable(t *testing.T) {
+	target := httptest.NewServer(http.NotFoundHandler())
+	target.Close()
+
+	p, err := NewProxy(target.URL, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", rec.Code)
+	}
+}
+
+func TestNewProxyInvalidURL(t *testing.T) {
+	if _, err := NewProxy("http://bad host\x7f", 1000); err == nil {
+		t.Error("NewProxy accepted an invalid URL")
+	}
+}
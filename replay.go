@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	api "you2api/api"
+	audit "you2api/audit"
+	corpus "you2api/corpus"
+)
+
+// runReplay 实现 `replay` 子命令：从审计日志文件中读取指定请求并重新执行，输出与原始回复的差异。
+// 指定 -corpus 时改为重放目录中的全部回归测试语料（不连接上游），有差异时返回错误。
+//
+//	you2api replay -file audit.jsonl -token <DS token> <request-id>
+//	you2api replay -corpus ./corpus
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	file := fs.String("file", os.Getenv("AUDIT_FILE"), "审计日志文件路径（默认读取 AUDIT_FILE）")
+	token := fs.String("token", os.Getenv("DS_TOKEN"), "用于重放的 DS token（默认读取 DS_TOKEN）")
+	corpusDir := fs.String("corpus", "", "回归测试语料目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *corpusDir != "" {
+		return replayCorpus(*corpusDir)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("用法: replay [-file 审计文件] [-token DS token] <request-id>")
+	}
+	if *file == "" || *token == "" {
+		return errors.New("必须提供审计日志文件和 DS token")
+	}
+
+	entry, err := audit.FindInFile(*file, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("读取审计记录失败: %w", err)
+	}
+
+	replayed, err := api.Replay(context.Background(), entry, *token)
+	if err != nil {
+		return fmt.Errorf("重放请求失败: %w", err)
+	}
+
+	fmt.Print(audit.Diff(entry.Response, replayed))
+	return nil
+}
+
+// replayCorpus 重放目录中的全部语料，输出有差异的语料及其差异。
+func replayCorpus(dir string) error {
+	fixtures, err := corpus.Load(dir)
+	if err != nil {
+		return fmt.Errorf("读取语料失败: %w", err)
+	}
+	failed := 0
+	for _, f := range fixtures {
+		replayed, recorded := api.ReplayFixture(f)
+		if replayed == recorded {
+			continue
+		}
+		failed++
+		fmt.Printf("=== %s\n%s\n", f.Name, audit.Diff(recorded, replayed))
+	}
+	fmt.Printf("重放 %d 条语料，%d 条有差异\n", len(fixtures), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d 条语料的响应与记录不一致", failed)
+	}
+	return nil
+}
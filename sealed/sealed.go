@@ -0,0 +1,71 @@
+package sealed
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// magic 标识加密文件的格式与版本。
+var magic = []byte("U2SEALED1")
+
+// KeySize 是 AES-256-GCM 密钥长度。
+const KeySize = 32
+
+// ErrInvalid 表示数据不是本格式、已被篡改或密钥错误。
+var ErrInvalid = errors.New("sealed: invalid data or wrong key")
+
+// ParseKey 解析 base64 编码的 32 字节密钥。
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("sealed: decode key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("sealed: key must be %d bytes", KeySize)
+	}
+	return key, nil
+}
+
+// Seal 使用 AES-256-GCM 加密数据，输出格式为 magic + nonce + 密文（含认证标签）。
+func Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte(nil), magic...), nonce...)
+	return aead.Seal(out, nonce, plaintext, magic), nil
+}
+
+// Open 解密 Seal 生成的数据。
+func Open(key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, magic) || len(data) < len(magic)+aead.NonceSize() {
+		return nil, ErrInvalid
+	}
+	data = data[len(magic):]
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], magic)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("sealed: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,28 @@
+package sealed
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	data, err := Seal(key, []byte("state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := Open(key, data)
+	if err != nil || string(plaintext) != "state" {
+		t.Fatalf("Open = %q, %v", plaintext, err)
+	}
+
+	wrongKey := bytes.Repeat([]byte{2}, KeySize)
+	if _, err := Open(wrongKey, data); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Open with wrong key: err = %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if _, err := Open(key, data); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Open tampered data: err = %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Header 是携带响应签名的 HTTP 头（流式响应中作为 trailer 发送）。
+const Header = "X-U2API-Signature"
+
+const (
+	AlgHMACSHA256 = "hmac-sha256"
+	AlgEd25519    = "ed25519"
+)
+
+// ErrInvalidSignature 表示签名与响应体不匹配。
+var ErrInvalidSignature = errors.New("signing: invalid signature")
+
+// Signer 对响应体签名。签名内容为 "<unix 时间戳>.<响应体 SHA-256 十六进制摘要>"，
+// 使用摘要而不是原文，便于在流式响应中边写边计算。
+type Signer struct {
+	alg     string
+	hmacKey []byte
+	edKey   ed25519.PrivateKey
+}
+
+// NewSigner 创建签名器。hmac-sha256 的 key 为共享密钥原文；
+// ed25519 的 key 为 base64 编码的 32 字节种子。
+func NewSigner(alg, key string) (*Signer, error) {
+	if key == "" {
+		return nil, errors.New("signing: empty key")
+	}
+	switch alg {
+	case AlgHMACSHA256:
+		return &Signer{alg: alg, hmacKey: []byte(key)}, nil
+	case AlgEd25519:
+		seed, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("signing: decode ed25519 seed: %w", err)
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("signing: ed25519 seed must be %d bytes", ed25519.SeedSize)
+		}
+		return &Signer{alg: alg, edKey: ed25519.NewKeyFromSeed(seed)}, nil
+	default:
+		return nil, fmt.Errorf("signing: unsupported algorithm %q", alg)
+	}
+}
+
+// PublicKey 返回 base64 编码的 Ed25519 公钥，HMAC 签名器返回空字符串。
+func (s *Signer) PublicKey() string {
+	if s.edKey == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(s.edKey.Public().(ed25519.PublicKey))
+}
+
+// Sign 根据时间戳与响应体摘要生成签名头的值：t=<ts>,alg=<alg>,sig=<base64>。
+func (s *Signer) Sign(timestamp int64, digest []byte) string {
+	payload := signedPayload(timestamp, digest)
+	var sig []byte
+	if s.alg == AlgEd25519 {
+		sig = ed25519.Sign(s.edKey, payload)
+	} else {
+		mac := hmac.New(sha256.New, s.hmacKey)
+		mac.Write(payload)
+		sig = mac.Sum(nil)
+	}
+	return fmt.Sprintf("t=%d,alg=%s,sig=%s", timestamp, s.alg, base64.StdEncoding.EncodeToString(sig))
+}
+
+func signedPayload(timestamp int64, digest []byte) []byte {
+	return []byte(strconv.FormatInt(timestamp, 10) + "." + hex.EncodeToString(digest))
+}
+
+// parseHeader 解析签名头，返回时间戳、算法与签名。
+func parseHeader(header string) (int64, string, []byte, error) {
+	var (
+		timestamp int64
+		alg       string
+		sig       []byte
+		err       error
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp, err = strconv.ParseInt(value, 10, 64)
+		case "alg":
+			alg = value
+		case "sig":
+			sig, err = base64.StdEncoding.DecodeString(value)
+		}
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("signing: malformed header: %w", err)
+		}
+	}
+	if alg == "" || sig == nil {
+		return 0, "", nil, errors.New("signing: malformed header")
+	}
+	return timestamp, alg, sig, nil
+}
+
+// VerifyHMAC 使用共享密钥校验响应体与签名头，返回签名时间戳。
+func VerifyHMAC(header string, body []byte, secret []byte) (int64, error) {
+	timestamp, alg, sig, err := parseHeader(header)
+	if err != nil {
+		return 0, err
+	}
+	if alg != AlgHMACSHA256 {
+		return 0, fmt.Errorf("signing: unexpected algorithm %q", alg)
+	}
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(signedPayload(timestamp, digest[:]))
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return 0, ErrInvalidSignature
+	}
+	return timestamp, nil
+}
+
+// VerifyEd25519 使用 base64 编码的公钥校验响应体与签名头，返回签名时间戳。
+func VerifyEd25519(header string, body []byte, publicKey string) (int64, error) {
+	timestamp, alg, sig, err := parseHeader(header)
+	if err != nil {
+		return 0, err
+	}
+	if alg != AlgEd25519 {
+		return 0, fmt.Errorf("signing: unexpected algorithm %q", alg)
+	}
+	pub, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return 0, errors.New("signing: invalid public key")
+	}
+	digest := sha256.Sum256(body)
+	if !ed25519.Verify(pub, signedPayload(timestamp, digest[:]), sig) {
+		return 0, ErrInvalidSignature
+	}
+	return timestamp, nil
+}
@@ -0,0 +1,42 @@
+package signing
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"chatcmpl-1","object":"chat.completion"}`)
+	digest := sha256.Sum256(body)
+
+	t.Run("hmac", func(t *testing.T) {
+		s, err := NewSigner(AlgHMACSHA256, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := s.Sign(1700000000, digest[:])
+		if ts, err := VerifyHMAC(header, body, []byte("secret")); err != nil || ts != 1700000000 {
+			t.Fatalf("VerifyHMAC() = %d, %v", ts, err)
+		}
+		if _, err := VerifyHMAC(header, append(body, ' '), []byte("secret")); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("tampered body: err = %v, want ErrInvalidSignature", err)
+		}
+	})
+
+	t.Run("ed25519", func(t *testing.T) {
+		seed := base64.StdEncoding.EncodeToString(make([]byte, 32))
+		s, err := NewSigner(AlgEd25519, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := s.Sign(1700000000, digest[:])
+		if _, err := VerifyEd25519(header, body, s.PublicKey()); err != nil {
+			t.Fatalf("VerifyEd25519() error = %v", err)
+		}
+		if _, err := VerifyEd25519(header, body[1:], s.PublicKey()); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("tampered body: err = %v, want ErrInvalidSignature", err)
+		}
+	})
+}
@@ -1,16 +1,44 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	api "you2api/api" // 请替换为您的实际项目名
+	canary "you2api/canary"
 	config "you2api/config"
+	lifecycle "you2api/lifecycle"
+	logger "you2api/logger"
+	metrics "you2api/metrics"
 	proxy "you2api/proxy"
+	tracing "you2api/tracing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	// 命令行子命令
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			log.Fatalf("重放错误: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "state" {
+		if err := runState(os.Args[2:]); err != nil {
+			log.Fatalf("状态迁移错误: %v", err)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatalf("运行错误: %v", err)
 	}
@@ -23,6 +51,39 @@ func run() error {
 		return fmt.Errorf("加载配置失败: %w", err)
 	}
 
+	// 初始化日志
+	if err := logger.Init(config.LogLevel); err != nil {
+		return fmt.Errorf("初始化日志失败: %w", err)
+	}
+
+	// 使用独立的 ServeMux：net/http/pprof 会在 DefaultServeMux 上注册未鉴权的 /debug/pprof/
+	mux := http.NewServeMux()
+
+	// 子系统按注册顺序启动、按相反顺序停止
+	lc := lifecycle.NewManager(time.Duration(config.ShutdownTimeoutMS) * time.Millisecond)
+
+	// 注册 Prometheus 指标
+	lc.Register(lifecycle.Hook{
+		Name:  "metrics",
+		Start: func(context.Context) error { metrics.Init(); return nil },
+	})
+	mux.Handle("/metrics", promhttp.Handler())
+
+	// OpenTelemetry：配置了 OTEL_EXPORTER_OTLP_ENDPOINT 时导出补全各阶段的 span
+	shutdownTracing := func(context.Context) error { return nil }
+	lc.Register(lifecycle.Hook{
+		Name: "tracing",
+		Start: func(ctx context.Context) error {
+			shutdown, err := tracing.Init(ctx, config.Tracing.Endpoint, config.Tracing.ServiceName, config.Tracing.SampleRatio)
+			if err != nil {
+				return fmt.Errorf("初始化 tracing 失败: %w", err)
+			}
+			shutdownTracing = shutdown
+			return nil
+		},
+		Stop: func(ctx context.Context) error { return shutdownTracing(ctx) },
+	})
+
 	// 如果启用代理
 	if config.Proxy.EnableProxy {
 		proxy, err := proxy.NewProxy(config.Proxy.ProxyURL, config.Proxy.ProxyTimeoutMS)
@@ -31,18 +92,72 @@ func run() error {
 		}
 
 		// 注册代理处理器
-		http.Handle("/proxy/", http.StripPrefix("/proxy", proxy))
+		mux.Handle("/proxy/", http.StripPrefix("/proxy", proxy))
 	}
 
 	// 注册API处理器到根路径
-	http.HandleFunc("/", api.Handler)
+	root, err := api.NewHandler()
+	if err != nil {
+		return fmt.Errorf("初始化处理器失败: %w", err)
+	}
+	lc.Register(lifecycle.Hook{
+		Name:  "api",
+		Start: api.Start,
+		Stop:  api.Shutdown,
+	})
+
+	// 如果配置了金丝雀实例，按权重分流
+	if config.Canary.Enabled() {
+		router, err := canary.NewRouter(root, config.Canary.CanaryURL, config.Canary.CanaryWeight,
+			config.Canary.HealthPath, config.Canary.HealthIntervalMS, config.Canary.CanaryTimeoutMS)
+		if err != nil {
+			return fmt.Errorf("初始化金丝雀路由失败: %w", err)
+		}
+		lc.Register(lifecycle.Hook{
+			Name: "canary",
+			// 健康检查循环的生命周期由 Stop 控制，不能跟随启动钩子的超时上下文
+			Start: func(ctx context.Context) error { router.Start(context.WithoutCancel(ctx)); return nil },
+			Stop:  func(context.Context) error { router.Stop(); return nil },
+		})
+		root = router
+	}
+	mux.Handle("/", root)
 
 	port := fmt.Sprintf(":%d", config.Port)
-	fmt.Printf("Server is running on http://0.0.0.0%s\n", port)
+	server := &http.Server{Addr: "0.0.0.0" + port, Handler: mux}
+	serveErr := make(chan error, 1)
+	lc.Register(lifecycle.Hook{
+		Name: "http",
+		Start: func(context.Context) error {
+			ln, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Server is running on http://0.0.0.0%s\n", port)
+			go func() { serveErr <- server.Serve(ln) }()
+			return nil
+		},
+		// 停止接收新连接，并等待进行中的请求结束
+		Stop: server.Shutdown,
+	})
 
 	// 启动服务器
-	if err := http.ListenAndServe("0.0.0.0"+port, nil); err != nil {
+	if err := lc.Start(context.Background()); err != nil {
 		return fmt.Errorf("启动服务器失败: %w", err)
 	}
-	return nil
+
+	// 收到退出信号或服务器异常退出时，按相反顺序停止所有子系统
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	var runErr error
+	select {
+	case sig := <-signals:
+		log.Printf("收到信号 %v，开始关闭", sig)
+	case err := <-serveErr:
+		runErr = fmt.Errorf("服务器异常退出: %w", err)
+	}
+	if err := lc.Stop(context.Background()); err != nil {
+		return errors.Join(runErr, err)
+	}
+	return runErr
 }
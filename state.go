@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// runState 实现 `state` 子命令：通过管理接口导出或导入加密的运行状态归档，用于迁移部署。
+//
+//	you2api state export -out state.bin
+//	you2api state import -in state.bin
+func runState(args []string) error {
+	usage := errors.New("用法: state export|import [-url 服务地址] [-admin-key 管理密钥] [-out 文件 | -in 文件]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("state "+args[0], flag.ContinueOnError)
+	baseURL := fs.String("url", getEnvDefault("U2API_URL", "http://127.0.0.1:8080"), "服务地址（默认读取 U2API_URL）")
+	adminKey := fs.String("admin-key", firstKey(os.Getenv("ADMIN_KEY")), "操作员管理密钥（默认读取 ADMIN_KEY）")
+	out := fs.String("out", "", "export：归档输出文件")
+	in := fs.String("in", "", "import：要导入的归档文件")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *adminKey == "" {
+		return errors.New("必须提供管理密钥")
+	}
+	base := strings.TrimRight(*baseURL, "/")
+	client := &http.Client{Timeout: 5 * time.Minute}
+
+	switch args[0] {
+	case "export":
+		if *out == "" {
+			return usage
+		}
+		data, err := stateRequest(client, http.MethodGet, base+"/admin/state/export", *adminKey, nil)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*out, data, 0o600); err != nil {
+			return err
+		}
+		fmt.Printf("已导出 %d 字节到 %s\n", len(data), *out)
+	case "import":
+		if *in == "" {
+			return usage
+		}
+		archive, err := os.ReadFile(*in)
+		if err != nil {
+			return err
+		}
+		data, err := stateRequest(client, http.MethodPost, base+"/admin/state/import", *adminKey, archive)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("导入完成: %s\n", bytes.TrimSpace(data))
+	default:
+		return usage
+	}
+	return nil
+}
+
+// stateRequest 调用管理接口并返回响应体，非 2xx 响应作为错误返回。
+func stateRequest(client *http.Client, method, url, adminKey string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+adminKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+func getEnvDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// firstKey 返回逗号分隔的密钥列表中的第一个。
+func firstKey(keys string) string {
+	key, _, _ := strings.Cut(keys, ",")
+	return strings.TrimSpace(key)
+}
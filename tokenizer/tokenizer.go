@@ -0,0 +1,125 @@
+package tokenizer
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Tokenizer 计算一段文本的 token 数。
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// Func 让普通函数实现 Tokenizer 接口。
+type Func func(text string) int
+
+func (f Func) Count(text string) int { return f(text) }
+
+// Family 是模型家族的名称，不同家族的分词方式不同。
+type Family string
+
+const (
+	FamilyOpenAI   Family = "openai"
+	FamilyClaude   Family = "claude"
+	FamilyLlama    Family = "llama"
+	FamilyQwen     Family = "qwen"
+	FamilyDeepSeek Family = "deepseek"
+	FamilyGemini   Family = "gemini"
+	FamilyDefault  Family = "default"
+)
+
+// familyPrefixes 按模型名称前缀识别家族，同时适用于 OpenAI 与 You.com 的模型名称。
+var familyPrefixes = []struct {
+	prefix string
+	family Family
+}{
+	{"gpt", FamilyOpenAI},
+	{"o1", FamilyOpenAI},
+	{"o3", FamilyOpenAI},
+	{"openai", FamilyOpenAI},
+	{"claude", FamilyClaude},
+	{"llama", FamilyLlama},
+	{"qwen", FamilyQwen},
+	{"deepseek", FamilyDeepSeek},
+	{"gemini", FamilyGemini},
+}
+
+// FamilyOf 返回模型所属的家族，无法识别时返回 FamilyDefault。
+func FamilyOf(model string) Family {
+	lower := strings.ToLower(model)
+	for _, p := range familyPrefixes {
+		if strings.HasPrefix(lower, p.prefix) {
+			return p.family
+		}
+	}
+	return FamilyDefault
+}
+
+// Registry 保存各模型家族的分词器。
+type Registry struct {
+	mu         sync.RWMutex
+	tokenizers map[Family]Tokenizer
+}
+
+// NewRegistry 创建一个预置了各家族启发式分词器的注册表。
+func NewRegistry() *Registry {
+	return &Registry{tokenizers: map[Family]Tokenizer{
+		FamilyOpenAI:   Heuristic(4.0, 1.0),
+		FamilyClaude:   Heuristic(3.5, 1.2),
+		FamilyLlama:    Heuristic(3.8, 1.5),
+		FamilyQwen:     Heuristic(3.7, 0.7),
+		FamilyDeepSeek: Heuristic(3.8, 0.7),
+		FamilyGemini:   Heuristic(4.0, 1.0),
+		FamilyDefault:  Heuristic(4.0, 1.0),
+	}}
+}
+
+// Register 注册或替换某个家族的分词器，可用于接入精确的 BPE 实现。
+func (r *Registry) Register(family Family, t Tokenizer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokenizers[family] = t
+}
+
+// ForModel 返回模型对应的分词器，家族未注册时回退到默认分词器。
+func (r *Registry) ForModel(model string) Tokenizer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if t, ok := r.tokenizers[FamilyOf(model)]; ok {
+		return t
+	}
+	return r.tokenizers[FamilyDefault]
+}
+
+// Count 使用模型对应的分词器计算 token 数。
+func (r *Registry) Count(model, text string) int {
+	return r.ForModel(model).Count(text)
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default 返回全局分词器注册表。
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Heuristic 返回基于字符统计的估算分词器：非 CJK 字符按 charsPerToken 个字符一个 token，
+// CJK 字符按每字 tokensPerCJK 个 token 计算。
+func Heuristic(charsPerToken, tokensPerCJK float64) Tokenizer {
+	return Func(func(text string) int {
+		cjk, other := 0, 0
+		for _, r := range text {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+				cjk++
+			} else {
+				other++
+			}
+		}
+		if cjk == 0 && other == 0 {
+			return 0
+		}
+		count := int(float64(cjk)*tokensPerCJK + float64(other)/charsPerToken + 0.999)
+		return max(count, 1)
+	})
+}
@@ -0,0 +1,66 @@
+package tokenizer
+
+import "testing"
+
+func TestFamilyOf(t *testing.T) {
+	tests := []struct {
+		model string
+		want  Family
+	}{
+		{"gpt-4o", FamilyOpenAI},
+		{"openai_o3_mini_high", FamilyOpenAI},
+		{"o1-preview", FamilyOpenAI},
+		{"claude_3_5_sonnet", FamilyClaude},
+		{"Claude-3-Opus", FamilyClaude},
+		{"llama3", FamilyLlama},
+		{"qwen2p5_72b", FamilyQwen},
+		{"deepseek_r1", FamilyDeepSeek},
+		{"gemini_1_5_pro", FamilyGemini},
+		{"mistral_large_2", FamilyDefault},
+		{"", FamilyDefault},
+	}
+	for _, tt := range tests {
+		if got := FamilyOf(tt.model); got != tt.want {
+			t.Errorf("FamilyOf(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestHeuristic(t *testing.T) {
+	tok := Heuristic(4, 1)
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"你好", 2},
+		{"你好abcd", 3},
+	}
+	for _, tt := range tests {
+		if got := tok.Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	text := "你好世界"
+	if claude, qwen := r.Count("claude_3_opus", text), r.Count("qwen2p5_72b", text); claude <= qwen {
+		t.Errorf("Claude should count more CJK tokens than Qwen: %d <= %d", claude, qwen)
+	}
+
+	r.Register(FamilyClaude, Func(func(string) int { return 42 }))
+	if got := r.Count("claude_3_opus", "x"); got != 42 {
+		t.Errorf("registered tokenizer not used: %d", got)
+	}
+	if got := r.Count("unknown_model", "abcd"); got != 1 {
+		t.Errorf("default tokenizer count = %d, want 1", got)
+	}
+	if Default() == r {
+		t.Error("NewRegistry should not return the global registry")
+	}
+}
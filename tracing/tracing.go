@@ -0,0 +1,58 @@
+// Package tracing 以 OpenTelemetry 导出请求的 span。
+//
+// 未调用 Init（或未配置导出地址）时使用 otel 默认的空实现，创建 span 几乎没有开销。
+// 传入请求的 W3C traceparent 通过 Extract 取出，补全的 span 挂在调用方的 trace 下。
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation 是本服务创建的 span 的 instrumentation scope。
+const instrumentation = "you2api"
+
+// propagator 解析 W3C traceparent 与 baggage。即使没有调用 Init 也会解析，便于测试与只在本地记录 span 的部署。
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Init 按 endpoint 创建 OTLP/HTTP 导出器并设置为全局的 TracerProvider，返回的函数在退出时导出剩余的 span。
+// endpoint 为空时不导出 span。
+func Init(ctx context.Context, endpoint, serviceName string, sampleRatio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer 返回本服务的 tracer。
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Extract 从请求头（traceparent、tracestate）中取出调用方的 trace 上下文。
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExtract(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	sc := trace.SpanContextFromContext(Extract(context.Background(), header))
+	if !sc.IsValid() || !sc.IsRemote() || !sc.IsSampled() {
+		t.Fatalf("span context = %+v, want a sampled remote parent", sc)
+	}
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("span context = %s/%s", sc.TraceID(), sc.SpanID())
+	}
+
+	if sc := trace.SpanContextFromContext(Extract(context.Background(), http.Header{})); sc.IsValid() {
+		t.Error("span context extracted from a request without traceparent")
+	}
+}
+
+func TestInitWithoutEndpoint(t *testing.T) {
+	shutdown, err := Init(context.Background(), "", "you2api", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}